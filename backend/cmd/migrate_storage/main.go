@@ -0,0 +1,140 @@
+// Commande de migration du stockage local vers S3/MinIO.
+//
+// Copie tous les fichiers de UPLOAD_DIR dans le bucket (même arborescence,
+// donc les URLs /uploads/... déjà présentes dans les contenus restent valides)
+// puis met à jour StoragePath, URL et StorageType des médias.
+//
+// Usage (depuis backend/, avec STORAGE_TYPE=s3 ou minio et les variables S3_*):
+//
+//	go run ./cmd/migrate_storage            # migration
+//	go run ./cmd/migrate_storage -dry-run   # simulation
+//	go run ./cmd/migrate_storage -delete-local
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"airboard/config"
+	"airboard/models"
+	"airboard/services"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Afficher les opérations sans rien copier ni modifier")
+	deleteLocal := flag.Bool("delete-local", false, "Supprimer les fichiers locaux après copie")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	if cfg.Storage.Type != "s3" && cfg.Storage.Type != "minio" {
+		log.Fatalf("STORAGE_TYPE doit valoir s3 ou minio (actuel: %q)", cfg.Storage.Type)
+	}
+
+	db, err := gorm.Open(postgres.Open(cfg.GetDSN()), &gorm.Config{})
+	if err != nil {
+		log.Fatal("Erreur de connexion à la base de données:", err)
+	}
+
+	storage, err := services.NewS3Storage(cfg.Storage)
+	if err != nil {
+		log.Fatal("Erreur d'initialisation du stockage S3:", err)
+	}
+
+	ctx := context.Background()
+	uploadDir := cfg.Storage.UploadDir
+
+	// 1. Copier tous les fichiers (médias, avatars, images hero...)
+	copied, failed := 0, 0
+	var migrated []string
+	err = filepath.Walk(uploadDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(uploadDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		if *dryRun {
+			fmt.Printf("[dry-run] %s -> s3://%s/%s\n", path, cfg.Storage.S3Bucket, key)
+			copied++
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			log.Printf("❌ %s: %v", key, err)
+			failed++
+			return nil
+		}
+		defer f.Close()
+
+		contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+		if err := storage.PutObject(ctx, key, f, info.Size(), contentType); err != nil {
+			log.Printf("❌ %s: %v", key, err)
+			failed++
+			return nil
+		}
+
+		fmt.Printf("✓ %s\n", key)
+		migrated = append(migrated, path)
+		copied++
+		return nil
+	})
+	if err != nil {
+		log.Fatal("Erreur lors du parcours du dossier d'upload:", err)
+	}
+
+	// 2. Réécrire les enregistrements Media
+	var medias []models.Media
+	if err := db.Where("storage_type = ? OR storage_type = '' OR storage_type IS NULL", "local").Find(&medias).Error; err != nil {
+		log.Fatal("Erreur lors de la lecture des médias:", err)
+	}
+
+	updated := 0
+	for _, media := range medias {
+		key := filepath.ToSlash(media.StoragePath)
+		if *dryRun {
+			fmt.Printf("[dry-run] media #%d: %s -> %s\n", media.ID, media.URL, storage.GetURL(key))
+			continue
+		}
+
+		if err := db.Model(&models.Media{}).Where("id = ?", media.ID).Updates(map[string]interface{}{
+			"storage_path": key,
+			"url":          storage.GetURL(key),
+			"storage_type": storage.GetType(),
+		}).Error; err != nil {
+			log.Printf("❌ media #%d: %v", media.ID, err)
+			continue
+		}
+		updated++
+	}
+
+	// 3. Nettoyage optionnel des fichiers locaux
+	if *deleteLocal && !*dryRun && failed == 0 {
+		for _, path := range migrated {
+			if err := os.Remove(path); err != nil {
+				log.Printf("⚠️ Impossible de supprimer %s: %v", path, err)
+			}
+		}
+	}
+
+	fmt.Printf("\n✅ Migration terminée: %d fichier(s) copié(s), %d échec(s), %d média(s) mis à jour\n", copied, failed, updated)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"airboard/utils"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -16,12 +17,27 @@ type Config struct {
 	JWT      JWTConfig
 	Server   ServerConfig
 	SSO      SSOConfig
+	LDAP     LDAPConfig
+	SAML     SAMLConfig
 	Storage  StorageConfig
 	Security SecurityConfig
+	WebAuthn WebAuthnConfig
 }
 
 type SecurityConfig struct {
 	BcryptCost int // Coût de hashage bcrypt (recommandé: 12 ou plus)
+	// Antivirus (clamd)
+	ClamdAddress  string // tcp://host:3310 ou unix:///chemin/clamd.ctl (vide = désactivé)
+	ClamdTimeout  int    // Délai maximal d'analyse (secondes)
+	ClamdFailOpen bool   // Accepter les fichiers si clamd est indisponible
+	// État partagé (verrouillages de connexion, tokens CSRF, états OAuth)
+	StateStore string // postgres (partagé entre instances) ou memory (instance unique)
+}
+
+type WebAuthnConfig struct {
+	RPID          string   // Domaine des passkeys (ex: tools.marocpme.gov.ma), dérivé de PUBLIC_URL par défaut
+	RPDisplayName string   // Nom affiché par le navigateur / l'authentificateur
+	RPOrigins     []string // Origines autorisées (PUBLIC_URL par défaut)
 }
 
 type DatabaseConfig struct {
@@ -54,18 +70,116 @@ type SSOConfig struct {
 	DefaultGroup  string
 	GroupMapping  map[string]string // map[AuthentikGroup]AirboardGroup
 	AdminGroups   []string          // Groupes Authentik qui ont le rôle admin
+	// SSO par headers d'un proxy d'authentification (forward-auth)
+	Headers        SSOHeaderProfile
+	TrustedProxies []string // Adresses ou réseaux CIDR autorisés à transmettre ces headers
+}
+
+// SSOHeaderProfile décrit les headers transmis par un proxy d'authentification. Seul l'email est
+// obligatoire : l'email sert d'identifiant à défaut de username.
+type SSOHeaderProfile struct {
+	Provider       string // Valeur de User.SSOProvider (nom du preset)
+	Label          string // Nom de la source dans les logs et la description des groupes créés
+	Email          string
+	Username       string
+	Name           string // Nom complet, découpé en prénom et nom
+	Groups         string
+	UID            string // Identifiant de l'utilisateur chez le fournisseur
+	GroupSeparator string
+}
+
+// SSOHeaderPresets contient les profils des proxys courants (SSO_HEADER_PRESET). Chaque header
+// peut ensuite être remplacé par SSO_HEADER_* ; le preset "custom" part d'un profil vide.
+var SSOHeaderPresets = map[string]SSOHeaderProfile{
+	"authentik": {
+		Provider: "authentik", Label: "Authentik",
+		Email: "X-authentik-email", Username: "X-authentik-username", Name: "X-authentik-name",
+		Groups: "X-authentik-groups", UID: "X-authentik-uid", GroupSeparator: "|",
+	},
+	// oauth2-proxy avec --set-xauthrequest (nginx auth_request, Traefik forwardAuth)
+	"oauth2-proxy": {
+		Provider: "oauth2-proxy", Label: "oauth2-proxy",
+		Email: "X-Auth-Request-Email", Username: "X-Auth-Request-Preferred-Username",
+		Groups: "X-Auth-Request-Groups", UID: "X-Auth-Request-User", GroupSeparator: ",",
+	},
+	"keycloak-gatekeeper": {
+		Provider: "keycloak-gatekeeper", Label: "Keycloak Gatekeeper",
+		Email: "X-Auth-Email", Username: "X-Auth-Username",
+		Groups: "X-Auth-Groups", UID: "X-Auth-Subject", GroupSeparator: ",",
+	},
+	// Pomerium avec jwt_claims_headers: email, preferred_username, name, groups, sub
+	"pomerium": {
+		Provider: "pomerium", Label: "Pomerium",
+		Email: "X-Pomerium-Claim-Email", Username: "X-Pomerium-Claim-Preferred-Username", Name: "X-Pomerium-Claim-Name",
+		Groups: "X-Pomerium-Claim-Groups", UID: "X-Pomerium-Claim-Sub", GroupSeparator: ",",
+	},
+	"custom": {Provider: "forward-auth", Label: "Proxy SSO", GroupSeparator: ","},
+}
+
+// LDAPConfig configure l'authentification LDAP / Active Directory et la synchronisation des groupes.
+// Dans les filtres, {username} est remplacé par l'identifiant saisi et {dn} par le DN de l'utilisateur.
+type LDAPConfig struct {
+	Enabled            bool
+	URL                string // ldap://host:389 ou ldaps://host:636
+	StartTLS           bool   // STARTTLS sur une connexion ldap://
+	InsecureSkipVerify bool   // Ne pas vérifier le certificat du serveur (tests uniquement)
+	BindDN             string // Compte de service utilisé pour les recherches
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string
+	GroupBaseDN        string // BaseDN par défaut
+	GroupFilter        string
+	GroupNameAttribute string
+	// Attributs LDAP reportés sur les champs de l'utilisateur
+	UsernameAttribute   string
+	EmailAttribute      string
+	FirstNameAttribute  string
+	LastNameAttribute   string
+	DepartmentAttribute string
+	JobTitleAttribute   string
+	PhoneAttribute      string
+	AdminGroups         []string // Groupes LDAP qui ont le rôle admin
+	SyncIntervalMinutes int      // Synchronisation planifiée des utilisateurs et des groupes (0 = désactivée)
+	Timeout             int      // Délai de connexion et de requête (secondes)
+}
+
+// SAMLConfig configure Airboard comme fournisseur de service (SP) SAML 2.0. Les attributs
+// acceptent plusieurs noms séparés par des virgules (le premier présent dans l'assertion est utilisé).
+type SAMLConfig struct {
+	Enabled           bool
+	EntityID          string // Identifiant du SP (URL des métadonnées par défaut)
+	RootURL           string // URL publique de l'API (PUBLIC_URL/api/v1 par défaut)
+	IdPMetadataURL    string // Métadonnées de l'IdP téléchargées et rafraîchies toutes les 24 h
+	IdPMetadataFile   string // ... ou lues depuis un fichier
+	CertFile          string // Certificat et clé du SP (signature des requêtes, assertions chiffrées)
+	KeyFile           string
+	SignRequests      bool
+	AllowIdPInitiated bool // Accepter les réponses non sollicitées (connexion depuis le portail de l'IdP)
+	// Attributs de l'assertion reportés sur l'utilisateur (NameID en dernier recours pour l'email)
+	EmailAttributes     []string
+	UsernameAttributes  []string
+	FirstNameAttributes []string
+	LastNameAttributes  []string
+	GroupsAttributes    []string
+	AdminGroups         []string // Groupes SAML qui ont le rôle admin
 }
 
 type StorageConfig struct {
 	Type      string // local, s3, minio
 	UploadDir string // For local storage
 	BaseURL   string // Base URL for serving files
-	// S3/MinIO config (for future use)
-	S3Bucket    string
-	S3Region    string
-	S3Endpoint  string
-	S3AccessKey string
-	S3SecretKey string
+	// S3/MinIO config (STORAGE_TYPE=s3 ou minio)
+	S3Bucket         string
+	S3Region         string
+	S3Endpoint       string // host[:port] ou URL complète (https://...)
+	S3AccessKey      string
+	S3SecretKey      string
+	S3UseSSL         bool
+	S3ForcePathStyle bool // Adressage path-style (requis pour MinIO)
+	S3URLExpiry      int  // Durée de validité des URLs présignées (minutes)
+	// URLs signées /uploads/... pour les médias non publics
+	SigningSecret   string // Clé HMAC (JWT_SECRET par défaut)
+	SignedURLExpiry int    // Durée de validité des URLs signées (minutes)
 }
 
 func LoadConfig() *Config {
@@ -123,6 +237,20 @@ func LoadConfig() *Config {
 	ssoEnabled := getEnv("SSO_ENABLED", "false") == "true"
 	ssoAutoProvision := getEnv("SSO_AUTO_PROVISION", "true") == "true"
 
+	// Headers du proxy d'authentification : preset puis remplacements header par header
+	ssoHeaderPreset := strings.ToLower(getEnv("SSO_HEADER_PRESET", "authentik"))
+	ssoHeaders, found := SSOHeaderPresets[ssoHeaderPreset]
+	if !found {
+		log.Printf("⚠️ SSO_HEADER_PRESET=%s inconnu, utilisation du preset custom", ssoHeaderPreset)
+		ssoHeaders = SSOHeaderPresets["custom"]
+	}
+	ssoHeaders.Email = getEnv("SSO_HEADER_EMAIL", ssoHeaders.Email)
+	ssoHeaders.Username = getEnv("SSO_HEADER_USERNAME", ssoHeaders.Username)
+	ssoHeaders.Name = getEnv("SSO_HEADER_NAME", ssoHeaders.Name)
+	ssoHeaders.Groups = getEnv("SSO_HEADER_GROUPS", ssoHeaders.Groups)
+	ssoHeaders.UID = getEnv("SSO_HEADER_UID", ssoHeaders.UID)
+	ssoHeaders.GroupSeparator = getEnv("SSO_GROUP_SEPARATOR", ssoHeaders.GroupSeparator)
+
 	// Configuration Signup
 	signupEnabled := getEnv("SIGNUP_ENABLED", "true") == "true"
 
@@ -134,6 +262,20 @@ func LoadConfig() *Config {
 		}
 	}
 
+	// Configuration LDAP / Active Directory
+	ldapSyncInterval, err := strconv.Atoi(getEnv("LDAP_SYNC_INTERVAL_MINUTES", "60"))
+	if err != nil || ldapSyncInterval < 0 {
+		ldapSyncInterval = 60
+	}
+	ldapTimeout, err := strconv.Atoi(getEnv("LDAP_TIMEOUT_SECONDS", "10"))
+	if err != nil || ldapTimeout <= 0 {
+		ldapTimeout = 10
+	}
+	ldapBaseDN := getEnv("LDAP_BASE_DN", "")
+
+	// Configuration SAML 2.0
+	samlRootURL := strings.TrimSuffix(getEnv("SAML_ROOT_URL", strings.TrimSuffix(getEnv("PUBLIC_URL", "http://localhost:80"), "/")+"/api/v1"), "/")
+
 	// Configuration Security - Bcrypt cost
 	bcryptCost, err := strconv.Atoi(getEnv("BCRYPT_COST", "12"))
 	if err != nil || bcryptCost < 10 || bcryptCost > 31 {
@@ -144,6 +286,41 @@ func LoadConfig() *Config {
 		log.Printf("⚠️ BCRYPT_COST=%d est faible. Recommandation OWASP 2025: minimum 12", bcryptCost)
 	}
 
+	// Configuration antivirus
+	clamdTimeout, err := strconv.Atoi(getEnv("CLAMD_TIMEOUT_SECONDS", "30"))
+	if err != nil || clamdTimeout <= 0 {
+		clamdTimeout = 30
+	}
+
+	// Configuration WebAuthn (passkeys) : RP ID et origine dérivés de l'URL publique
+	publicURL := getEnv("PUBLIC_URL", "http://localhost:80")
+	webauthnRPID := getEnv("WEBAUTHN_RP_ID", "")
+	webauthnOrigin := strings.TrimSuffix(publicURL, "/")
+	if u, err := url.Parse(publicURL); err == nil && u.Host != "" {
+		if webauthnRPID == "" {
+			webauthnRPID = u.Hostname()
+		}
+		// L'origine envoyée par le navigateur omet le port par défaut et le chemin
+		webauthnOrigin = u.Scheme + "://" + u.Host
+		if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+			webauthnOrigin = u.Scheme + "://" + u.Hostname()
+		}
+	}
+	webauthnOrigins := splitAndTrim(getEnv("WEBAUTHN_RP_ORIGINS", webauthnOrigin), ",")
+
+	// Configuration stockage S3/MinIO
+	storageType := getEnv("STORAGE_TYPE", "local")
+	s3URLExpiry, err := strconv.Atoi(getEnv("S3_URL_EXPIRY_MINUTES", "60"))
+	if err != nil || s3URLExpiry <= 0 {
+		s3URLExpiry = 60
+	}
+	mediaURLExpiry, err := strconv.Atoi(getEnv("MEDIA_URL_EXPIRY_MINUTES", "60"))
+	if err != nil || mediaURLExpiry <= 0 {
+		mediaURLExpiry = 60
+	}
+	// MinIO n'accepte que le path-style par défaut
+	s3ForcePathStyle := getEnv("S3_FORCE_PATH_STYLE", strconv.FormatBool(storageType == "minio")) == "true"
+
 	return &Config{
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -161,7 +338,7 @@ func LoadConfig() *Config {
 		Server: ServerConfig{
 			Port:          getEnv("PORT", "8080"),
 			Mode:          getEnv("GIN_MODE", "debug"),
-			PublicURL:     getEnv("PUBLIC_URL", "http://localhost:80"),
+			PublicURL:     publicURL,
 			SignupEnabled: signupEnabled,
 			Origins: []string{
 				getEnv("FRONTEND_URL", "http://localhost:3000"),
@@ -177,19 +354,81 @@ func LoadConfig() *Config {
 			DefaultGroup:  getEnv("SSO_DEFAULT_GROUP", "Common"),
 			GroupMapping:  make(map[string]string), // Sera peuplé par les groupes Authentik
 			AdminGroups:   adminGroups,
+			Headers:       ssoHeaders,
+			TrustedProxies: splitAndTrim(getEnv("SSO_TRUSTED_PROXIES",
+				"127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16"), ","),
+		},
+		LDAP: LDAPConfig{
+			Enabled:             getEnv("LDAP_ENABLED", "false") == "true",
+			URL:                 getEnv("LDAP_URL", "ldap://localhost:389"),
+			StartTLS:            getEnv("LDAP_START_TLS", "false") == "true",
+			InsecureSkipVerify:  getEnv("LDAP_TLS_INSECURE_SKIP_VERIFY", "false") == "true",
+			BindDN:              getEnv("LDAP_BIND_DN", ""),
+			BindPassword:        getEnv("LDAP_BIND_PASSWORD", ""),
+			BaseDN:              ldapBaseDN,
+			UserFilter:          getEnv("LDAP_USER_FILTER", "(&(objectClass=inetOrgPerson)(|(uid={username})(mail={username})))"),
+			GroupBaseDN:         getEnv("LDAP_GROUP_BASE_DN", ldapBaseDN),
+			GroupFilter:         getEnv("LDAP_GROUP_FILTER", "(&(objectClass=groupOfNames)(member={dn}))"),
+			GroupNameAttribute:  getEnv("LDAP_GROUP_NAME_ATTRIBUTE", "cn"),
+			UsernameAttribute:   getEnv("LDAP_ATTR_USERNAME", "uid"),
+			EmailAttribute:      getEnv("LDAP_ATTR_EMAIL", "mail"),
+			FirstNameAttribute:  getEnv("LDAP_ATTR_FIRST_NAME", "givenName"),
+			LastNameAttribute:   getEnv("LDAP_ATTR_LAST_NAME", "sn"),
+			DepartmentAttribute: getEnv("LDAP_ATTR_DEPARTMENT", "departmentNumber"),
+			JobTitleAttribute:   getEnv("LDAP_ATTR_JOB_TITLE", "title"),
+			PhoneAttribute:      getEnv("LDAP_ATTR_PHONE", "telephoneNumber"),
+			AdminGroups:         splitAndTrim(getEnv("LDAP_ADMIN_GROUPS", ""), ","),
+			SyncIntervalMinutes: ldapSyncInterval,
+			Timeout:             ldapTimeout,
+		},
+		SAML: SAMLConfig{
+			Enabled:           getEnv("SAML_ENABLED", "false") == "true",
+			EntityID:          getEnv("SAML_ENTITY_ID", samlRootURL+"/auth/saml/metadata"),
+			RootURL:           samlRootURL,
+			IdPMetadataURL:    getEnv("SAML_IDP_METADATA_URL", ""),
+			IdPMetadataFile:   getEnv("SAML_IDP_METADATA_FILE", ""),
+			CertFile:          getEnv("SAML_SP_CERT_FILE", ""),
+			KeyFile:           getEnv("SAML_SP_KEY_FILE", ""),
+			SignRequests:      getEnv("SAML_SIGN_REQUESTS", "false") == "true",
+			AllowIdPInitiated: getEnv("SAML_ALLOW_IDP_INITIATED", "false") == "true",
+			EmailAttributes: splitAndTrim(getEnv("SAML_ATTR_EMAIL",
+				"email,mail,urn:oid:0.9.2342.19200300.100.1.3,http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"), ","),
+			UsernameAttributes: splitAndTrim(getEnv("SAML_ATTR_USERNAME",
+				"username,uid,urn:oid:0.9.2342.19200300.100.1.1,http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"), ","),
+			FirstNameAttributes: splitAndTrim(getEnv("SAML_ATTR_FIRST_NAME",
+				"firstName,givenName,urn:oid:2.5.4.42,http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname"), ","),
+			LastNameAttributes: splitAndTrim(getEnv("SAML_ATTR_LAST_NAME",
+				"lastName,sn,surname,urn:oid:2.5.4.4,http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname"), ","),
+			GroupsAttributes: splitAndTrim(getEnv("SAML_ATTR_GROUPS",
+				"groups,memberOf,urn:oid:1.3.6.1.4.1.5923.1.5.1.1,http://schemas.microsoft.com/ws/2008/06/identity/claims/groups"), ","),
+			AdminGroups: splitAndTrim(getEnv("SAML_ADMIN_GROUPS", ""), ","),
 		},
 		Storage: StorageConfig{
-			Type:        getEnv("STORAGE_TYPE", "local"),
-			UploadDir:   getEnv("UPLOAD_DIR", "./uploads"),
-			BaseURL:     getEnv("PUBLIC_URL", "http://localhost:80"),
-			S3Bucket:    getEnv("S3_BUCKET", ""),
-			S3Region:    getEnv("S3_REGION", ""),
-			S3Endpoint:  getEnv("S3_ENDPOINT", ""),
-			S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
-			S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+			Type:             storageType,
+			UploadDir:        getEnv("UPLOAD_DIR", "./uploads"),
+			BaseURL:          getEnv("PUBLIC_URL", "http://localhost:80"),
+			S3Bucket:         getEnv("S3_BUCKET", ""),
+			S3Region:         getEnv("S3_REGION", ""),
+			S3Endpoint:       getEnv("S3_ENDPOINT", ""),
+			S3AccessKey:      getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:      getEnv("S3_SECRET_KEY", ""),
+			S3UseSSL:         getEnv("S3_USE_SSL", "true") == "true",
+			S3ForcePathStyle: s3ForcePathStyle,
+			S3URLExpiry:      s3URLExpiry,
+			SigningSecret:    getEnv("MEDIA_SIGNING_SECRET", jwtSecret),
+			SignedURLExpiry:  mediaURLExpiry,
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:          webauthnRPID,
+			RPDisplayName: getEnv("WEBAUTHN_RP_NAME", "Airboard"),
+			RPOrigins:     webauthnOrigins,
 		},
 		Security: SecurityConfig{
-			BcryptCost: bcryptCost,
+			BcryptCost:    bcryptCost,
+			ClamdAddress:  getEnv("CLAMD_ADDRESS", ""),
+			ClamdTimeout:  clamdTimeout,
+			ClamdFailOpen: getEnv("CLAMD_FAIL_OPEN", "false") == "true",
+			StateStore:    getEnv("STATE_STORE", "postgres"),
 		},
 	}
 }
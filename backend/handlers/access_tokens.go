@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Scopes disponibles
+// @Description Liste les scopes attribuables aux tokens d'accès personnels
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} services.AccessTokenScope
+// @Router /auth/tokens/scopes [get]
+func (h *AuthHandler) GetAccessTokenScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, services.AccessTokenScopes)
+}
+
+// @Summary Tokens d'accès personnels
+// @Description Liste les tokens d'accès de l'utilisateur connecté (sans leur valeur)
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.AccessToken
+// @Router /auth/tokens [get]
+func (h *AuthHandler) GetAccessTokens(c *gin.Context) {
+	h.listAccessTokens(c, c.GetUint("user_id"))
+}
+
+// @Summary Créer un token d'accès personnel
+// @Description Crée un token pour l'automatisation de l'API. Sa valeur n'est retournée qu'une seule fois.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.AccessTokenRequest true "Nom, scopes et durée de validité"
+// @Success 201 {object} models.AccessTokenCreatedResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/tokens [post]
+func (h *AuthHandler) CreateAccessToken(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+	h.createAccessToken(c, &user, nil)
+}
+
+// @Summary Révoquer un token d'accès personnel
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du token"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/tokens/{id} [delete]
+func (h *AuthHandler) RevokeAccessToken(c *gin.Context) {
+	h.revokeAccessToken(c, c.GetUint("user_id"), c.Param("id"))
+}
+
+// @Summary Tokens d'un compte de service
+// @Description Liste les tokens d'accès d'un utilisateur (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'utilisateur"
+// @Success 200 {array} models.AccessToken
+// @Router /admin/users/{id}/tokens [get]
+func (h *AuthHandler) GetUserAccessTokens(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "ID invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	h.listAccessTokens(c, uint(userID))
+}
+
+// @Summary Créer un token pour un compte de service
+// @Description Crée un token d'accès pour un compte de service (admin uniquement)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du compte de service"
+// @Param request body models.AccessTokenRequest true "Nom, scopes et durée de validité"
+// @Success 201 {object} models.AccessTokenCreatedResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /admin/users/{id}/tokens [post]
+func (h *AuthHandler) CreateUserAccessToken(c *gin.Context) {
+	var user models.User
+	if err := h.db.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+
+	// Les administrateurs ne créent pas de tokens au nom de personnes réelles
+	if !user.IsServiceAccount {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Seuls les comptes de service peuvent recevoir un token créé par un administrateur",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	h.createAccessToken(c, &user, &adminID)
+}
+
+// @Summary Révoquer un token d'un utilisateur
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'utilisateur"
+// @Param tokenId path int true "ID du token"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /admin/users/{id}/tokens/{tokenId} [delete]
+func (h *AuthHandler) RevokeUserAccessToken(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "ID invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	h.revokeAccessToken(c, uint(userID), c.Param("tokenId"))
+}
+
+func (h *AuthHandler) listAccessTokens(c *gin.Context, userID uint) {
+	tokens, err := h.authMiddleware.AccessTokens().List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la récupération des tokens",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) createAccessToken(c *gin.Context, user *models.User, createdByID *uint) {
+	var req models.AccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Nom, scopes et durée de validité (1 à 365 jours) requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, record, err := h.authMiddleware.AccessTokens().Create(user, req.Name, req.Scopes, ttl, createdByID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidScope):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Scope inconnu ou non autorisé pour ce rôle",
+				Code:    http.StatusBadRequest,
+			})
+		case errors.Is(err, services.ErrAccessTokenLimit):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Nombre maximal de tokens actifs atteint",
+				Code:    http.StatusBadRequest,
+			})
+		default:
+			log.Printf("[Auth] Erreur lors de la création d'un token d'accès: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Erreur lors de la création du token",
+				Code:    http.StatusInternalServerError,
+			})
+		}
+		return
+	}
+
+	log.Printf("[Auth] Access token %d created for user %d by user %d (scopes: %s)", record.ID, user.ID, c.GetUint("user_id"), record.Scopes)
+	c.JSON(http.StatusCreated, models.AccessTokenCreatedResponse{
+		Token:       token,
+		AccessToken: *record,
+	})
+}
+
+func (h *AuthHandler) revokeAccessToken(c *gin.Context, userID uint, tokenParam string) {
+	tokenID, err := strconv.Atoi(tokenParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "ID invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := h.authMiddleware.AccessTokens().Revoke(userID, uint(tokenID)); err != nil {
+		if errors.Is(err, services.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: "Token non trouvé",
+				Code:    http.StatusNotFound,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la révocation du token",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Access token %d of user %d revoked by user %d", tokenID, userID, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Token révoqué",
+	})
+}
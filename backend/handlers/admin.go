@@ -19,13 +19,15 @@ type AdminHandler struct {
 	db                  *gorm.DB
 	bcryptCost          int
 	gamificationService *services.GamificationService
+	storage             services.StorageService
 }
 
-func NewAdminHandler(db *gorm.DB, cfg *config.Config, gs *services.GamificationService) *AdminHandler {
+func NewAdminHandler(db *gorm.DB, cfg *config.Config, gs *services.GamificationService, storage services.StorageService) *AdminHandler {
 	return &AdminHandler{
 		db:                  db,
 		bcryptCost:          cfg.Security.BcryptCost,
 		gamificationService: gs,
+		storage:             storage,
 	}
 }
 
@@ -635,6 +637,10 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		Role      string `json:"role"`
 		IsActive  bool   `json:"is_active"`
 		GroupIDs  []uint `json:"group_ids"`
+		// Compte technique : pas de mot de passe, accès par tokens créés par un administrateur
+		IsServiceAccount bool `json:"is_service_account"`
+		// Changement du mot de passe imposé à la première connexion
+		MustChangePassword bool `json:"must_change_password"`
 	}
 
 	if err := c.ShouldBindJSON(&createData); err != nil {
@@ -657,28 +663,50 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	passwordPolicy := services.NewPasswordPolicyService(h.db)
+
 	// Hasher le mot de passe avec coût sécurisé (12 minimum - OWASP 2025)
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(createData.Password), h.bcryptCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors du hashage du mot de passe",
-			Code:    http.StatusInternalServerError,
-		})
-		return
+	var password string
+	if !createData.IsServiceAccount {
+		if err := passwordPolicy.Validate(nil, createData.Password); err != nil {
+			writePasswordPolicyError(c, err)
+			return
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(createData.Password), h.bcryptCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Erreur lors du hashage du mot de passe",
+				Code:    http.StatusInternalServerError,
+			})
+			return
+		}
+		password = string(hashedPassword)
 	}
 
 	user := models.User{
-		Username:  createData.Username,
-		Email:     createData.Email,
-		Password:  string(hashedPassword),
-		FirstName: createData.FirstName,
-		LastName:  createData.LastName,
-		Role:      createData.Role,
-		IsActive:  createData.IsActive,
+		Username:         createData.Username,
+		Email:            createData.Email,
+		Password:         password,
+		FirstName:        createData.FirstName,
+		LastName:         createData.LastName,
+		Role:             createData.Role,
+		IsActive:         createData.IsActive,
+		IsServiceAccount: createData.IsServiceAccount,
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if password == "" {
+			return nil
+		}
+		if err := passwordPolicy.Record(tx, user.ID, password); err != nil {
+			return err
+		}
+		return tx.Model(&user).Update("must_change_password", createData.MustChangePassword).Error
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
 			Message: "Erreur lors de la création de l'utilisateur",
@@ -741,6 +769,10 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		IsActive  *bool  `json:"is_active"`
 		Password  string `json:"password,omitempty"`
 		GroupIDs  []uint `json:"group_ids"`
+		// Confirmation manuelle d'une inscription (email non reçu, SMTP non configuré...)
+		EmailVerified *bool `json:"email_verified"`
+		// Imposer (ou annuler) le changement du mot de passe à la prochaine connexion
+		MustChangePassword *bool `json:"must_change_password"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -752,6 +784,8 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	previousRole, wasActive := user.Role, user.IsActive
+
 	// Mise à jour des champs
 	if updateData.Username != "" {
 		user.Username = updateData.Username
@@ -771,9 +805,17 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 	if updateData.IsActive != nil {
 		user.IsActive = *updateData.IsActive
 	}
+	if updateData.EmailVerified != nil && *updateData.EmailVerified {
+		user.EmailVerificationPending = false
+	}
 
 	// Hash du nouveau mot de passe si fourni avec coût sécurisé (12 minimum - OWASP 2025)
+	passwordPolicy := services.NewPasswordPolicyService(h.db)
 	if updateData.Password != "" {
+		if err := passwordPolicy.Validate(nil, updateData.Password); err != nil {
+			writePasswordPolicyError(c, err)
+			return
+		}
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updateData.Password), h.bcryptCost)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -786,7 +828,22 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		user.Password = string(hashedPassword)
 	}
 
-	if err := h.db.Save(&user).Error; err != nil {
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if updateData.Password != "" {
+			if err := passwordPolicy.Record(tx, user.ID, user.Password); err != nil {
+				return err
+			}
+			user.MustChangePassword = false
+		}
+		if updateData.MustChangePassword != nil {
+			user.MustChangePassword = *updateData.MustChangePassword
+			return tx.Model(&user).Update("must_change_password", user.MustChangePassword).Error
+		}
+		return nil
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
 			Message: "Erreur lors de la modification",
@@ -795,6 +852,14 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Désactivation ou changement de rôle : les tokens en circulation (rôle dans le JWT) sont révoqués
+	switch {
+	case wasActive && !user.IsActive:
+		h.revokeUserSessions(user.ID, services.SessionRevokedDeactivated)
+	case previousRole != user.Role:
+		h.revokeUserSessions(user.ID, services.SessionRevokedRoleChanged)
+	}
+
 	// Mise à jour des groupes si fournis
 	if updateData.GroupIDs != nil {
 		var groups []models.Group
@@ -853,6 +918,7 @@ func (h *AdminHandler) DeleteUser(c *gin.Context) {
 		})
 		return
 	}
+	h.revokeUserSessions(user.ID, services.SessionRevokedDeleted)
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Utilisateur supprimé avec succès",
@@ -968,77 +1034,7 @@ func (h *AdminHandler) PermanentlyDeleteUser(c *gin.Context) {
 
 	// Supprimer définitivement dans une transaction
 	if err := h.db.Transaction(func(tx *gorm.DB) error {
-		// 1. Supprimer les associations many-to-many
-		txUnscoped := tx.Unscoped()
-		if err := txUnscoped.Model(&user).Association("Groups").Clear(); err != nil {
-			return err
-		}
-		if err := txUnscoped.Model(&user).Association("Favorites").Clear(); err != nil {
-			return err
-		}
-		if err := txUnscoped.Model(&user).Association("AdminOfGroups").Clear(); err != nil {
-			return err
-		}
-
-		// 2. Supprimer les enregistrements liés à l'utilisateur
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.NewsReaction{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.NewsRead{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.ApplicationClick{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Notification{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Unscoped().Where("sender_id = ? OR recipient_id = ?", user.ID, user.ID).Delete(&models.ChatMessage{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Comment{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Feedback{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PollVote{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.GamificationProfile{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserAchievement{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Where("user_id = ?", user.ID).Delete(&models.XPTransaction{}).Error; err != nil {
-			return err
-		}
-
-		// 3. Nullifier les références d'auteur sur le contenu (préserver les articles/sondages)
-		if err := tx.Model(&models.News{}).Where("author_id = ?", user.ID).Update("author_id", nil).Error; err != nil {
-			return err
-		}
-		if err := tx.Model(&models.Poll{}).Where("author_id = ?", user.ID).Update("author_id", nil).Error; err != nil {
-			return err
-		}
-		if err := tx.Unscoped().Where("author_id = ?", user.ID).Delete(&models.Event{}).Error; err != nil {
-			return err
-		}
-		if err := tx.Model(&models.Media{}).Where("uploaded_by = ?", user.ID).Update("uploaded_by", nil).Error; err != nil {
-			return err
-		}
-		// Nullifier moderated_by dans les commentaires
-		if err := tx.Model(&models.Comment{}).Where("moderated_by = ?", user.ID).Update("moderated_by", nil).Error; err != nil {
-			return err
-		}
-
-		// 4. Supprimer définitivement l'utilisateur
-		if err := txUnscoped.Delete(&user).Error; err != nil {
-			return err
-		}
-
-		return nil
+		return services.EraseUserData(tx, &user)
 	}); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
@@ -1048,6 +1044,8 @@ func (h *AdminHandler) PermanentlyDeleteUser(c *gin.Context) {
 		return
 	}
 
+	services.CleanupErasedUserFiles(h.db, h.storage, &user)
+
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "Utilisateur supprimé définitivement",
 	})
@@ -1571,3 +1569,15 @@ func (h *AdminHandler) AssignGroupAdmins(c *gin.Context) {
 		Message: "Administrateurs de groupe assignés avec succès",
 	})
 }
+
+// revokeUserSessions déconnecte un utilisateur de tous ses appareils
+func (h *AdminHandler) revokeUserSessions(userID uint, reason string) {
+	count, err := services.NewSessionService(h.db).RevokeAll(userID, reason)
+	if err != nil {
+		log.Printf("[Admin] Erreur lors de la révocation des sessions de l'utilisateur %d: %v", userID, err)
+		return
+	}
+	if count > 0 {
+		log.Printf("[Admin] %d session(s) de l'utilisateur %d révoquée(s) (%s)", count, userID, reason)
+	}
+}
@@ -99,6 +99,10 @@ func (h *EventsHandler) CreateEvent(c *gin.Context) {
 	// Award Contributor XP
 	go h.gamificationService.AwardXP(userID, 150, "event_publish", "")
 
+	if err := h.references.SyncEvent(&event); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des médias de l'événement %d: %v", event.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, event)
 }
 
@@ -197,6 +201,10 @@ func (h *EventsHandler) UpdateEvent(c *gin.Context) {
 		Preload("TargetGroups").
 		First(&event, event.ID)
 
+	if err := h.references.SyncEvent(&event); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des médias de l'événement %d: %v", event.ID, err)
+	}
+
 	c.JSON(http.StatusOK, event)
 }
 
@@ -236,6 +244,10 @@ func (h *EventsHandler) DeleteEvent(c *gin.Context) {
 		return
 	}
 
+	if err := h.references.RemoveEntity(services.MediaRefEvent, event.ID); err != nil {
+		log.Printf("[Media] Erreur lors de la suppression des références de l'événement %d: %v", event.ID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Événement supprimé avec succès"})
 }
 
@@ -310,6 +322,8 @@ func (h *EventsHandler) ListEvents(c *gin.Context) {
 		totalPages++
 	}
 
+	attachEventCoverVariants(h.db, events)
+
 	c.JSON(http.StatusOK, models.EventListResponse{
 		Events:     events,
 		Total:      total,
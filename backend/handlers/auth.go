@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"strings"
 	"time"
@@ -26,17 +29,43 @@ type AuthHandler struct {
 	authSecurity        *utils.AuthSecurityManager
 	bcryptCost          int
 	gamificationService *services.GamificationService
+	twoFactor           *services.TwoFactorService
+	webauthn            *services.WebAuthnService // nil si WebAuthn n'est pas configuré
+	passwordReset       *services.PasswordResetService
+	emailVerification   *services.EmailVerificationService
+	passwordPolicy      *services.PasswordPolicyService
+	ldap                *services.LDAPService
+	saml                *services.SAMLService
+	storage             services.StorageService
+	references          *services.MediaReferenceService
+	publicURL           string
 }
 
-func NewAuthHandler(db *gorm.DB, authMiddleware *middleware.AuthMiddleware, signupEnabled bool, cfg *config.Config, gs *services.GamificationService) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, authMiddleware *middleware.AuthMiddleware, signupEnabled bool, cfg *config.Config, gs *services.GamificationService, stateStore utils.StateStore, storage services.StorageService) *AuthHandler {
+	// Les passkeys restent désactivées (503) si le RP ID ou les origines sont invalides
+	webauthnService, err := services.NewWebAuthnService(db, cfg)
+	if err != nil {
+		log.Printf("[Auth] WebAuthn désactivé: %v", err)
+	}
+
 	return &AuthHandler{
 		db:                  db,
 		authMiddleware:      authMiddleware,
 		signupEnabled:       signupEnabled,
 		notificationService: services.NewNotificationService(db),
-		authSecurity:        utils.NewAuthSecurityManager(),
+		authSecurity:        utils.NewAuthSecurityManagerWithStore(stateStore),
 		bcryptCost:          cfg.Security.BcryptCost,
 		gamificationService: gs,
+		twoFactor:           services.NewTwoFactorService(db, cfg),
+		webauthn:            webauthnService,
+		passwordReset:       services.NewPasswordResetService(db, cfg),
+		emailVerification:   services.NewEmailVerificationService(db, cfg),
+		passwordPolicy:      services.NewPasswordPolicyService(db),
+		ldap:                services.NewLDAPService(db, cfg),
+		saml:                services.NewSAMLService(db, cfg, stateStore),
+		storage:             storage,
+		references:          services.NewMediaReferenceService(db),
+		publicURL:           strings.TrimSuffix(cfg.Server.PublicURL, "/"),
 	}
 }
 
@@ -77,17 +106,10 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Rechercher l'utilisateur avec ses relations
 	var user models.User
-	if err := h.db.Preload("Groups").Preload("AdminOfGroups").Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-			Error:   "Unauthorized",
-			Message: "Nom d'utilisateur ou mot de passe incorrect",
-			Code:    http.StatusUnauthorized,
-		})
-		return
-	}
+	found := h.db.Preload("Groups").Preload("AdminOfGroups").Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error == nil
 
 	// Vérifier le compte actif
-	if !user.IsActive {
+	if found && !user.IsActive {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "Unauthorized",
 			Message: "Compte désactivé",
@@ -96,34 +118,97 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Vérifier le mot de passe
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		// Enregistrer la tentative échouée
-		if isLocked, remaining := h.authSecurity.RecordFailedLogin(identifier); isLocked {
-			log.Printf("[Auth] Account locked for %s after failed attempts: %v", identifier, remaining)
-			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
-				Error:   "Too Many Requests",
-				Message: fmt.Sprintf("Compte verrouillé après trop de tentatives échouées. Réessayez dans %.0f minutes", remaining.Minutes()),
-				Code:    http.StatusTooManyRequests,
-			})
+	switch {
+	case h.ldap.Enabled() && (!found || user.SSOProvider == "ldap"):
+		// Annuaire LDAP : utilisateurs inconnus d'Airboard et comptes déjà rattachés à l'annuaire
+		ldapUser, err := h.ldap.Authenticate(req.Username, req.Password)
+		if err != nil {
+			if !errors.Is(err, services.ErrLDAPInvalidCredentials) {
+				log.Printf("[Auth] LDAP authentication failed for %s from IP %s: %v", req.Username, clientIP, err)
+			}
+			if errors.Is(err, services.ErrLDAPAccountDisabled) {
+				c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+					Error:   "Unauthorized",
+					Message: "Compte désactivé",
+					Code:    http.StatusUnauthorized,
+				})
+				return
+			}
+			h.rejectLogin(c, identifier)
 			return
 		}
+		user = *ldapUser
 
+	case !found:
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "Unauthorized",
 			Message: "Nom d'utilisateur ou mot de passe incorrect",
 			Code:    http.StatusUnauthorized,
 		})
 		return
+
+	default:
+		// Vérifier le mot de passe
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			h.rejectLogin(c, identifier)
+			return
+		}
 	}
 
 	// Enregistrer la connexion réussie et nettoyer les tentatives échouées
 	h.authSecurity.RecordSuccessfulLogin(identifier)
+
+	// Inscription dont l'adresse email n'a pas encore été confirmée
+	if user.EmailVerificationPending {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Email Not Verified",
+			Message: "Adresse email non confirmée. Consultez le lien reçu par email ou demandez un nouvel envoi",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
+
+	// Mot de passe expiré ou changement imposé par un administrateur
+	if h.requirePasswordChange(c, &user) {
+		log.Printf("[Auth] Password verified for %s from IP %s, password change required", req.Username, clientIP)
+		return
+	}
+
+	// Double authentification : le JWT n'est délivré qu'après vérification du code
+	if h.requireSecondFactor(c, &user, http.StatusOK) {
+		log.Printf("[Auth] Password verified for %s from IP %s, waiting for second factor", req.Username, clientIP)
+		return
+	}
 	log.Printf("[Auth] Successful login for %s from IP %s", req.Username, clientIP)
 
+	h.completeLogin(c, &user, nil)
+}
+
+// rejectLogin enregistre une tentative échouée et répond 401 (ou 429 si l'identifiant est désormais verrouillé)
+func (h *AuthHandler) rejectLogin(c *gin.Context, identifier string) {
+	if isLocked, remaining := h.authSecurity.RecordFailedLogin(identifier); isLocked {
+		log.Printf("[Auth] Account locked for %s after failed attempts: %v", identifier, remaining)
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "Too Many Requests",
+			Message: fmt.Sprintf("Compte verrouillé après trop de tentatives échouées. Réessayez dans %.0f minutes", remaining.Minutes()),
+			Code:    http.StatusTooManyRequests,
+		})
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+		Error:   "Unauthorized",
+		Message: "Nom d'utilisateur ou mot de passe incorrect",
+		Code:    http.StatusUnauthorized,
+	})
+}
+
+// completeLogin délivre les tokens d'un utilisateur authentifié (après le mot de passe
+// et, si nécessaire, le second facteur)
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, recoveryCodes []string) {
 	// Mettre à jour la date de dernière connexion
 	now := time.Now()
-	if err := h.db.Model(&user).Update("last_login", now).Error; err != nil {
+	if err := h.db.Model(user).Update("last_login", now).Error; err != nil {
 		log.Printf("Erreur lors de la mise à jour de la dernière connexion: %v", err)
 		// Ne pas bloquer la connexion pour cette erreur
 	}
@@ -141,7 +226,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	*/
 
 	// Générer les tokens
-	token, err := h.authMiddleware.GenerateToken(&user)
+	token, refreshToken, err := h.authMiddleware.IssueTokens(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
@@ -151,16 +236,6 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	refreshToken, err := h.authMiddleware.GenerateRefreshToken(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors de la génération du refresh token",
-			Code:    http.StatusInternalServerError,
-		})
-		return
-	}
-
 	// Charger les IDs des groupes administrés
 	var managedGroupIDs []uint
 	h.db.Table("group_admins").
@@ -178,20 +253,24 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Masquer le mot de passe
 	user.Password = ""
 
+	// Cookie d'accès aux fichiers /uploads (balises <img>, <video>...)
+	h.authMiddleware.SetMediaCookie(c, token)
+
 	c.JSON(http.StatusOK, models.LoginResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
-		User:         user,
+		Token:         token,
+		RefreshToken:  refreshToken,
+		User:          *user,
+		RecoveryCodes: recoveryCodes,
 	})
 }
 
 // @Summary Inscription utilisateur
-// @Description Crée un nouveau compte utilisateur
+// @Description Crée un nouveau compte utilisateur, en attente jusqu'à la confirmation de l'adresse email
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param register body models.RegisterRequest true "Informations d'inscription"
-// @Success 201 {object} models.LoginResponse
+// @Success 201 {object} models.RegistrationPendingResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 409 {object} models.ErrorResponse
@@ -230,16 +309,22 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Valider la force du mot de passe
-	if err := h.authSecurity.ValidatePassword(req.Password); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "Weak Password",
-			Message: fmt.Sprintf("Mot de passe trop faible: %v", err),
-			Code:    http.StatusBadRequest,
+	// Restreindre l'inscription aux domaines email autorisés
+	if !services.EmailDomainAllowed(req.Email, settings.SignupAllowedDomains) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "L'inscription n'est pas autorisée pour ce domaine email",
+			Code:    http.StatusForbidden,
 		})
 		return
 	}
 
+	// Valider la force du mot de passe
+	if err := h.passwordPolicy.Validate(nil, req.Password); err != nil {
+		writePasswordPolicyError(c, err)
+		return
+	}
+
 	// Vérifier si l'utilisateur existe déjà
 	var existingUser models.User
 	if err := h.db.Where("username = ? OR email = ?", req.Username, req.Email).First(&existingUser).Error; err == nil {
@@ -271,6 +356,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		LastName:  req.LastName,
 		Role:      "user", // Par défaut, les nouveaux utilisateurs sont des users normaux
 		IsActive:  true,
+		// Aucune session tant que l'adresse email n'est pas confirmée
+		EmailVerificationPending: true,
 	}
 
 	if err := h.db.Create(&user).Error; err != nil {
@@ -281,6 +368,9 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		})
 		return
 	}
+	if err := h.passwordPolicy.Record(nil, user.ID, user.Password); err != nil {
+		log.Printf("[Auth] Erreur lors de l'enregistrement de l'historique du mot de passe: %v", err)
+	}
 
 	// Ajouter l'utilisateur au groupe par défaut configuré
 	defaultGroup := GetDefaultGroupFromDB(h.db)
@@ -315,37 +405,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		}
 	}
 
-	// Recharger l'utilisateur avec ses relations
-	h.db.Preload("Groups").Preload("AdminOfGroups").First(&user, user.ID)
-
-	// Générer les tokens
-	token, err := h.authMiddleware.GenerateToken(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors de la génération du token",
-			Code:    http.StatusInternalServerError,
-		})
-		return
-	}
-
-	refreshToken, err := h.authMiddleware.GenerateRefreshToken(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors de la génération du refresh token",
-			Code:    http.StatusInternalServerError,
-		})
-		return
+	// Envoyer le lien de confirmation : le compte pourra se connecter une fois l'email confirmé
+	// (l'utilisateur peut redemander l'envoi si celui-ci échoue)
+	if err := h.emailVerification.Send(&user); err != nil {
+		log.Printf("[Auth] Erreur lors de l'envoi de l'email de confirmation à l'utilisateur %d: %v", user.ID, err)
 	}
 
-	// Masquer le mot de passe
-	user.Password = ""
-
-	c.JSON(http.StatusCreated, models.LoginResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
-		User:         user,
+	log.Printf("[Auth] User %d registered from IP %s, waiting for email verification", user.ID, c.ClientIP())
+	c.JSON(http.StatusCreated, models.RegistrationPendingResponse{
+		Message:                   "Compte créé. Un email de confirmation a été envoyé à votre adresse",
+		Email:                     user.Email,
+		EmailVerificationRequired: true,
 	})
 }
 
@@ -416,23 +486,24 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Générer de nouveaux tokens
-	newToken, err := h.authMiddleware.GenerateToken(&user)
+	// Rotation : le refresh token présenté est invalidé et remplacé
+	newToken, newRefreshToken, err := h.authMiddleware.RotateTokens(c, &user, claims)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors de la génération du token",
-			Code:    http.StatusInternalServerError,
-		})
-		return
-	}
-
-	newRefreshToken, err := h.authMiddleware.GenerateRefreshToken(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors de la génération du refresh token",
-			Code:    http.StatusInternalServerError,
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			log.Printf("[Auth] Refresh token reuse detected for user %d (session %d) from IP %s, session revoked",
+				user.ID, claims.SessionID, c.ClientIP())
+		} else if !errors.Is(err, services.ErrSessionNotFound) && !errors.Is(err, services.ErrSessionRevoked) {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Erreur lors de la génération du token",
+				Code:    http.StatusInternalServerError,
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Session révoquée ou expirée",
+			Code:    http.StatusUnauthorized,
 		})
 		return
 	}
@@ -440,6 +511,9 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	// Masquer le mot de passe
 	user.Password = ""
 
+	// Cookie d'accès aux fichiers /uploads (balises <img>, <video>...)
+	h.authMiddleware.SetMediaCookie(c, newToken)
+
 	c.JSON(http.StatusOK, models.LoginResponse{
 		Token:        newToken,
 		RefreshToken: newRefreshToken,
@@ -549,6 +623,12 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	// Politique de mots de passe, y compris la non-réutilisation
+	if err := h.passwordPolicy.Validate(&user, req.NewPassword); err != nil {
+		writePasswordPolicyError(c, err)
+		return
+	}
+
 	// Hasher le nouveau mot de passe avec coût sécurisé (12 minimum - OWASP 2025)
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), h.bcryptCost)
 	if err != nil {
@@ -562,7 +642,12 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 
 	// Mettre à jour le mot de passe
 	user.Password = string(hashedPassword)
-	if err := h.db.Save(&user).Error; err != nil {
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return h.passwordPolicy.Record(tx, user.ID, user.Password)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
 			Message: "Erreur lors de la mise à jour du mot de passe",
@@ -648,7 +733,7 @@ func (h *AuthHandler) SSOAutoLogin(c *gin.Context) {
 	}
 
 	// Générer les tokens JWT pour l'utilisateur SSO
-	token, err := h.authMiddleware.GenerateToken(ssoUser)
+	token, refreshToken, err := h.authMiddleware.IssueTokens(c, ssoUser)
 	if err != nil {
 		log.Printf("[SSO] Erreur lors de la génération du token: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -666,17 +751,6 @@ func (h *AuthHandler) SSOAutoLogin(c *gin.Context) {
 		// Ne pas bloquer la connexion pour cette erreur
 	}
 
-	refreshToken, err := h.authMiddleware.GenerateRefreshToken(ssoUser)
-	if err != nil {
-		log.Printf("[SSO] Erreur lors de la génération du refresh token: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors de la génération du refresh token",
-			Code:    http.StatusInternalServerError,
-		})
-		return
-	}
-
 	// Recharger l'utilisateur avec les groupes et les groupes administrés
 	var user models.User
 	if err := h.db.Preload("Groups").Preload("AdminOfGroups").First(&user, ssoUser.ID).Error; err != nil {
@@ -707,6 +781,9 @@ func (h *AuthHandler) SSOAutoLogin(c *gin.Context) {
 
 	log.Printf("[SSO] Auto-login réussi pour: %s (%s)", user.Email, user.Username)
 
+	// Cookie d'accès aux fichiers /uploads (balises <img>, <video>...)
+	h.authMiddleware.SetMediaCookie(c, token)
+
 	c.JSON(http.StatusOK, models.LoginResponse{
 		Token:        token,
 		RefreshToken: refreshToken,
@@ -854,16 +931,25 @@ func (h *AuthHandler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	// Créer le dossier avatars s'il n'existe pas
-	avatarDir := "./uploads/avatars"
-	if err := utils.EnsureDir(avatarDir); err != nil {
-		log.Printf("Erreur lors de la création du dossier avatars: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Erreur lors de la sauvegarde de l'avatar",
-			Code:    http.StatusInternalServerError,
-		})
-		return
+	// Ré-encoder l'image : suppression des métadonnées (EXIF, GPS...), application
+	// de l'orientation et taille maximale (les GIF sont conservés pour l'animation)
+	var sanitized *services.SanitizedImage
+	if services.SupportsSanitize(contentType) {
+		src, err := file.Open()
+		if err == nil {
+			sanitized, err = services.NewImageSanitizer(services.AvatarMaxDimension).Sanitize(src, contentType)
+			src.Close()
+		}
+		if err != nil {
+			log.Printf("Erreur lors du traitement de l'avatar: %v", err)
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Image invalide ou corrompue",
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		contentType = sanitized.MimeType
 	}
 
 	// Générer un nom de fichier unique
@@ -876,11 +962,19 @@ func (h *AuthHandler) UploadAvatar(c *gin.Context) {
 	case "image/webp":
 		ext = ".webp"
 	}
-	filename := fmt.Sprintf("avatar_%d_%d%s", user.ID, time.Now().Unix(), ext)
-	filepath := fmt.Sprintf("%s/%s", avatarDir, filename)
+	key := fmt.Sprintf("avatars/avatar_%d_%d%s", user.ID, time.Now().Unix(), ext)
 
-	// Sauvegarder le fichier
-	if err := c.SaveUploadedFile(file, filepath); err != nil {
+	// Sauvegarder le fichier dans le stockage configuré (disque local ou S3)
+	if sanitized != nil {
+		err = h.storage.PutObject(c.Request.Context(), key, bytes.NewReader(sanitized.Data), int64(len(sanitized.Data)), contentType)
+	} else {
+		var src multipart.File
+		if src, err = file.Open(); err == nil {
+			err = h.storage.PutObject(c.Request.Context(), key, src, file.Size, contentType)
+			src.Close()
+		}
+	}
+	if err != nil {
 		log.Printf("Erreur lors de la sauvegarde du fichier avatar: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
@@ -890,17 +984,16 @@ func (h *AuthHandler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	// Supprimer l'ancien avatar si existant (et si c'est un fichier local)
-	if user.AvatarURL != "" && strings.HasPrefix(user.AvatarURL, "/uploads/avatars/") {
-		oldPath := "." + user.AvatarURL
-		if err := utils.RemoveFile(oldPath); err != nil {
+	// Supprimer l'ancien avatar s'il a été uploadé
+	if oldKey, ok := services.AvatarKey(user.AvatarURL); ok {
+		if err := h.storage.Delete(c.Request.Context(), oldKey); err != nil {
 			log.Printf("Erreur lors de la suppression de l'ancien avatar: %v", err)
 			// On continue quand même
 		}
 	}
 
 	// Mettre à jour l'URL de l'avatar
-	user.AvatarURL = fmt.Sprintf("/uploads/avatars/%s", filename)
+	user.AvatarURL = h.storage.GetURL(key)
 	if err := h.db.Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Internal Server Error",
@@ -910,6 +1003,10 @@ func (h *AuthHandler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
+	if err := h.references.SyncUser(&user); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation de l'avatar: %v", err)
+	}
+
 	// Recharger l'utilisateur avec ses relations
 	h.db.Preload("Groups").Preload("AdminOfGroups").First(&user, user.ID)
 
@@ -949,10 +1046,9 @@ func (h *AuthHandler) DeleteAvatar(c *gin.Context) {
 		return
 	}
 
-	// Supprimer le fichier avatar si existant (et si c'est un fichier local)
-	if user.AvatarURL != "" && strings.HasPrefix(user.AvatarURL, "/uploads/avatars/") {
-		oldPath := "." + user.AvatarURL
-		if err := utils.RemoveFile(oldPath); err != nil {
+	// Supprimer le fichier avatar s'il a été uploadé
+	if key, ok := services.AvatarKey(user.AvatarURL); ok {
+		if err := h.storage.Delete(c.Request.Context(), key); err != nil {
 			log.Printf("Erreur lors de la suppression de l'avatar: %v", err)
 			// On continue quand même
 		}
@@ -969,6 +1065,10 @@ func (h *AuthHandler) DeleteAvatar(c *gin.Context) {
 		return
 	}
 
+	if err := h.references.SyncUser(&user); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation de l'avatar: %v", err)
+	}
+
 	// Recharger l'utilisateur avec ses relations
 	h.db.Preload("Groups").Preload("AdminOfGroups").First(&user, user.ID)
 
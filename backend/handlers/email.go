@@ -299,6 +299,29 @@ func (h *EmailHandler) GetTemplateVariables(c *gin.Context) {
 			{"name": "{{.Type}}", "description": "Type d'annonce (info, warning, success, error)"},
 			{"name": "{{.AppName}}", "description": "Nom de l'application"},
 		},
+		"password_reset": {
+			{"name": "{{.Name}}", "description": "Nom de l'utilisateur"},
+			{"name": "{{.Username}}", "description": "Identifiant du compte"},
+			{"name": "{{.Link}}", "description": "Lien de réinitialisation (usage unique)"},
+			{"name": "{{.ExpiresIn}}", "description": "Durée de validité du lien"},
+			{"name": "{{.AppName}}", "description": "Nom de l'application"},
+		},
+		"email_verification": {
+			{"name": "{{.Name}}", "description": "Nom de l'utilisateur"},
+			{"name": "{{.Username}}", "description": "Identifiant du compte"},
+			{"name": "{{.Link}}", "description": "Lien de confirmation de l'adresse email"},
+			{"name": "{{.ExpiresIn}}", "description": "Durée de validité du lien"},
+			{"name": "{{.AppName}}", "description": "Nom de l'application"},
+		},
+		"invitation": {
+			{"name": "{{.Email}}", "description": "Adresse email invitée"},
+			{"name": "{{.InvitedBy}}", "description": "Nom de l'auteur de l'invitation"},
+			{"name": "{{.Role}}", "description": "Rôle attribué (user, editor, admin)"},
+			{"name": "{{.Groups}}", "description": "Groupes attribués, séparés par des virgules"},
+			{"name": "{{.Link}}", "description": "Lien de création du compte (usage unique)"},
+			{"name": "{{.ExpiresIn}}", "description": "Durée de validité du lien"},
+			{"name": "{{.AppName}}", "description": "Nom de l'application"},
+		},
 	}
 
 	c.JSON(http.StatusOK, variables)
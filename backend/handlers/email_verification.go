@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Confirmer l'adresse email
+// @Description Active une inscription avec les paramètres du lien signé reçu par email
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.EmailVerificationRequest true "Paramètres du lien de confirmation"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/email/verify [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.EmailVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Lien de confirmation invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	user, err := h.emailVerification.Verify(req.UserID, req.Expires, req.Signature)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationLink) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Lien de confirmation invalide ou expiré",
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+		log.Printf("[Auth] Erreur lors de la confirmation de l'email: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la confirmation de l'adresse email",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Email verified for user %d from IP %s", user.ID, c.ClientIP())
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Adresse email confirmée. Vous pouvez vous connecter",
+	})
+}
+
+// @Summary Renvoyer l'email de confirmation
+// @Description Renvoie le lien de confirmation d'une inscription en attente. La réponse est identique que le compte existe ou non.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.EmailVerificationResendRequest true "Email de l'inscription"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /auth/email/resend [post]
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	var req models.EmailVerificationResendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Adresse email invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	// Envoi en arrière-plan : le temps de réponse ne doit pas dépendre de l'existence du compte
+	go func() {
+		if err := h.emailVerification.Resend(req.Email); err != nil {
+			log.Printf("[Auth] Erreur lors du renvoi de l'email de confirmation: %v", err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Si une inscription en attente correspond à cette adresse, un nouvel email de confirmation a été envoyé",
+	})
+}
+
+// RunUnverifiedAccountPurge supprime périodiquement les inscriptions jamais confirmées (à lancer en goroutine)
+func (h *AuthHandler) RunUnverifiedAccountPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := h.emailVerification.PurgeUnverified()
+		if err != nil {
+			log.Printf("[Auth] Erreur lors de la purge des inscriptions non confirmées: %v", err)
+		} else if count > 0 {
+			log.Printf("[Auth] %d inscription(s) non confirmée(s) supprimée(s)", count)
+		}
+	}
+}
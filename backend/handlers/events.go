@@ -18,10 +18,11 @@ import (
 type EventsHandler struct {
 	db                  *gorm.DB
 	gamificationService *services.GamificationService
+	references          *services.MediaReferenceService
 }
 
 func NewEventsHandler(db *gorm.DB, gs *services.GamificationService) *EventsHandler {
-	return &EventsHandler{db: db, gamificationService: gs}
+	return &EventsHandler{db: db, gamificationService: gs, references: services.NewMediaReferenceService(db)}
 }
 
 // GetEvents - Liste des événements (accessible à tous les utilisateurs connectés)
@@ -207,6 +208,8 @@ func (h *EventsHandler) GetEvents(c *gin.Context) {
 		totalPages++
 	}
 
+	attachEventCoverVariants(h.db, events)
+
 	c.JSON(http.StatusOK, models.EventListResponse{
 		Events:     events,
 		Total:      total,
@@ -424,6 +427,8 @@ func (h *EventsHandler) GetEventBySlug(c *gin.Context) {
 		}
 	}
 
+	event.CoverImageVariants = services.LoadVariantsByURL(h.db, []string{event.CoverImage})[event.CoverImage]
+
 	c.JSON(http.StatusOK, event)
 }
 
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"airboard/middleware"
 	"airboard/models"
+	"airboard/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -105,6 +107,10 @@ func (h *EventsHandler) CreateEventGroupAdmin(c *gin.Context) {
 	// Award Contributor XP
 	go h.gamificationService.AwardXP(userID, 150, "event_publish", "")
 
+	if err := h.references.SyncEvent(&event); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des médias de l'événement %d: %v", event.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, event)
 }
 
@@ -244,6 +250,10 @@ func (h *EventsHandler) UpdateEventGroupAdmin(c *gin.Context) {
 		Preload("TargetGroups").
 		First(&event, event.ID)
 
+	if err := h.references.SyncEvent(&event); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des médias de l'événement %d: %v", event.ID, err)
+	}
+
 	c.JSON(http.StatusOK, event)
 }
 
@@ -305,5 +315,9 @@ func (h *EventsHandler) DeleteEventGroupAdmin(c *gin.Context) {
 		return
 	}
 
+	if err := h.references.RemoveEntity(services.MediaRefEvent, event.ID); err != nil {
+		log.Printf("[Media] Erreur lors de la suppression des références de l'événement %d: %v", event.ID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Événement supprimé avec succès"})
 }
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Voir en tant qu'un utilisateur
+// @Description Démarre une session en lecture seule avec l'identité d'un utilisateur (admin uniquement). Le motif est conservé dans le journal.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'utilisateur"
+// @Param request body models.ImpersonationRequest true "Motif et durée (minutes, 60 au maximum)"
+// @Success 201 {object} models.ImpersonationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /admin/users/{id}/impersonate [post]
+func (h *AuthHandler) StartImpersonation(c *gin.Context) {
+	var req models.ImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Un motif (3 à 500 caractères) est requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var admin models.User
+	if err := h.db.First(&admin, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+
+	var target models.User
+	if err := h.db.Preload("Groups").Preload("AdminOfGroups").First(&target, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+
+	if !target.IsActive {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Le compte de cet utilisateur est désactivé",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	ttl := time.Duration(req.DurationMinutes) * time.Minute
+	impersonation, err := h.authMiddleware.Impersonations().Start(&admin, &target, req.Reason, c.ClientIP(), c.Request.UserAgent(), ttl)
+	if err != nil {
+		if errors.Is(err, services.ErrImpersonationNotAllowed) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "Forbidden",
+				Message: "Impossible de voir l'application en tant qu'administrateur",
+				Code:    http.StatusForbidden,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors du démarrage de la session",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	token, err := h.authMiddleware.GenerateImpersonationToken(&target, impersonation)
+	if err != nil {
+		h.authMiddleware.Impersonations().End(impersonation.ID)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la génération du token",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Admin %d (%s) started impersonation %d of user %d (%s): %s",
+		admin.ID, admin.Username, impersonation.ID, target.ID, target.Username, impersonation.Reason)
+
+	c.JSON(http.StatusCreated, models.ImpersonationResponse{
+		Token:         token,
+		ExpiresAt:     impersonation.ExpiresAt,
+		User:          target,
+		Impersonation: *impersonation,
+	})
+}
+
+// @Summary Session "voir en tant que" en cours
+// @Description Indique si le token courant est une session d'impersonation et retourne son entrée du journal
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/impersonation [get]
+func (h *AuthHandler) GetImpersonationStatus(c *gin.Context) {
+	impersonationID := c.GetUint("impersonation_id")
+	if impersonationID == 0 {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	impersonation, err := h.authMiddleware.Impersonations().Get(impersonationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la récupération de la session",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"active":        true,
+		"impersonation": impersonation,
+	})
+}
+
+// @Summary Quitter le mode "voir en tant que"
+// @Description Termine la session d'impersonation du token courant ; le token est refusé ensuite
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/impersonation/end [post]
+func (h *AuthHandler) EndImpersonation(c *gin.Context) {
+	impersonationID := c.GetUint("impersonation_id")
+	if impersonationID == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Aucune session \"voir en tant que\" en cours",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := h.authMiddleware.Impersonations().End(impersonationID); err != nil && !errors.Is(err, services.ErrImpersonationEnded) {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la fin de la session",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Admin %d ended impersonation %d of user %d", c.GetUint("impersonator_id"), impersonationID, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Session \"voir en tant que\" terminée",
+	})
+}
+
+// @Summary Journal des impersonations
+// @Description Liste les sessions "voir en tant que" (200 dernières), filtrables par administrateur ou utilisateur
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param admin_id query int false "ID de l'administrateur"
+// @Param user_id query int false "ID de l'utilisateur consulté"
+// @Success 200 {array} models.Impersonation
+// @Router /admin/impersonations [get]
+func (h *AuthHandler) GetImpersonations(c *gin.Context) {
+	adminID, _ := strconv.Atoi(c.Query("admin_id"))
+	userID, _ := strconv.Atoi(c.Query("user_id"))
+
+	impersonations, err := h.authMiddleware.Impersonations().List(uint(adminID), uint(userID), 200)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la récupération du journal",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, impersonations)
+}
@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"airboard/config"
+	"airboard/middleware"
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InvitationHandler gère les invitations envoyées par les administrateurs et les administrateurs de groupe
+type InvitationHandler struct {
+	db          *gorm.DB
+	invitations *services.InvitationService
+	bcryptCost  int
+}
+
+// NewInvitationHandler crée une nouvelle instance d'InvitationHandler
+func NewInvitationHandler(db *gorm.DB, cfg *config.Config) *InvitationHandler {
+	return &InvitationHandler{
+		db:          db,
+		invitations: services.NewInvitationService(db, cfg),
+		bcryptCost:  cfg.Security.BcryptCost,
+	}
+}
+
+// @Summary Lister les invitations
+// @Description Liste les invitations avec leur statut (pending, accepted, expired, revoked). Un administrateur de groupe ne voit que ses invitations.
+// @Tags Invitations
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filtrer par statut"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Éléments par page" default(20)
+// @Success 200 {object} models.PaginatedResponse
+// @Router /admin/invitations [get]
+func (h *InvitationHandler) ListInvitations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var invitedBy uint
+	if c.GetString("role") != "admin" {
+		invitedBy = c.GetUint("user_id")
+	}
+
+	invitations, total, err := h.invitations.List(invitedBy, c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Statut invalide (pending, accepted, expired ou revoked)",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	totalPages := int(total)/limit + 1
+	if int(total)%limit == 0 && total > 0 {
+		totalPages = int(total) / limit
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedResponse{
+		Data:       invitations,
+		Total:      total,
+		Page:       page,
+		PageSize:   limit,
+		TotalPages: totalPages,
+	})
+}
+
+// @Summary Inviter un utilisateur
+// @Description Envoie par email un lien de création de compte avec un rôle et des groupes attribués. Un administrateur de groupe ne peut inviter qu'avec le rôle user dans les groupes qu'il administre.
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.InvitationRequest true "Invitation"
+// @Success 201 {object} models.Invitation
+// @Failure 409 {object} models.ErrorResponse
+// @Router /admin/invitations [post]
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	var req models.InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Adresse email invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	role, groups, inviter, ok := h.resolveInvitation(c, req.Role, req.GroupIDs)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.invitations.Create(req.Email, role, groups, inviter)
+	if err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// @Summary Inviter plusieurs utilisateurs
+// @Description Envoie une invitation à chaque adresse (100 au plus) avec le même rôle et les mêmes groupes. Les adresses refusées sont listées avec le motif.
+// @Tags Invitations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.InvitationBulkRequest true "Invitations"
+// @Success 200 {object} models.InvitationBulkResponse
+// @Router /admin/invitations/bulk [post]
+func (h *InvitationHandler) CreateBulkInvitations(c *gin.Context) {
+	var req models.InvitationBulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Liste d'adresses email invalide (100 au plus)",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	role, groups, inviter, ok := h.resolveInvitation(c, req.Role, req.GroupIDs)
+	if !ok {
+		return
+	}
+
+	response := models.InvitationBulkResponse{
+		Invitations: []models.Invitation{},
+		Failed:      []models.InvitationBulkFailure{},
+	}
+	seen := make(map[string]bool, len(req.Emails))
+	for _, email := range req.Emails {
+		key := strings.ToLower(strings.TrimSpace(email))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		invitation, err := h.invitations.Create(email, role, groups, inviter)
+		if err != nil {
+			message := "Erreur lors de la création de l'invitation"
+			switch {
+			case errors.Is(err, services.ErrInvitationConflict):
+				message = "Un compte ou une invitation en attente existe déjà pour cette adresse"
+			case errors.Is(err, services.ErrInvitationEmail):
+				message = "L'email d'invitation n'a pas pu être envoyé"
+			}
+			log.Printf("[Invitations] Invitation de %s refusée: %v", email, err)
+			response.Failed = append(response.Failed, models.InvitationBulkFailure{Email: email, Message: message})
+			continue
+		}
+		response.Invitations = append(response.Invitations, *invitation)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Renvoyer une invitation
+// @Description Renvoie une invitation en attente ou expirée avec une nouvelle expiration (le lien précédent n'est plus valable)
+// @Tags Invitations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'invitation"
+// @Success 200 {object} models.Invitation
+// @Failure 409 {object} models.ErrorResponse
+// @Router /admin/invitations/{id}/resend [post]
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	invitation, ok := h.findInvitation(c)
+	if !ok {
+		return
+	}
+	if err := h.invitations.Resend(invitation); err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	log.Printf("[Invitations] Invitation %d resent by user %d", invitation.ID, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, invitation)
+}
+
+// @Summary Révoquer une invitation
+// @Description Invalide le lien d'une invitation qui n'a pas encore été acceptée
+// @Tags Invitations
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'invitation"
+// @Success 200 {object} models.Invitation
+// @Failure 409 {object} models.ErrorResponse
+// @Router /admin/invitations/{id} [delete]
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	invitation, ok := h.findInvitation(c)
+	if !ok {
+		return
+	}
+	if err := h.invitations.Revoke(invitation); err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	log.Printf("[Invitations] Invitation %d revoked by user %d", invitation.ID, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, invitation)
+}
+
+// @Summary Consulter une invitation
+// @Description Retourne l'adresse, le rôle et les groupes d'une invitation valide, affichés avant la création du compte
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Token reçu par email"
+// @Success 200 {object} models.InvitationDetails
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/invitations [get]
+func (h *InvitationHandler) GetInvitation(c *gin.Context) {
+	details, err := h.invitations.Lookup(c.Query("token"))
+	if err != nil {
+		writeInvitationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+// @Summary Accepter une invitation
+// @Description Crée le compte invité avec l'identifiant et le mot de passe choisis. L'adresse email est considérée comme confirmée.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.InvitationAcceptRequest true "Token et informations du compte"
+// @Success 201 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/invitations/accept [post]
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req models.InvitationAcceptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Données invalides",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	user, err := h.invitations.Accept(req, h.bcryptCost)
+	if err != nil {
+		var policyErr *services.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			writePasswordPolicyError(c, err)
+			return
+		}
+		writeInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "Compte créé. Vous pouvez vous connecter avec votre identifiant et votre mot de passe",
+		Data:    user,
+	})
+}
+
+// resolveInvitation contrôle le rôle et les groupes demandés selon les droits de l'auteur.
+// Un administrateur de groupe invite uniquement avec le rôle user dans les groupes qu'il administre.
+func (h *InvitationHandler) resolveInvitation(c *gin.Context, role string, groupIDs []uint) (string, []models.Group, *models.User, bool) {
+	if role == "" {
+		role = "user"
+	}
+	isAdmin := c.GetString("role") == "admin"
+
+	if !services.InvitationRoles[role] {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Rôle invalide (user, editor ou admin)",
+			Code:    http.StatusBadRequest,
+		})
+		return "", nil, nil, false
+	}
+	if !isAdmin && role != "user" {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "Seul un administrateur peut inviter avec un autre rôle que user",
+			Code:    http.StatusForbidden,
+		})
+		return "", nil, nil, false
+	}
+	if !isAdmin && len(groupIDs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Au moins un groupe administré est requis",
+			Code:    http.StatusBadRequest,
+		})
+		return "", nil, nil, false
+	}
+	for _, groupID := range groupIDs {
+		if !middleware.CanManageGroup(c, groupID) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "Forbidden",
+				Message: "Vous n'administrez pas tous les groupes demandés",
+				Code:    http.StatusForbidden,
+			})
+			return "", nil, nil, false
+		}
+	}
+
+	var groups []models.Group
+	if len(groupIDs) > 0 {
+		requested := make(map[uint]bool, len(groupIDs))
+		for _, groupID := range groupIDs {
+			requested[groupID] = true
+		}
+		if err := h.db.Where("id IN ?", groupIDs).Find(&groups).Error; err != nil || len(groups) != len(requested) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Un ou plusieurs groupes sont introuvables",
+				Code:    http.StatusBadRequest,
+			})
+			return "", nil, nil, false
+		}
+	}
+
+	var inviter models.User
+	if err := h.db.First(&inviter, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Utilisateur introuvable",
+			Code:    http.StatusUnauthorized,
+		})
+		return "", nil, nil, false
+	}
+	return role, groups, &inviter, true
+}
+
+// findInvitation charge l'invitation de la route. Un administrateur de groupe n'accède qu'à ses invitations.
+func (h *InvitationHandler) findInvitation(c *gin.Context) (*models.Invitation, bool) {
+	if id, err := strconv.ParseUint(c.Param("id"), 10, 32); err == nil {
+		invitation, err := h.invitations.Get(uint(id))
+		if err == nil && (c.GetString("role") == "admin" || invitation.InvitedByID == c.GetUint("user_id")) {
+			return invitation, true
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("[Invitations] Erreur lors de la récupération de l'invitation: %v", err)
+		}
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "Not Found",
+		Message: "Invitation non trouvée",
+		Code:    http.StatusNotFound,
+	})
+	return nil, false
+}
+
+func writeInvitationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidInvitation):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invitation invalide, expirée ou révoquée",
+			Code:    http.StatusBadRequest,
+		})
+	case errors.Is(err, services.ErrInvitationConflict):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Un compte ou une invitation en attente existe déjà pour cette adresse",
+			Code:    http.StatusConflict,
+		})
+	case errors.Is(err, services.ErrInvitationNotPending):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: "L'invitation a déjà été acceptée ou révoquée",
+			Code:    http.StatusConflict,
+		})
+	case errors.Is(err, services.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Nom d'utilisateur déjà utilisé",
+			Code:    http.StatusConflict,
+		})
+	case errors.Is(err, services.ErrInvitationEmail):
+		log.Printf("[Invitations] %v", err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Bad Gateway",
+			Message: "L'email d'invitation n'a pas pu être envoyé (vérifiez la configuration SMTP)",
+			Code:    http.StatusBadGateway,
+		})
+	default:
+		log.Printf("[Invitations] Erreur: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors du traitement de l'invitation",
+			Code:    http.StatusInternalServerError,
+		})
+	}
+}
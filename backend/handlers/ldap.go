@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"airboard/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Synchroniser l'annuaire LDAP
+// @Description Met à jour les attributs et les groupes des utilisateurs LDAP et désactive ceux retirés de l'annuaire (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} services.LDAPSyncResult
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /admin/ldap/sync [post]
+func (h *AuthHandler) SyncLDAP(c *gin.Context) {
+	if !h.ldap.Enabled() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "L'authentification LDAP n'est pas activée",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	result, err := h.ldap.Sync()
+	if err != nil {
+		log.Printf("[LDAP] Erreur lors de la synchronisation de l'annuaire: %v", err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Bad Gateway",
+			Message: "Erreur lors de la synchronisation avec l'annuaire LDAP",
+			Code:    http.StatusBadGateway,
+		})
+		return
+	}
+
+	log.Printf("[LDAP] Synchronisation lancée par l'utilisateur %d: %d à jour, %d désactivé(s), %d erreur(s)",
+		c.GetUint("user_id"), result.Synced, result.Deactivated, result.Failed)
+	c.JSON(http.StatusOK, result)
+}
+
+// RunLDAPSync synchronise périodiquement les utilisateurs et les groupes LDAP (à lancer en goroutine)
+func (h *AuthHandler) RunLDAPSync(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result, err := h.ldap.Sync()
+		if err != nil {
+			log.Printf("[LDAP] Erreur lors de la synchronisation de l'annuaire: %v", err)
+			continue
+		}
+		log.Printf("[LDAP] Synchronisation terminée: %d à jour, %d désactivé(s), %d erreur(s)",
+			result.Synced, result.Deactivated, result.Failed)
+	}
+}
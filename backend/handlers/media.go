@@ -4,14 +4,23 @@ import (
 	"airboard/models"
 	"airboard/services"
 	"airboard/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -21,13 +30,27 @@ type MediaHandler struct {
 	db             *gorm.DB
 	storageService services.StorageService
 	fileValidator  *utils.SecureFileValidator
+	variants       *services.ImageVariantService
+	references     *services.MediaReferenceService
+	blobs          *services.MediaBlobService
+	access         *services.MediaAccessService
+	urlSigner      *services.MediaURLSigner
+	sanitizer      *services.ImageSanitizer
+	quotas         *services.StorageQuotaService
 }
 
-func NewMediaHandler(db *gorm.DB, storageService services.StorageService) *MediaHandler {
+func NewMediaHandler(db *gorm.DB, storageService services.StorageService, urlSigner *services.MediaURLSigner, fileValidator *utils.SecureFileValidator) *MediaHandler {
 	return &MediaHandler{
 		db:             db,
 		storageService: storageService,
-		fileValidator:  utils.NewSecureFileValidator(),
+		fileValidator:  fileValidator,
+		variants:       services.NewImageVariantService(db, storageService),
+		references:     services.NewMediaReferenceService(db),
+		blobs:          services.NewMediaBlobService(db, storageService),
+		access:         services.NewMediaAccessService(db),
+		urlSigner:      urlSigner,
+		sanitizer:      services.NewImageSanitizer(services.DefaultImageMaxDimension),
+		quotas:         services.NewStorageQuotaService(db),
 	}
 }
 
@@ -59,32 +82,77 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// Enhanced security validation
-	validationResult := h.fileValidator.ValidateSecureFile(file, fileHeader)
-	if !validationResult.IsValid {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "file_security_violation",
-			Message: validationResult.Reason,
-			Code:    http.StatusBadRequest,
+	media, deduplicated, uploadErr := h.createMedia(c.Request.Context(), file, fileHeader, userID.(uint))
+	if uploadErr != nil {
+		c.JSON(uploadErr.Status, models.ErrorResponse{
+			Error:   uploadErr.Code,
+			Message: uploadErr.Message,
+			Code:    uploadErr.Status,
 		})
 		return
 	}
 
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "File uploaded successfully",
+		"media":        media,
+		"deduplicated": deduplicated,
+	})
+}
+
+// mediaUploadError is returned by createMedia with the HTTP status to send
+type mediaUploadError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// createMedia validates a file, stores it (or reuses identical content) and creates
+// the Media record with its variants. Shared by multipart and resumable uploads.
+func (h *MediaHandler) createMedia(ctx context.Context, file multipart.File, fileHeader *multipart.FileHeader, userID uint) (*models.Media, bool, *mediaUploadError) {
+	// Enhanced security validation
+	validationResult := h.fileValidator.ValidateSecureFile(file, fileHeader)
+	if !validationResult.IsValid && !validationResult.Quarantine {
+		return nil, false, &mediaUploadError{http.StatusBadRequest, "file_security_violation", validationResult.Reason}
+	}
+
+	// Re-encode images: strip EXIF/XMP, apply the orientation and cap dimensions.
+	// Quarantined files are stored as uploaded for review.
+	var originalMetadata string
+	if !validationResult.Quarantine && services.SupportsSanitize(validationResult.SafeMIME) {
+		sanitized, err := h.sanitizer.Sanitize(file, validationResult.SafeMIME)
+		if err != nil {
+			return nil, false, &mediaUploadError{http.StatusBadRequest, "invalid_image", fmt.Sprintf("Failed to process image: %v", err)}
+		}
+		file = memoryFile{bytes.NewReader(sanitized.Data)}
+		fileHeader.Size = int64(len(sanitized.Data))
+		if fileHeader.Header == nil {
+			fileHeader.Header = make(textproto.MIMEHeader)
+		}
+		fileHeader.Header.Set("Content-Type", sanitized.MimeType)
+		validationResult.SafeMIME = sanitized.MimeType
+
+		if len(sanitized.Metadata) > 0 && h.keepImageMetadata() {
+			if data, err := json.Marshal(sanitized.Metadata); err == nil {
+				originalMetadata = string(data)
+			}
+		}
+	}
+
+	// Enforce storage quotas on the size actually stored
+	if uploadErr := h.checkQuota(userID, fileHeader.Size); uploadErr != nil {
+		return nil, false, uploadErr
+	}
+
 	// Sanitize filename
 	safeFilename := h.fileValidator.SanitizeFilename(fileHeader.Filename)
 
 	// Update fileHeader with safe filename
 	fileHeader.Filename = safeFilename
 
-	// Upload file to storage
-	storagePath, url, err := h.storageService.Upload(c.Request.Context(), file, fileHeader)
+	// Upload file to storage, or reuse the stored object if the same content already exists
+	blob, err := h.blobs.Store(ctx, file, fileHeader)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "upload_failed",
-			Message: fmt.Sprintf("Failed to upload file: %v", err),
-			Code:    http.StatusInternalServerError,
-		})
-		return
+		return nil, false, &mediaUploadError{http.StatusInternalServerError, "upload_failed", fmt.Sprintf("Failed to upload file: %v", err)}
 	}
 
 	// Get image dimensions if it's an image (only for safe image types)
@@ -103,30 +171,110 @@ func (h *MediaHandler) UploadMedia(c *gin.Context) {
 	// Create media record in database
 	media := models.Media{
 		Filename:    safeFilename,
-		StoragePath: storagePath,
-		URL:         url,
+		StoragePath: blob.StoragePath,
+		URL:         blob.URL,
+		ContentHash: blob.ContentHash,
 		MimeType:    validationResult.SafeMIME,
 		FileSize:    fileHeader.Size,
 		Width:       width,
 		Height:      height,
 		StorageType: h.storageService.GetType(),
-		UploadedBy:  userID.(uint),
+		UploadedBy:  userID,
+		Quarantined: validationResult.Quarantine,
+
+		OriginalMetadata: originalMetadata,
+	}
+	if scan := validationResult.Scan; scan != nil {
+		media.ScanStatus = scan.Status
+		media.ScanSignature = scan.Signature
+		if !scan.ScannedAt.IsZero() {
+			media.ScannedAt = &scan.ScannedAt
+		}
 	}
 
 	if err := h.db.Create(&media).Error; err != nil {
-		// Try to delete uploaded file on database error
-		h.storageService.Delete(c.Request.Context(), storagePath)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "database_error",
-			Message: "Failed to save media record",
-			Code:    http.StatusInternalServerError,
-		})
+		// Release the blob (deletes the uploaded file unless it is shared)
+		h.blobs.Release(ctx, blob.StoragePath)
+		return nil, false, &mediaUploadError{http.StatusInternalServerError, "database_error", "Failed to save media record"}
+	}
+
+	// Quarantined files are kept for review by an admin, without variants
+	if media.Quarantined {
+		log.Printf("[Antivirus] Media %d quarantined (%s): %s", media.ID, media.ScanStatus, validationResult.Reason)
+		if media.ScanStatus == utils.ScanStatusInfected {
+			if err := h.applyScanResult(&media); err != nil {
+				log.Printf("Warning: failed to quarantine copies of media %d: %v", media.ID, err)
+			}
+			return &media, blob.Deduplicated, &mediaUploadError{http.StatusUnprocessableEntity, "malware_detected", validationResult.Reason}
+		}
+		return &media, blob.Deduplicated, &mediaUploadError{http.StatusServiceUnavailable, "scan_unavailable", validationResult.Reason}
+	}
+
+	// Generate thumbnail and responsive variants (non-blocking for the upload itself).
+	// Deduplicated uploads reuse the variants of the media sharing the same content.
+	if services.SupportsVariants(media.MimeType) {
+		var source models.Media
+		if blob.Deduplicated && h.db.Where("content_hash = ? AND id <> ? AND thumbnail_url <> ''", blob.ContentHash, media.ID).
+			First(&source).Error == nil {
+			err = h.variants.CopyVariants(source.ID, &media)
+		} else {
+			err = h.variants.Generate(ctx, &media, file)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to generate variants for media %d: %v", media.ID, err)
+		}
+	}
+
+	return &media, blob.Deduplicated, nil
+}
+
+// checkQuota rejects an upload of size bytes that would exceed a storage quota of the user
+func (h *MediaHandler) checkQuota(userID uint, size int64) *mediaUploadError {
+	err := h.quotas.Check(userID, size)
+	if err == nil {
+		return nil
+	}
+	var quotaErr *services.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		return &mediaUploadError{http.StatusRequestEntityTooLarge, "quota_exceeded", quotaErr.Error()}
+	}
+	log.Printf("Warning: %v", err)
+	return &mediaUploadError{http.StatusInternalServerError, "database_error", "Failed to check storage quota"}
+}
+
+// memoryFile exposes re-encoded content as a multipart.File
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error { return nil }
+
+// keepImageMetadata reports whether admins chose to record the original EXIF tags
+func (h *MediaHandler) keepImageMetadata() bool {
+	var settings models.AppSettings
+	if err := h.db.Select("keep_image_metadata").First(&settings).Error; err != nil {
+		return false
+	}
+	return settings.KeepImageMetadata
+}
+
+// GetMediaMetadata returns the original EXIF tags recorded for a media (admin only)
+func (h *MediaHandler) GetMediaMetadata(c *gin.Context) {
+	media, ok := h.loadMediaParam(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "File uploaded successfully",
-		"media":   media,
+	metadata := map[string]string{}
+	if media.OriginalMetadata != "" {
+		if err := json.Unmarshal([]byte(media.OriginalMetadata), &metadata); err != nil {
+			log.Printf("Warning: invalid metadata for media %d: %v", media.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"media_id": media.ID,
+		"metadata": metadata,
 	})
 }
 
@@ -143,7 +291,7 @@ func (h *MediaHandler) GetMediaList(c *gin.Context) {
 		pageSize = 20
 	}
 
-	query := h.db.Model(&models.Media{}).Preload("Uploader")
+	query := h.db.Model(&models.Media{}).Preload("Uploader").Preload("Variants")
 
 	// Filter by media type if specified
 	if mediaType != "" {
@@ -163,6 +311,14 @@ func (h *MediaHandler) GetMediaList(c *gin.Context) {
 		}
 	}
 
+	// Filter by visibility (public, authenticated, group)
+	if visibility := c.Query("visibility"); visibility != "" {
+		query = query.Where("visibility = ?", visibility)
+	}
+
+	// Quarantined files are only listed in the admin quarantine view
+	query = query.Where("quarantined = ?", false)
+
 	// Count total
 	var total int64
 	query.Count(&total)
@@ -206,7 +362,7 @@ func (h *MediaHandler) GetMedia(c *gin.Context) {
 	}
 
 	var media models.Media
-	if err := h.db.Preload("Uploader").First(&media, id).Error; err != nil {
+	if err := h.db.Preload("Uploader").Preload("Variants").Preload("AccessGroups").First(&media, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "not_found",
@@ -332,24 +488,353 @@ func (h *MediaHandler) DeleteMedia(c *gin.Context) {
 		return
 	}
 
-	// Delete from storage
-	if err := h.storageService.Delete(c.Request.Context(), media.StoragePath); err != nil {
+	// Refuse to delete a file that is still used by some content (admins can force)
+	refs, err := h.references.References(media.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check media usage",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	// A deduplicated copy can always go: the shared file stays for the other media
+	inUse := len(refs) > 0 && !h.blobs.IsShared(media.StoragePath)
+	if inUse && !(c.Query("force") == "true" && role.(string) == "admin") {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "media_in_use",
+			"message":    fmt.Sprintf("Media is still used in %d place(s)", len(refs)),
+			"code":       http.StatusConflict,
+			"references": refs,
+		})
+		return
+	}
+
+	if err := h.removeMedia(c.Request.Context(), &media, false); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete media record",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Media deleted successfully",
+	})
+}
+
+// removeMedia deletes the stored file, its variants, its references and the database record
+func (h *MediaHandler) removeMedia(ctx context.Context, media *models.Media, hardDelete bool) error {
+	// Delete from storage (only when no other media shares the same content)
+	if err := h.blobs.Release(ctx, media.StoragePath); err != nil {
 		// Log error but continue with database deletion
 		fmt.Printf("Warning: Failed to delete file from storage: %v\n", err)
 	}
 
+	// Delete thumbnail and responsive variants
+	if err := h.variants.DeleteForMedia(ctx, media.ID); err != nil {
+		fmt.Printf("Warning: Failed to delete media variants: %v\n", err)
+	}
+
+	if err := h.db.Where("media_id = ?", media.ID).Delete(&models.MediaReference{}).Error; err != nil {
+		return err
+	}
+	if err := h.db.Model(media).Association("AccessGroups").Clear(); err != nil {
+		return err
+	}
+
 	// Delete from database
-	if err := h.db.Delete(&media).Error; err != nil {
+	query := h.db
+	if hardDelete {
+		query = query.Unscoped()
+	}
+	return query.Delete(media).Error
+}
+
+// GetMediaReferences lists where a media is used
+func (h *MediaHandler) GetMediaReferences(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid media ID",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	refs, err := h.references.References(uint(id))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "database_error",
-			Message: "Failed to delete media record",
+			Message: "Failed to fetch media references",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, refs)
+}
+
+// RebuildMediaReferences rebuilds the whole usage index from existing content (admin only)
+func (h *MediaHandler) RebuildMediaReferences(c *gin.Context) {
+	if err := h.references.RebuildAll(); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to rebuild media references",
 			Code:    http.StatusInternalServerError,
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Message: "Media deleted successfully",
+		Message: "Media references rebuilt successfully",
+	})
+}
+
+// GetOrphanedMedia lists unused media records and stored files without a record (admin only)
+func (h *MediaHandler) GetOrphanedMedia(c *gin.Context) {
+	report, err := h.findOrphans(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "storage_error",
+			Message: fmt.Sprintf("Failed to list orphaned media: %v", err),
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// PurgeOrphanedMedia permanently deletes orphans (admin only).
+// Without a body, every orphan currently detected is purged; otherwise only the
+// given media IDs / files, which must still be orphans.
+func (h *MediaHandler) PurgeOrphanedMedia(c *gin.Context) {
+	var input struct {
+		MediaIDs []uint   `json:"media_ids"`
+		Files    []string `json:"files"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_input",
+				Message: "Invalid input data",
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	report, err := h.findOrphans(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "storage_error",
+			Message: fmt.Sprintf("Failed to list orphaned media: %v", err),
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	selectAll := len(input.MediaIDs) == 0 && len(input.Files) == 0
+	wantedMedia := make(map[uint]bool)
+	for _, id := range input.MediaIDs {
+		wantedMedia[id] = true
+	}
+	wantedFiles := make(map[string]bool)
+	for _, f := range input.Files {
+		wantedFiles[f] = true
+	}
+
+	ctx := c.Request.Context()
+	purgedMedia, purgedFiles := 0, 0
+	for i := range report.Media {
+		if !selectAll && !wantedMedia[report.Media[i].ID] {
+			continue
+		}
+		if err := h.removeMedia(ctx, &report.Media[i], true); err != nil {
+			log.Printf("Warning: failed to purge media %d: %v", report.Media[i].ID, err)
+			continue
+		}
+		purgedMedia++
+	}
+	for _, file := range report.Files {
+		if !selectAll && !wantedFiles[file] {
+			continue
+		}
+		if err := h.storageService.Delete(ctx, file); err != nil {
+			log.Printf("Warning: failed to purge file %s: %v", file, err)
+			continue
+		}
+		purgedFiles++
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: fmt.Sprintf("Purged %d media record(s) and %d file(s)", purgedMedia, purgedFiles),
+		Data: gin.H{
+			"purged_media": purgedMedia,
+			"purged_files": purgedFiles,
+		},
+	})
+}
+
+// findOrphans runs orphan detection with the min_age_hours grace period (default 24h),
+// so files uploaded for content still being edited are not reported
+func (h *MediaHandler) findOrphans(c *gin.Context) (*services.MediaOrphanReport, error) {
+	minAgeHours, err := strconv.Atoi(c.DefaultQuery("min_age_hours", "24"))
+	if err != nil || minAgeHours < 0 {
+		minAgeHours = 24
+	}
+	return h.references.FindOrphans(c.Request.Context(), h.storageService, time.Duration(minAgeHours)*time.Hour)
+}
+
+// ServeUpload serves /uploads/* after checking the media visibility: public files
+// are open to anyone, other files need a session (header or media cookie) allowed
+// to see them, or a valid signed URL. Local files are streamed with Range support,
+// object stores get a redirect to a presigned URL (which handles Range itself).
+func (h *MediaHandler) ServeUpload(c *gin.Context) {
+	key := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+	// Dot-paths are technical areas (partial uploads...)
+	if key == "" || strings.HasPrefix(key, ".") || strings.Contains(key, "/.") {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	access, err := h.access.Resolve(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check file access",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	if access.Quarantined {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "file_quarantined",
+			Message: "This file has been quarantined by the antivirus",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
+
+	viewer := services.MediaViewer{UserID: c.GetUint("user_id"), Role: c.GetString("role")}
+	expiresAt, signed := h.urlSigner.Verify(key, c.Query("expires"), c.Query("signature"))
+
+	switch {
+	case access.Visibility == services.MediaVisibilityPublic:
+		c.Header("Cache-Control", "public, max-age=86400")
+	case signed:
+		maxAge := int(time.Until(expiresAt).Seconds())
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	case h.access.CanAccess(access, viewer):
+		c.Header("Cache-Control", "private, no-cache")
+		c.Header("Vary", "Authorization, Cookie")
+	case viewer.UserID == 0:
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Authentication required",
+			Code:    http.StatusUnauthorized,
+		})
+		return
+	default:
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "You do not have access to this file",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
+
+	if opener, ok := h.storageService.(services.FileOpener); ok {
+		f, err := opener.Open(c.Request.Context(), key)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		// Stored files have no extension: use the recorded type instead of sniffing
+		if access.MimeType != "" {
+			c.Header("Content-Type", access.MimeType)
+		}
+		if access.ContentHash != "" {
+			c.Header("ETag", `"`+access.ContentHash+`"`)
+		}
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+		http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime(), f)
+		return
+	}
+
+	provider, ok := h.storageService.(services.PresignedURLProvider)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	url, err := provider.PresignedURL(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "storage_error",
+			Message: "Failed to generate file URL",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	// The presigned URL expires, so the redirect itself must not be cached for long
+	if access.Visibility != services.MediaVisibilityPublic {
+		c.Header("Cache-Control", "private, max-age=60")
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// SignMediaURLsRequest lists /uploads/... URLs to sign
+type SignMediaURLsRequest struct {
+	URLs []string `json:"urls" binding:"required,max=200"`
+}
+
+// SignMediaURLs returns expiring signed URLs (for sharing or embedding outside the app)
+// for the files the current user is allowed to see. Public files are returned as is.
+func (h *MediaHandler) SignMediaURLs(c *gin.Context) {
+	var req SignMediaURLsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	viewer := services.MediaViewer{UserID: c.GetUint("user_id"), Role: c.GetString("role")}
+	signed := make(map[string]string)
+	for _, u := range req.URLs {
+		for _, uploadURL := range services.ExtractUploadURLs(u) {
+			access, err := h.access.Resolve(strings.TrimPrefix(uploadURL, "/uploads/"))
+			if err != nil || access.Quarantined || !h.access.CanAccess(access, viewer) {
+				continue
+			}
+			if access.Visibility == services.MediaVisibilityPublic {
+				signed[u] = uploadURL
+			} else {
+				signed[u] = h.urlSigner.SignURL(uploadURL)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"urls":       signed,
+		"expires_in": int(h.urlSigner.Expiry().Seconds()),
 	})
 }
 
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"airboard/models"
+	"airboard/services"
+	"airboard/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetQuarantinedMedia lists quarantined files (admin only)
+func (h *MediaHandler) GetQuarantinedMedia(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := h.db.Model(&models.Media{}).Preload("Uploader").Where("quarantined = ?", true)
+	if status := c.Query("scan_status"); status != "" {
+		query = query.Where("scan_status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var mediaList []models.Media
+	if err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&mediaList).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to fetch quarantined media",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+	c.JSON(http.StatusOK, models.PaginatedResponse{
+		Data:       mediaList,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	})
+}
+
+// RescanMedia scans a stored file again (e.g. clamd was down at upload time).
+// A clean result lifts the quarantine.
+func (h *MediaHandler) RescanMedia(c *gin.Context) {
+	media, ok := h.loadMediaParam(c)
+	if !ok {
+		return
+	}
+
+	reader, err := h.storageService.Get(c.Request.Context(), media.StoragePath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "storage_error",
+			Message: "Failed to read file from storage",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	scan := h.fileValidator.ScanContent(reader)
+	reader.Close()
+
+	if scan.Status == utils.ScanStatusError {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "scan_unavailable",
+			Message: "Antivirus scan failed, quarantine status unchanged",
+			Code:    http.StatusServiceUnavailable,
+		})
+		return
+	}
+
+	wasQuarantined := media.Quarantined
+	media.ScanStatus = scan.Status
+	media.ScanSignature = scan.Signature
+	if !scan.ScannedAt.IsZero() {
+		media.ScannedAt = &scan.ScannedAt
+	}
+	media.Quarantined = scan.Status == utils.ScanStatusInfected
+
+	if err := h.applyScanResult(media); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to save scan result",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	if wasQuarantined && !media.Quarantined {
+		h.generateVariantsFromStorage(c.Request.Context(), media)
+	}
+
+	log.Printf("[Antivirus] Media %d rescanned by user %d: %s %s", media.ID, c.GetUint("user_id"), media.ScanStatus, media.ScanSignature)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Media rescanned",
+		Data:    media,
+	})
+}
+
+// ReleaseMedia lifts the quarantine of a file (admin override for false positives)
+func (h *MediaHandler) ReleaseMedia(c *gin.Context) {
+	media, ok := h.loadMediaParam(c)
+	if !ok {
+		return
+	}
+	if !media.Quarantined {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "not_quarantined",
+			Message: "Media is not quarantined",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	media.Quarantined = false
+	if err := h.applyScanResult(media); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to release media",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	h.generateVariantsFromStorage(c.Request.Context(), media)
+
+	log.Printf("[Antivirus] Media %d released from quarantine by user %d (scan status: %s %s)",
+		media.ID, c.GetUint("user_id"), media.ScanStatus, media.ScanSignature)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Media released from quarantine",
+		Data:    media,
+	})
+}
+
+// applyScanResult saves the scan fields and quarantine flag of a media on every
+// media sharing its content (deduplicated uploads point to the same stored file)
+func (h *MediaHandler) applyScanResult(media *models.Media) error {
+	return h.db.Model(&models.Media{}).
+		Where("id = ? OR (content_hash <> '' AND content_hash = ?)", media.ID, media.ContentHash).
+		Updates(map[string]interface{}{
+			"quarantined":    media.Quarantined,
+			"scan_status":    media.ScanStatus,
+			"scan_signature": media.ScanSignature,
+			"scanned_at":     media.ScannedAt,
+		}).Error
+}
+
+// generateVariantsFromStorage creates the variants skipped while the file was quarantined
+func (h *MediaHandler) generateVariantsFromStorage(ctx context.Context, media *models.Media) {
+	if !services.SupportsVariants(media.MimeType) || media.ThumbnailURL != "" {
+		return
+	}
+
+	reader, err := h.storageService.Get(ctx, media.StoragePath)
+	if err != nil {
+		log.Printf("Warning: failed to read media %d for variants: %v", media.ID, err)
+		return
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, h.fileValidator.MaxFileSize()+1))
+	if err != nil {
+		log.Printf("Warning: failed to read media %d for variants: %v", media.ID, err)
+		return
+	}
+	if err := h.variants.Generate(ctx, media, bytes.NewReader(data)); err != nil {
+		log.Printf("Warning: failed to generate variants for media %d: %v", media.ID, err)
+	}
+}
+
+// loadMediaParam loads the media from the :id URL parameter, writing the error response
+func (h *MediaHandler) loadMediaParam(c *gin.Context) (*models.Media, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid media ID",
+			Code:    http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var media models.Media
+	if err := h.db.First(&media, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "not_found",
+				Message: "Media not found",
+				Code:    http.StatusNotFound,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "database_error",
+				Message: fmt.Sprintf("Failed to fetch media: %v", err),
+				Code:    http.StatusInternalServerError,
+			})
+		}
+		return nil, false
+	}
+	return &media, true
+}
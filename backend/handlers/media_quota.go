@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"airboard/models"
+	"airboard/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetMyStorageQuota returns the storage used by the current user and the quotas that apply
+func (h *MediaHandler) GetMyStorageQuota(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	usage, err := h.quotas.UserUsage(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to compute storage usage",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	quotas, err := h.quotas.Applicable(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to fetch storage quotas",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	if quotas == nil {
+		quotas = []services.QuotaStatus{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"usage":  usage,
+		"quotas": quotas,
+	})
+}
+
+// GetStorageQuotas lists the configured quotas (admin only)
+func (h *MediaHandler) GetStorageQuotas(c *gin.Context) {
+	query := h.db.Model(&models.StorageQuota{})
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	var quotas []models.StorageQuota
+	if err := query.Order("scope, role, target_id").Find(&quotas).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to fetch storage quotas",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, quotas)
+}
+
+// CreateStorageQuota creates a quota for a user, a role or a group (admin only)
+func (h *MediaHandler) CreateStorageQuota(c *gin.Context) {
+	var req models.StorageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	quota := models.StorageQuota{
+		Scope:    req.Scope,
+		MaxBytes: req.MaxBytes,
+		MaxFiles: req.MaxFiles,
+	}
+	if !h.resolveQuotaTarget(c, &quota, req) {
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.StorageQuota{}).
+		Where("scope = ? AND target_id = ? AND role = ?", quota.Scope, quota.TargetID, quota.Role).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "quota_exists",
+			Message: "A quota already exists for this target",
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	if err := h.db.Create(&quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to create storage quota",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, quota)
+}
+
+// UpdateStorageQuota changes the limits of a quota (admin only)
+func (h *MediaHandler) UpdateStorageQuota(c *gin.Context) {
+	quota, ok := h.loadStorageQuota(c)
+	if !ok {
+		return
+	}
+
+	var req models.StorageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	if req.Scope != quota.Scope {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "The scope of a quota cannot be changed",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	quota.MaxBytes = req.MaxBytes
+	quota.MaxFiles = req.MaxFiles
+	if err := h.db.Save(quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to update storage quota",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, quota)
+}
+
+// DeleteStorageQuota removes a quota (admin only)
+func (h *MediaHandler) DeleteStorageQuota(c *gin.Context) {
+	quota, ok := h.loadStorageQuota(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to delete storage quota",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Storage quota deleted",
+	})
+}
+
+// GetStorageReport returns the top storage consumers, users and managed groups (admin only)
+func (h *MediaHandler) GetStorageReport(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	users, err := h.quotas.TopUsers(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to compute storage report",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	groups, err := h.quotas.TopGroups(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to compute storage report",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	var total services.StorageUsage
+	h.db.Model(&models.Media{}).
+		Select("COALESCE(SUM(file_size), 0) AS bytes, COUNT(*) AS files").
+		Scan(&total)
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":  total,
+		"users":  users,
+		"groups": groups,
+	})
+}
+
+// resolveQuotaTarget validates the target of a new quota, writing the error response
+func (h *MediaHandler) resolveQuotaTarget(c *gin.Context, quota *models.StorageQuota, req models.StorageQuotaRequest) bool {
+	var err error
+	switch req.Scope {
+	case services.QuotaScopeUser:
+		quota.TargetID = req.TargetID
+		err = h.db.Select("id").First(&models.User{}, req.TargetID).Error
+	case services.QuotaScopeGroup:
+		quota.TargetID = req.TargetID
+		err = h.db.Select("id").First(&models.Group{}, req.TargetID).Error
+	case services.QuotaScopeRole:
+		if req.Role != "admin" && req.Role != "editor" && req.Role != "user" {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Role must be admin, editor or user",
+				Code:    http.StatusBadRequest,
+			})
+			return false
+		}
+		quota.Role = req.Role
+		return true
+	}
+
+	if req.TargetID == 0 || errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_target",
+			Message: "Unknown " + req.Scope,
+			Code:    http.StatusBadRequest,
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to check quota target",
+			Code:    http.StatusInternalServerError,
+		})
+		return false
+	}
+	return true
+}
+
+// loadStorageQuota loads the quota from the :id URL parameter, writing the error response
+func (h *MediaHandler) loadStorageQuota(c *gin.Context) (*models.StorageQuota, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid quota ID",
+			Code:    http.StatusBadRequest,
+		})
+		return nil, false
+	}
+
+	var quota models.StorageQuota
+	if err := h.db.First(&quota, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "not_found",
+				Message: "Storage quota not found",
+				Code:    http.StatusNotFound,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "database_error",
+				Message: "Failed to fetch storage quota",
+				Code:    http.StatusInternalServerError,
+			})
+		}
+		return nil, false
+	}
+	return &quota, true
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"airboard/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// tus 1.0 resumable uploads (https://tus.io/protocols/resumable-upload)
+// Supported extensions: creation, termination, expiration.
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	tusUploadTTL  = 24 * time.Hour
+)
+
+// tusLocks serializes PATCH requests on the same upload
+var tusLocks sync.Map
+
+// TusOptions advertises the server capabilities
+func (h *MediaHandler) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.fileValidator.MaxFileSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreate creates a new resumable upload (creation extension)
+func (h *MediaHandler) TusCreate(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		h.tusError(c, http.StatusBadRequest, "invalid_upload_length", "Missing or invalid Upload-Length header")
+		return
+	}
+	if length > h.fileValidator.MaxFileSize() {
+		h.tusError(c, http.StatusRequestEntityTooLarge, "file_too_large",
+			fmt.Sprintf("File too large (max: %d MB)", h.fileValidator.MaxFileSize()/(1024*1024)))
+		return
+	}
+
+	// Reject early when the announced size is already over quota (checked again once complete)
+	if uploadErr := h.checkQuota(userID, length); uploadErr != nil {
+		h.tusError(c, uploadErr.Status, uploadErr.Code, uploadErr.Message)
+		return
+	}
+
+	rawMetadata := c.GetHeader("Upload-Metadata")
+	metadata := parseTusMetadata(rawMetadata)
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	if filename == "" {
+		h.tusError(c, http.StatusBadRequest, "invalid_metadata", "Upload-Metadata must contain a filename")
+		return
+	}
+	fileType := metadata["filetype"]
+	if fileType == "" {
+		fileType = metadata["type"]
+	}
+
+	if err := os.MkdirAll(h.storageService.TempDir(), 0755); err != nil {
+		h.tusError(c, http.StatusInternalServerError, "storage_error", "Failed to prepare upload")
+		return
+	}
+
+	upload := models.MediaUpload{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Filename:  filename,
+		FileType:  fileType,
+		Metadata:  rawMetadata,
+		Length:    length,
+		ExpiresAt: time.Now().Add(tusUploadTTL),
+	}
+
+	f, err := os.Create(h.tusPartPath(upload.ID))
+	if err != nil {
+		h.tusError(c, http.StatusInternalServerError, "storage_error", "Failed to prepare upload")
+		return
+	}
+	f.Close()
+
+	if err := h.db.Create(&upload).Error; err != nil {
+		os.Remove(h.tusPartPath(upload.ID))
+		h.tusError(c, http.StatusInternalServerError, "database_error", "Failed to save upload")
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Header("Tus-Resumable", tusVersion)
+	c.Status(http.StatusCreated)
+}
+
+// TusHead returns the current offset so the client can resume
+func (h *MediaHandler) TusHead(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	upload, ok := h.loadTusUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	if upload.MediaID != nil {
+		c.Header("Upload-Media-Id", strconv.FormatUint(uint64(*upload.MediaID), 10))
+	} else {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Tus-Resumable", tusVersion)
+	c.Status(http.StatusOK)
+}
+
+// TusPatch appends a chunk; the last chunk validates the file and creates the Media
+func (h *MediaHandler) TusPatch(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != "application/offset+octet-stream" {
+		h.tusError(c, http.StatusUnsupportedMediaType, "invalid_content_type", "Content-Type must be application/offset+octet-stream")
+		return
+	}
+
+	lock, _ := tusLocks.LoadOrStore(c.Param("upload_id"), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	upload, ok := h.loadTusUpload(c)
+	if !ok {
+		return
+	}
+	if upload.MediaID != nil || upload.Offset >= upload.Length && upload.Length > 0 {
+		h.tusError(c, http.StatusForbidden, "upload_complete", "Upload already completed")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset != upload.Offset {
+		h.tusError(c, http.StatusConflict, "offset_mismatch", "Upload-Offset does not match the current offset")
+		return
+	}
+
+	f, err := os.OpenFile(h.tusPartPath(upload.ID), os.O_WRONLY, 0644)
+	if err != nil {
+		h.tusError(c, http.StatusInternalServerError, "storage_error", "Failed to open upload")
+		return
+	}
+	if _, err := f.Seek(upload.Offset, io.SeekStart); err != nil {
+		f.Close()
+		h.tusError(c, http.StatusInternalServerError, "storage_error", "Failed to open upload")
+		return
+	}
+
+	// Whatever reached the disk counts, even if the connection drops mid-chunk
+	written, copyErr := io.Copy(f, io.LimitReader(c.Request.Body, upload.Length-upload.Offset))
+	f.Close()
+
+	upload.Offset += written
+	upload.ExpiresAt = time.Now().Add(tusUploadTTL)
+	if err := h.db.Model(upload).Updates(map[string]interface{}{
+		"offset":     upload.Offset,
+		"expires_at": upload.ExpiresAt,
+	}).Error; err != nil {
+		h.tusError(c, http.StatusInternalServerError, "database_error", "Failed to save upload progress")
+		return
+	}
+
+	if copyErr != nil && upload.Offset < upload.Length {
+		log.Printf("[tus] Upload %s interrupted at %d/%d: %v", upload.ID, upload.Offset, upload.Length, copyErr)
+		h.tusError(c, http.StatusBadRequest, "upload_interrupted", "Chunk transfer interrupted")
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+
+	if upload.Offset < upload.Length {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	media, uploadErr := h.finishTusUpload(c, upload)
+	if uploadErr != nil {
+		h.tusError(c, uploadErr.Status, uploadErr.Code, uploadErr.Message)
+		return
+	}
+
+	c.Header("Upload-Media-Id", strconv.FormatUint(uint64(media.ID), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// finishTusUpload runs the completed file through the regular media pipeline.
+// The partial upload is discarded whatever the outcome.
+func (h *MediaHandler) finishTusUpload(c *gin.Context, upload *models.MediaUpload) (*models.Media, *mediaUploadError) {
+	partPath := h.tusPartPath(upload.ID)
+	defer os.Remove(partPath)
+
+	f, err := os.Open(partPath)
+	if err != nil {
+		h.db.Delete(upload)
+		return nil, &mediaUploadError{http.StatusInternalServerError, "storage_error", "Failed to read upload"}
+	}
+	defer f.Close()
+
+	fileHeader := &multipart.FileHeader{
+		Filename: upload.Filename,
+		Size:     upload.Length,
+		Header:   textproto.MIMEHeader{"Content-Type": []string{upload.FileType}},
+	}
+
+	media, _, uploadErr := h.createMedia(c.Request.Context(), f, fileHeader, upload.UserID)
+	if uploadErr != nil {
+		h.db.Delete(upload)
+		return nil, uploadErr
+	}
+
+	now := time.Now()
+	h.db.Model(upload).Updates(map[string]interface{}{
+		"media_id":    media.ID,
+		"finished_at": now,
+	})
+	return media, nil
+}
+
+// TusDelete aborts an upload (termination extension)
+func (h *MediaHandler) TusDelete(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	upload, ok := h.loadTusUpload(c)
+	if !ok {
+		return
+	}
+
+	os.Remove(h.tusPartPath(upload.ID))
+	if err := h.db.Delete(upload).Error; err != nil {
+		h.tusError(c, http.StatusInternalServerError, "database_error", "Failed to delete upload")
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Status(http.StatusNoContent)
+}
+
+// GetTusUpload returns the upload state and, once finished, the created media (JSON helper for clients)
+func (h *MediaHandler) GetTusUpload(c *gin.Context) {
+	upload, ok := h.loadTusUpload(c)
+	if !ok {
+		return
+	}
+
+	response := gin.H{"upload": upload}
+	if upload.MediaID != nil {
+		var media models.Media
+		if err := h.db.Preload("Variants").First(&media, *upload.MediaID).Error; err == nil {
+			response["media"] = media
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CleanupExpiredUploads removes partial uploads past their expiration date,
+// finished upload records older than the TTL and stray files in the temp area
+func (h *MediaHandler) CleanupExpiredUploads() (int, error) {
+	now := time.Now()
+
+	var expired []models.MediaUpload
+	if err := h.db.Where("(finished_at IS NULL AND expires_at < ?) OR finished_at < ?", now, now.Add(-tusUploadTTL)).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	for _, upload := range expired {
+		os.Remove(h.tusPartPath(upload.ID))
+		h.db.Delete(&upload)
+		tusLocks.Delete(upload.ID)
+	}
+
+	// Files left behind without a record (crash between file and row creation...)
+	entries, err := os.ReadDir(h.storageService.TempDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return len(expired), err
+	}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".part")
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < tusUploadTTL {
+			continue
+		}
+		var count int64
+		h.db.Model(&models.MediaUpload{}).Where("id = ?", id).Count(&count)
+		if count == 0 {
+			os.Remove(filepath.Join(h.storageService.TempDir(), entry.Name()))
+		}
+	}
+
+	return len(expired), nil
+}
+
+// RunUploadCleanup periodically purges expired resumable uploads (blocking, run in a goroutine)
+func (h *MediaHandler) RunUploadCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := h.CleanupExpiredUploads()
+		if err != nil {
+			log.Printf("[tus] Error while cleaning up expired uploads: %v", err)
+		} else if count > 0 {
+			log.Printf("[tus] %d expired upload(s) removed", count)
+		}
+	}
+}
+
+// loadTusUpload fetches the upload from the URL, restricted to its owner
+func (h *MediaHandler) loadTusUpload(c *gin.Context) (*models.MediaUpload, bool) {
+	var upload models.MediaUpload
+	err := h.db.Where("id = ? AND user_id = ?", c.Param("upload_id"), c.GetUint("user_id")).First(&upload).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.tusError(c, http.StatusNotFound, "not_found", "Upload not found")
+		} else {
+			h.tusError(c, http.StatusInternalServerError, "database_error", "Failed to fetch upload")
+		}
+		return nil, false
+	}
+
+	if upload.FinishedAt == nil && time.Now().After(upload.ExpiresAt) {
+		h.tusError(c, http.StatusGone, "upload_expired", "Upload expired")
+		return nil, false
+	}
+	return &upload, true
+}
+
+func (h *MediaHandler) tusPartPath(id string) string {
+	return filepath.Join(h.storageService.TempDir(), id+".part")
+}
+
+// checkTusResumable rejects requests from clients speaking another protocol version
+func (h *MediaHandler) checkTusResumable(c *gin.Context) bool {
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		h.tusError(c, http.StatusPreconditionFailed, "unsupported_version", "Unsupported Tus-Resumable version")
+		return false
+	}
+	return true
+}
+
+func (h *MediaHandler) tusError(c *gin.Context, status int, code, message string) {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		return
+	}
+	c.JSON(status, models.ErrorResponse{
+		Error:   code,
+		Message: message,
+		Code:    status,
+	})
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2"
+func parseTusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if parts[0] == "" {
+			continue
+		}
+		value := ""
+		if len(parts) == 2 {
+			if decoded, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
+				value = string(decoded)
+			}
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata
+}
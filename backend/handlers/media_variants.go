@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"airboard/models"
+	"airboard/services"
+
+	"gorm.io/gorm"
+)
+
+// attachNewsCoverVariants renseigne les variantes redimensionnées des images de couverture
+func attachNewsCoverVariants(db *gorm.DB, news []models.News) {
+	urls := make([]string, 0, len(news))
+	for _, n := range news {
+		urls = append(urls, n.CoverImage)
+	}
+	variants := services.LoadVariantsByURL(db, urls)
+	for i := range news {
+		news[i].CoverImageVariants = variants[news[i].CoverImage]
+	}
+}
+
+// attachEventCoverVariants renseigne les variantes redimensionnées des images de couverture
+func attachEventCoverVariants(db *gorm.DB, events []models.Event) {
+	urls := make([]string, 0, len(events))
+	for _, e := range events {
+		urls = append(urls, e.CoverImage)
+	}
+	variants := services.LoadVariantsByURL(db, urls)
+	for i := range events {
+		events[i].CoverImageVariants = variants[events[i].CoverImage]
+	}
+}
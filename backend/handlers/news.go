@@ -21,10 +21,11 @@ type NewsHandler struct {
 	db                  *gorm.DB
 	config              *config.Config
 	gamificationService *services.GamificationService
+	references          *services.MediaReferenceService
 }
 
 func NewNewsHandler(db *gorm.DB, cfg *config.Config, gs *services.GamificationService) *NewsHandler {
-	return &NewsHandler{db: db, config: cfg, gamificationService: gs}
+	return &NewsHandler{db: db, config: cfg, gamificationService: gs, references: services.NewMediaReferenceService(db)}
 }
 
 // GetNews - Liste des news (accessible à tous les utilisateurs connectés)
@@ -231,6 +232,8 @@ func (h *NewsHandler) GetNews(c *gin.Context) {
 		totalPages++
 	}
 
+	attachNewsCoverVariants(h.db, news)
+
 	log.Printf("[DEBUG GetNews] Returning %d news (total=%d, page=%d, totalPages=%d)", len(news), total, page, totalPages)
 	for i, n := range news {
 		log.Printf("[DEBUG GetNews] News[%d]: ID=%d, Title=%s, DeletedAt=%v", i, n.ID, n.Title, n.DeletedAt)
@@ -265,6 +268,8 @@ func (h *NewsHandler) GetNewsBySlug(c *gin.Context) {
 		return
 	}
 
+	news.CoverImageVariants = services.LoadVariantsByURL(h.db, []string{news.CoverImage})[news.CoverImage]
+
 	// Vérifier les permissions selon le rôle
 	userRole := c.GetString("role")
 	userID := c.GetUint("user_id")
@@ -468,6 +473,10 @@ func (h *NewsHandler) CreateNews(c *gin.Context) {
 		}()
 	}
 
+	if err := h.references.SyncNews(&news); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des médias de la news %d: %v", news.ID, err)
+	}
+
 	// Award Contributor XP
 	go h.gamificationService.AwardXP(userID, 100, "news_publish", fmt.Sprintf("{\"news_id\": %d}", news.ID))
 
@@ -608,6 +617,10 @@ func (h *NewsHandler) UpdateNews(c *gin.Context) {
 		Preload("TargetGroups").
 		First(&news, news.ID)
 
+	if err := h.references.SyncNews(&news); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des médias de la news %d: %v", news.ID, err)
+	}
+
 	c.JSON(http.StatusOK, news)
 }
 
@@ -675,6 +688,10 @@ func (h *NewsHandler) DeleteNews(c *gin.Context) {
 		return
 	}
 
+	if err := h.references.RemoveEntity(services.MediaRefNews, news.ID); err != nil {
+		log.Printf("[Media] Erreur lors de la suppression des références de la news %d: %v", news.ID, err)
+	}
+
 	log.Printf("[DEBUG DeleteNews] Successfully deleted news ID=%d", news.ID)
 	c.JSON(http.StatusOK, gin.H{"message": "News deleted successfully"})
 }
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"airboard/config"
 	"airboard/middleware"
 	"airboard/models"
+	"airboard/services"
 	"airboard/utils"
 	"encoding/json"
 	"fmt"
@@ -10,6 +12,8 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,15 +23,19 @@ import (
 
 type OAuthHandler struct {
 	db             *gorm.DB
+	config         *config.Config
 	authMiddleware *middleware.AuthMiddleware
 	stateManager   *utils.OAuthStateManager
+	oidc           *services.OIDCService
 }
 
-func NewOAuthHandler(db *gorm.DB, authMiddleware *middleware.AuthMiddleware) *OAuthHandler {
+func NewOAuthHandler(db *gorm.DB, cfg *config.Config, authMiddleware *middleware.AuthMiddleware, stateStore utils.StateStore) *OAuthHandler {
 	return &OAuthHandler{
 		db:             db,
+		config:         cfg,
 		authMiddleware: authMiddleware,
-		stateManager:   utils.NewOAuthStateManager(),
+		stateManager:   utils.NewOAuthStateManager(stateStore),
+		oidc:           services.NewOIDCService(),
 	}
 }
 
@@ -103,18 +111,14 @@ func (h *OAuthHandler) UpdateProvider(c *gin.Context) {
 	}
 
 	// Mettre à jour les champs
-	provider.DisplayName = req.DisplayName
-	provider.Icon = req.Icon
-	provider.IsEnabled = req.IsEnabled
-	provider.ClientID = req.ClientID
-	if req.ClientSecret != "" {
-		provider.ClientSecret = req.ClientSecret
+	if err := h.applyProviderRequest(&provider, &req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "discovery_error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
 	}
-	provider.RedirectURI = req.RedirectURI
-	provider.AuthURL = req.AuthURL
-	provider.TokenURL = req.TokenURL
-	provider.UserInfoURL = req.UserInfoURL
-	provider.Scopes = req.Scopes
 
 	if err := h.db.Save(&provider).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -131,6 +135,133 @@ func (h *OAuthHandler) UpdateProvider(c *gin.Context) {
 	})
 }
 
+// CreateProvider ajoute un fournisseur OAuth / OpenID Connect générique (admin uniquement)
+func (h *OAuthHandler) CreateProvider(c *gin.Context) {
+	var req models.OAuthProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	// Le nom apparaît dans les URLs (/auth/oauth/:provider/...) et dans User.SSOProvider
+	req.ProviderName = strings.ToLower(strings.TrimSpace(req.ProviderName))
+	if !oauthProviderNamePattern.MatchString(req.ProviderName) || isReservedSSOProvider(req.ProviderName) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: "provider_name must contain only lowercase letters, digits and dashes",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var count int64
+	h.db.Model(&models.OAuthProvider{}).Where("provider_name = ?", req.ProviderName).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "conflict",
+			Message: "OAuth provider already exists",
+			Code:    http.StatusConflict,
+		})
+		return
+	}
+
+	provider := models.OAuthProvider{ProviderName: req.ProviderName}
+	if err := h.applyProviderRequest(&provider, &req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "discovery_error",
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	if provider.RedirectURI == "" {
+		provider.RedirectURI = h.config.Server.PublicURL + "/auth/oauth/" + provider.ProviderName + "/callback"
+	}
+	if provider.Icon == "" {
+		provider.Icon = "mdi:login"
+	}
+
+	if err := h.db.Create(&provider).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database_error",
+			Message: "Failed to create OAuth provider",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Message: "OAuth provider created successfully",
+		Data:    provider,
+	})
+}
+
+// applyProviderRequest copie la requête dans le fournisseur. Avec un issuer, la découverte
+// OpenID Connect est lancée pour le valider et renseigner les URLs laissées vides.
+func (h *OAuthHandler) applyProviderRequest(provider *models.OAuthProvider, req *models.OAuthProviderRequest) error {
+	provider.DisplayName = req.DisplayName
+	provider.Icon = req.Icon
+	provider.IsEnabled = req.IsEnabled
+	provider.ClientID = req.ClientID
+	if req.ClientSecret != "" {
+		provider.ClientSecret = req.ClientSecret
+	}
+	provider.RedirectURI = req.RedirectURI
+	provider.AuthURL = req.AuthURL
+	provider.TokenURL = req.TokenURL
+	provider.UserInfoURL = req.UserInfoURL
+	provider.Scopes = req.Scopes
+	provider.IssuerURL = strings.TrimSuffix(strings.TrimSpace(req.IssuerURL), "/")
+	provider.JWKSURL = req.JWKSURL
+	provider.EmailClaim = req.EmailClaim
+	provider.FirstNameClaim = req.FirstNameClaim
+	provider.LastNameClaim = req.LastNameClaim
+	provider.NameClaim = req.NameClaim
+	provider.GroupsClaim = req.GroupsClaim
+	provider.AdminGroups = req.AdminGroups
+
+	if provider.IssuerURL == "" {
+		return nil
+	}
+	if provider.Scopes == "" {
+		provider.Scopes = "openid email profile"
+	}
+	return h.resolveEndpoints(provider, true)
+}
+
+// resolveEndpoints complète les URLs vides d'un fournisseur OpenID Connect depuis la découverte.
+// Sans force, la découverte n'est lancée que s'il manque une URL (fournisseur initialisé avec
+// son seul issuer).
+func (h *OAuthHandler) resolveEndpoints(provider *models.OAuthProvider, force bool) error {
+	if provider.IssuerURL == "" {
+		return nil
+	}
+	if !force && provider.AuthURL != "" && provider.TokenURL != "" && provider.JWKSURL != "" {
+		return nil
+	}
+	discovery, err := h.oidc.Discover(provider.IssuerURL)
+	if err != nil {
+		return err
+	}
+	if provider.AuthURL == "" {
+		provider.AuthURL = discovery.AuthorizationEndpoint
+	}
+	if provider.TokenURL == "" {
+		provider.TokenURL = discovery.TokenEndpoint
+	}
+	if provider.UserInfoURL == "" {
+		provider.UserInfoURL = discovery.UserinfoEndpoint
+	}
+	if provider.JWKSURL == "" {
+		provider.JWKSURL = discovery.JWKSURI
+	}
+	return nil
+}
+
 // InitiateOAuth démarre le flux OAuth pour un fournisseur avec protection CSRF renforcée
 func (h *OAuthHandler) InitiateOAuth(c *gin.Context) {
 	providerName := c.Param("provider")
@@ -145,8 +276,18 @@ func (h *OAuthHandler) InitiateOAuth(c *gin.Context) {
 		return
 	}
 
-	// Générer un state et nonce sécurisés
-	state, nonce, err := h.stateManager.GenerateState(providerName, provider.ClientID)
+	if err := h.resolveEndpoints(&provider, false); err != nil {
+		log.Printf("[OAuth] OIDC discovery failed for %s: %v", providerName, err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "discovery_error",
+			Message: "Failed to load OpenID Connect configuration",
+			Code:    http.StatusBadGateway,
+		})
+		return
+	}
+
+	// Générer un state, un nonce et un code verifier PKCE sécurisés
+	state, oauthState, err := h.stateManager.GenerateState(providerName, provider.ClientID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "state_generation_error",
@@ -156,13 +297,21 @@ func (h *OAuthHandler) InitiateOAuth(c *gin.Context) {
 		return
 	}
 
-	// Construire l'URL d'autorisation sécurisée
+	// Un fournisseur OpenID Connect ne délivre d'id_token qu'avec le scope openid
+	scopes := provider.Scopes
+	if provider.IssuerURL != "" && !containsScope(scopes, "openid") {
+		scopes = strings.TrimSpace("openid " + scopes)
+	}
+
+	// Construire l'URL d'autorisation sécurisée (PKCE S256)
 	authURL, err := utils.SecureOAuthURL(provider.AuthURL, map[string]string{
-		"client_id":     provider.ClientID,
-		"redirect_uri":  provider.RedirectURI,
-		"response_type": "code",
-		"scope":         provider.Scopes,
-	}, state, nonce)
+		"client_id":             provider.ClientID,
+		"redirect_uri":          provider.RedirectURI,
+		"response_type":         "code",
+		"scope":                 scopes,
+		"code_challenge":        utils.PKCEChallenge(oauthState.CodeVerifier),
+		"code_challenge_method": "S256",
+	}, state, oauthState.Nonce)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -175,8 +324,8 @@ func (h *OAuthHandler) InitiateOAuth(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"auth_url": authURL,
-		"state":    state, // Pour debug, à retirer en production
-		"nonce":    nonce, // Pour debug, à retirer en production
+		"state":    state,            // Pour debug, à retirer en production
+		"nonce":    oauthState.Nonce, // Pour debug, à retirer en production
 	})
 }
 
@@ -233,62 +382,41 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 		return
 	}
 
-	// Valider le state et nonce pour protection CSRF (optionnel selon le provider)
-	var validatedNonce string
-	var err error
-	
-	if state != "" && nonce != "" {
-		// Validation complète si state et nonce sont présents
-		log.Printf("[OAuth] Validating state and nonce for %s", providerName)
-		
-		validatedNonce, err = h.stateManager.ValidateState(state, providerName, provider.ClientID)
-		if err != nil {
-			log.Printf("[OAuth] State validation failed: %v", err)
-			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Error:   "invalid_state",
-				Message: "Invalid or expired state parameter",
-				Code:    http.StatusForbidden,
-			})
-			return
-		}
-
-		// Valider le nonce
-		if err := h.stateManager.ValidateNonce(state, nonce); err != nil {
-			log.Printf("[OAuth] Nonce validation failed: %v", err)
-			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Error:   "invalid_nonce",
-				Message: "Invalid nonce parameter",
-				Code:    http.StatusForbidden,
-			})
-			return
-		}
+	// Valider le state (protection CSRF) : il porte aussi le nonce et le code verifier PKCE,
+	// un callback sans state ne peut donc pas aboutir
+	oauthState, err := h.stateManager.ValidateState(state, providerName, provider.ClientID)
+	if err != nil {
+		log.Printf("[OAuth] State validation failed: %v", err)
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "invalid_state",
+			Message: "Invalid or expired state parameter",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
 
-		// Vérifier que les nonces correspondent
-		if validatedNonce != nonce {
-			log.Printf("[OAuth] Nonce mismatch: expected %s, got %s", validatedNonce, nonce)
-			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Error:   "nonce_mismatch",
-				Message: "Nonce validation failed",
-				Code:    http.StatusForbidden,
-			})
-			return
-		}
-		
-		log.Printf("[OAuth] State and nonce validation successful for %s", providerName)
-	} else {
-		// Pour certains providers (comme Microsoft), on peut continuer sans state/nonce
-		// ATTENTION: Ceci réduit la sécurité CSRF, mais permet l'authentification
-		log.Printf("[OAuth] No state/nonce provided for %s, proceeding without CSRF validation", providerName)
-		
-		// Optionnel: Valider que le provider supporte l'authentification sans state
-		if providerName == "microsoft" {
-			log.Printf("[OAuth] Microsoft provider detected, allowing authentication without state")
-		} else {
-			log.Printf("[OAuth] Warning: Authentication without state for provider %s", providerName)
-		}
+	// Le nonce renvoyé par le client, s'il est fourni, doit correspondre à celui du state
+	if nonce != "" && nonce != oauthState.Nonce {
+		log.Printf("[OAuth] Nonce mismatch for %s", providerName)
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "nonce_mismatch",
+			Message: "Nonce validation failed",
+			Code:    http.StatusForbidden,
+		})
+		return
 	}
 
-	log.Printf("[OAuth] State and nonce validation successful")
+	log.Printf("[OAuth] State validation successful for %s", providerName)
+
+	if err := h.resolveEndpoints(&provider, false); err != nil {
+		log.Printf("[OAuth] OIDC discovery failed for %s: %v", providerName, err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "discovery_error",
+			Message: "Failed to load OpenID Connect configuration",
+			Code:    http.StatusBadGateway,
+		})
+		return
+	}
 
 	// Valider la callback URL pour sécurité supplémentaire
 	if err := utils.ValidateOAuthCallbackURL(provider.RedirectURI, c.Request.Host); err != nil {
@@ -308,7 +436,7 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 
 	// Échanger le code contre un token
 	log.Printf("[OAuth] Exchanging code for token with %s...", provider.ProviderName)
-	token, err := h.exchangeCodeForToken(provider, code)
+	tokens, err := h.exchangeCodeForToken(provider, code, oauthState.CodeVerifier)
 	if err != nil {
 		log.Printf("[OAuth] ❌ Error exchanging code for token: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -320,23 +448,86 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 	}
 	log.Printf("[OAuth] ✅ Token exchange successful")
 
+	// Fournisseur OpenID Connect : l'identité vient de l'id_token, dont la signature et les claims
+	// sont vérifiés ; la réponse userinfo ne fait que la compléter
+	claims := map[string]interface{}{}
+	if provider.IssuerURL != "" {
+		if tokens.IDToken == "" {
+			log.Printf("[OAuth] ❌ No id_token returned by %s", provider.ProviderName)
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "invalid_id_token",
+				Message: "Missing id_token",
+				Code:    http.StatusUnauthorized,
+			})
+			return
+		}
+		// Le claim iss est comparé à l'issuer exact annoncé par la découverte (avec son éventuel "/" final)
+		discovery, err := h.oidc.Discover(provider.IssuerURL)
+		if err != nil {
+			log.Printf("[OAuth] OIDC discovery failed for %s: %v", provider.ProviderName, err)
+			c.JSON(http.StatusBadGateway, models.ErrorResponse{
+				Error:   "discovery_error",
+				Message: "Failed to load OpenID Connect configuration",
+				Code:    http.StatusBadGateway,
+			})
+			return
+		}
+		idClaims, err := h.oidc.VerifyIDToken(tokens.IDToken, discovery.Issuer, provider.JWKSURL, provider.ClientID, oauthState.Nonce)
+		if err != nil {
+			log.Printf("[OAuth] ❌ id_token rejected for %s: %v", provider.ProviderName, err)
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "invalid_id_token",
+				Message: "ID token validation failed",
+				Code:    http.StatusUnauthorized,
+			})
+			return
+		}
+		claims = idClaims
+	}
+
 	// Récupérer les informations utilisateur
-	log.Printf("[OAuth] Fetching user info from %s...", provider.ProviderName)
-	userInfo, err := h.getUserInfo(provider, token)
+	if provider.UserInfoURL != "" {
+		log.Printf("[OAuth] Fetching user info from %s...", provider.ProviderName)
+		userInfo, err := h.getUserInfo(provider, tokens.AccessToken)
+		if err != nil {
+			log.Printf("[OAuth] ❌ Error getting user info: %v", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "oauth_error",
+				Message: "Failed to get user information",
+				Code:    http.StatusInternalServerError,
+			})
+			return
+		}
+		// La réponse userinfo doit concerner le sujet de l'id_token (OpenID Connect Core 5.3.2)
+		if sub, ok := claims["sub"]; ok && userInfo["sub"] != nil && userInfo["sub"] != sub {
+			log.Printf("[OAuth] ❌ userinfo subject mismatch for %s", provider.ProviderName)
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "invalid_id_token",
+				Message: "User info subject mismatch",
+				Code:    http.StatusUnauthorized,
+			})
+			return
+		}
+		for key, value := range userInfo {
+			if _, reserved := idTokenOnlyClaims[key]; !reserved {
+				claims[key] = value
+			}
+		}
+	}
+
+	// Créer ou récupérer l'utilisateur
+	identity, err := mapOAuthClaims(&provider, claims)
 	if err != nil {
-		log.Printf("[OAuth] ❌ Error getting user info: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		log.Printf("[OAuth] ❌ Invalid identity from %s: %v", provider.ProviderName, err)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "oauth_error",
-			Message: "Failed to get user information",
-			Code:    http.StatusInternalServerError,
+			Message: "Missing or unverified user information",
+			Code:    http.StatusUnauthorized,
 		})
 		return
 	}
-	log.Printf("[OAuth] ✅ User info retrieved: %v", userInfo["mail"])
-
-	// Créer ou récupérer l'utilisateur
 	log.Printf("[OAuth] Finding or creating user...")
-	user, err := h.findOrCreateOAuthUser(provider.ProviderName, userInfo)
+	user, err := h.findOrCreateOAuthUser(&provider, identity)
 	if err != nil {
 		log.Printf("[OAuth] ❌ Error finding or creating user: %v", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -348,6 +539,16 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 	}
 	log.Printf("[OAuth] ✅ User found/created: %s (%s)", user.Username, user.Email)
 
+	// Un compte désactivé (par un administrateur ou par l'IdP via SCIM) ne peut pas se connecter
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "account_disabled",
+			Message: "This account has been disabled",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
+
 	// Mettre à jour la date de dernière connexion
 	now := time.Now()
 	if err := h.db.Model(&user).Update("last_login", now).Error; err != nil {
@@ -356,7 +557,7 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 	}
 
 	// Générer les tokens JWT
-	jwtToken, err := h.authMiddleware.GenerateToken(&user)
+	jwtToken, refreshToken, err := h.authMiddleware.IssueTokens(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "token_error",
@@ -366,16 +567,6 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 		return
 	}
 
-	refreshToken, err := h.authMiddleware.GenerateRefreshToken(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "token_error",
-			Message: "Failed to generate refresh token",
-			Code:    http.StatusInternalServerError,
-		})
-		return
-	}
-
 	// Recharger l'utilisateur avec les groupes et les groupes administrés
 	if err := h.db.Preload("Groups").Preload("AdminOfGroups").First(&user, user.ID).Error; err != nil {
 		log.Printf("Error loading user groups: %v", err)
@@ -397,6 +588,9 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 
 	user.Password = ""
 
+	// Cookie d'accès aux fichiers /uploads (balises <img>, <video>...)
+	h.authMiddleware.SetMediaCookie(c, jwtToken)
+
 	c.JSON(http.StatusOK, models.LoginResponse{
 		Token:        jwtToken,
 		RefreshToken: refreshToken,
@@ -404,18 +598,25 @@ func (h *OAuthHandler) OAuthCallback(c *gin.Context) {
 	})
 }
 
-// exchangeCodeForToken échange le code d'autorisation contre un token d'accès
-func (h *OAuthHandler) exchangeCodeForToken(provider models.OAuthProvider, code string) (string, error) {
+// oauthTokens contient les tokens retournés par l'échange du code
+type oauthTokens struct {
+	AccessToken string
+	IDToken     string
+}
+
+// exchangeCodeForToken échange le code d'autorisation (et le code verifier PKCE) contre les tokens
+func (h *OAuthHandler) exchangeCodeForToken(provider models.OAuthProvider, code, codeVerifier string) (*oauthTokens, error) {
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", code)
 	data.Set("client_id", provider.ClientID)
 	data.Set("client_secret", provider.ClientSecret)
 	data.Set("redirect_uri", provider.RedirectURI)
+	data.Set("code_verifier", codeVerifier)
 
 	req, err := http.NewRequest("POST", provider.TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -423,30 +624,31 @@ func (h *OAuthHandler) exchangeCodeForToken(provider models.OAuthProvider, code
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("token exchange failed: %s", string(body))
+		return nil, fmt.Errorf("token exchange failed: %s", string(body))
 	}
 
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	accessToken, ok := result["access_token"].(string)
 	if !ok {
-		return "", fmt.Errorf("no access_token in response")
+		return nil, fmt.Errorf("no access_token in response")
 	}
+	idToken, _ := result["id_token"].(string)
 
-	return accessToken, nil
+	return &oauthTokens{AccessToken: accessToken, IDToken: idToken}, nil
 }
 
 // getUserInfo récupère les informations utilisateur depuis le provider OAuth
@@ -482,30 +684,153 @@ func (h *OAuthHandler) getUserInfo(provider models.OAuthProvider, accessToken st
 	return userInfo, nil
 }
 
-// findOrCreateOAuthUser trouve ou crée un utilisateur OAuth
-func (h *OAuthHandler) findOrCreateOAuthUser(providerName string, userInfo map[string]interface{}) (models.User, error) {
-	var email, firstName, lastName, ssoID string
-
-	// Extraire les informations selon le provider
-	switch providerName {
-	case "google":
-		email, _ = userInfo["email"].(string)
-		firstName, _ = userInfo["given_name"].(string)
-		lastName, _ = userInfo["family_name"].(string)
-		ssoID, _ = userInfo["sub"].(string)
-	case "microsoft":
-		email, _ = userInfo["mail"].(string)
-		if email == "" {
-			email, _ = userInfo["userPrincipalName"].(string)
+// oauthIdentity est l'identité extraite des claims d'un fournisseur OAuth
+type oauthIdentity struct {
+	Email     string
+	FirstName string
+	LastName  string
+	SSOID     string
+	Groups    []string
+	HasGroups bool // Le fournisseur transmet des groupes (GroupsClaim configuré)
+}
+
+var (
+	// oauthProviderNamePattern valide le nom d'un fournisseur créé par un administrateur
+	oauthProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+	// reservedSSOProviders sont les valeurs de User.SSOProvider des autres sources d'identité
+	reservedSSOProviders = map[string]bool{"ldap": true, "saml": true, "scim": true}
+
+	// idTokenOnlyClaims ne sont jamais repris de la réponse userinfo
+	idTokenOnlyClaims = map[string]struct{}{
+		"iss": {}, "aud": {}, "exp": {}, "iat": {}, "nbf": {}, "nonce": {}, "azp": {}, "sub": {},
+	}
+)
+
+// mapOAuthClaims extrait l'identité selon le mapping du fournisseur. Sans mapping, les claims
+// standards OpenID Connect sont utilisés (et ceux de Microsoft Graph pour le fournisseur microsoft).
+func mapOAuthClaims(provider *models.OAuthProvider, claims map[string]interface{}) (*oauthIdentity, error) {
+	emailClaims := []string{"email"}
+	firstNameClaims := []string{"given_name"}
+	lastNameClaims := []string{"family_name"}
+	subjectClaims := []string{"sub", "id"}
+	if provider.ProviderName == "microsoft" && provider.IssuerURL == "" {
+		emailClaims = []string{"mail", "userPrincipalName"}
+		firstNameClaims = []string{"givenName"}
+		lastNameClaims = []string{"surname"}
+		subjectClaims = []string{"id"}
+	}
+	if provider.EmailClaim != "" {
+		emailClaims = []string{provider.EmailClaim}
+	}
+	if provider.FirstNameClaim != "" {
+		firstNameClaims = []string{provider.FirstNameClaim}
+	}
+	if provider.LastNameClaim != "" {
+		lastNameClaims = []string{provider.LastNameClaim}
+	}
+
+	identity := &oauthIdentity{
+		Email:     strings.ToLower(strings.TrimSpace(firstClaim(claims, emailClaims))),
+		FirstName: firstClaim(claims, firstNameClaims),
+		LastName:  firstClaim(claims, lastNameClaims),
+		SSOID:     firstClaim(claims, subjectClaims),
+	}
+
+	// Nom complet découpé lorsque le fournisseur ne transmet ni prénom ni nom
+	if identity.FirstName == "" && identity.LastName == "" {
+		nameClaim := provider.NameClaim
+		if nameClaim == "" {
+			nameClaim = "name"
+		}
+		if name := strings.TrimSpace(firstClaim(claims, []string{nameClaim})); name != "" {
+			parts := strings.SplitN(name, " ", 2)
+			identity.FirstName = parts[0]
+			if len(parts) == 2 {
+				identity.LastName = parts[1]
+			}
+		}
+	}
+
+	if provider.GroupsClaim != "" {
+		identity.HasGroups = true
+		identity.Groups = claimStrings(lookupClaim(claims, provider.GroupsClaim))
+	}
+
+	if identity.Email == "" || identity.SSOID == "" {
+		return nil, fmt.Errorf("missing required user information")
+	}
+	// L'email sert à rattacher un compte existant : il doit avoir été vérifié par le fournisseur
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("email %s is not verified by the provider", identity.Email)
+	}
+	return identity, nil
+}
+
+// lookupClaim retourne la valeur d'un claim, éventuellement imbriqué (ex: "realm_access.roles")
+func lookupClaim(claims map[string]interface{}, path string) interface{} {
+	if value, ok := claims[path]; ok {
+		return value
+	}
+	var current interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
 		}
-		firstName, _ = userInfo["givenName"].(string)
-		lastName, _ = userInfo["surname"].(string)
-		ssoID, _ = userInfo["id"].(string)
+		current = object[part]
 	}
+	return current
+}
+
+// firstClaim retourne la première valeur non vide parmi les claims donnés
+func firstClaim(claims map[string]interface{}, paths []string) string {
+	for _, path := range paths {
+		switch value := lookupClaim(claims, path).(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// claimStrings convertit un claim de groupes (liste ou chaîne séparée par des virgules)
+func claimStrings(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
 
-	if email == "" || ssoID == "" {
-		return models.User{}, fmt.Errorf("missing required user information")
+func containsScope(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
 	}
+	return false
+}
+
+// findOrCreateOAuthUser trouve ou crée un utilisateur OAuth
+func (h *OAuthHandler) findOrCreateOAuthUser(provider *models.OAuthProvider, identity *oauthIdentity) (models.User, error) {
+	providerName := provider.ProviderName
+	email, firstName, lastName, ssoID := identity.Email, identity.FirstName, identity.LastName, identity.SSOID
 
 	// Chercher l'utilisateur existant par email d'abord, puis par SSO
 	var user models.User
@@ -579,11 +904,12 @@ func (h *OAuthHandler) findOrCreateOAuthUser(providerName string, userInfo map[s
 			log.Printf("[OAuth] Linking existing user %s to %s SSO", user.Email, providerName)
 		}
 
-		if user.FirstName != firstName && firstName != "" {
+		// Le profil des utilisateurs provisionnés par SCIM est géré par l'IdP
+		if user.FirstName != firstName && firstName != "" && !user.SCIMManaged {
 			user.FirstName = firstName
 			updated = true
 		}
-		if user.LastName != lastName && lastName != "" {
+		if user.LastName != lastName && lastName != "" && !user.SCIMManaged {
 			user.LastName = lastName
 			updated = true
 		}
@@ -594,7 +920,28 @@ func (h *OAuthHandler) findOrCreateOAuthUser(providerName string, userInfo map[s
 		log.Printf("[OAuth] Existing user logged in: %s (%s) via %s", user.Email, user.Username, providerName)
 	}
 
+	// Groupes (et rôle si des groupes admin sont configurés) transmis par le fournisseur
+	if identity.HasGroups && user.SSOProvider == providerName {
+		if err := services.NewOAuthMapper(h.db, h.config, provider).SyncGroups(&user, identity.Groups); err != nil {
+			return models.User{}, err
+		}
+	}
+
 	return user, nil
 }
 
 // generateRandomState function removed - replaced by OAuthStateManager
+
+// isReservedSSOProvider indique si le nom est déjà utilisé par une autre source d'identité
+// (LDAP, SAML, SCIM ou l'un des presets du SSO par headers)
+func isReservedSSOProvider(name string) bool {
+	if reservedSSOProviders[name] {
+		return true
+	}
+	for _, preset := range config.SSOHeaderPresets {
+		if preset.Provider == name {
+			return true
+		}
+	}
+	return false
+}
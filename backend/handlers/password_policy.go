@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// @Summary Politique de mots de passe
+// @Description Retourne les règles en vigueur pour l'affichage dans les formulaires
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/password/policy [get]
+func (h *AuthHandler) GetPasswordPolicy(c *gin.Context) {
+	policy := h.passwordPolicy.Policy()
+	c.JSON(http.StatusOK, gin.H{
+		"min_length":      policy.MinLength,
+		"max_length":      policy.MaxLength,
+		"require_upper":   policy.RequireUpper,
+		"require_lower":   policy.RequireLower,
+		"require_digit":   policy.RequireDigit,
+		"require_special": policy.RequireSpecial,
+		"check_breached":  policy.CheckBreached,
+		"history_count":   policy.HistoryCount,
+		"max_age_days":    int(policy.MaxAge.Hours() / 24),
+	})
+}
+
+// @Summary Changer un mot de passe expiré
+// @Description Termine une connexion dont le mot de passe a expiré (ou doit être changé) en définissant un nouveau mot de passe
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.ExpiredPasswordChangeRequest true "Challenge et nouveau mot de passe"
+// @Success 200 {object} models.LoginResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/password/expired [post]
+func (h *AuthHandler) ChangeExpiredPassword(c *gin.Context) {
+	var req models.ExpiredPasswordChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Challenge et nouveau mot de passe requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	challenge, user, ok := h.loadLoginChallenge(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+	if challenge.Purpose != services.ChallengePurposePassword {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Aucun changement de mot de passe en attente",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if err := h.passwordPolicy.Validate(user, req.NewPassword); err != nil {
+		writePasswordPolicyError(c, err)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), h.bcryptCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors du hashage du mot de passe",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		return h.passwordPolicy.Record(tx, user.ID, string(hashedPassword))
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la mise à jour du mot de passe",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	h.twoFactor.DeleteChallenge(challenge)
+	user.MustChangePassword = false
+	log.Printf("[Auth] Expired password changed for user %d from IP %s", user.ID, c.ClientIP())
+
+	// La connexion reprend là où elle s'était arrêtée : second facteur éventuel, puis tokens
+	if h.requireSecondFactor(c, user, http.StatusOK) {
+		return
+	}
+	h.completeLogin(c, user, nil)
+}
+
+// requirePasswordChange interrompt la connexion d'un compte dont le mot de passe doit être changé
+// et retourne le challenge à présenter avec le nouveau mot de passe
+func (h *AuthHandler) requirePasswordChange(c *gin.Context, user *models.User) bool {
+	required, reason := h.passwordPolicy.ChangeRequired(user)
+	if !required {
+		return false
+	}
+
+	token, err := h.twoFactor.CreateChallenge(user.ID, services.ChallengePurposePassword)
+	if err != nil {
+		log.Printf("[Auth] Erreur lors de la création du challenge de changement de mot de passe pour l'utilisateur %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la connexion",
+			Code:    http.StatusInternalServerError,
+		})
+		return true
+	}
+
+	c.JSON(http.StatusOK, models.PasswordChangeRequiredResponse{
+		PasswordChangeRequired: true,
+		Reason:                 reason,
+		ChallengeToken:         token,
+		ExpiresIn:              int(services.LoginChallengeTTL.Seconds()),
+	})
+	return true
+}
+
+// writePasswordPolicyError écrit la réponse d'un mot de passe refusé (400) ou d'une erreur interne
+func writePasswordPolicyError(c *gin.Context, err error) {
+	var policyErr *services.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Weak Password",
+			Message: policyErr.Reason,
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	log.Printf("[Auth] Erreur lors de la vérification de la politique de mots de passe: %v", err)
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "Internal Server Error",
+		Message: "Erreur lors de la vérification du mot de passe",
+		Code:    http.StatusInternalServerError,
+	})
+}
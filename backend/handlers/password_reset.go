@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Mot de passe oublié
+// @Description Envoie un lien de réinitialisation si un compte local correspond à l'email. La réponse est identique que le compte existe ou non.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetRequest true "Email du compte"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /auth/password/forgot [post]
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var req models.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Adresse email invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	// Envoi en arrière-plan : le temps de réponse ne doit pas dépendre de l'existence du compte
+	ip := c.ClientIP()
+	go func() {
+		if err := h.passwordReset.Request(req.Email, ip); err != nil {
+			log.Printf("[Auth] Erreur lors de la demande de réinitialisation de mot de passe: %v", err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Si un compte correspond à cette adresse, un email de réinitialisation a été envoyé",
+	})
+}
+
+// @Summary Réinitialiser le mot de passe
+// @Description Définit un nouveau mot de passe avec le token reçu par email et déconnecte toutes les sessions
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetConfirmRequest true "Token et nouveau mot de passe"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/password/reset [post]
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req models.PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Token et nouveau mot de passe requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	user, err := h.passwordReset.Confirm(req.Token, req.NewPassword, h.bcryptCost)
+	if err != nil {
+		var policyErr *services.PasswordPolicyError
+		switch {
+		case errors.Is(err, services.ErrInvalidPasswordResetToken):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Lien de réinitialisation invalide ou expiré",
+				Code:    http.StatusBadRequest,
+			})
+			return
+		case errors.As(err, &policyErr):
+			writePasswordPolicyError(c, err)
+			return
+		}
+		log.Printf("[Auth] Erreur lors de la réinitialisation du mot de passe: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la réinitialisation du mot de passe",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Password reset completed for user %d from IP %s, all sessions revoked", user.ID, c.ClientIP())
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Mot de passe réinitialisé. Vous pouvez vous connecter avec votre nouveau mot de passe",
+	})
+}
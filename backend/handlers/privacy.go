@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PrivacyHandler gère l'export des données personnelles et les demandes d'effacement (RGPD)
+type PrivacyHandler struct {
+	db      *gorm.DB
+	privacy *services.PersonalDataService
+}
+
+// NewPrivacyHandler crée une nouvelle instance de PrivacyHandler
+func NewPrivacyHandler(db *gorm.DB, storage services.StorageService) *PrivacyHandler {
+	return &PrivacyHandler{
+		db:      db,
+		privacy: services.NewPersonalDataService(db, storage),
+	}
+}
+
+// @Summary Exporter mes données personnelles
+// @Description Télécharge tout ce qui est rattaché au compte : profil, sessions, tokens, passkeys, messages, commentaires, votes, XP, clics et notifications
+// @Tags Privacy
+// @Produce application/zip
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "zip (par défaut) ou json"
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/privacy/export [get]
+func (h *PrivacyHandler) ExportPersonalData(c *gin.Context) {
+	// En mode "voir en tant que", l'administrateur ne peut pas télécharger les données de l'utilisateur
+	if c.GetUint("impersonation_id") != 0 {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "L'export des données n'est pas disponible en mode \"voir en tant que\"",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "zip")
+	if format != "zip" && format != "json" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Format invalide (zip ou json)",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	export, err := h.privacy.Export(c.GetUint("user_id"))
+	if err != nil {
+		log.Printf("[Privacy] Erreur lors de l'export des données de l'utilisateur %d: %v", c.GetUint("user_id"), err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de l'export des données personnelles",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("airboard-export-%s-%s.%s", export.Profile.Username, export.GeneratedAt.Format("2006-01-02"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	if format == "json" {
+		c.IndentedJSON(http.StatusOK, export)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	if err := export.WriteZip(c.Writer); err != nil {
+		log.Printf("[Privacy] Erreur lors de l'écriture de l'archive de l'utilisateur %d: %v", export.Profile.ID, err)
+	}
+}
+
+// @Summary Ma demande d'effacement
+// @Description Retourne la dernière demande d'effacement du compte (null si aucune)
+// @Tags Privacy
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.ErasureRequest
+// @Router /auth/privacy/erasure [get]
+func (h *PrivacyHandler) GetErasureRequest(c *gin.Context) {
+	request, err := h.privacy.LatestErasure(c.GetUint("user_id"))
+	if err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// @Summary Demander l'effacement de mon compte
+// @Description Enregistre une demande d'effacement à approuver par un administrateur. À l'approbation, les contenus rédigés sont anonymisés et les données personnelles supprimées définitivement.
+// @Tags Privacy
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ErasureRequestCreate false "Motif (optionnel)"
+// @Success 201 {object} models.ErasureRequest
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/privacy/erasure [post]
+func (h *PrivacyHandler) RequestErasure(c *gin.Context) {
+	var req models.ErasureRequestCreate
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Données invalides: " + err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	var user models.User
+	if err := h.db.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+
+	request, err := h.privacy.RequestErasure(&user, req.Reason)
+	if err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, request)
+}
+
+// @Summary Annuler ma demande d'effacement
+// @Tags Privacy
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/privacy/erasure [delete]
+func (h *PrivacyHandler) CancelErasure(c *gin.Context) {
+	if err := h.privacy.CancelErasure(c.GetUint("user_id")); err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Demande d'effacement annulée",
+	})
+}
+
+// @Summary Lister les demandes d'effacement
+// @Description Liste les demandes d'effacement des comptes, les plus récentes d'abord (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending, approved, rejected ou cancelled"
+// @Success 200 {array} models.ErasureRequest
+// @Router /admin/erasure-requests [get]
+func (h *PrivacyHandler) ListErasureRequests(c *gin.Context) {
+	requests, err := h.privacy.ListErasures(c.Query("status"))
+	if err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// @Summary Approuver une demande d'effacement
+// @Description Efface le compte : contenus rédigés anonymisés, données personnelles supprimées définitivement (admin uniquement)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la demande"
+// @Param request body models.ErasureRequestReview false "Note"
+// @Success 200 {object} models.ErasureRequest
+// @Failure 409 {object} models.ErrorResponse
+// @Router /admin/erasure-requests/{id}/approve [post]
+func (h *PrivacyHandler) ApproveErasureRequest(c *gin.Context) {
+	h.reviewErasure(c, h.privacy.ApproveErasure)
+}
+
+// @Summary Refuser une demande d'effacement
+// @Description Refuse la demande avec un motif visible par l'utilisateur (admin uniquement)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la demande"
+// @Param request body models.ErasureRequestReview false "Motif du refus"
+// @Success 200 {object} models.ErasureRequest
+// @Failure 409 {object} models.ErrorResponse
+// @Router /admin/erasure-requests/{id}/reject [post]
+func (h *PrivacyHandler) RejectErasureRequest(c *gin.Context) {
+	h.reviewErasure(c, h.privacy.RejectErasure)
+}
+
+func (h *PrivacyHandler) reviewErasure(c *gin.Context, review func(uint, *models.User, string) (*models.ErasureRequest, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "ID invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req models.ErasureRequestReview
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Données invalides: " + err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	var admin models.User
+	if err := h.db.First(&admin, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+
+	request, err := review(uint(id), &admin, req.Note)
+	if err != nil {
+		writeErasureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+func writeErasureError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Demande d'effacement non trouvée",
+			Code:    http.StatusNotFound,
+		})
+	case errors.Is(err, services.ErrErasureRequestExists):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Une demande d'effacement est déjà en attente",
+			Code:    http.StatusConflict,
+		})
+	case errors.Is(err, services.ErrErasureRequestNotPending):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Aucune demande d'effacement en attente",
+			Code:    http.StatusConflict,
+		})
+	case errors.Is(err, services.ErrErasureSelfApproval):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "Un administrateur ne peut pas approuver l'effacement de son propre compte",
+			Code:    http.StatusForbidden,
+		})
+	default:
+		log.Printf("[Privacy] Erreur: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors du traitement de la demande d'effacement",
+			Code:    http.StatusInternalServerError,
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Métadonnées SAML du fournisseur de service
+// @Description Métadonnées XML (entity ID, ACS, certificat) à déclarer auprès de l'IdP
+// @Tags Auth
+// @Produce xml
+// @Success 200 {string} string
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/saml/metadata [get]
+func (h *AuthHandler) GetSAMLMetadata(c *gin.Context) {
+	if !h.requireSAML(c) {
+		return
+	}
+
+	metadata, err := h.saml.Metadata()
+	if err != nil {
+		log.Printf("[SAML] Erreur lors de la génération des métadonnées: %v", err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Bad Gateway",
+			Message: "Configuration SAML indisponible",
+			Code:    http.StatusBadGateway,
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// @Summary Connexion SAML
+// @Description Redirige le navigateur vers l'IdP (HTTP-Redirect) ou lui soumet la requête d'authentification (HTTP-POST)
+// @Tags Auth
+// @Success 302
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/saml/login [get]
+func (h *AuthHandler) SAMLLogin(c *gin.Context) {
+	if !h.requireSAML(c) {
+		return
+	}
+
+	request, err := h.saml.StartLogin()
+	if err != nil {
+		log.Printf("[SAML] Erreur lors de la création de la requête d'authentification: %v", err)
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "Bad Gateway",
+			Message: "Configuration SAML indisponible",
+			Code:    http.StatusBadGateway,
+		})
+		return
+	}
+
+	if request.RedirectURL != "" {
+		c.Redirect(http.StatusFound, request.RedirectURL)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", request.PostForm)
+}
+
+// @Summary Assertion Consumer Service SAML
+// @Description Reçoit la réponse de l'IdP (HTTP-POST), provisionne l'utilisateur et renvoie le navigateur vers l'application avec un challenge à échanger contre les tokens
+// @Tags Auth
+// @Accept x-www-form-urlencoded
+// @Success 303
+// @Router /auth/saml/acs [post]
+func (h *AuthHandler) SAMLAssertionConsumer(c *gin.Context) {
+	if !h.requireSAML(c) {
+		return
+	}
+
+	user, err := h.saml.HandleResponse(c.PostForm("SAMLResponse"), c.PostForm("RelayState"))
+	if err != nil {
+		reason := "invalid_response"
+		switch {
+		case errors.Is(err, services.ErrSAMLAccountConflict):
+			reason = "account_conflict"
+		case errors.Is(err, services.ErrSAMLAccountDisabled):
+			reason = "account_disabled"
+		case errors.Is(err, services.ErrSAMLMissingEmail):
+			reason = "missing_email"
+		}
+		log.Printf("[SAML] Connexion refusée depuis l'IP %s: %v", c.ClientIP(), err)
+		c.Redirect(http.StatusSeeOther, h.publicURL+"/login?sso_error="+reason)
+		return
+	}
+
+	// Les tokens ne transitent pas par l'URL : le navigateur reçoit un challenge à usage unique
+	token, err := h.twoFactor.CreateChallenge(user.ID, services.ChallengePurposeSAML)
+	if err != nil {
+		log.Printf("[SAML] Erreur lors de la création du challenge pour l'utilisateur %d: %v", user.ID, err)
+		c.Redirect(http.StatusSeeOther, h.publicURL+"/login?sso_error=internal")
+		return
+	}
+	log.Printf("[SAML] Assertion acceptée pour %s depuis l'IP %s", user.Email, c.ClientIP())
+	c.Redirect(http.StatusSeeOther, h.publicURL+"/auth/saml/callback#challenge_token="+url.QueryEscape(token))
+}
+
+// @Summary Terminer la connexion SAML
+// @Description Échange le challenge reçu au retour de l'IdP contre les tokens de session
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.SAMLCompleteRequest true "Challenge"
+// @Success 200 {object} models.LoginResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/saml/complete [post]
+func (h *AuthHandler) CompleteSAMLLogin(c *gin.Context) {
+	var req models.SAMLCompleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Challenge requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	challenge, user, ok := h.loadLoginChallenge(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+	if challenge.Purpose != services.ChallengePurposeSAML {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Aucune connexion SAML en attente",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	h.twoFactor.DeleteChallenge(challenge)
+
+	h.completeLogin(c, user, nil)
+}
+
+// requireSAML répond 404 lorsque la connexion SAML n'est pas activée
+func (h *AuthHandler) requireSAML(c *gin.Context) bool {
+	if h.saml.Enabled() {
+		return true
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "Not Found",
+		Message: "La connexion SAML n'est pas activée",
+		Code:    http.StatusNotFound,
+	})
+	return false
+}
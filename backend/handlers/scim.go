@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"airboard/config"
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SCIMHandler expose le provisionnement SCIM 2.0 (RFC 7644) des utilisateurs et des groupes.
+// Les routes sont protégées par middleware.RequireSCIM (token d'accès avec le scope admin:scim).
+type SCIMHandler struct {
+	scim    *services.SCIMService
+	baseURL string
+}
+
+// NewSCIMHandler crée une nouvelle instance du handler SCIM
+func NewSCIMHandler(db *gorm.DB, cfg *config.Config) *SCIMHandler {
+	return &SCIMHandler{
+		scim:    services.NewSCIMService(db, cfg),
+		baseURL: strings.TrimSuffix(cfg.Server.PublicURL, "/") + "/api/v1/scim/v2",
+	}
+}
+
+// GetServiceProviderConfig décrit les fonctionnalités SCIM prises en charge
+func (h *SCIMHandler) GetServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, gin.H{
+		"schemas":          []string{models.SCIMSchemaSPConfig},
+		"documentationUri": "https://github.com/ARRATQ/AirBoard",
+		"patch":            gin.H{"supported": true},
+		"bulk":             gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           gin.H{"supported": true, "maxResults": services.SCIMMaxResults},
+		"changePassword":   gin.H{"supported": false},
+		"sort":             gin.H{"supported": false},
+		"etag":             gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Token d'accès personnel d'un administrateur avec le scope admin:scim",
+			"primary":     true,
+		}},
+		"meta": gin.H{"resourceType": "ServiceProviderConfig", "location": h.baseURL + "/ServiceProviderConfig"},
+	})
+}
+
+// GetResourceTypes liste les types de ressources exposés
+func (h *SCIMHandler) GetResourceTypes(c *gin.Context) {
+	resourceTypes := []interface{}{
+		gin.H{
+			"schemas":  []string{models.SCIMSchemaResourceType},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   models.SCIMSchemaUser,
+			"schemaExtensions": []gin.H{
+				{"schema": models.SCIMSchemaEnterpriseUser, "required": false},
+			},
+			"meta": gin.H{"resourceType": "ResourceType", "location": h.baseURL + "/ResourceTypes/User"},
+		},
+		gin.H{
+			"schemas":  []string{models.SCIMSchemaResourceType},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   models.SCIMSchemaGroup,
+			"meta":     gin.H{"resourceType": "ResourceType", "location": h.baseURL + "/ResourceTypes/Group"},
+		},
+	}
+	writeSCIM(c, http.StatusOK, models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: int64(len(resourceTypes)),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    resourceTypes,
+	})
+}
+
+// ===== Users =====
+
+// @Summary Lister les utilisateurs (SCIM)
+// @Description Recherche paginée des utilisateurs (filter, startIndex, count)
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param filter query string false "Filtre SCIM (ex: userName eq \"jdoe\")"
+// @Param startIndex query int false "Index du premier résultat (1 par défaut)"
+// @Param count query int false "Nombre de résultats (100 par défaut, 200 maximum)"
+// @Success 200 {object} models.SCIMListResponse
+// @Router /scim/v2/Users [get]
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex, count := scimPagination(c)
+	users, total, err := h.scim.ListUsers(c.Query("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	resources := make([]interface{}, 0, len(users))
+	for i := range users {
+		resources = append(resources, h.scim.UserResource(&users[i]))
+	}
+	writeSCIMList(c, total, startIndex, resources)
+}
+
+// @Summary Récupérer un utilisateur (SCIM)
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID de l'utilisateur"
+// @Success 200 {object} models.SCIMUser
+// @Router /scim/v2/Users/{id} [get]
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, err := h.scim.GetUser(c.Param("id"))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, h.scim.UserResource(user))
+}
+
+// @Summary Provisionner un utilisateur (SCIM)
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user body models.SCIMUser true "Ressource User"
+// @Success 201 {object} models.SCIMUser
+// @Failure 409 {object} models.SCIMError
+// @Router /scim/v2/Users [post]
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var resource models.SCIMUser
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		writeSCIM(c, http.StatusBadRequest, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid User resource"))
+		return
+	}
+	user, err := h.scim.CreateUser(&resource)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	created := h.scim.UserResource(user)
+	c.Header("Location", created.Meta.Location)
+	writeSCIM(c, http.StatusCreated, created)
+}
+
+// @Summary Remplacer un utilisateur (SCIM)
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID de l'utilisateur"
+// @Param user body models.SCIMUser true "Ressource User"
+// @Success 200 {object} models.SCIMUser
+// @Router /scim/v2/Users/{id} [put]
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var resource models.SCIMUser
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		writeSCIM(c, http.StatusBadRequest, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid User resource"))
+		return
+	}
+	user, err := h.scim.ReplaceUser(c.Param("id"), &resource)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, h.scim.UserResource(user))
+}
+
+// @Summary Modifier un utilisateur (SCIM)
+// @Description Opérations add, remove et replace (active=false désactive le compte et révoque ses sessions)
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID de l'utilisateur"
+// @Param patch body models.SCIMPatchRequest true "Opérations PATCH"
+// @Success 200 {object} models.SCIMUser
+// @Router /scim/v2/Users/{id} [patch]
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	operations, ok := bindSCIMPatch(c)
+	if !ok {
+		return
+	}
+	user, err := h.scim.PatchUser(c.Param("id"), operations)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, h.scim.UserResource(user))
+}
+
+// @Summary Supprimer un utilisateur (SCIM)
+// @Tags SCIM
+// @Security BearerAuth
+// @Param id path string true "ID de l'utilisateur"
+// @Success 204
+// @Router /scim/v2/Users/{id} [delete]
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	if err := h.scim.DeleteUser(c.Param("id")); err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ===== Groups =====
+
+// @Summary Lister les groupes (SCIM)
+// @Description Recherche paginée des groupes (excludedAttributes=members pour ne pas charger les membres)
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param filter query string false "Filtre SCIM (ex: displayName eq \"Marketing\")"
+// @Param startIndex query int false "Index du premier résultat (1 par défaut)"
+// @Param count query int false "Nombre de résultats (100 par défaut, 200 maximum)"
+// @Success 200 {object} models.SCIMListResponse
+// @Router /scim/v2/Groups [get]
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	startIndex, count := scimPagination(c)
+	groups, total, err := h.scim.ListGroups(c.Query("filter"), startIndex, count, scimIncludesMembers(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	resources := make([]interface{}, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, h.scim.GroupResource(&groups[i]))
+	}
+	writeSCIMList(c, total, startIndex, resources)
+}
+
+// @Summary Récupérer un groupe (SCIM)
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID du groupe"
+// @Success 200 {object} models.SCIMGroup
+// @Router /scim/v2/Groups/{id} [get]
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	group, err := h.scim.GetGroup(c.Param("id"), scimIncludesMembers(c))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, h.scim.GroupResource(group))
+}
+
+// @Summary Créer un groupe (SCIM)
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group body models.SCIMGroup true "Ressource Group"
+// @Success 201 {object} models.SCIMGroup
+// @Failure 409 {object} models.SCIMError
+// @Router /scim/v2/Groups [post]
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var resource models.SCIMGroup
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		writeSCIM(c, http.StatusBadRequest, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid Group resource"))
+		return
+	}
+	group, err := h.scim.CreateGroup(&resource)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	created := h.scim.GroupResource(group)
+	c.Header("Location", created.Meta.Location)
+	writeSCIM(c, http.StatusCreated, created)
+}
+
+// @Summary Remplacer un groupe (SCIM)
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "ID du groupe"
+// @Param group body models.SCIMGroup true "Ressource Group"
+// @Success 200 {object} models.SCIMGroup
+// @Router /scim/v2/Groups/{id} [put]
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	var resource models.SCIMGroup
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		writeSCIM(c, http.StatusBadRequest, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid Group resource"))
+		return
+	}
+	group, err := h.scim.ReplaceGroup(c.Param("id"), &resource)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeSCIM(c, http.StatusOK, h.scim.GroupResource(group))
+}
+
+// @Summary Modifier un groupe (SCIM)
+// @Description Renommage et ajout, retrait ou remplacement des membres (table user_groups)
+// @Tags SCIM
+// @Accept json
+// @Security BearerAuth
+// @Param id path string true "ID du groupe"
+// @Param patch body models.SCIMPatchRequest true "Opérations PATCH"
+// @Success 204
+// @Router /scim/v2/Groups/{id} [patch]
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	operations, ok := bindSCIMPatch(c)
+	if !ok {
+		return
+	}
+	if err := h.scim.PatchGroup(c.Param("id"), operations); err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Supprimer un groupe (SCIM)
+// @Tags SCIM
+// @Security BearerAuth
+// @Param id path string true "ID du groupe"
+// @Success 204
+// @Router /scim/v2/Groups/{id} [delete]
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	if err := h.scim.DeleteGroup(c.Param("id")); err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ===== Helpers =====
+
+func writeSCIM(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+func writeSCIMList(c *gin.Context, total int64, startIndex int, resources []interface{}) {
+	writeSCIM(c, http.StatusOK, models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func writeSCIMError(c *gin.Context, err error) {
+	var scimErr *services.SCIMError
+	if errors.As(err, &scimErr) {
+		writeSCIM(c, scimErr.Status, models.NewSCIMError(scimErr.Status, scimErr.Type, scimErr.Detail))
+		return
+	}
+	log.Printf("[SCIM] Erreur sur %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	writeSCIM(c, http.StatusInternalServerError, models.NewSCIMError(http.StatusInternalServerError, "", "Internal server error"))
+}
+
+func bindSCIMPatch(c *gin.Context) ([]models.SCIMPatchOperation, bool) {
+	var request models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.Operations) == 0 {
+		writeSCIM(c, http.StatusBadRequest, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid PatchOp request"))
+		return nil, false
+	}
+	return request.Operations, true
+}
+
+// scimPagination lit startIndex (1 minimum) et count (borné à SCIMMaxResults, 0 pour le seul total)
+func scimPagination(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	switch {
+	case err != nil:
+		count = services.SCIMDefaultCount
+	case count < 0:
+		count = 0
+	case count > services.SCIMMaxResults:
+		count = services.SCIMMaxResults
+	}
+	return startIndex, count
+}
+
+// scimIncludesMembers indique si les membres sont demandés (attributes / excludedAttributes)
+func scimIncludesMembers(c *gin.Context) bool {
+	if containsSCIMAttribute(c.Query("excludedAttributes"), "members") {
+		return false
+	}
+	attributes := c.Query("attributes")
+	return attributes == "" || containsSCIMAttribute(attributes, "members")
+}
+
+func containsSCIMAttribute(list, attribute string) bool {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == attribute || strings.HasSuffix(item, ":"+attribute) || strings.HasPrefix(item, attribute+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Sessions actives
+// @Description Liste les appareils connectés au compte (la session courante est marquée current)
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Session
+// @Router /auth/sessions [get]
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	sessions, err := h.authMiddleware.Sessions().ListActive(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la récupération des sessions",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	currentID := c.GetUint("session_id")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// @Summary Révoquer une session
+// @Description Déconnecte un appareil : ses tokens sont refusés immédiatement
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la session"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "ID invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if err := h.authMiddleware.Sessions().Revoke(userID, uint(id), services.SessionRevokedByUser); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: "Session non trouvée",
+				Code:    http.StatusNotFound,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la révocation de la session",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Session %d revoked by user %d", id, userID)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Session révoquée",
+	})
+}
+
+// @Summary Se déconnecter partout
+// @Description Révoque toutes les sessions du compte, y compris la session courante
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/sessions [delete]
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	count, err := h.authMiddleware.Sessions().RevokeAll(userID, services.SessionRevokedLogoutAll)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la révocation des sessions",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] User %d logged out everywhere (%d session(s) revoked)", userID, count)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Toutes les sessions ont été révoquées",
+		Data:    gin.H{"revoked": count},
+	})
+}
+
+// @Summary Déconnexion
+// @Description Révoque la session courante (token et refresh token)
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	err := h.authMiddleware.Sessions().Revoke(c.GetUint("user_id"), c.GetUint("session_id"), services.SessionRevokedLogout)
+	if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la déconnexion",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Déconnexion réussie",
+	})
+}
+
+// RunSessionCleanup supprime périodiquement les sessions et les tokens d'accès expirés ou révoqués
+// depuis longtemps (à lancer en goroutine)
+func (h *AuthHandler) RunSessionCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := h.authMiddleware.Sessions().CleanupExpired()
+		if err != nil {
+			log.Printf("[Auth] Erreur lors de la purge des sessions: %v", err)
+		} else if count > 0 {
+			log.Printf("[Auth] %d session(s) expirée(s) supprimée(s)", count)
+		}
+
+		count, err = h.authMiddleware.AccessTokens().CleanupExpired()
+		if err != nil {
+			log.Printf("[Auth] Erreur lors de la purge des tokens d'accès: %v", err)
+		} else if count > 0 {
+			log.Printf("[Auth] %d token(s) d'accès expiré(s) supprimé(s)", count)
+		}
+	}
+}
@@ -2,21 +2,27 @@ package handlers
 
 import (
 	"airboard/models"
+	"airboard/services"
+	"airboard/utils"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type SettingsHandler struct {
-	DB        *gorm.DB
-	homeCache *HomeCache
+	DB         *gorm.DB
+	homeCache  *HomeCache
+	references *services.MediaReferenceService
 }
 
 func NewSettingsHandler(db *gorm.DB) *SettingsHandler {
 	return &SettingsHandler{
-		DB:        db,
-		homeCache: homeCache,
+		DB:         db,
+		homeCache:  homeCache,
+		references: services.NewMediaReferenceService(db),
 	}
 }
 
@@ -98,8 +104,33 @@ func (h *SettingsHandler) UpdateAppSettings(c *gin.Context) {
 				HeroImageURLDark:  request.HeroImageURLDark,
 				HeroImagePosition: request.HeroImagePosition,
 			}
-
-			if err := h.DB.Create(&settings).Error; err != nil {
+			// Politique par défaut : seuls les champs envoyés peuvent la modifier
+			defaultPolicy := utils.DefaultPasswordPolicy()
+			settings.PasswordMinLength = defaultPolicy.MinLength
+			settings.PasswordRequireUpper = defaultPolicy.RequireUpper
+			settings.PasswordRequireLower = defaultPolicy.RequireLower
+			settings.PasswordRequireDigit = defaultPolicy.RequireDigit
+			settings.PasswordRequireSpecial = defaultPolicy.RequireSpecial
+			settings.PasswordCheckBreached = defaultPolicy.CheckBreached
+			if request.KeepImageMetadata != nil {
+				settings.KeepImageMetadata = *request.KeepImageMetadata
+			}
+			if request.TwoFactorRequiredRoles != nil {
+				settings.TwoFactorRequiredRoles = normalizeRoleList(*request.TwoFactorRequiredRoles)
+			}
+			if request.SignupAllowedDomains != nil {
+				settings.SignupAllowedDomains = normalizeDomainList(*request.SignupAllowedDomains)
+			}
+			applyPasswordPolicySettings(&settings, &request)
+
+			// Create ignore les booléens à false et leur applique default:true : ils sont écrits ensuite
+			flags := defaultTrueSettings(&settings)
+			if err := h.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&settings).Error; err != nil {
+					return err
+				}
+				return tx.Model(&settings).Updates(flags).Error
+			}); err != nil {
 				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 					Error:   "database_error",
 					Message: "Failed to create app settings",
@@ -129,6 +160,16 @@ func (h *SettingsHandler) UpdateAppSettings(c *gin.Context) {
 		settings.HeroImageURL = request.HeroImageURL
 		settings.HeroImageURLDark = request.HeroImageURLDark
 		settings.HeroImagePosition = request.HeroImagePosition
+		if request.KeepImageMetadata != nil {
+			settings.KeepImageMetadata = *request.KeepImageMetadata
+		}
+		if request.TwoFactorRequiredRoles != nil {
+			settings.TwoFactorRequiredRoles = normalizeRoleList(*request.TwoFactorRequiredRoles)
+		}
+		if request.SignupAllowedDomains != nil {
+			settings.SignupAllowedDomains = normalizeDomainList(*request.SignupAllowedDomains)
+		}
+		applyPasswordPolicySettings(&settings, &request)
 
 		if err := h.DB.Save(&settings).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -140,6 +181,10 @@ func (h *SettingsHandler) UpdateAppSettings(c *gin.Context) {
 		}
 	}
 
+	if err := h.references.SyncAppSettings(&settings); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des images hero: %v", err)
+	}
+
 	// Invalidate home cache
 	if h.homeCache != nil {
 		h.homeCache.InvalidateAppSettings()
@@ -151,6 +196,70 @@ func (h *SettingsHandler) UpdateAppSettings(c *gin.Context) {
 	})
 }
 
+// normalizeRoleList ne garde que les rôles connus d'une liste séparée par des virgules
+func normalizeRoleList(value string) string {
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		role = strings.TrimSpace(role)
+		if role == "admin" || role == "editor" || role == "user" {
+			roles = append(roles, role)
+		}
+	}
+	return strings.Join(roles, ",")
+}
+
+// normalizeDomainList nettoie une liste de domaines email séparés par des virgules (minuscules, sans "@")
+func normalizeDomainList(value string) string {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return strings.Join(domains, ",")
+}
+
+// defaultTrueSettings retourne les booléens de AppSettings dont la valeur par défaut est true
+func defaultTrueSettings(settings *models.AppSettings) map[string]interface{} {
+	return map[string]interface{}{
+		"signup_enabled":           settings.SignupEnabled,
+		"password_require_upper":   settings.PasswordRequireUpper,
+		"password_require_lower":   settings.PasswordRequireLower,
+		"password_require_digit":   settings.PasswordRequireDigit,
+		"password_require_special": settings.PasswordRequireSpecial,
+		"password_check_breached":  settings.PasswordCheckBreached,
+	}
+}
+
+// applyPasswordPolicySettings reporte les champs de politique de mots de passe fournis dans la requête
+func applyPasswordPolicySettings(settings *models.AppSettings, request *models.AppSettingsRequest) {
+	if request.PasswordMinLength != nil {
+		settings.PasswordMinLength = *request.PasswordMinLength
+	}
+	if request.PasswordRequireUpper != nil {
+		settings.PasswordRequireUpper = *request.PasswordRequireUpper
+	}
+	if request.PasswordRequireLower != nil {
+		settings.PasswordRequireLower = *request.PasswordRequireLower
+	}
+	if request.PasswordRequireDigit != nil {
+		settings.PasswordRequireDigit = *request.PasswordRequireDigit
+	}
+	if request.PasswordRequireSpecial != nil {
+		settings.PasswordRequireSpecial = *request.PasswordRequireSpecial
+	}
+	if request.PasswordCheckBreached != nil {
+		settings.PasswordCheckBreached = *request.PasswordCheckBreached
+	}
+	if request.PasswordHistoryCount != nil {
+		settings.PasswordHistoryCount = *request.PasswordHistoryCount
+	}
+	if request.PasswordMaxAgeDays != nil {
+		settings.PasswordMaxAgeDays = *request.PasswordMaxAgeDays
+	}
+}
+
 // ResetAppSettings remet les paramètres aux valeurs par défaut
 func (h *SettingsHandler) ResetAppSettings(c *gin.Context) {
 	var settings models.AppSettings
@@ -176,6 +285,17 @@ func (h *SettingsHandler) ResetAppSettings(c *gin.Context) {
 	settings.HeroImageURL = ""
 	settings.HeroImageURLDark = ""
 	settings.HeroImagePosition = "center center"
+	settings.KeepImageMetadata = false
+	settings.TwoFactorRequiredRoles = ""
+	settings.SignupAllowedDomains = ""
+	settings.PasswordMinLength = 8
+	settings.PasswordRequireUpper = true
+	settings.PasswordRequireLower = true
+	settings.PasswordRequireDigit = true
+	settings.PasswordRequireSpecial = true
+	settings.PasswordCheckBreached = true
+	settings.PasswordHistoryCount = 0
+	settings.PasswordMaxAgeDays = 0
 
 	if result.Error == gorm.ErrRecordNotFound {
 		// Créer de nouveaux paramètres avec les valeurs par défaut
@@ -199,6 +319,10 @@ func (h *SettingsHandler) ResetAppSettings(c *gin.Context) {
 		}
 	}
 
+	if err := h.references.SyncAppSettings(&settings); err != nil {
+		log.Printf("[Media] Erreur lors de l'indexation des images hero: %v", err)
+	}
+
 	// Invalidate home cache
 	if h.homeCache != nil {
 		h.homeCache.InvalidateAppSettings()
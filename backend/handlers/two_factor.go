@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// requireSecondFactor interrompt la connexion d'un compte local quand un second facteur est
+// nécessaire : la réponse contient un challenge à présenter avec le code au lieu des tokens.
+// Retourne true si la réponse a été envoyée.
+func (h *AuthHandler) requireSecondFactor(c *gin.Context, user *models.User, status int) bool {
+	response := models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ExpiresIn:         int(services.LoginChallengeTTL.Seconds()),
+	}
+
+	purpose := services.ChallengePurposeVerify
+	switch {
+	case user.TwoFactorEnabled:
+		response.Methods = []string{services.TwoFactorMethodTOTP, services.TwoFactorMethodRecovery}
+	case h.twoFactor.RequiredFor(user):
+		purpose = services.ChallengePurposeSetup
+		response.SetupRequired = true
+		response.Methods = []string{services.TwoFactorMethodTOTP}
+	default:
+		return false
+	}
+
+	token, err := h.twoFactor.CreateChallenge(user.ID, purpose)
+	if err != nil {
+		log.Printf("[Auth] Erreur lors de la création du challenge 2FA pour l'utilisateur %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la vérification en deux étapes",
+			Code:    http.StatusInternalServerError,
+		})
+		return true
+	}
+	response.ChallengeToken = token
+
+	c.JSON(status, response)
+	return true
+}
+
+// @Summary Second facteur de connexion
+// @Description Termine une connexion avec un code TOTP ou un code de récupération et délivre les tokens
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorLoginRequest true "Challenge et code"
+// @Success 200 {object} models.LoginResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /auth/2fa/login/verify [post]
+func (h *AuthHandler) VerifyTwoFactorLogin(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Challenge et code requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	challenge, user, ok := h.loadLoginChallenge(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+	if challenge.Purpose == services.ChallengePurposePassword {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Le mot de passe doit être changé avant de poursuivre la connexion",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+	if challenge.Purpose != services.ChallengePurposeVerify && challenge.Purpose != services.ChallengePurposeSetup {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Aucune vérification en attente",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	// Verrouillage par utilisateur : un mot de passe connu ne doit pas permettre de tester tous les codes
+	identifier := fmt.Sprintf("2fa:%d", user.ID)
+	if isLocked, remaining := h.authSecurity.CheckFailedLogin(identifier); isLocked {
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "Too Many Requests",
+			Message: fmt.Sprintf("Trop de codes invalides. Réessayez dans %.0f minutes", remaining.Minutes()),
+			Code:    http.StatusTooManyRequests,
+		})
+		return
+	}
+
+	var recoveryCodes []string
+	var err error
+	if challenge.Purpose == services.ChallengePurposeSetup {
+		recoveryCodes, err = h.twoFactor.Enable(user.ID, req.Code)
+		user.TwoFactorEnabled = err == nil
+	} else {
+		var method string
+		method, err = h.twoFactor.Verify(user.ID, req.Code)
+		if method == services.TwoFactorMethodRecovery {
+			log.Printf("[Auth] Recovery code used by user %d", user.ID)
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			h.twoFactor.RecordChallengeFailure(challenge)
+			h.authSecurity.RecordFailedLogin(identifier)
+			log.Printf("[Auth] Invalid second factor for user %d from IP %s", user.ID, c.ClientIP())
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "Unauthorized",
+				Message: "Code de vérification invalide",
+				Code:    http.StatusUnauthorized,
+			})
+			return
+		}
+		h.twoFactorError(c, err)
+		return
+	}
+
+	h.twoFactor.DeleteChallenge(challenge)
+	h.authSecurity.RecordSuccessfulLogin(identifier)
+	log.Printf("[Auth] Successful login with second factor for user %d from IP %s", user.ID, c.ClientIP())
+
+	h.completeLogin(c, user, recoveryCodes)
+}
+
+// @Summary Enrôlement 2FA à la connexion
+// @Description Génère le secret TOTP d'un utilisateur dont le rôle impose la double authentification
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorChallengeRequest true "Challenge de connexion"
+// @Success 200 {object} models.TwoFactorSetupResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/2fa/login/setup [post]
+func (h *AuthHandler) SetupTwoFactorLogin(c *gin.Context) {
+	var req models.TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Challenge requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	challenge, user, ok := h.loadLoginChallenge(c, req.ChallengeToken)
+	if !ok {
+		return
+	}
+	if challenge.Purpose != services.ChallengePurposeSetup {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "La double authentification est déjà configurée",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	h.beginTwoFactorSetup(c, user)
+}
+
+// @Summary État de la double authentification
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.TwoFactorStatusResponse
+// @Router /auth/2fa [get]
+func (h *AuthHandler) GetTwoFactorStatus(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.twoFactor.Status(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la récupération de la double authentification",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary Configurer la double authentification
+// @Description Génère un secret TOTP et son URI otpauth:// (à confirmer avec /auth/2fa/enable)
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.TwoFactorSetupResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/2fa/setup [post]
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	h.beginTwoFactorSetup(c, user)
+}
+
+// @Summary Activer la double authentification
+// @Description Confirme le secret avec un premier code et retourne les codes de récupération
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "Code TOTP"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/2fa/enable [post]
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Code requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	codes, err := h.twoFactor.Enable(user.ID, req.Code)
+	if err != nil {
+		h.twoFactorError(c, err)
+		return
+	}
+
+	log.Printf("[Auth] Two-factor authentication enabled for user %d", user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Double authentification activée",
+		"recovery_codes": codes,
+	})
+}
+
+// @Summary Désactiver la double authentification
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorDisableRequest true "Mot de passe et code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Mot de passe et code requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if h.twoFactor.RequiredFor(user) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "La double authentification est obligatoire pour votre rôle",
+			Code:    http.StatusForbidden,
+		})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Mot de passe incorrect",
+			Code:    http.StatusUnauthorized,
+		})
+		return
+	}
+	if _, err := h.twoFactor.Verify(user.ID, req.Code); err != nil {
+		h.twoFactorError(c, err)
+		return
+	}
+
+	if err := h.twoFactor.Disable(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la désactivation de la double authentification",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Two-factor authentication disabled by user %d", user.ID)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Double authentification désactivée",
+	})
+}
+
+// @Summary Régénérer les codes de récupération
+// @Description Invalide les anciens codes de récupération et en génère de nouveaux
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "Code TOTP"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Code requis",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	if _, err := h.twoFactor.Verify(user.ID, req.Code); err != nil {
+		h.twoFactorError(c, err)
+		return
+	}
+
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la génération des codes de récupération",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Nouveaux codes de récupération générés",
+		"recovery_codes": codes,
+	})
+}
+
+// @Summary Réinitialiser la double authentification d'un utilisateur
+// @Description Supprime le secret TOTP et les codes de récupération (appareil perdu). Admin uniquement.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'utilisateur"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /admin/users/{id}/2fa [delete]
+func (h *AuthHandler) ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "ID invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+
+	if err := h.twoFactor.Disable(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la réinitialisation de la double authentification",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Two-factor authentication of user %d reset by admin %d", user.ID, c.GetUint("user_id"))
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Double authentification réinitialisée",
+	})
+}
+
+// RunChallengeCleanup supprime périodiquement les challenges de connexion (2FA, WebAuthn) et les liens
+// de réinitialisation de mot de passe expirés (à lancer en goroutine)
+func (h *AuthHandler) RunChallengeCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := h.twoFactor.CleanupExpiredChallenges()
+		if err != nil {
+			log.Printf("[Auth] Erreur lors de la purge des challenges 2FA: %v", err)
+		} else if count > 0 {
+			log.Printf("[Auth] %d challenge(s) 2FA expiré(s) supprimé(s)", count)
+		}
+
+		count, err = h.passwordReset.CleanupExpired()
+		if err != nil {
+			log.Printf("[Auth] Erreur lors de la purge des liens de réinitialisation: %v", err)
+		} else if count > 0 {
+			log.Printf("[Auth] %d lien(s) de réinitialisation expiré(s) supprimé(s)", count)
+		}
+
+		if h.webauthn == nil {
+			continue
+		}
+		count, err = h.webauthn.CleanupExpiredSessions()
+		if err != nil {
+			log.Printf("[Auth] Erreur lors de la purge des sessions WebAuthn: %v", err)
+		} else if count > 0 {
+			log.Printf("[Auth] %d session(s) WebAuthn expirée(s) supprimée(s)", count)
+		}
+	}
+}
+
+// beginTwoFactorSetup génère le secret TOTP d'un compte local
+func (h *AuthHandler) beginTwoFactorSetup(c *gin.Context, user *models.User) {
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "La double authentification est gérée par votre fournisseur d'identité",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	setup, err := h.twoFactor.BeginSetup(user)
+	if err != nil {
+		h.twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// loadLoginChallenge charge le challenge de connexion et son utilisateur, en écrivant la réponse d'erreur
+func (h *AuthHandler) loadLoginChallenge(c *gin.Context, token string) (*models.LoginChallenge, *models.User, bool) {
+	challenge, err := h.twoFactor.GetChallenge(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Session de connexion expirée, veuillez vous reconnecter",
+			Code:    http.StatusUnauthorized,
+		})
+		return nil, nil, false
+	}
+
+	var user models.User
+	if err := h.db.Preload("Groups").Preload("AdminOfGroups").First(&user, challenge.UserID).Error; err != nil || !user.IsActive {
+		h.twoFactor.DeleteChallenge(challenge)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Compte désactivé",
+			Code:    http.StatusUnauthorized,
+		})
+		return nil, nil, false
+	}
+	return challenge, &user, true
+}
+
+// currentUser charge l'utilisateur connecté, en écrivant la réponse d'erreur
+func (h *AuthHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := h.db.First(&user, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Utilisateur non trouvé",
+			Code:    http.StatusNotFound,
+		})
+		return nil, false
+	}
+	return &user, true
+}
+
+// twoFactorError traduit les erreurs du service de double authentification en réponse HTTP
+func (h *AuthHandler) twoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Code de vérification invalide",
+			Code:    http.StatusUnauthorized,
+		})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Conflict",
+			Message: "La double authentification est déjà activée",
+			Code:    http.StatusConflict,
+		})
+	case errors.Is(err, services.ErrTwoFactorNotSetUp), errors.Is(err, services.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "La double authentification n'est pas configurée",
+			Code:    http.StatusBadRequest,
+		})
+	default:
+		log.Printf("[Auth] Erreur double authentification: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la double authentification",
+			Code:    http.StatusInternalServerError,
+		})
+	}
+}
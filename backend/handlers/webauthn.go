@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"airboard/models"
+	"airboard/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// @Summary Démarrer une connexion par passkey
+// @Description Retourne les options WebAuthn à passer à navigator.credentials.get(). Sans identifiant, la connexion est découvrable.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body models.WebAuthnLoginRequest false "Identifiant (facultatif)"
+// @Success 200 {object} models.WebAuthnCeremonyResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /auth/webauthn/login/begin [post]
+func (h *AuthHandler) BeginWebAuthnLogin(c *gin.Context) {
+	if !h.webAuthnAvailable(c) {
+		return
+	}
+
+	var req models.WebAuthnLoginRequest
+	// Corps facultatif : une connexion découvrable n'a pas besoin d'identifiant
+	_ = c.ShouldBindJSON(&req)
+
+	assertion, sessionID, err := h.webauthn.BeginLogin(req.Username)
+	if err != nil {
+		h.webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebAuthnCeremonyResponse{
+		SessionID: sessionID,
+		Options:   assertion,
+		ExpiresIn: int(services.WebAuthnSessionTTL.Seconds()),
+	})
+}
+
+// @Summary Terminer une connexion par passkey
+// @Description Vérifie la réponse de navigator.credentials.get() et délivre les mêmes tokens que /auth/login
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param session_id query string true "Identifiant de session retourné par /auth/webauthn/login/begin"
+// @Success 200 {object} models.LoginResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /auth/webauthn/login/finish [post]
+func (h *AuthHandler) FinishWebAuthnLogin(c *gin.Context) {
+	if !h.webAuthnAvailable(c) {
+		return
+	}
+
+	// Même verrouillage par IP que la connexion par mot de passe
+	identifier := "webauthn:" + c.ClientIP()
+	if isLocked, remaining := h.authSecurity.CheckFailedLogin(identifier); isLocked {
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error:   "Too Many Requests",
+			Message: fmt.Sprintf("Trop de tentatives échouées. Réessayez dans %.0f minutes", remaining.Minutes()),
+			Code:    http.StatusTooManyRequests,
+		})
+		return
+	}
+
+	user, err := h.webauthn.FinishLogin(c.Query("session_id"), c.Request)
+	if err != nil {
+		h.authSecurity.RecordFailedLogin(identifier)
+		log.Printf("[Auth] Failed passkey login from IP %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Passkey invalide ou expirée",
+			Code:    http.StatusUnauthorized,
+		})
+		return
+	}
+
+	h.authSecurity.RecordSuccessfulLogin(identifier)
+	log.Printf("[Auth] Successful passkey login for user %d from IP %s", user.ID, c.ClientIP())
+
+	// La passkey (possession + vérification de l'utilisateur) remplace le mot de passe et le code TOTP
+	h.completeLogin(c, user, nil)
+}
+
+// @Summary Démarrer l'enregistrement d'une passkey
+// @Description Retourne les options WebAuthn à passer à navigator.credentials.create()
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.WebAuthnRegistrationRequest false "Libellé de la passkey"
+// @Success 200 {object} models.WebAuthnCeremonyResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/webauthn/register/begin [post]
+func (h *AuthHandler) BeginWebAuthnRegistration(c *gin.Context) {
+	if !h.webAuthnAvailable(c) {
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.Password == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "L'authentification de votre compte est gérée par votre fournisseur d'identité",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	var req models.WebAuthnRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Libellé invalide (100 caractères maximum)",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	creation, sessionID, err := h.webauthn.BeginRegistration(user, req.Name)
+	if err != nil {
+		h.webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.WebAuthnCeremonyResponse{
+		SessionID: sessionID,
+		Options:   creation,
+		ExpiresIn: int(services.WebAuthnSessionTTL.Seconds()),
+	})
+}
+
+// @Summary Terminer l'enregistrement d'une passkey
+// @Description Vérifie la réponse de navigator.credentials.create() et enregistre la passkey
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param session_id query string true "Identifiant de session retourné par /auth/webauthn/register/begin"
+// @Success 201 {object} models.WebAuthnCredential
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/webauthn/register/finish [post]
+func (h *AuthHandler) FinishWebAuthnRegistration(c *gin.Context) {
+	if !h.webAuthnAvailable(c) {
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	credential, err := h.webauthn.FinishRegistration(user, c.Query("session_id"), c.Request)
+	if err != nil {
+		h.webAuthnError(c, err)
+		return
+	}
+
+	log.Printf("[Auth] Passkey %d registered by user %d", credential.ID, user.ID)
+	c.JSON(http.StatusCreated, credential)
+}
+
+// @Summary Lister mes passkeys
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.WebAuthnCredential
+// @Router /auth/webauthn/credentials [get]
+func (h *AuthHandler) GetWebAuthnCredentials(c *gin.Context) {
+	if !h.webAuthnAvailable(c) {
+		return
+	}
+
+	credentials, err := h.webauthn.ListCredentials(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la récupération des passkeys",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, credentials)
+}
+
+// @Summary Révoquer une passkey
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la passkey"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/webauthn/credentials/{id} [delete]
+func (h *AuthHandler) DeleteWebAuthnCredential(c *gin.Context) {
+	if !h.webAuthnAvailable(c) {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "ID invalide",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if err := h.webauthn.DeleteCredential(userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "Not Found",
+				Message: "Passkey non trouvée",
+				Code:    http.StatusNotFound,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Erreur lors de la révocation de la passkey",
+			Code:    http.StatusInternalServerError,
+		})
+		return
+	}
+
+	log.Printf("[Auth] Passkey %d revoked by user %d", id, userID)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Passkey révoquée",
+	})
+}
+
+// webAuthnAvailable répond 503 quand WebAuthn n'est pas configuré (RP ID ou origines invalides)
+func (h *AuthHandler) webAuthnAvailable(c *gin.Context) bool {
+	if h.webauthn != nil {
+		return true
+	}
+	c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+		Error:   "Service Unavailable",
+		Message: "La connexion par passkey n'est pas configurée",
+		Code:    http.StatusServiceUnavailable,
+	})
+	return false
+}
+
+// webAuthnError traduit les erreurs des cérémonies WebAuthn en réponse HTTP
+func (h *AuthHandler) webAuthnError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidWebAuthnSession):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Session WebAuthn invalide ou expirée",
+			Code:    http.StatusBadRequest,
+		})
+	default:
+		log.Printf("[Auth] Erreur WebAuthn: %v", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "La vérification de la passkey a échoué",
+			Code:    http.StatusBadRequest,
+		})
+	}
+}
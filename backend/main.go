@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"airboard/models"
 	"airboard/services"
 	"airboard/services/chat" // Import chat service
+	"airboard/utils"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -55,6 +57,24 @@ func main() {
 		&models.EmailTemplate{},
 		&models.EmailNotificationLog{},
 		&models.Media{},
+		&models.MediaVariant{},
+		&models.MediaReference{},
+		&models.MediaBlob{},
+		&models.MediaUpload{},
+		&models.StorageQuota{},
+		&models.TwoFactorAuth{},
+		&models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnSession{},
+		&models.Session{},
+		&models.PasswordResetToken{},
+		&models.Invitation{},
+		&models.ErasureRequest{},
+		&models.PasswordHistory{},
+		&models.StateEntry{},
+		&models.AccessToken{},
+		&models.Impersonation{},
 		&models.Comment{},
 		&models.Feedback{},
 		&models.CommentSettings{},
@@ -155,6 +175,14 @@ func main() {
 		log.Println("✓ Index unique partiel créé/vérifié pour event_categories.slug")
 	}
 
+	// Déduplication des médias: plusieurs médias peuvent partager le même fichier
+	if err := db.Exec("ALTER TABLE media DROP CONSTRAINT IF EXISTS media_storage_path_key").Error; err != nil {
+		log.Printf("Avertissement: Impossible de supprimer la contrainte unique sur media.storage_path: %v", err)
+	}
+	if err := db.Exec("ALTER TABLE media_variants DROP CONSTRAINT IF EXISTS media_variants_storage_path_key").Error; err != nil {
+		log.Printf("Avertissement: Impossible de supprimer la contrainte unique sur media_variants.storage_path: %v", err)
+	}
+
 	// Créer les données initiales
 	if err := createInitialData(db, cfg); err != nil {
 		log.Fatalf("Erreur lors de la création des données initiales: %v", err)
@@ -166,7 +194,7 @@ func main() {
 	InitEmailService(db, cfg)
 
 	// Initialiser le service de stockage
-	storageService, err := services.NewLocalStorage(cfg.Storage.UploadDir, cfg.Storage.BaseURL)
+	storageService, err := services.NewStorageService(cfg)
 	if err != nil {
 		log.Fatal("Erreur d'initialisation du service de stockage:", err)
 	}
@@ -174,20 +202,45 @@ func main() {
 	// Initialisation des middlewares
 	authMiddleware := middleware.NewAuthMiddleware(cfg, db)
 	ssoMiddleware := middleware.NewSSOMiddleware(db, cfg)
-	csrfManager := middleware.NewCSRFManager()
+	// État de sécurité partagé entre instances (verrouillages, tokens CSRF, états OAuth)
+	stateStore := utils.NewStateStore(cfg.Security.StateStore, db)
+	go utils.RunStateStoreCleanup(stateStore, 15*time.Minute)
+	csrfManager := middleware.NewCSRFManager(stateStore)
+
+	// Validation des fichiers uploadés, avec analyse antivirus si clamd est configuré
+	fileValidator := utils.NewSecureFileValidator()
+	if cfg.Security.ClamdAddress != "" {
+		scanner, err := utils.NewClamdScanner(cfg.Security.ClamdAddress, time.Duration(cfg.Security.ClamdTimeout)*time.Second)
+		if err != nil {
+			log.Fatalf("Configuration clamd invalide: %v", err)
+		}
+		if err := scanner.Ping(context.Background()); err != nil {
+			log.Printf("Avertissement: clamd injoignable (%v), les uploads seront mis en quarantaine (ou acceptés si CLAMD_FAIL_OPEN=true)", err)
+		}
+		fileValidator.SetScanner(scanner, cfg.Security.ClamdFailOpen)
+	}
 
-	mediaHandler := handlers.NewMediaHandler(db, storageService)
+	mediaHandler := handlers.NewMediaHandler(db, storageService, services.NewMediaURLSigner(cfg.Storage), fileValidator)
+	// Purge des uploads reprenables (tus) expirés
+	go mediaHandler.RunUploadCleanup(time.Hour)
 
 	// Gamification
 	gamificationService := services.NewGamificationService(db)
 
 	// Initialisation des handlers
-	authHandler := handlers.NewAuthHandler(db, authMiddleware, cfg.Server.SignupEnabled, cfg, gamificationService)
+	authHandler := handlers.NewAuthHandler(db, authMiddleware, cfg.Server.SignupEnabled, cfg, gamificationService, stateStore, storageService)
+	// Purge des connexions en attente du second facteur (2FA) expirées
+	go authHandler.RunChallengeCleanup(time.Hour)
+	go authHandler.RunSessionCleanup(6 * time.Hour)
+	go authHandler.RunUnverifiedAccountPurge(6 * time.Hour)
+	if cfg.LDAP.Enabled && cfg.LDAP.SyncIntervalMinutes > 0 {
+		go authHandler.RunLDAPSync(time.Duration(cfg.LDAP.SyncIntervalMinutes) * time.Minute)
+	}
 	dashboardHandler := handlers.NewDashboardHandler(db)
-	adminHandler := handlers.NewAdminHandler(db, cfg, gamificationService)
+	adminHandler := handlers.NewAdminHandler(db, cfg, gamificationService, storageService)
 	groupAdminHandler := handlers.NewGroupAdminHandler(db)
 	settingsHandler := handlers.NewSettingsHandler(db)
-	oauthHandler := handlers.NewOAuthHandler(db, authMiddleware)
+	oauthHandler := handlers.NewOAuthHandler(db, cfg, authMiddleware, stateStore)
 	favoritesHandler := handlers.NewFavoritesHandler(db)
 	analyticsHandler := handlers.NewAnalyticsHandler(db, gamificationService)
 	announcementHandler := handlers.NewAnnouncementHandler(db)
@@ -203,6 +256,9 @@ func main() {
 	suggestionsHandler := handlers.NewSuggestionsHandler(db, gamificationService)
 	gamificationHandler := handlers.NewGamificationHandler(db, gamificationService)
 	searchHandler := handlers.NewSearchHandler(db)
+	scimHandler := handlers.NewSCIMHandler(db, cfg)
+	invitationHandler := handlers.NewInvitationHandler(db, cfg)
+	privacyHandler := handlers.NewPrivacyHandler(db, storageService)
 
 	// Seeding gamification
 	if err := gamificationService.SeedAchievements(); err != nil {
@@ -272,15 +328,21 @@ func main() {
 	// Middleware SSO (détection des headers Authentik)
 	router.Use(ssoMiddleware.DetectSSO())
 
-	// Serve uploaded files statically
-	router.Static("/uploads", cfg.Storage.UploadDir)
+	// Fichiers uploadés : contrôle de visibilité (session, cookie média ou URL signée),
+	// puis streaming local ou redirection vers une URL présignée (S3/MinIO)
+	uploads := router.Group("/uploads")
+	uploads.Use(authMiddleware.OptionalAuth())
+	{
+		uploads.GET("/*filepath", mediaHandler.ServeUpload)
+		uploads.HEAD("/*filepath", mediaHandler.ServeUpload)
+	}
 
 	// Routes publiques
 	api := router.Group("/api/v1")
 	{
 		// Gamification
 		gamification := api.Group("/gamification")
-		gamification.Use(authMiddleware.RequireAuth())
+		gamification.Use(authMiddleware.RequireAuth(), authMiddleware.RequireScope("user"))
 		{
 			gamification.GET("/profile", gamificationHandler.GetMyProfile)
 			gamification.GET("/achievements", gamificationHandler.GetMyAchievements)
@@ -294,6 +356,33 @@ func main() {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/2fa/login/verify", authHandler.VerifyTwoFactorLogin)     // Second facteur (TOTP ou code de récupération)
+			auth.POST("/2fa/login/setup", authHandler.SetupTwoFactorLogin)       // Enrôlement 2FA imposé par le rôle
+			auth.POST("/webauthn/login/begin", authHandler.BeginWebAuthnLogin)   // Connexion par passkey (options)
+			auth.POST("/webauthn/login/finish", authHandler.FinishWebAuthnLogin) // Connexion par passkey (vérification)
+
+			// Mot de passe oublié ou expiré (limité à 5 requêtes par minute et par IP)
+			password := auth.Group("/password", middleware.AuthRateLimit())
+			{
+				password.POST("/forgot", authHandler.RequestPasswordReset)
+				password.POST("/reset", authHandler.ConfirmPasswordReset)
+				password.POST("/expired", authHandler.ChangeExpiredPassword) // Nouveau mot de passe exigé à la connexion
+			}
+			auth.GET("/password/policy", authHandler.GetPasswordPolicy) // Règles affichées par les formulaires
+
+			// Confirmation de l'adresse email des inscriptions
+			email := auth.Group("/email", middleware.AuthRateLimit())
+			{
+				email.POST("/verify", authHandler.VerifyEmail)
+				email.POST("/resend", authHandler.ResendVerificationEmail)
+			}
+
+			// Création d'un compte à partir d'une invitation reçue par email
+			invitations := auth.Group("/invitations", middleware.AuthRateLimit())
+			{
+				invitations.GET("", invitationHandler.GetInvitation)
+				invitations.POST("/accept", invitationHandler.AcceptInvitation)
+			}
 
 			// Route pour vérifier si l'inscription est activée
 			signup := auth.Group("/signup")
@@ -316,6 +405,15 @@ func main() {
 				oauth.GET("/:provider/callback", oauthHandler.OAuthCallback)
 				oauth.POST("/:provider/callback", oauthHandler.OAuthCallback)
 			}
+
+			// Fournisseur de service SAML 2.0 (l'IdP poste la réponse sur /acs)
+			samlRoutes := auth.Group("/saml")
+			{
+				samlRoutes.GET("/metadata", authHandler.GetSAMLMetadata)
+				samlRoutes.GET("/login", authHandler.SAMLLogin)
+				samlRoutes.POST("/acs", authHandler.SAMLAssertionConsumer)
+				samlRoutes.POST("/complete", authHandler.CompleteSAMLLogin)
+			}
 		}
 
 		// Routes version (publiques)
@@ -324,6 +422,27 @@ func main() {
 			version.GET("", versionHandler.GetVersion)
 			version.GET("/check-updates", versionHandler.CheckForUpdates)
 		}
+
+		// Provisionnement SCIM 2.0 par l'IdP (token d'accès admin avec le scope admin:scim, sans CSRF)
+		scim := api.Group("/scim/v2", authMiddleware.RequireSCIM())
+		{
+			scim.GET("/ServiceProviderConfig", scimHandler.GetServiceProviderConfig)
+			scim.GET("/ResourceTypes", scimHandler.GetResourceTypes)
+
+			scim.GET("/Users", scimHandler.ListUsers)
+			scim.POST("/Users", scimHandler.CreateUser)
+			scim.GET("/Users/:id", scimHandler.GetUser)
+			scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+			scim.PATCH("/Users/:id", scimHandler.PatchUser)
+			scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+
+			scim.GET("/Groups", scimHandler.ListGroups)
+			scim.POST("/Groups", scimHandler.CreateGroup)
+			scim.GET("/Groups/:id", scimHandler.GetGroup)
+			scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+			scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+			scim.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+		}
 	}
 
 	// Routes protégées - Ordre correct: Auth d'abord, puis CSRF
@@ -331,24 +450,68 @@ func main() {
 	protected.Use(authMiddleware.RequireAuth())
 	protected.Use(middleware.OptionalCSRFProtection(csrfManager))
 	{
-		// Route pour générer un token CSRF
-		protected.POST("/auth/csrf-token", middleware.CSRFTokenHandler(csrfManager))
+		// Les tokens d'accès personnels ne passent que les routes dont le groupe vérifie les scopes
+		// (RequireScope...) ; la gestion des identifiants du compte leur est interdite.
+		credentials := protected.Group("/auth", authMiddleware.RejectAccessToken())
+		{
+			// Route pour générer un token CSRF
+			credentials.POST("/csrf-token", middleware.CSRFTokenHandler(csrfManager))
+
+			credentials.POST("/change-password", authHandler.ChangePassword)
+
+			// Double authentification (TOTP)
+			credentials.GET("/2fa", authHandler.GetTwoFactorStatus)
+			credentials.POST("/2fa/setup", authHandler.SetupTwoFactor)
+			credentials.POST("/2fa/enable", authHandler.EnableTwoFactor)
+			credentials.POST("/2fa/disable", authHandler.DisableTwoFactor)
+			credentials.POST("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+			// Sessions (appareils connectés)
+			credentials.POST("/logout", authHandler.Logout)
+			credentials.GET("/sessions", authHandler.GetSessions)
+			credentials.DELETE("/sessions", authHandler.RevokeAllSessions)
+			credentials.DELETE("/sessions/:id", authHandler.RevokeSession)
+
+			// Passkeys (WebAuthn)
+			credentials.POST("/webauthn/register/begin", authHandler.BeginWebAuthnRegistration)
+			credentials.POST("/webauthn/register/finish", authHandler.FinishWebAuthnRegistration)
+			credentials.GET("/webauthn/credentials", authHandler.GetWebAuthnCredentials)
+			credentials.DELETE("/webauthn/credentials/:id", authHandler.DeleteWebAuthnCredential)
+
+			// Tokens d'accès personnels (API)
+			credentials.GET("/tokens/scopes", authHandler.GetAccessTokenScopes)
+			credentials.GET("/tokens", authHandler.GetAccessTokens)
+			credentials.POST("/tokens", authHandler.CreateAccessToken)
+			credentials.DELETE("/tokens/:id", authHandler.RevokeAccessToken)
+
+			// Mode "voir en tant que" (token d'impersonation, lecture seule)
+			credentials.GET("/impersonation", authHandler.GetImpersonationStatus)
+			credentials.POST("/impersonation/end", authHandler.EndImpersonation)
+
+			// Données personnelles (RGPD) : export et demande d'effacement du compte
+			credentials.GET("/privacy/export", privacyHandler.ExportPersonalData)
+			credentials.GET("/privacy/erasure", privacyHandler.GetErasureRequest)
+			credentials.POST("/privacy/erasure", privacyHandler.RequestErasure)
+			credentials.DELETE("/privacy/erasure", privacyHandler.CancelErasure)
+		}
 
 		// Profil utilisateur
-		protected.GET("/auth/profile", authHandler.GetProfile)
-		protected.PUT("/auth/profile", authHandler.UpdateProfile)
-		protected.POST("/auth/change-password", authHandler.ChangePassword)
-		protected.POST("/auth/avatar", authHandler.UploadAvatar)
-		protected.DELETE("/auth/avatar", authHandler.DeleteAvatar)
+		profile := protected.Group("/auth", authMiddleware.RequireScope("user"))
+		{
+			profile.GET("/profile", authHandler.GetProfile)
+			profile.PUT("/profile", authHandler.UpdateProfile)
+			profile.POST("/avatar", authHandler.UploadAvatar)
+			profile.DELETE("/avatar", authHandler.DeleteAvatar)
+		}
 
 		// Dashboard
-		protected.GET("/dashboard", dashboardHandler.GetDashboard)
+		protected.GET("/dashboard", authMiddleware.RequireScope("user"), dashboardHandler.GetDashboard)
 
 		// Home page
-		protected.GET("/home", homeHandler.GetHomeData)
+		protected.GET("/home", authMiddleware.RequireScope("user"), homeHandler.GetHomeData)
 
 		// Routes favorites
-		user := protected.Group("/user")
+		user := protected.Group("/user", authMiddleware.RequireScope("user"))
 		{
 			user.GET("/favorites", favoritesHandler.GetUserFavorites)
 			user.POST("/favorites", favoritesHandler.AddFavorite)
@@ -357,19 +520,19 @@ func main() {
 		}
 
 		// Routes analytics (tracking accessible à tous les utilisateurs connectés)
-		analytics := protected.Group("/analytics")
+		analytics := protected.Group("/analytics", authMiddleware.RequireScope("user"))
 		{
 			analytics.POST("/track", analyticsHandler.TrackClick)
 		}
 
 		// Recherche globale
-		protected.GET("/search", searchHandler.GlobalSearch)
+		protected.GET("/search", authMiddleware.RequireScope("user"), searchHandler.GlobalSearch)
 
 		// Routes announcements (accessible à tous les utilisateurs connectés)
-		protected.GET("/announcements", announcementHandler.GetActiveAnnouncements)
+		protected.GET("/announcements", authMiddleware.RequireScope("user"), announcementHandler.GetActiveAnnouncements)
 
 		// Routes News Hub (accessible à tous les utilisateurs connectés)
-		news := protected.Group("/news")
+		news := protected.Group("/news", authMiddleware.RequireScope("news"))
 		{
 			news.GET("", newsHandler.GetNews) // Liste des news avec filtres
 
@@ -390,15 +553,18 @@ func main() {
 		}
 
 		// Routes Media (accessible à tous les utilisateurs connectés - editors et admins peuvent uploader)
-		media := protected.Group("/media")
+		media := protected.Group("/media", authMiddleware.RequireScope("media"))
 		{
-			media.GET("", mediaHandler.GetMediaList)       // Liste des médias avec pagination et filtres
-			media.GET("/:id", mediaHandler.GetMedia)       // Récupérer un média par ID
-			media.DELETE("/:id", mediaHandler.DeleteMedia) // Supprimer un média (uploader ou admin)
+			media.GET("", mediaHandler.GetMediaList)                      // Liste des médias avec pagination et filtres
+			media.GET("/:id", mediaHandler.GetMedia)                      // Récupérer un média par ID
+			media.GET("/:id/references", mediaHandler.GetMediaReferences) // Contenus qui utilisent ce média
+			media.POST("/sign", mediaHandler.SignMediaURLs)               // URLs signées temporaires pour des fichiers /uploads
+			media.GET("/quota", mediaHandler.GetMyStorageQuota)           // Espace utilisé et quotas applicables
+			media.DELETE("/:id", mediaHandler.DeleteMedia)                // Supprimer un média (uploader ou admin, refusé si utilisé)
 		}
 
 		// Routes Events (accessible à tous les utilisateurs connectés)
-		events := protected.Group("/events")
+		events := protected.Group("/events", authMiddleware.RequireScope("events"))
 		{
 			events.GET("", eventsHandler.GetEvents)                // Liste des événements avec filtres
 			events.GET("/calendar", eventsHandler.GetCalendarView) // Vue calendrier (expand récurrences)
@@ -407,7 +573,7 @@ func main() {
 		}
 
 		// Routes Commentaires (accessible à tous les utilisateurs connectés)
-		comments := protected.Group("/comments")
+		comments := protected.Group("/comments", authMiddleware.RequireScope("comments"))
 		{
 			comments.GET("", commentHandler.GetComments)                 // Récupérer les commentaires d'une entité
 			comments.POST("", commentHandler.CreateComment)              // Créer un commentaire
@@ -417,7 +583,7 @@ func main() {
 		}
 
 		// Routes Feedback (accessible à tous les utilisateurs connectés)
-		feedback := protected.Group("/feedback")
+		feedback := protected.Group("/feedback", authMiddleware.RequireScope("comments"))
 		{
 			feedback.GET("/stats", feedbackHandler.GetFeedbackStats) // Statistiques de feedback
 			feedback.POST("", feedbackHandler.AddFeedback)           // Ajouter/modifier un feedback
@@ -425,7 +591,7 @@ func main() {
 		}
 
 		// Routes Notifications (accessible à tous les utilisateurs connectés)
-		notifications := protected.Group("/notifications")
+		notifications := protected.Group("/notifications", authMiddleware.RequireScope("user"))
 		{
 			notifications.GET("", notificationHandler.GetNotifications)            // Récupérer les notifications
 			notifications.GET("/unread/count", notificationHandler.GetUnreadCount) // Nombre de notifications non lues
@@ -437,7 +603,7 @@ func main() {
 		}
 
 		// Routes Polls (accessible à tous les utilisateurs connectés)
-		polls := protected.Group("/polls")
+		polls := protected.Group("/polls", authMiddleware.RequireScope("polls"))
 		{
 			polls.GET("", pollsHandler.GetPolls)                   // Liste des sondages avec filtres
 			polls.GET("/:id", pollsHandler.GetPollByID)            // Récupérer un sondage par ID
@@ -447,7 +613,7 @@ func main() {
 		}
 
 		// Routes Suggestions (accessible à tous les utilisateurs connectés)
-		suggestions := protected.Group("/suggestions")
+		suggestions := protected.Group("/suggestions", authMiddleware.RequireScope("suggestions"))
 		{
 			suggestions.GET("/categories", suggestionsHandler.GetSuggestionCategories)
 			suggestions.GET("", suggestionsHandler.GetSuggestions)
@@ -457,7 +623,7 @@ func main() {
 		}
 
 		// Routes Chat (accessible à tous les utilisateurs connectés)
-		chatGroup := protected.Group("/chat")
+		chatGroup := protected.Group("/chat", authMiddleware.RequireScope("chat"))
 		{
 			chatGroup.GET("/contacts", chatHandler.GetContacts)
 			chatGroup.GET("/history", chatHandler.GetHistory)
@@ -480,11 +646,11 @@ func main() {
 		// actually, our ServeWS implementation checks context "user_id". So it NEEDS the middleware.
 		// We can tell middleware to look at query param "d_token" or "token".
 		// For this implementation, let's keep it here.
-		protected.GET("/ws", chatHandler.ServeWS)
+		protected.GET("/ws", authMiddleware.RequireScope("chat"), chatHandler.ServeWS)
 
 		// Routes admin
 		admin := protected.Group("/admin")
-		admin.Use(authMiddleware.RequireAdmin())
+		admin.Use(authMiddleware.RequireAdmin(), authMiddleware.RequireAdminScope())
 		{
 			// Gestion des groupes d'applications
 			admin.GET("/app-groups", adminHandler.GetAppGroups)
@@ -506,6 +672,25 @@ func main() {
 			admin.GET("/users/deleted", adminHandler.GetDeletedUsers)
 			admin.POST("/users/:id/restore", adminHandler.RestoreUser)
 			admin.DELETE("/users/:id/permanent", adminHandler.PermanentlyDeleteUser)
+			admin.DELETE("/users/:id/2fa", authHandler.ResetUserTwoFactor)
+			admin.GET("/users/:id/tokens", authHandler.GetUserAccessTokens)
+			admin.POST("/users/:id/tokens", authMiddleware.RejectAccessToken(), authHandler.CreateUserAccessToken)
+			admin.DELETE("/users/:id/tokens/:tokenId", authHandler.RevokeUserAccessToken)
+			admin.POST("/users/:id/impersonate", authMiddleware.RejectAccessToken(), authHandler.StartImpersonation)
+			admin.POST("/ldap/sync", authHandler.SyncLDAP)
+			admin.GET("/impersonations", authHandler.GetImpersonations)
+
+			// Invitations par email (compte local avec rôle et groupes attribués)
+			admin.GET("/invitations", invitationHandler.ListInvitations)
+			admin.POST("/invitations", invitationHandler.CreateInvitation)
+			admin.POST("/invitations/bulk", invitationHandler.CreateBulkInvitations)
+			admin.POST("/invitations/:id/resend", invitationHandler.ResendInvitation)
+			admin.DELETE("/invitations/:id", invitationHandler.RevokeInvitation)
+
+			// Demandes d'effacement des comptes (RGPD)
+			admin.GET("/erasure-requests", privacyHandler.ListErasureRequests)
+			admin.POST("/erasure-requests/:id/approve", authMiddleware.RejectAccessToken(), privacyHandler.ApproveErasureRequest)
+			admin.POST("/erasure-requests/:id/reject", privacyHandler.RejectErasureRequest)
 
 			// Gestion des groupes d'utilisateurs
 			admin.GET("/groups", adminHandler.GetGroups)
@@ -530,6 +715,7 @@ func main() {
 
 			// Gestion des fournisseurs OAuth
 			admin.GET("/oauth/providers", oauthHandler.GetAllProviders)
+			admin.POST("/oauth/providers", oauthHandler.CreateProvider)
 			admin.PUT("/oauth/providers/:id", oauthHandler.UpdateProvider)
 
 			// Analytics (réservé aux admins)
@@ -545,7 +731,7 @@ func main() {
 			admin.DELETE("/announcements/:id", announcementHandler.DeleteAnnouncement)
 
 			// Gestion de la base de données
-			admin.POST("/database/reset", adminHandler.ResetDatabase)
+			admin.POST("/database/reset", authMiddleware.RejectAccessToken(), adminHandler.ResetDatabase)
 
 			// Gestion des catégories de news (admin uniquement)
 			admin.POST("/news/categories", newsHandler.CreateCategory)
@@ -635,16 +821,34 @@ func main() {
 			admin.DELETE("/suggestion-categories/:id", suggestionsHandler.DeleteSuggestionCategory)
 
 			// Gestion des médias (admin uniquement)
-			admin.GET("/media", mediaHandler.GetMediaList)        // Liste des médias avec pagination et filtres
-			admin.GET("/media/:id", mediaHandler.GetMedia)        // Récupérer un média par ID
-			admin.POST("/media/upload", mediaHandler.UploadMedia) // Uploader un média
-			admin.PUT("/media/:id", mediaHandler.UpdateMedia)     // Mettre à jour les métadonnées d'un média
-			admin.DELETE("/media/:id", mediaHandler.DeleteMedia)  // Supprimer un média
+			admin.GET("/media", mediaHandler.GetMediaList)                               // Liste des médias avec pagination et filtres
+			admin.GET("/media/:id", mediaHandler.GetMedia)                               // Récupérer un média par ID
+			admin.POST("/media/upload", mediaHandler.UploadMedia)                        // Uploader un média
+			admin.PUT("/media/:id", mediaHandler.UpdateMedia)                            // Mettre à jour les métadonnées d'un média
+			admin.DELETE("/media/:id", mediaHandler.DeleteMedia)                         // Supprimer un média (?force=true si encore utilisé)
+			admin.GET("/media/orphans", mediaHandler.GetOrphanedMedia)                   // Médias/fichiers non utilisés
+			admin.POST("/media/orphans/purge", mediaHandler.PurgeOrphanedMedia)          // Purger les orphelins
+			admin.POST("/media/references/rebuild", mediaHandler.RebuildMediaReferences) // Reconstruire l'index des usages
+			admin.GET("/media/quarantine", mediaHandler.GetQuarantinedMedia)             // Fichiers mis en quarantaine par l'antivirus
+			admin.POST("/media/:id/rescan", mediaHandler.RescanMedia)                    // Relancer l'analyse antivirus
+			admin.POST("/media/:id/release", mediaHandler.ReleaseMedia)                  // Lever la quarantaine (faux positif)
+			admin.GET("/media/:id/metadata", mediaHandler.GetMediaMetadata)              // Métadonnées EXIF d'origine (si conservées)
+			admin.GET("/media/quotas", mediaHandler.GetStorageQuotas)                    // Quotas de stockage (utilisateur, rôle, groupe)
+			admin.POST("/media/quotas", mediaHandler.CreateStorageQuota)                 // Créer un quota
+			admin.PUT("/media/quotas/:id", mediaHandler.UpdateStorageQuota)              // Modifier les limites d'un quota
+			admin.DELETE("/media/quotas/:id", mediaHandler.DeleteStorageQuota)           // Supprimer un quota
+			admin.GET("/media/quotas/report", mediaHandler.GetStorageReport)             // Plus gros consommateurs d'espace
+			admin.OPTIONS("/media/tus", mediaHandler.TusOptions)                         // Capacités du serveur tus
+			admin.POST("/media/tus", mediaHandler.TusCreate)                             // Créer un upload reprenable (tus)
+			admin.HEAD("/media/tus/:upload_id", mediaHandler.TusHead)                    // Offset courant pour reprendre l'upload
+			admin.PATCH("/media/tus/:upload_id", mediaHandler.TusPatch)                  // Envoyer un morceau (crée le média à la fin)
+			admin.DELETE("/media/tus/:upload_id", mediaHandler.TusDelete)                // Annuler un upload reprenable
+			admin.GET("/media/tus/:upload_id", mediaHandler.GetTusUpload)                // État de l'upload et média créé
 		}
 
 		// Routes editor (admin et editor peuvent créer/modifier des news et événements)
 		editor := protected.Group("/editor")
-		editor.Use(authMiddleware.RequireEditor())
+		editor.Use(authMiddleware.RequireEditor(), authMiddleware.RequireContentScope("/editor/"))
 		{
 			// Gestion des news
 			editor.POST("/news", newsHandler.CreateNews)
@@ -658,6 +862,12 @@ func main() {
 
 			// Upload de médias (editors, group_admins et admins peuvent uploader)
 			editor.POST("/media/upload", mediaHandler.UploadMedia)
+			editor.OPTIONS("/media/tus", mediaHandler.TusOptions)
+			editor.POST("/media/tus", mediaHandler.TusCreate)
+			editor.HEAD("/media/tus/:upload_id", mediaHandler.TusHead)
+			editor.PATCH("/media/tus/:upload_id", mediaHandler.TusPatch)
+			editor.DELETE("/media/tus/:upload_id", mediaHandler.TusDelete)
+			editor.GET("/media/tus/:upload_id", mediaHandler.GetTusUpload)
 
 			// Gestion des événements
 			editor.POST("/events", eventsHandler.CreateEvent)
@@ -676,7 +886,7 @@ func main() {
 
 		// Routes group-admin (gestion limitée au périmètre)
 		groupAdmin := protected.Group("/group-admin")
-		groupAdmin.Use(authMiddleware.RequireGroupAdmin())
+		groupAdmin.Use(authMiddleware.RequireGroupAdmin(), authMiddleware.RequireContentScope("/group-admin/"))
 		{
 			// AppGroups (scoped)
 			groupAdmin.GET("/app-groups", groupAdminHandler.GetAppGroups)
@@ -698,6 +908,12 @@ func main() {
 
 			// Upload de médias
 			groupAdmin.POST("/media/upload", mediaHandler.UploadMedia)
+			groupAdmin.OPTIONS("/media/tus", mediaHandler.TusOptions)
+			groupAdmin.POST("/media/tus", mediaHandler.TusCreate)
+			groupAdmin.HEAD("/media/tus/:upload_id", mediaHandler.TusHead)
+			groupAdmin.PATCH("/media/tus/:upload_id", mediaHandler.TusPatch)
+			groupAdmin.DELETE("/media/tus/:upload_id", mediaHandler.TusDelete)
+			groupAdmin.GET("/media/tus/:upload_id", mediaHandler.GetTusUpload)
 
 			// Tags (group admin peut créer/modifier des tags)
 			groupAdmin.POST("/news/tags", newsHandler.CreateTag)
@@ -724,6 +940,13 @@ func main() {
 
 			// Info sur les groupes administrés
 			groupAdmin.GET("/managed-groups", groupAdminHandler.GetManagedGroups)
+
+			// Invitations dans les groupes administrés (rôle user uniquement)
+			groupAdmin.GET("/invitations", invitationHandler.ListInvitations)
+			groupAdmin.POST("/invitations", invitationHandler.CreateInvitation)
+			groupAdmin.POST("/invitations/bulk", invitationHandler.CreateBulkInvitations)
+			groupAdmin.POST("/invitations/:id/resend", invitationHandler.ResendInvitation)
+			groupAdmin.DELETE("/invitations/:id", invitationHandler.RevokeInvitation)
 		}
 	}
 
@@ -1044,6 +1267,7 @@ func createDefaultOAuthProviders(db *gorm.DB, cfg *config.Config, adminUser mode
 			UserInfoURL:  "https://www.googleapis.com/oauth2/v2/userinfo",
 			Scopes:       "openid email profile",
 			RedirectURI:  publicURL + "/auth/oauth/google/callback",
+			IssuerURL:    "https://accounts.google.com", // JWKS résolu par découverte à la première connexion
 		}
 		if err = db.Create(&googleProvider).Error; err != nil {
 			return fmt.Errorf("failed to create Google OAuth provider: %w", err)
@@ -1408,25 +1632,25 @@ func ensureDefaultNewsTypes(db *gorm.DB) {
 }
 
 func createDefaultEmailTemplates(db *gorm.DB) error {
-	// Vérifier si des templates existent déjà
-	var count int64
-	if err := db.Model(&models.EmailTemplate{}).Count(&count).Error; err != nil {
-		return fmt.Errorf("failed to count email templates: %w", err)
-	}
-
-	if count > 0 {
-		return nil // Templates déjà créés
-	}
-
-	// Créer les templates par défaut
-	templates := models.GetDefaultEmailTemplates()
-	for _, t := range templates {
+	// Créer les templates par défaut manquants (les templates existants, personnalisés ou non, sont conservés)
+	created := 0
+	for _, t := range models.GetDefaultEmailTemplates() {
+		var count int64
+		if err := db.Model(&models.EmailTemplate{}).Where("type = ?", t.Type).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count email templates: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
 		if err := db.Create(&t).Error; err != nil {
 			return fmt.Errorf("failed to create email template %s: %w", t.Type, err)
 		}
+		created++
 	}
 
-	log.Println("✅ Templates d'email par défaut créés (4 templates)")
+	if created > 0 {
+		log.Printf("✅ Templates d'email par défaut créés (%d templates)", created)
+	}
 	return nil
 }
 
@@ -2,12 +2,14 @@ package middleware
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"airboard/config"
 	"airboard/models"
+	"airboard/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,14 +17,43 @@ import (
 )
 
 type AuthMiddleware struct {
-	config *config.Config
-	db     *gorm.DB
+	config   *config.Config
+	db       *gorm.DB
+	sessions *services.SessionService
+	tokens   *services.AccessTokenService
+
+	impersonations *services.ImpersonationService
 }
 
 func NewAuthMiddleware(cfg *config.Config, db *gorm.DB) *AuthMiddleware {
-	return &AuthMiddleware{config: cfg, db: db}
+	return &AuthMiddleware{
+		config:   cfg,
+		db:       db,
+		sessions: services.NewSessionService(db),
+		tokens:   services.NewAccessTokenService(db),
+
+		impersonations: services.NewImpersonationService(db),
+	}
+}
+
+// Sessions retourne le service des sessions serveur (liste, révocation)
+func (am *AuthMiddleware) Sessions() *services.SessionService {
+	return am.sessions
 }
 
+// AccessTokens retourne le service des tokens d'accès personnels
+func (am *AuthMiddleware) AccessTokens() *services.AccessTokenService {
+	return am.tokens
+}
+
+// Impersonations retourne le service des sessions "voir en tant que"
+func (am *AuthMiddleware) Impersonations() *services.ImpersonationService {
+	return am.impersonations
+}
+
+// ImpersonationEndPath est la seule modification permise pendant une impersonation
+const ImpersonationEndPath = "/auth/impersonation/end"
+
 // RequireAuth middleware pour vérifier l'authentification
 func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -44,7 +75,7 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 		} else {
 			// Essayer de récupérer le token depuis les query params (pour WebSocket)
 			tokenString = c.Query("token")
-			if tokenString == "" {
+			if tokenString == "" || strings.HasPrefix(tokenString, services.AccessTokenPrefix) {
 				c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 					Error:   "Unauthorized",
 					Message: "Token d'autorisation manquant",
@@ -55,6 +86,12 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			}
 		}
 
+		// Token d'accès personnel (scripts, automatisation)
+		if strings.HasPrefix(tokenString, services.AccessTokenPrefix) {
+			am.authenticateAccessToken(c, tokenString)
+			return
+		}
+
 		// Vérifier le token
 		claims, err := am.verifyToken(tokenString)
 		if err != nil {
@@ -67,7 +104,37 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		// Vérifier que la session n'a pas été révoquée (déconnexion, compte désactivé...)
+		if err := am.validateClaims(claims, c.ClientIP()); err != nil {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "Unauthorized",
+				Message: "Session révoquée ou expirée",
+				Code:    http.StatusUnauthorized,
+			})
+			c.Abort()
+			return
+		}
+
+		// Impersonation : lecture seule, les modifications tentées sont refusées et comptées au journal
+		if claims.ImpersonationID != 0 {
+			readOnly := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions
+			if (!readOnly && !strings.HasSuffix(c.FullPath(), ImpersonationEndPath)) || strings.HasSuffix(c.FullPath(), "/ws") {
+				am.impersonations.RecordBlockedWrite(claims.ImpersonationID)
+				log.Printf("[Auth] Blocked %s %s for admin %d impersonating user %d", c.Request.Method, c.Request.URL.Path, claims.ImpersonatorID, claims.UserID)
+				c.JSON(http.StatusForbidden, models.ErrorResponse{
+					Error:   "Forbidden",
+					Message: "Action impossible en mode \"voir en tant que\" (lecture seule)",
+					Code:    http.StatusForbidden,
+				})
+				c.Abort()
+				return
+			}
+			c.Set("impersonator_id", claims.ImpersonatorID)
+			c.Set("impersonation_id", claims.ImpersonationID)
+		}
+
 		// Stocker les informations de l'utilisateur dans le contexte
+		c.Set("session_id", claims.SessionID)
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
@@ -85,6 +152,215 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	}
 }
 
+// validateClaims vérifie que la session (ou l'impersonation) d'un token d'accès est toujours active
+func (am *AuthMiddleware) validateClaims(claims *models.Claims, ipAddress string) error {
+	if claims.ImpersonationID != 0 {
+		return am.impersonations.Validate(claims.ImpersonationID, claims.ImpersonatorID, claims.UserID)
+	}
+	return am.sessions.Validate(claims.SessionID, claims.UserID, ipAddress)
+}
+
+// authenticateAccessToken authentifie une requête par token d'accès personnel. Les scopes
+// sont ensuite vérifiés par RequireScope et ses variantes sur chaque groupe de routes.
+func (am *AuthMiddleware) authenticateAccessToken(c *gin.Context, tokenString string) {
+	user, token, err := am.tokens.Authenticate(tokenString, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "Unauthorized",
+			Message: "Token d'accès invalide, expiré ou révoqué",
+			Code:    http.StatusUnauthorized,
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("email", user.Email)
+	c.Set("access_token_id", token.ID)
+	c.Set("token_scopes", token.ScopeList)
+
+	var managedGroupIDs []uint
+	am.db.Table("group_admins").
+		Where("user_id = ?", user.ID).
+		Pluck("group_id", &managedGroupIDs)
+	c.Set("managed_group_ids", managedGroupIDs)
+
+	c.Next()
+}
+
+// RequireScope vérifie le scope d'un token d'accès personnel : resource:read pour les
+// lectures, resource:write pour les modifications. Sans effet pour les sessions navigateur.
+func (am *AuthMiddleware) RequireScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireTokenScope(c, resource)
+	}
+}
+
+// RequireAdminScope vérifie le scope admin:* correspondant à la route d'administration
+func (am *AuthMiddleware) RequireAdminScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := "admin:content"
+		switch routeSection(c.FullPath(), "/admin/") {
+		case "users", "groups", "impersonations", "ldap", "invitations", "erasure-requests":
+			scope = "admin:users"
+		case "settings", "oauth", "email":
+			scope = "admin:settings"
+		}
+		checkTokenScope(c, scope)
+	}
+}
+
+// RequireContentScope vérifie le scope des routes editor et group-admin, d'après la ressource visée
+func (am *AuthMiddleware) RequireContentScope(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource := routeSection(c.FullPath(), prefix)
+		switch resource {
+		case "news", "media", "events", "comments", "polls":
+		case "app-groups", "applications", "managed-groups":
+			resource = "applications"
+		default:
+			resource = ""
+		}
+		requireTokenScope(c, resource)
+	}
+}
+
+// RequireSCIM authentifie les requêtes SCIM : token d'accès personnel d'un administrateur portant
+// le scope admin:scim. Les erreurs sont au format SCIM attendu par les IdP.
+func (am *AuthMiddleware) RequireSCIM() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || !strings.HasPrefix(tokenString, services.AccessTokenPrefix) {
+			abortSCIM(c, http.StatusUnauthorized, "Bearer access token required")
+			return
+		}
+		user, token, err := am.tokens.Authenticate(tokenString, c.ClientIP())
+		if err != nil {
+			abortSCIM(c, http.StatusUnauthorized, "Invalid, expired or revoked access token")
+			return
+		}
+		if user.Role != "admin" || !services.ScopeAllows(token.ScopeList, "admin:scim") {
+			abortSCIM(c, http.StatusForbidden, "Access token lacks the admin:scim scope")
+			return
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("access_token_id", token.ID)
+		c.Set("token_scopes", token.ScopeList)
+		c.Next()
+	}
+}
+
+func abortSCIM(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", "application/scim+json")
+	if status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Bearer realm="SCIM"`)
+	}
+	c.AbortWithStatusJSON(status, models.NewSCIMError(status, "", detail))
+}
+
+// RejectAccessToken refuse les tokens d'accès personnels (gestion des identifiants du compte)
+func (am *AuthMiddleware) RejectAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isToken := c.Get("token_scopes"); isToken {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "Forbidden",
+				Message: "Action impossible avec un token d'accès personnel",
+				Code:    http.StatusForbidden,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func requireTokenScope(c *gin.Context, resource string) {
+	if resource == "" {
+		checkTokenScope(c, "")
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		checkTokenScope(c, resource+":read")
+	default:
+		checkTokenScope(c, resource+":write")
+	}
+}
+
+// checkTokenScope refuse la requête si elle vient d'un token sans le scope requis
+// (un scope vide refuse tous les tokens)
+func checkTokenScope(c *gin.Context, scope string) {
+	value, isToken := c.Get("token_scopes")
+	if !isToken {
+		c.Next()
+		return
+	}
+	scopes, _ := value.([]string)
+	if scope == "" || !services.ScopeAllows(scopes, scope) {
+		message := "Cette route n'est pas accessible avec un token d'accès personnel"
+		if scope != "" {
+			message = fmt.Sprintf("Scope %s requis pour ce token d'accès", scope)
+		}
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "Insufficient Scope",
+			Message: message,
+			Code:    http.StatusForbidden,
+		})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// routeSection retourne le segment de route qui suit prefix (ex: "users" pour /api/v1/admin/users/:id)
+func routeSection(fullPath, prefix string) string {
+	index := strings.Index(fullPath, prefix)
+	if index < 0 {
+		return ""
+	}
+	section, _, _ := strings.Cut(fullPath[index+len(prefix):], "/")
+	return section
+}
+
+// MediaCookieName est le cookie qui authentifie le téléchargement des fichiers /uploads
+// (les balises <img> et <video> ne peuvent pas envoyer d'en-tête Authorization)
+const MediaCookieName = "airboard_media"
+
+// OptionalAuth identifie l'utilisateur s'il présente un token valide (en-tête
+// Authorization ou cookie média), sans jamais bloquer la requête
+func (am *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			tokenString, _ = c.Cookie(MediaCookieName)
+		}
+
+		if tokenString != "" {
+			if claims, err := am.verifyToken(tokenString); err == nil && am.validateClaims(claims, c.ClientIP()) == nil {
+				c.Set("session_id", claims.SessionID)
+				c.Set("user_id", claims.UserID)
+				c.Set("username", claims.Username)
+				c.Set("role", claims.Role)
+				c.Set("email", claims.Email)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// SetMediaCookie dépose le token d'accès dans un cookie limité au chemin /uploads
+func (am *AuthMiddleware) SetMediaCookie(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(MediaCookieName, token, am.config.JWT.TokenExpirationHours*3600, "/uploads", "",
+		strings.HasPrefix(am.config.Server.PublicURL, "https://"), true)
+}
+
 // RequireAdmin middleware pour vérifier les droits administrateur
 func (am *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -102,8 +378,49 @@ func (am *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
 	}
 }
 
-// GenerateToken génère un token JWT
-func (am *AuthMiddleware) GenerateToken(user *models.User) (string, error) {
+// IssueTokens ouvre une session serveur pour l'utilisateur et génère la paire token/refresh token associée
+func (am *AuthMiddleware) IssueTokens(c *gin.Context, user *models.User) (string, string, error) {
+	session, err := am.sessions.Create(user.ID, c.ClientIP(), c.Request.UserAgent(), am.refreshTTL())
+	if err != nil {
+		return "", "", fmt.Errorf("erreur création session: %w", err)
+	}
+
+	token, err := am.GenerateToken(user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := am.GenerateRefreshToken(user, session.ID, session.RefreshTokenID)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// RotateTokens remplace le refresh token présenté (claims vérifiés par VerifyRefreshToken) par un nouveau.
+// Un refresh token déjà utilisé révoque la session (services.ErrRefreshTokenReused).
+func (am *AuthMiddleware) RotateTokens(c *gin.Context, user *models.User, claims *models.Claims) (string, string, error) {
+	tokenID, err := am.sessions.Rotate(claims.SessionID, user.ID, claims.TokenID, c.ClientIP(), am.refreshTTL())
+	if err != nil {
+		return "", "", err
+	}
+
+	token, err := am.GenerateToken(user, claims.SessionID)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := am.GenerateRefreshToken(user, claims.SessionID, tokenID)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+func (am *AuthMiddleware) refreshTTL() time.Duration {
+	return time.Hour * 24 * time.Duration(am.config.JWT.RefreshExpirationDays)
+}
+
+// GenerateToken génère un token JWT rattaché à une session
+func (am *AuthMiddleware) GenerateToken(user *models.User, sessionID uint) (string, error) {
 	// Charger les groupes administrés pour tous les utilisateurs
 	var managedGroupIDs []uint
 	am.db.Table("group_admins").
@@ -118,14 +435,38 @@ func (am *AuthMiddleware) GenerateToken(user *models.User) (string, error) {
 		"managed_group_ids": managedGroupIDs,
 		"exp":               time.Now().Add(time.Hour * time.Duration(am.config.JWT.TokenExpirationHours)).Unix(),
 		"iat":               time.Now().Unix(),
+		"sid":               sessionID,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(am.config.JWT.Secret))
+}
+
+// GenerateImpersonationToken génère le token d'accès (sans refresh token) d'une session "voir en tant que"
+func (am *AuthMiddleware) GenerateImpersonationToken(user *models.User, impersonation *models.Impersonation) (string, error) {
+	var managedGroupIDs []uint
+	am.db.Table("group_admins").
+		Where("user_id = ?", user.ID).
+		Pluck("group_id", &managedGroupIDs)
+
+	claims := jwt.MapClaims{
+		"user_id":           user.ID,
+		"username":          user.Username,
+		"role":              user.Role,
+		"email":             user.Email,
+		"managed_group_ids": managedGroupIDs,
+		"exp":               impersonation.ExpiresAt.Unix(),
+		"iat":               time.Now().Unix(),
+		"imp":               impersonation.AdminID,
+		"impid":             impersonation.ID,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(am.config.JWT.Secret))
 }
 
-// GenerateRefreshToken génère un refresh token
-func (am *AuthMiddleware) GenerateRefreshToken(user *models.User) (string, error) {
+// GenerateRefreshToken génère un refresh token ; tokenID (jti) est celui enregistré dans la session
+func (am *AuthMiddleware) GenerateRefreshToken(user *models.User, sessionID uint, tokenID string) (string, error) {
 	// Charger les groupes administrés pour tous les utilisateurs
 	var managedGroupIDs []uint
 	am.db.Table("group_admins").
@@ -138,9 +479,11 @@ func (am *AuthMiddleware) GenerateRefreshToken(user *models.User) (string, error
 		"role":              user.Role,
 		"email":             user.Email,
 		"managed_group_ids": managedGroupIDs,
-		"exp":               time.Now().Add(time.Hour * 24 * time.Duration(am.config.JWT.RefreshExpirationDays)).Unix(),
+		"exp":               time.Now().Add(am.refreshTTL()).Unix(),
 		"iat":               time.Now().Unix(),
 		"type":              "refresh",
+		"sid":               sessionID,
+		"jti":               tokenID,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -169,6 +512,11 @@ func (am *AuthMiddleware) verifyToken(tokenString string) (*models.Claims, error
 		return nil, jwt.ErrTokenInvalidClaims
 	}
 
+	// Un refresh token ne doit pas servir de token d'accès
+	if tokenType, ok := claims["type"].(string); ok && tokenType == "refresh" {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
 	// Vérifier l'expiration
 	if exp, ok := claims["exp"].(float64); ok {
 		if time.Now().Unix() > int64(exp) {
@@ -186,6 +534,11 @@ func (am *AuthMiddleware) verifyToken(tokenString string) (*models.Claims, error
 		}
 	}
 
+	// Les tokens émis avant les sessions serveur n'ont pas de sid : ils sont refusés
+	sessionID, _ := claims["sid"].(float64)
+	impersonatorID, _ := claims["imp"].(float64)
+	impersonationID, _ := claims["impid"].(float64)
+
 	// Extraire les informations utilisateur
 	userClaims := &models.Claims{
 		UserID:          uint(claims["user_id"].(float64)),
@@ -193,6 +546,9 @@ func (am *AuthMiddleware) verifyToken(tokenString string) (*models.Claims, error
 		Role:            claims["role"].(string),
 		Email:           claims["email"].(string),
 		ManagedGroupIDs: managedGroupIDs,
+		SessionID:       uint(sessionID),
+		ImpersonatorID:  uint(impersonatorID),
+		ImpersonationID: uint(impersonationID),
 	}
 
 	return userClaims, nil
@@ -247,6 +603,12 @@ func (am *AuthMiddleware) VerifyRefreshToken(tokenString string) (*models.Claims
 		}
 	}
 
+	sessionID, _ := claims["sid"].(float64)
+	tokenID, _ := claims["jti"].(string)
+	if sessionID == 0 || tokenID == "" {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
 	// Extraire les informations utilisateur
 	userClaims := &models.Claims{
 		UserID:          uint(claims["user_id"].(float64)),
@@ -254,6 +616,8 @@ func (am *AuthMiddleware) VerifyRefreshToken(tokenString string) (*models.Claims
 		Role:            claims["role"].(string),
 		Email:           claims["email"].(string),
 		ManagedGroupIDs: managedGroupIDs,
+		SessionID:       uint(sessionID),
+		TokenID:         tokenID,
 	}
 
 	return userClaims, nil
@@ -65,6 +65,7 @@ func createSecureCORSConfig(cfg *config.Config) *SecureCORSConfig {
 			"PUT",     // Mise à jour (protégée par CSRF)
 			"PATCH",   // Modification partielle (protégée par CSRF)
 			"DELETE",  // Suppression (protégée par CSRF)
+			"HEAD",    // Reprise d'upload (tus)
 			"OPTIONS", // Preflight requis
 		},
 		AllowedHeaders: []string{
@@ -74,10 +75,23 @@ func createSecureCORSConfig(cfg *config.Config) *SecureCORSConfig {
 			"Authorization",    // Bearer token (sécurisé)
 			"X-CSRF-Token",     // Protection CSRF
 			"X-Requested-With", // AJAX requests
+			"Tus-Resumable",    // Uploads reprenables (tus)
+			"Upload-Length",
+			"Upload-Offset",
+			"Upload-Metadata",
 		},
 		ExposedHeaders: []string{
 			"Content-Length", // Informations de taille
 			"X-Total-Count",  // Pagination info
+			"Location",       // Uploads reprenables (tus)
+			"Tus-Resumable",
+			"Tus-Version",
+			"Tus-Extension",
+			"Tus-Max-Size",
+			"Upload-Offset",
+			"Upload-Length",
+			"Upload-Expires",
+			"Upload-Media-Id",
 		},
 		AllowCredentials: shouldAllowCredentials(allowedOrigins),
 		MaxAge:           time.Duration(3600) * time.Second, // 1 heure max (réduit pour sécurité)
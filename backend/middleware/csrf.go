@@ -3,13 +3,17 @@ package middleware
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"airboard/models"
+	"airboard/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,16 +24,17 @@ type CSRFToken struct {
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
+// CSRFTokenTTL est la durée de validité d'un token CSRF
+const CSRFTokenTTL = time.Hour
+
 // CSRFManager gère les tokens CSRF
 type CSRFManager struct {
-	tokens map[string]CSRFToken // userID -> CSRFToken
+	store utils.StateStore // Token courant de chaque utilisateur (clé "csrf:" + userID)
 }
 
 // NewCSRFManager crée un nouveau gestionnaire CSRF
-func NewCSRFManager() *CSRFManager {
-	return &CSRFManager{
-		tokens: make(map[string]CSRFToken),
-	}
+func NewCSRFManager(store utils.StateStore) *CSRFManager {
+	return &CSRFManager{store: store}
 }
 
 // generateToken génère un token CSRF aléatoire
@@ -44,20 +49,20 @@ func (csm *CSRFManager) generateToken() string {
 }
 
 // generateCSRFForUser génère un token CSRF pour un utilisateur
-func (csm *CSRFManager) generateCSRFForUser(userID uint) string {
-	// Nettoyer les anciens tokens expirés
-	csm.cleanupExpiredTokens()
-
-	// Générer un nouveau token
-	token := csm.generateToken()
-
+func (csm *CSRFManager) generateCSRFForUser(userID uint) (CSRFToken, error) {
 	// Stocker avec expiration (1 heure)
-	csm.tokens[fmt.Sprintf("%d", userID)] = CSRFToken{
-		Token:     token,
-		ExpiresAt: time.Now().Add(time.Hour),
+	csrfToken := CSRFToken{
+		Token:     csm.generateToken(),
+		ExpiresAt: time.Now().Add(CSRFTokenTTL),
 	}
-
-	return token
+	value, err := json.Marshal(csrfToken)
+	if err != nil {
+		return CSRFToken{}, err
+	}
+	if err := csm.store.Set(csrfKey(userID), value, CSRFTokenTTL); err != nil {
+		return CSRFToken{}, err
+	}
+	return csrfToken, nil
 }
 
 // validateCSRFToken valide un token CSRF
@@ -66,29 +71,27 @@ func (csm *CSRFManager) validateCSRFToken(userID uint, token string) bool {
 		return false
 	}
 
-	userToken, exists := csm.tokens[fmt.Sprintf("%d", userID)]
-	if !exists {
+	value, err := csm.store.Get(csrfKey(userID))
+	if err != nil {
+		log.Printf("[CSRF] Erreur lors de la lecture du token: %v", err)
+		return false
+	}
+	var userToken CSRFToken
+	if value == nil || json.Unmarshal(value, &userToken) != nil {
 		return false
 	}
 
 	// Vérifier l'expiration
 	if time.Now().After(userToken.ExpiresAt) {
-		delete(csm.tokens, fmt.Sprintf("%d", userID))
 		return false
 	}
 
 	// Vérifier la correspondance
-	return userToken.Token == token
+	return subtle.ConstantTimeCompare([]byte(userToken.Token), []byte(token)) == 1
 }
 
-// cleanupExpiredTokens supprime les tokens expirés
-func (csm *CSRFManager) cleanupExpiredTokens() {
-	now := time.Now()
-	for userID, tokenData := range csm.tokens {
-		if now.After(tokenData.ExpiresAt) {
-			delete(csm.tokens, userID)
-		}
-	}
+func csrfKey(userID uint) string {
+	return fmt.Sprintf("csrf:%d", userID)
 }
 
 // CSRFProtection middleware pour protéger les requêtes de mutation
@@ -159,11 +162,19 @@ func CSRFTokenHandler(csrfManager *CSRFManager) gin.HandlerFunc {
 		}
 
 		// Générer un nouveau token CSRF
-		token := csrfManager.generateCSRFForUser(userID.(uint))
+		token, err := csrfManager.generateCSRFForUser(userID.(uint))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Internal Server Error",
+				Message: "Erreur lors de la génération du token CSRF",
+				Code:    http.StatusInternalServerError,
+			})
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"csrf_token": token,
-			"expires_at": time.Now().Add(time.Hour),
+			"csrf_token": token.Token,
+			"expires_at": token.ExpiresAt,
 		})
 	}
 }
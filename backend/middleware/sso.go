@@ -4,87 +4,138 @@ import (
 	"airboard/config"
 	"airboard/services"
 	"log"
+	"net"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// SSOMiddleware détecte et traite les headers Authentik pour l'authentification SSO
+// SSOMiddleware détecte et traite les headers d'un proxy d'authentification (Authentik,
+// oauth2-proxy, ...) pour l'authentification SSO
 type SSOMiddleware struct {
-	db        *gorm.DB
-	config    *config.Config
-	ssoMapper *services.SSOMapper
+	db             *gorm.DB
+	config         *config.Config
+	ssoMapper      *services.SSOMapper
+	headers        config.SSOHeaderProfile
+	trustedProxies []*net.IPNet
 }
 
 // NewSSOMiddleware crée une nouvelle instance de SSOMiddleware
 func NewSSOMiddleware(db *gorm.DB, cfg *config.Config) *SSOMiddleware {
-	return &SSOMiddleware{
-		db:        db,
-		config:    cfg,
-		ssoMapper: services.NewSSOMapper(db, cfg),
+	m := &SSOMiddleware{
+		db:             db,
+		config:         cfg,
+		ssoMapper:      services.NewSSOMapper(db, cfg),
+		headers:        cfg.SSO.Headers,
+		trustedProxies: parseTrustedProxies(cfg.SSO.TrustedProxies),
 	}
+
+	if cfg.SSO.Enabled {
+		if m.headers.Email == "" {
+			log.Printf("⚠️ SSO activé sans header email (SSO_HEADER_EMAIL) : la détection SSO par headers est inactive")
+		} else {
+			log.Printf("[SSO] Profil de headers %s (email: %s, proxys de confiance: %v)",
+				m.headers.Label, m.headers.Email, cfg.SSO.TrustedProxies)
+		}
+	}
+
+	return m
+}
+
+// parseTrustedProxies convertit la liste SSO_TRUSTED_PROXIES (CIDR ou adresses IP seules)
+func parseTrustedProxies(entries []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Printf("⚠️ SSO_TRUSTED_PROXIES: adresse invalide ignorée: %s", entry)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("⚠️ SSO_TRUSTED_PROXIES: réseau invalide ignoré: %s", entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isTrustedProxy vérifie que l'adresse appartient à un réseau de SSO_TRUSTED_PROXIES
+func (m *SSOMiddleware) isTrustedProxy(remoteIP string) bool {
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, network := range m.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
-// DetectSSO détecte si la requête contient des headers Authentik
+// DetectSSO détecte si la requête contient les headers du proxy d'authentification configuré
 func (m *SSOMiddleware) DetectSSO() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Si SSO n'est pas activé, passer
-		if !m.config.SSO.Enabled {
+		if !m.config.SSO.Enabled || m.headers.Email == "" {
 			c.Next()
 			return
 		}
 
-		// Vérifier la présence des headers Authentik
-		email := c.GetHeader("X-authentik-email")
-		username := c.GetHeader("X-authentik-username")
+		// Vérifier la présence des headers SSO (l'email sert de username à défaut)
+		email := strings.TrimSpace(c.GetHeader(m.headers.Email))
+		username := ""
+		if m.headers.Username != "" {
+			username = strings.TrimSpace(c.GetHeader(m.headers.Username))
+		}
+		if username == "" {
+			username = email
+		}
 
 		// Si pas de headers SSO, continuer normalement (mode classique)
-		if email == "" || username == "" {
+		if email == "" {
 			c.Next()
 			return
 		}
 
-		// SECURITY: Valider que les headers SSO proviennent d'une source de confiance
-		clientIP := c.ClientIP()
-		trustedSources := []string{
-			"127.0.0.1",
-			"::1",
-			"localhost",
-			"172.", // Docker networks (commence par 172.)
-			"192.168.", // Private networks
-			"10.", // Private networks
-		}
-
-		isTrusted := false
-		for _, trusted := range trustedSources {
-			if clientIP == trusted || strings.HasPrefix(clientIP, trusted) {
-				isTrusted = true
-				break
-			}
-		}
-
-		if !isTrusted {
+		// SECURITY: Valider que les headers SSO proviennent d'une source de confiance.
+		// On contrôle l'adresse du pair direct (le proxy qui a injecté les headers) et non
+		// c.ClientIP(), qui suit X-Forwarded-For et désigne le navigateur de l'utilisateur.
+		remoteIP := c.RemoteIP()
+		if !m.isTrustedProxy(remoteIP) {
 			log.Printf("[SECURITY] Tentative de SSO spoofing détectée depuis IP non autorisée: %s (email: %s, username: %s)",
-				clientIP, email, username)
+				remoteIP, email, username)
 			c.JSON(403, gin.H{
-				"error": "Forbidden",
+				"error":   "Forbidden",
 				"message": "Headers SSO non autorisés depuis cette source",
 			})
 			c.Abort()
 			return
 		}
 
-		log.Printf("[SSO] Headers Authentik détectés pour: %s (%s) depuis IP autorisée: %s", username, email, clientIP)
+		log.Printf("[SSO] Headers %s détectés pour: %s (%s) depuis IP autorisée: %s", m.headers.Label, username, email, remoteIP)
 
 		// Extraire les informations SSO
 		ssoInfo := &services.SSOUserInfo{
 			Email:     email,
 			Username:  username,
-			FirstName: c.GetHeader("X-authentik-name"),
+			FirstName: m.header(c, m.headers.Name),
 			LastName:  "",
-			Groups:    parseGroups(c.GetHeader("X-authentik-groups")),
-			SSOID:     c.GetHeader("X-authentik-uid"),
+			Groups:    parseGroups(m.header(c, m.headers.Groups), m.headers.GroupSeparator),
+			SSOID:     m.header(c, m.headers.UID),
 		}
 
 		// Séparer FirstName et LastName si nécessaire
@@ -114,32 +165,32 @@ func (m *SSOMiddleware) DetectSSO() gin.HandlerFunc {
 	}
 }
 
-// parseGroups parse le header X-authentik-groups qui peut être sous différents formats
-func parseGroups(groupsHeader string) []string {
+// header lit un header optionnel du profil (nom vide : header non fourni par le proxy)
+func (m *SSOMiddleware) header(c *gin.Context, name string) string {
+	if name == "" {
+		return ""
+	}
+	return strings.TrimSpace(c.GetHeader(name))
+}
+
+// parseGroups découpe le header des groupes selon le séparateur du profil
+// (Authentik utilise "|", la plupart des autres proxys ",")
+func parseGroups(groupsHeader, separator string) []string {
 	if groupsHeader == "" {
 		return []string{}
 	}
+	if separator == "" {
+		separator = ","
+	}
 
 	var groups []string
-
-	// Essayer de parser comme liste séparée par des virgules
-	for _, group := range strings.Split(groupsHeader, ",") {
+	for _, group := range strings.Split(groupsHeader, separator) {
 		trimmed := strings.TrimSpace(group)
 		if trimmed != "" {
 			groups = append(groups, trimmed)
 		}
 	}
 
-	// Si pas de groupes trouvés, essayer avec des pipes (|)
-	if len(groups) == 0 {
-		for _, group := range strings.Split(groupsHeader, "|") {
-			trimmed := strings.TrimSpace(group)
-			if trimmed != "" {
-				groups = append(groups, trimmed)
-			}
-		}
-	}
-
 	log.Printf("[SSO] Groupes parsés: %v", groups)
 	return groups
 }
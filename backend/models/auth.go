@@ -0,0 +1,288 @@
+package models
+
+import (
+	"time"
+)
+
+// TwoFactorAuth contient le secret TOTP d'un utilisateur (chiffré comme les tokens OAuth email)
+type TwoFactorAuth struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"uniqueIndex;not null"`
+	Secret       string     `json:"-" gorm:"type:text;not null"`  // Secret base32 chiffré (AES-256)
+	Enabled      bool       `json:"enabled" gorm:"default:false"` // false tant que l'enrôlement n'est pas confirmé par un code
+	LastUsedStep int64      `json:"-" gorm:"default:0"`           // Dernier pas TOTP accepté (anti-rejeu)
+	EnabledAt    *time.Time `json:"enabled_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode est un code de secours à usage unique (seule l'empreinte est stockée)
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash  string     `json:"-" gorm:"size:64;index;not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginChallenge représente une connexion en attente du second facteur
+// (mot de passe vérifié, JWT pas encore délivré). Seule l'empreinte du token est stockée.
+type LoginChallenge struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TokenHash string    `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	Purpose   string    `json:"purpose" gorm:"size:20;not null"` // verify (code demandé), setup (enrôlement obligatoire), password (mot de passe expiré), saml (retour de l'IdP)
+	Attempts  int       `json:"attempts" gorm:"default:0"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TwoFactorChallengeResponse est renvoyée par la connexion quand un second facteur est requis
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool     `json:"two_factor_required"`
+	SetupRequired     bool     `json:"setup_required"` // Le rôle impose la double authentification mais elle n'est pas configurée
+	ChallengeToken    string   `json:"challenge_token"`
+	ExpiresIn         int      `json:"expires_in"` // Secondes
+	Methods           []string `json:"methods"`    // totp, recovery_code
+}
+
+// TwoFactorLoginRequest termine une connexion avec un code TOTP ou un code de récupération
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// TwoFactorChallengeRequest démarre l'enrôlement imposé pendant la connexion
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// TwoFactorCodeRequest confirme une action avec un code TOTP (ou de récupération)
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorDisableRequest désactive la double authentification
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorSetupResponse contient le secret à enregistrer dans l'application d'authentification
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorStatusResponse décrit l'état de la double authentification d'un utilisateur
+type TwoFactorStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"` // Imposée par le rôle
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// WebAuthnCredential est une passkey (clé publique WebAuthn) enregistrée par un utilisateur
+type WebAuthnCredential struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	UserID          uint       `json:"user_id" gorm:"index;not null"`
+	CredentialID    string     `json:"-" gorm:"size:255;uniqueIndex;not null"` // Identifiant de l'authentificateur (base64url)
+	PublicKey       []byte     `json:"-" gorm:"not null"`                      // Clé publique COSE
+	AttestationType string     `json:"attestation_type" gorm:"size:50"`
+	Transports      string     `json:"transports" gorm:"size:255"` // usb, nfc, ble, internal, hybrid (séparés par des virgules)
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-" gorm:"default:0"`
+	Flags           uint8      `json:"-" gorm:"default:0"`                // Drapeaux de l'authentificateur (UV, BE, BS...)
+	Name            string     `json:"name" gorm:"size:100"`              // Libellé choisi par l'utilisateur
+	BackupState     bool       `json:"backup_state" gorm:"default:false"` // Passkey synchronisée (iCloud, Google...)
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// WebAuthnSession conserve l'état d'une cérémonie WebAuthn (enregistrement ou connexion)
+// entre ses deux étapes. Seule l'empreinte de l'identifiant de session est stockée.
+type WebAuthnSession struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TokenHash string    `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"index;default:0"`  // 0 pour une connexion par passkey découvrable
+	Purpose   string    `json:"purpose" gorm:"size:20;not null"` // registration, login
+	Name      string    `json:"name" gorm:"size:100"`            // Libellé de la passkey en cours d'enregistrement
+	Data      string    `json:"-" gorm:"type:text;not null"`     // webauthn.SessionData sérialisée en JSON
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebAuthnRegistrationRequest démarre l'enregistrement d'une passkey
+type WebAuthnRegistrationRequest struct {
+	Name string `json:"name" binding:"max=100"`
+}
+
+// WebAuthnLoginRequest démarre une connexion par passkey (identifiant facultatif : passkey découvrable)
+type WebAuthnLoginRequest struct {
+	Username string `json:"username"`
+}
+
+// WebAuthnCeremonyResponse contient les options à transmettre à navigator.credentials
+// et l'identifiant de session à renvoyer à l'étape finish
+type WebAuthnCeremonyResponse struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+	ExpiresIn int         `json:"expires_in"` // Secondes
+}
+
+// Session est une session de connexion côté serveur : chaque paire JWT/refresh token y est rattachée,
+// ce qui permet de lister les appareils connectés et de révoquer leurs tokens avant expiration.
+type Session struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	UserID         uint       `json:"user_id" gorm:"index;not null"`
+	RefreshTokenID string     `json:"-" gorm:"size:64;index;not null"` // jti du refresh token courant (change à chaque rotation)
+	Device         string     `json:"device" gorm:"size:100"`          // Navigateur et système déduits du User-Agent
+	IPAddress      string     `json:"ip_address" gorm:"size:45"`
+	UserAgent      string     `json:"user_agent" gorm:"size:512"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" gorm:"index"`
+	RevokedReason  string     `json:"revoked_reason,omitempty" gorm:"size:50"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Current        bool       `json:"current" gorm:"-"` // Session de la requête en cours
+}
+
+// PasswordResetToken est un lien de réinitialisation de mot de passe à usage unique (seule l'empreinte est stockée)
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"index"`
+	UsedAt    *time.Time `json:"used_at"`
+	RequestIP string     `json:"request_ip" gorm:"size:45"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PasswordHistory conserve l'empreinte des derniers mots de passe d'un utilisateur (non-réutilisation)
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"index;not null"`
+	PasswordHash string    `json:"-" gorm:"size:255;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PasswordChangeRequiredResponse est renvoyée par la connexion quand le mot de passe a expiré
+// ou doit être changé ; le nouveau mot de passe est envoyé avec le challenge
+type PasswordChangeRequiredResponse struct {
+	PasswordChangeRequired bool   `json:"password_change_required"`
+	Reason                 string `json:"reason"` // expired, admin
+	ChallengeToken         string `json:"challenge_token"`
+	ExpiresIn              int    `json:"expires_in"` // Secondes
+}
+
+// ExpiredPasswordChangeRequest remplace un mot de passe expiré pendant la connexion
+type ExpiredPasswordChangeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	NewPassword    string `json:"new_password" binding:"required"`
+}
+
+// SAMLCompleteRequest échange le challenge transmis au retour de l'IdP SAML contre les tokens
+type SAMLCompleteRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// PasswordResetRequest demande un lien de réinitialisation
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// EmailVerificationRequest confirme l'adresse email avec les paramètres du lien signé
+type EmailVerificationRequest struct {
+	UserID    uint   `json:"uid" binding:"required"`
+	Expires   int64  `json:"expires" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+}
+
+// EmailVerificationResendRequest redemande le lien de confirmation d'une inscription
+type EmailVerificationResendRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RegistrationPendingResponse est retournée à l'inscription lorsque l'email doit être confirmé
+type RegistrationPendingResponse struct {
+	Message                   string `json:"message"`
+	Email                     string `json:"email"`
+	EmailVerificationRequired bool   `json:"email_verification_required"`
+}
+
+// PasswordResetConfirmRequest définit le nouveau mot de passe avec le token reçu par email
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// StateEntry est une entrée de l'état partagé entre instances (verrouillages de connexion,
+// tokens CSRF, états OAuth). La valeur est un document JSON propre à chaque usage.
+type StateEntry struct {
+	Key       string    `gorm:"primaryKey;size:255"`
+	Value     []byte    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// AccessToken est un token d'accès personnel pour l'automatisation de l'API (seule l'empreinte est stockée)
+type AccessToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	Name        string     `json:"name" gorm:"size:100;not null"`
+	TokenHash   string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Prefix      string     `json:"prefix" gorm:"size:16"` // Début du token, pour le reconnaître dans la liste
+	Scopes      string     `json:"-" gorm:"not null"`     // Scopes séparés par des virgules (ex: "news:write,events:read")
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"index"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip" gorm:"size:45"`
+	CreatedByID *uint      `json:"created_by_id"` // Administrateur ayant créé le token pour un compte de service
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	ScopeList []string `json:"scopes" gorm:"-"`
+}
+
+// AccessTokenRequest crée un token d'accès personnel
+type AccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // 90 jours par défaut
+}
+
+// AccessTokenCreatedResponse contient le token en clair, affiché une seule fois
+type AccessTokenCreatedResponse struct {
+	Token       string      `json:"token"`
+	AccessToken AccessToken `json:"access_token"`
+}
+
+// Impersonation trace une session "voir en tant que" d'un administrateur (journal d'audit).
+// Les noms d'utilisateur sont copiés pour que le journal survive à la suppression des comptes.
+type Impersonation struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	AdminID        uint       `json:"admin_id" gorm:"index;not null"`
+	AdminUsername  string     `json:"admin_username" gorm:"size:255"`
+	TargetUserID   uint       `json:"target_user_id" gorm:"index;not null"`
+	TargetUsername string     `json:"target_username" gorm:"size:255"`
+	Reason         string     `json:"reason" gorm:"size:500"`
+	IPAddress      string     `json:"ip_address" gorm:"size:45"`
+	UserAgent      string     `json:"user_agent" gorm:"size:512"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at"`
+	BlockedWrites  int        `json:"blocked_writes" gorm:"default:0"` // Modifications tentées puis refusées pendant la session
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ImpersonationRequest démarre une session "voir en tant que"
+type ImpersonationRequest struct {
+	Reason          string `json:"reason" binding:"required,min=3,max=500"` // Ticket ou motif, conservé dans le journal
+	DurationMinutes int    `json:"duration_minutes" binding:"omitempty,min=1,max=60"`
+}
+
+// ImpersonationResponse contient le token (lecture seule) de l'utilisateur consulté
+type ImpersonationResponse struct {
+	Token         string        `json:"token"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	User          User          `json:"user"`
+	Impersonation Impersonation `json:"impersonation"`
+}
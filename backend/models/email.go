@@ -340,6 +340,136 @@ body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helv
 </div>
 </div>
 </body>
+</html>`,
+		},
+		{
+			Type:      "password_reset",
+			Name:      "Réinitialisation du mot de passe",
+			Subject:   "{{.AppName}} - Réinitialisation de votre mot de passe",
+			IsEnabled: true,
+			HTMLBody: `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f5f5f5; }
+.container { max-width: 600px; margin: 0 auto; background: white; }
+.header { background: linear-gradient(135deg, #3B82F6 0%, #2563EB 100%); color: white; padding: 30px; text-align: center; }
+.header h1 { margin: 0; font-size: 24px; font-weight: 600; }
+.content { padding: 30px; }
+.content h2 { color: #1f2937; margin-top: 0; font-size: 22px; }
+.notice { background: #f8fafc; border-left: 4px solid #3B82F6; padding: 15px; margin: 20px 0; border-radius: 0 8px 8px 0; color: #4b5563; font-size: 14px; }
+.button { display: inline-block; padding: 12px 24px; background: #3B82F6; color: white; text-decoration: none; border-radius: 8px; font-weight: 500; margin-top: 20px; }
+.button:hover { background: #2563EB; }
+.footer { background: #f8fafc; padding: 20px; text-align: center; color: #6b7280; font-size: 12px; }
+</style>
+</head>
+<body>
+<div class="container">
+<div class="header">
+<h1>{{.AppName}}</h1>
+</div>
+<div class="content">
+<h2>Bonjour {{.Name}},</h2>
+<p>Une réinitialisation du mot de passe de votre compte <strong>{{.Username}}</strong> a été demandée.</p>
+<a href="{{.Link}}" class="button">Choisir un nouveau mot de passe</a>
+<div class="notice">Ce lien est valable {{.ExpiresIn}} et ne peut être utilisé qu'une seule fois. Toutes vos sessions seront déconnectées après la réinitialisation.</div>
+<p>Si vous n'êtes pas à l'origine de cette demande, ignorez cet email : votre mot de passe reste inchangé.</p>
+</div>
+<div class="footer">
+<p>Cet email a été envoyé suite à une demande effectuée sur {{.AppName}}.</p>
+<p>© {{.AppName}}</p>
+</div>
+</div>
+</body>
+</html>`,
+		},
+		{
+			Type:      "email_verification",
+			Name:      "Confirmation de l'adresse email",
+			Subject:   "{{.AppName}} - Confirmez votre adresse email",
+			IsEnabled: true,
+			HTMLBody: `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f5f5f5; }
+.container { max-width: 600px; margin: 0 auto; background: white; }
+.header { background: linear-gradient(135deg, #3B82F6 0%, #2563EB 100%); color: white; padding: 30px; text-align: center; }
+.header h1 { margin: 0; font-size: 24px; font-weight: 600; }
+.content { padding: 30px; }
+.content h2 { color: #1f2937; margin-top: 0; font-size: 22px; }
+.notice { background: #f8fafc; border-left: 4px solid #3B82F6; padding: 15px; margin: 20px 0; border-radius: 0 8px 8px 0; color: #4b5563; font-size: 14px; }
+.button { display: inline-block; padding: 12px 24px; background: #3B82F6; color: white; text-decoration: none; border-radius: 8px; font-weight: 500; margin-top: 20px; }
+.button:hover { background: #2563EB; }
+.footer { background: #f8fafc; padding: 20px; text-align: center; color: #6b7280; font-size: 12px; }
+</style>
+</head>
+<body>
+<div class="container">
+<div class="header">
+<h1>{{.AppName}}</h1>
+</div>
+<div class="content">
+<h2>Bonjour {{.Name}},</h2>
+<p>Merci pour votre inscription. Pour activer votre compte <strong>{{.Username}}</strong>, confirmez votre adresse email.</p>
+<a href="{{.Link}}" class="button">Confirmer mon adresse email</a>
+<div class="notice">Ce lien est valable {{.ExpiresIn}}. Les inscriptions non confirmées sont supprimées automatiquement.</div>
+<p>Si vous n'êtes pas à l'origine de cette inscription, ignorez cet email.</p>
+</div>
+<div class="footer">
+<p>Cet email a été envoyé suite à une inscription sur {{.AppName}}.</p>
+<p>© {{.AppName}}</p>
+</div>
+</div>
+</body>
+</html>`,
+		},
+		{
+			Type:      "invitation",
+			Name:      "Invitation",
+			Subject:   "{{.AppName}} - {{.InvitedBy}} vous invite à créer votre compte",
+			IsEnabled: true,
+			HTMLBody: `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; line-height: 1.6; color: #333; margin: 0; padding: 0; background-color: #f5f5f5; }
+.container { max-width: 600px; margin: 0 auto; background: white; }
+.header { background: linear-gradient(135deg, #3B82F6 0%, #2563EB 100%); color: white; padding: 30px; text-align: center; }
+.header h1 { margin: 0; font-size: 24px; font-weight: 600; }
+.content { padding: 30px; }
+.content h2 { color: #1f2937; margin-top: 0; font-size: 22px; }
+.notice { background: #f8fafc; border-left: 4px solid #3B82F6; padding: 15px; margin: 20px 0; border-radius: 0 8px 8px 0; color: #4b5563; font-size: 14px; }
+.button { display: inline-block; padding: 12px 24px; background: #3B82F6; color: white; text-decoration: none; border-radius: 8px; font-weight: 500; margin-top: 20px; }
+.button:hover { background: #2563EB; }
+.footer { background: #f8fafc; padding: 20px; text-align: center; color: #6b7280; font-size: 12px; }
+</style>
+</head>
+<body>
+<div class="container">
+<div class="header">
+<h1>{{.AppName}}</h1>
+</div>
+<div class="content">
+<h2>Bonjour,</h2>
+<p><strong>{{.InvitedBy}}</strong> vous invite à rejoindre {{.AppName}} avec l'adresse <strong>{{.Email}}</strong>.</p>
+{{if .Groups}}<p>Vous serez membre des groupes : {{.Groups}}.</p>{{end}}
+<a href="{{.Link}}" class="button">Créer mon compte</a>
+<div class="notice">Ce lien est valable {{.ExpiresIn}} et ne peut être utilisé qu'une seule fois. Vous choisirez votre identifiant et votre mot de passe.</div>
+<p>Si vous ne vous attendiez pas à cette invitation, ignorez cet email.</p>
+</div>
+<div class="footer">
+<p>Cet email a été envoyé par un administrateur de {{.AppName}}.</p>
+<p>© {{.AppName}}</p>
+</div>
+</div>
+</body>
 </html>`,
 		},
 	}
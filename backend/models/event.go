@@ -35,6 +35,9 @@ type Event struct {
 	Status        string `json:"status" gorm:"size:20;default:'confirmed'"` // confirmed, tentative, cancelled
 	CoverImage    string `json:"cover_image" gorm:"size:500"`
 
+	// Variantes redimensionnées de l'image de couverture (non persistées)
+	CoverImageVariants []MediaVariant `json:"cover_image_variants,omitempty" gorm:"-"`
+
 	// Publication
 	IsPublished bool       `json:"is_published" gorm:"default:false;index"`
 	PublishedAt *time.Time `json:"published_at"`
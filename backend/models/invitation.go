@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuts d'une invitation (calculés à la lecture, voir Invitation.CurrentStatus)
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationExpired  = "expired"
+	InvitationRevoked  = "revoked"
+)
+
+// Invitation est une invitation à créer un compte local, envoyée par email par un administrateur
+// ou un administrateur de groupe. Le lien est signé (seule l'invitation est stockée) et le
+// renvoi d'une invitation remplace son expiration, ce qui invalide le lien précédent.
+type Invitation struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	Email             string     `json:"email" gorm:"size:255;index;not null"`
+	Role              string     `json:"role" gorm:"size:20;not null;default:'user'"` // Rôle attribué à l'acceptation
+	Groups            []Group    `json:"groups" gorm:"many2many:invitation_groups;"`  // Groupes attribués à l'acceptation
+	InvitedByID       uint       `json:"invited_by_id" gorm:"index"`
+	InvitedByUsername string     `json:"invited_by_username" gorm:"size:255"`
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"index"`
+	SentAt            time.Time  `json:"sent_at"`
+	SendCount         int        `json:"send_count" gorm:"default:1"`
+	AcceptedAt        *time.Time `json:"accepted_at"`
+	AcceptedUserID    *uint      `json:"accepted_user_id"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	Status            string     `json:"status" gorm:"-"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// CurrentStatus retourne le statut de l'invitation à l'instant présent
+func (i *Invitation) CurrentStatus() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case !i.ExpiresAt.After(time.Now()):
+		return InvitationExpired
+	}
+	return InvitationPending
+}
+
+// AfterFind renseigne le statut calculé
+func (i *Invitation) AfterFind(tx *gorm.DB) error {
+	i.Status = i.CurrentStatus()
+	return nil
+}
+
+// InvitationRequest invite une adresse email
+type InvitationRequest struct {
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Role     string `json:"role"` // user (par défaut), editor ou admin
+	GroupIDs []uint `json:"group_ids"`
+}
+
+// InvitationBulkRequest invite plusieurs adresses avec le même rôle et les mêmes groupes
+type InvitationBulkRequest struct {
+	Emails   []string `json:"emails" binding:"required,min=1,max=100,dive,email,max=100"`
+	Role     string   `json:"role"`
+	GroupIDs []uint   `json:"group_ids"`
+}
+
+// InvitationBulkFailure décrit une adresse qui n'a pas pu être invitée
+type InvitationBulkFailure struct {
+	Email   string `json:"email"`
+	Message string `json:"message"`
+}
+
+// InvitationBulkResponse est le résultat d'une invitation groupée
+type InvitationBulkResponse struct {
+	Invitations []Invitation            `json:"invitations"`
+	Failed      []InvitationBulkFailure `json:"failed"`
+}
+
+// InvitationDetails est affiché au destinataire avant la création de son compte
+type InvitationDetails struct {
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Groups    []string  `json:"groups"`
+	InvitedBy string    `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InvitationAcceptRequest crée le compte invité avec le token reçu par email
+type InvitationAcceptRequest struct {
+	Token     string `json:"token" binding:"required"`
+	Username  string `json:"username" binding:"required,min=3,max=30,alphanum"`
+	Password  string `json:"password" binding:"required,min=8,max=128"`
+	FirstName string `json:"first_name" binding:"max=50"`
+	LastName  string `json:"last_name" binding:"max=50"`
+}
@@ -9,25 +9,33 @@ import (
 
 // User représente un utilisateur du système
 type User struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Username    string         `json:"username" gorm:"unique;not null"`
-	Email       string         `json:"email" gorm:"unique;not null"`
-	Password    string         `json:"-"` // Nullable pour les users SSO
-	FirstName   string         `json:"first_name"`
-	LastName    string         `json:"last_name"`
-	Role        string         `json:"role" gorm:"default:'user'"` // admin, editor, user
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
-	SSOProvider string         `json:"sso_provider,omitempty"` // authentik, azure, etc.
-	SSOID       string         `json:"sso_id,omitempty"`       // ID utilisateur externe
-	LastLogin   *time.Time     `json:"last_login"`             // Dernière connexion
-	AvatarURL   string         `json:"avatar_url,omitempty"`   // URL de l'avatar (stocké localement ou externe)
-	Phone       string         `json:"phone,omitempty"`        // Numéro de téléphone
-	Department  string         `json:"department,omitempty"`   // Département
-	JobTitle    string         `json:"job_title,omitempty"`    // Titre du poste
-	Location    string         `json:"location,omitempty"`     // Localisation
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	Username         string         `json:"username" gorm:"unique;not null"`
+	Email            string         `json:"email" gorm:"unique;not null"`
+	Password         string         `json:"-"` // Nullable pour les users SSO
+	FirstName        string         `json:"first_name"`
+	LastName         string         `json:"last_name"`
+	Role             string         `json:"role" gorm:"default:'user'"` // admin, editor, user
+	IsActive         bool           `json:"is_active" gorm:"default:true"`
+	SSOProvider      string         `json:"sso_provider,omitempty"`                  // authentik, azure, etc.
+	SSOID            string         `json:"sso_id,omitempty"`                        // ID utilisateur externe
+	LastLogin        *time.Time     `json:"last_login"`                              // Dernière connexion
+	AvatarURL        string         `json:"avatar_url,omitempty"`                    // URL de l'avatar (stocké localement ou externe)
+	Phone            string         `json:"phone,omitempty"`                         // Numéro de téléphone
+	Department       string         `json:"department,omitempty"`                    // Département
+	JobTitle         string         `json:"job_title,omitempty"`                     // Titre du poste
+	Location         string         `json:"location,omitempty"`                      // Localisation
+	TwoFactorEnabled bool           `json:"two_factor_enabled" gorm:"default:false"` // Double authentification TOTP activée
+	EmailVerificationPending bool   `json:"email_verification_pending" gorm:"default:false;index"` // Inscription en attente de confirmation de l'email
+	EmailVerificationSentAt  *time.Time `json:"-"`                                                // Dernier envoi du lien de confirmation
+	IsServiceAccount bool           `json:"is_service_account" gorm:"default:false"` // Compte technique sans connexion interactive (tokens d'accès uniquement)
+	PasswordChangedAt  *time.Time `json:"password_changed_at"`                       // Dernier changement du mot de passe local (âge maximal)
+	MustChangePassword bool       `json:"must_change_password" gorm:"default:false"` // Changement imposé à la prochaine connexion
+	SCIMManaged        bool       `json:"scim_managed" gorm:"default:false"`         // Profil et groupes gérés par l'IdP via SCIM (non écrasés à la connexion)
+	SCIMExternalID     string     `json:"-" gorm:"size:255;index"`                   // externalId SCIM transmis par l'IdP
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
 	Groups        []Group       `json:"groups,omitempty" gorm:"many2many:user_groups;"`
@@ -45,6 +53,7 @@ type Group struct {
 	Description string         `json:"description"`
 	Color       string         `json:"color" gorm:"default:'#3B82F6'"` // Couleur pour l'affichage
 	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	SCIMExternalID string      `json:"-" gorm:"size:255;index"` // externalId SCIM transmis par l'IdP
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -102,6 +111,10 @@ type Claims struct {
 	Role            string `json:"role"`
 	Email           string `json:"email"`
 	ManagedGroupIDs []uint `json:"managed_group_ids,omitempty"` // IDs des groupes administrés (chargés depuis group_admins)
+	SessionID       uint   `json:"sid"`                         // Session serveur (révocable) à laquelle appartient le token
+	TokenID         string `json:"jti,omitempty"`               // Identifiant du refresh token (rotation)
+	ImpersonatorID  uint   `json:"imp,omitempty"`               // Administrateur qui consulte l'application en tant que UserID
+	ImpersonationID uint   `json:"impid,omitempty"`             // Entrée du journal d'impersonation associée
 }
 
 // Request/Response structures
@@ -114,6 +127,8 @@ type LoginResponse struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 	User         User   `json:"user"`
+	// Codes de récupération, renvoyés une seule fois quand la double authentification est activée à la connexion
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 type RegisterRequest struct {
@@ -165,7 +180,7 @@ type DashboardStats struct {
 type Media struct {
 	ID           uint           `json:"id" gorm:"primaryKey"`
 	Filename     string         `json:"filename" gorm:"not null"`            // Original filename
-	StoragePath  string         `json:"storage_path" gorm:"not null;unique"` // Path on disk or S3 key
+	StoragePath  string         `json:"storage_path" gorm:"not null;index"`  // Path on disk or S3 key (shared when deduplicated)
 	URL          string         `json:"url" gorm:"not null"`                 // Public URL to access the file
 	MimeType     string         `json:"mime_type" gorm:"not null"`           // image/jpeg, application/pdf, etc.
 	FileSize     int64          `json:"file_size" gorm:"not null"`           // Size in bytes
@@ -179,8 +194,101 @@ type Media struct {
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// SHA-256 of the content, links the media to its shared MediaBlob
+	ContentHash string `json:"content_hash,omitempty" gorm:"size:64;index"`
+
+	// Who can download the file, derived from the content that uses it:
+	// public, authenticated or group (members of AccessGroups only)
+	Visibility string `json:"visibility" gorm:"size:20;default:'authenticated';index"`
+
+	// Antivirus scan result; quarantined files are kept for review but never served
+	ScanStatus    string     `json:"scan_status,omitempty" gorm:"size:20;index"` // clean, infected, error, skipped
+	ScanSignature string     `json:"scan_signature,omitempty"`                   // Threat name reported by the scanner
+	ScannedAt     *time.Time `json:"scanned_at,omitempty"`
+	Quarantined   bool       `json:"quarantined" gorm:"default:false;index"`
+
+	// EXIF tags of the original image (JSON), kept only when AppSettings.KeepImageMetadata
+	// is enabled; the stored file itself is always stripped. Admin-only, see GetMediaMetadata.
+	OriginalMetadata string `json:"-" gorm:"type:text"`
+
 	// Relations
-	Uploader User `json:"uploader,omitempty" gorm:"foreignKey:UploadedBy"`
+	Uploader     User           `json:"uploader,omitempty" gorm:"foreignKey:UploadedBy"`
+	Variants     []MediaVariant `json:"variants,omitempty" gorm:"foreignKey:MediaID"`
+	AccessGroups []Group        `json:"access_groups,omitempty" gorm:"many2many:media_access_groups;"`
+}
+
+// MediaVariant is a resized copy of an image media (thumbnail, responsive widths)
+type MediaVariant struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	MediaID     uint      `json:"media_id" gorm:"not null;index"`
+	Name        string    `json:"name" gorm:"not null"`                // thumbnail, w320, w768, w1280
+	StoragePath string    `json:"storage_path" gorm:"not null;index"` // Path on disk or S3 key (shared by deduplicated media)
+	URL         string    `json:"url" gorm:"not null"`
+	MimeType    string    `json:"mime_type" gorm:"not null"`
+	FileSize    int64     `json:"file_size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MediaBlob is a stored file shared by every Media with the same content hash
+type MediaBlob struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ContentHash string    `json:"content_hash" gorm:"size:64;not null;uniqueIndex:idx_media_blob_hash"`
+	StorageType string    `json:"storage_type" gorm:"not null;uniqueIndex:idx_media_blob_hash"`
+	StoragePath string    `json:"storage_path" gorm:"not null;index"`
+	FileSize    int64     `json:"file_size"`
+	RefCount    int       `json:"ref_count" gorm:"not null;default:0"` // Number of Media rows using this blob
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// MediaUpload tracks a resumable (tus) upload until it becomes a Media
+type MediaUpload struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:36"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Filename   string     `json:"filename"`
+	FileType   string     `json:"file_type"`
+	Metadata   string     `json:"-" gorm:"type:text"` // Raw Upload-Metadata header
+	Length     int64      `json:"length"`
+	Offset     int64      `json:"offset"`
+	MediaID    *uint      `json:"media_id,omitempty"` // Set once the upload is complete and validated
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"index"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// MediaReference indexes where an uploaded file is used (news content, covers, avatars...)
+type MediaReference struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	MediaID    uint      `json:"media_id" gorm:"not null;uniqueIndex:idx_media_reference"`
+	EntityType string    `json:"entity_type" gorm:"not null;uniqueIndex:idx_media_reference;index:idx_media_reference_entity"` // news, event, user, app_settings
+	EntityID   uint      `json:"entity_id" gorm:"not null;uniqueIndex:idx_media_reference;index:idx_media_reference_entity"`
+	Field      string    `json:"field" gorm:"not null;uniqueIndex:idx_media_reference"` // content, cover_image, avatar_url, hero_image_url...
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// StorageQuota limits the media storage (bytes and file count, 0 = unlimited) of a user,
+// of every user with a role, or of the admins of a group. A user quota overrides the role quota.
+type StorageQuota struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Scope     string    `json:"scope" gorm:"size:20;not null;uniqueIndex:idx_storage_quota_target"`           // user, role, group
+	TargetID  uint      `json:"target_id" gorm:"not null;default:0;uniqueIndex:idx_storage_quota_target"`     // User or group ID (0 for role quotas)
+	Role      string    `json:"role" gorm:"size:20;not null;default:'';uniqueIndex:idx_storage_quota_target"` // Role name (role quotas only)
+	MaxBytes  int64     `json:"max_bytes" gorm:"not null;default:0"`
+	MaxFiles  int       `json:"max_files" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StorageQuotaRequest creates or updates a storage quota
+type StorageQuotaRequest struct {
+	Scope    string `json:"scope" binding:"required,oneof=user role group"`
+	TargetID uint   `json:"target_id"`
+	Role     string `json:"role"`
+	MaxBytes int64  `json:"max_bytes" binding:"min=0"`
+	MaxFiles int    `json:"max_files" binding:"min=0"`
 }
 
 // MediaType returns a user-friendly media type category
@@ -216,6 +324,18 @@ type AppSettings struct {
 	HeroImageURL      string    `json:"hero_image_url" gorm:"default:''"` // URL de l'image de fond du hero (vide = gradient par défaut)
 	HeroImageURLDark  string    `json:"hero_image_url_dark" gorm:"default:''"` // URL de l'image de fond du hero en mode sombre (vide = utilise hero_image_url)
 	HeroImagePosition string    `json:"hero_image_position" gorm:"default:'center center'"` // Position CSS background-position
+	KeepImageMetadata bool      `json:"keep_image_metadata" gorm:"default:false"` // Conserver les métadonnées d'origine (EXIF) des images uploadées
+	TwoFactorRequiredRoles string `json:"two_factor_required_roles" gorm:"default:''"` // Rôles pour lesquels la double authentification est obligatoire (ex: "admin,editor")
+	SignupAllowedDomains string `json:"signup_allowed_domains" gorm:"default:''"` // Domaines email autorisés à l'inscription (ex: "example.com,corp.example.com", vide = tous)
+	// Politique de mots de passe des comptes locaux
+	PasswordMinLength      int  `json:"password_min_length" gorm:"default:8"`
+	PasswordRequireUpper   bool `json:"password_require_upper" gorm:"default:true"`
+	PasswordRequireLower   bool `json:"password_require_lower" gorm:"default:true"`
+	PasswordRequireDigit   bool `json:"password_require_digit" gorm:"default:true"`
+	PasswordRequireSpecial bool `json:"password_require_special" gorm:"default:true"`
+	PasswordCheckBreached  bool `json:"password_check_breached" gorm:"default:true"` // Refuser les mots de passe de la liste des mots de passe compromis
+	PasswordHistoryCount   int  `json:"password_history_count" gorm:"default:0"`     // Derniers mots de passe non réutilisables (0 = désactivé)
+	PasswordMaxAgeDays     int  `json:"password_max_age_days" gorm:"default:0"`      // Changement imposé après N jours (0 = jamais)
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -232,12 +352,24 @@ type AppSettingsRequest struct {
 	HeroImageURL      string `json:"hero_image_url"`       // URL de l'image de fond du hero (optionnel)
 	HeroImageURLDark  string `json:"hero_image_url_dark"`  // URL de l'image en mode sombre (optionnel)
 	HeroImagePosition string `json:"hero_image_position"`  // Position CSS background-position (optionnel)
+	KeepImageMetadata *bool  `json:"keep_image_metadata"`  // Conserver les métadonnées EXIF d'origine (optionnel)
+	TwoFactorRequiredRoles *string `json:"two_factor_required_roles"` // Rôles avec double authentification obligatoire, séparés par des virgules (optionnel)
+	SignupAllowedDomains *string `json:"signup_allowed_domains"` // Domaines email autorisés à l'inscription, séparés par des virgules (optionnel)
+	// Politique de mots de passe (optionnel, champ absent = inchangé)
+	PasswordMinLength      *int  `json:"password_min_length" binding:"omitempty,min=8,max=128"`
+	PasswordRequireUpper   *bool `json:"password_require_upper"`
+	PasswordRequireLower   *bool `json:"password_require_lower"`
+	PasswordRequireDigit   *bool `json:"password_require_digit"`
+	PasswordRequireSpecial *bool `json:"password_require_special"`
+	PasswordCheckBreached  *bool `json:"password_check_breached"`
+	PasswordHistoryCount   *int  `json:"password_history_count" binding:"omitempty,min=0,max=24"`
+	PasswordMaxAgeDays     *int  `json:"password_max_age_days" binding:"omitempty,min=0,max=3650"`
 }
 
 // ChangePasswordRequest pour les changements de mot de passe
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=128"`
 }
 
 // UpdateProfileRequest pour les mises à jour du profil utilisateur
@@ -252,20 +384,34 @@ type UpdateProfileRequest struct {
 
 // OAuthProvider représente un fournisseur OAuth (Google, Microsoft, etc.)
 type OAuthProvider struct {
-	ID           uint      `json:"id" gorm:"primaryKey"`
-	ProviderName string    `json:"provider_name" gorm:"unique;not null"` // google, microsoft
-	DisplayName  string    `json:"display_name" gorm:"not null"`         // "Google", "Microsoft"
-	Icon         string    `json:"icon" gorm:"default:'mdi:login'"`      // Icône Iconify
-	IsEnabled    bool      `json:"is_enabled" gorm:"default:false"`
-	ClientID     string    `json:"client_id"`
-	ClientSecret string    `json:"-"` // Ne jamais exposer dans le JSON
-	RedirectURI  string    `json:"redirect_uri"`
-	AuthURL      string    `json:"auth_url"`      // URL d'autorisation OAuth
-	TokenURL     string    `json:"token_url"`     // URL d'échange de token
-	UserInfoURL  string    `json:"user_info_url"` // URL pour récupérer les infos utilisateur
-	Scopes       string    `json:"scopes"`        // Scopes OAuth séparés par des espaces
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	ProviderName string `json:"provider_name" gorm:"unique;not null"` // google, microsoft
+	DisplayName  string `json:"display_name" gorm:"not null"`         // "Google", "Microsoft"
+	Icon         string `json:"icon" gorm:"default:'mdi:login'"`      // Icône Iconify
+	IsEnabled    bool   `json:"is_enabled" gorm:"default:false"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"-"` // Ne jamais exposer dans le JSON
+	RedirectURI  string `json:"redirect_uri"`
+	AuthURL      string `json:"auth_url"`      // URL d'autorisation OAuth
+	TokenURL     string `json:"token_url"`     // URL d'échange de token
+	UserInfoURL  string `json:"user_info_url"` // URL pour récupérer les infos utilisateur
+	Scopes       string `json:"scopes"`        // Scopes OAuth séparés par des espaces
+
+	// OpenID Connect : avec un issuer, les URLs sont lues dans .well-known/openid-configuration
+	// et l'id_token est vérifié (signature JWKS, iss, aud, exp, nonce)
+	IssuerURL string `json:"issuer_url"`
+	JWKSURL   string `json:"jwks_url"`
+
+	// Mapping des claims (vides = claims standards OpenID Connect ; chemins imbriqués avec ".")
+	EmailClaim     string `json:"email_claim"`
+	FirstNameClaim string `json:"first_name_claim"`
+	LastNameClaim  string `json:"last_name_claim"`
+	NameClaim      string `json:"name_claim"`   // Nom complet, découpé si prénom et nom sont absents
+	GroupsClaim    string `json:"groups_claim"` // Vide = groupes non synchronisés
+	AdminGroups    string `json:"admin_groups"` // Groupes donnant le rôle admin, séparés par des virgules
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // OAuthProviderRequest pour les requêtes de mise à jour
@@ -281,6 +427,15 @@ type OAuthProviderRequest struct {
 	TokenURL     string `json:"token_url"`
 	UserInfoURL  string `json:"user_info_url"`
 	Scopes       string `json:"scopes"`
+
+	IssuerURL      string `json:"issuer_url"`
+	JWKSURL        string `json:"jwks_url"`
+	EmailClaim     string `json:"email_claim"`
+	FirstNameClaim string `json:"first_name_claim"`
+	LastNameClaim  string `json:"last_name_claim"`
+	NameClaim      string `json:"name_claim"`
+	GroupsClaim    string `json:"groups_claim"`
+	AdminGroups    string `json:"admin_groups"`
 }
 
 // OAuthProviderPublic pour l'affichage public (sans secrets)
@@ -47,6 +47,9 @@ type News struct {
 	CommentCount  int `json:"comment_count" gorm:"-"`
 	ReactionCount int `json:"reaction_count" gorm:"-"`
 
+	// Variantes redimensionnées de l'image de couverture (non persistées)
+	CoverImageVariants []MediaVariant `json:"cover_image_variants,omitempty" gorm:"-"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
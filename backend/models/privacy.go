@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Statuts d'une demande d'effacement
+const (
+	ErasureRequestPending   = "pending"
+	ErasureRequestApproved  = "approved"
+	ErasureRequestRejected  = "rejected"
+	ErasureRequestCancelled = "cancelled"
+)
+
+// ErasureRequest est une demande d'effacement du compte (droit à l'effacement RGPD), traitée par
+// un administrateur. Une fois approuvée, seule la trace du traitement est conservée : l'identité
+// du demandeur et le motif sont vidés.
+type ErasureRequest struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"index;not null"`
+	Username     string     `json:"username" gorm:"size:255"`
+	Email        string     `json:"email" gorm:"size:255"`
+	Reason       string     `json:"reason" gorm:"size:1000"`
+	Status       string     `json:"status" gorm:"size:20;index;not null;default:'pending'"`
+	ReviewedByID *uint      `json:"reviewed_by_id"`
+	ReviewNote   string     `json:"review_note" gorm:"size:1000"` // Motif du refus communiqué à l'utilisateur
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ErasureRequestCreate demande l'effacement de son propre compte
+type ErasureRequestCreate struct {
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
+// ErasureRequestReview approuve ou refuse une demande d'effacement
+type ErasureRequestReview struct {
+	Note string `json:"note" binding:"max=1000"`
+}
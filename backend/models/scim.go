@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Schémas SCIM 2.0 (RFC 7643 / RFC 7644)
+const (
+	SCIMSchemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaGroup          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMSchemaEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SCIMSchemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMSchemaError          = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMSchemaSPConfig       = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIMSchemaResourceType   = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// SCIMUser est la ressource User SCIM (models.User côté Airboard)
+type SCIMUser struct {
+	Schemas      []string            `json:"schemas"`
+	ID           string              `json:"id,omitempty"`
+	ExternalID   string              `json:"externalId,omitempty"`
+	UserName     string              `json:"userName"`
+	Name         *SCIMName           `json:"name,omitempty"`
+	DisplayName  string              `json:"displayName,omitempty"`
+	Title        string              `json:"title,omitempty"`
+	Active       *bool               `json:"active,omitempty"`
+	Emails       []SCIMMultiValue    `json:"emails,omitempty"`
+	PhoneNumbers []SCIMMultiValue    `json:"phoneNumbers,omitempty"`
+	Groups       []SCIMMember        `json:"groups,omitempty"` // Lecture seule : les appartenances se gèrent sur /Groups
+	Enterprise   *SCIMEnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta         *SCIMMeta           `json:"meta,omitempty"`
+}
+
+// SCIMName est l'attribut complexe name d'un User
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMMultiValue est une valeur d'un attribut multivalué (emails, phoneNumbers)
+type SCIMMultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMEnterpriseUser contient les attributs utilisés de l'extension entreprise
+type SCIMEnterpriseUser struct {
+	Department string `json:"department,omitempty"`
+}
+
+// SCIMGroup est la ressource Group SCIM (models.Group et la table user_groups)
+type SCIMGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members,omitempty"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+// SCIMMember référence un membre d'un groupe (ou un groupe d'un utilisateur)
+type SCIMMember struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// SCIMMeta contient les métadonnées d'une ressource
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// SCIMListResponse est la réponse paginée des recherches
+type SCIMListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// SCIMPatchRequest est le corps d'une requête PATCH
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation est une opération add, remove ou replace (la casse de op est ignorée)
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMError est le format d'erreur SCIM (le statut HTTP est répété en chaîne)
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewSCIMError crée une réponse d'erreur SCIM
+func NewSCIMError(status int, scimType, detail string) SCIMError {
+	return SCIMError{
+		Schemas:  []string{SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"airboard/models"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Paramètres des tokens d'accès personnels
+const (
+	AccessTokenPrefix           = "abp_"
+	AccessTokenDefaultTTL       = 90 * 24 * time.Hour
+	AccessTokenTouchInterval    = time.Minute         // Fréquence maximale de mise à jour de last_used_at
+	RevokedAccessTokenRetention = 30 * 24 * time.Hour // Durée de conservation des tokens révoqués ou expirés
+	MaxAccessTokensPerUser      = 50
+)
+
+var (
+	ErrAccessTokenInvalid  = errors.New("invalid, expired or revoked access token")
+	ErrAccessTokenNotFound = errors.New("access token not found")
+	ErrAccessTokenLimit    = errors.New("too many access tokens")
+	ErrInvalidScope        = errors.New("invalid access token scope")
+)
+
+// AccessTokenScope décrit un scope attribuable à un token d'accès
+type AccessTokenScope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	AdminOnly   bool   `json:"admin_only"`
+}
+
+// AccessTokenScopes liste les scopes disponibles. Un scope ":write" inclut le ":read"
+// correspondant ; les droits du rôle de l'utilisateur s'appliquent en plus des scopes.
+var AccessTokenScopes = []AccessTokenScope{
+	{Name: "user:read", Description: "Profil, tableau de bord, favoris, notifications, recherche"},
+	{Name: "user:write", Description: "Modifier le profil, les favoris et les notifications"},
+	{Name: "news:read", Description: "Lire les news"},
+	{Name: "news:write", Description: "Créer, modifier et supprimer des news (selon le rôle)"},
+	{Name: "events:read", Description: "Lire les événements"},
+	{Name: "events:write", Description: "Créer, modifier et supprimer des événements (selon le rôle)"},
+	{Name: "polls:read", Description: "Lire les sondages et leurs résultats"},
+	{Name: "polls:write", Description: "Voter, créer et gérer des sondages (selon le rôle)"},
+	{Name: "media:read", Description: "Lister les médias"},
+	{Name: "media:write", Description: "Uploader et supprimer des médias (selon le rôle)"},
+	{Name: "comments:read", Description: "Lire les commentaires et feedbacks"},
+	{Name: "comments:write", Description: "Commenter, réagir et modérer (selon le rôle)"},
+	{Name: "suggestions:read", Description: "Lire les suggestions"},
+	{Name: "suggestions:write", Description: "Proposer et voter pour des suggestions"},
+	{Name: "chat:read", Description: "Lire les conversations"},
+	{Name: "chat:write", Description: "Gérer les conversations"},
+	{Name: "applications:read", Description: "Lire les applications des groupes administrés"},
+	{Name: "applications:write", Description: "Gérer les applications des groupes administrés"},
+	{Name: "admin:users", Description: "Administration des utilisateurs et des groupes", AdminOnly: true},
+	{Name: "admin:settings", Description: "Administration des paramètres, emails et fournisseurs OAuth", AdminOnly: true},
+	{Name: "admin:content", Description: "Administration des contenus, médias et statistiques", AdminOnly: true},
+	{Name: "admin:scim", Description: "Provisionnement SCIM 2.0 des utilisateurs et des groupes par l'IdP", AdminOnly: true},
+}
+
+// AccessTokenService gère les tokens d'accès personnels
+type AccessTokenService struct {
+	db *gorm.DB
+}
+
+// NewAccessTokenService crée une nouvelle instance du service des tokens d'accès
+func NewAccessTokenService(db *gorm.DB) *AccessTokenService {
+	return &AccessTokenService{db: db}
+}
+
+// Create génère un token pour un utilisateur et retourne sa valeur en clair (affichée une seule fois)
+func (s *AccessTokenService) Create(user *models.User, name string, scopes []string, ttl time.Duration, createdByID *uint) (string, *models.AccessToken, error) {
+	normalized, err := normalizeScopes(scopes, user.Role == "admin")
+	if err != nil {
+		return "", nil, err
+	}
+	if ttl <= 0 {
+		ttl = AccessTokenDefaultTTL
+	}
+
+	var count int64
+	if err := s.db.Model(&models.AccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Count(&count).Error; err != nil {
+		return "", nil, err
+	}
+	if count >= MaxAccessTokensPerUser {
+		return "", nil, ErrAccessTokenLimit
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	token := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	record := models.AccessToken{
+		UserID:      user.ID,
+		Name:        strings.TrimSpace(name),
+		TokenHash:   hashChallengeToken(token),
+		Prefix:      token[:len(AccessTokenPrefix)+8],
+		Scopes:      strings.Join(normalized, ","),
+		ExpiresAt:   time.Now().Add(ttl),
+		CreatedByID: createdByID,
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return "", nil, err
+	}
+	record.ScopeList = normalized
+	return token, &record, nil
+}
+
+// Authenticate retourne le token et son utilisateur s'ils sont valides et met à jour la dernière utilisation
+func (s *AccessTokenService) Authenticate(token, ipAddress string) (*models.User, *models.AccessToken, error) {
+	var record models.AccessToken
+	if err := s.db.Where("token_hash = ?", hashChallengeToken(token)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAccessTokenInvalid
+		}
+		return nil, nil, err
+	}
+	if record.RevokedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, nil, ErrAccessTokenInvalid
+	}
+
+	// Le rôle et l'état du compte sont relus à chaque requête
+	var user models.User
+	if err := s.db.First(&user, record.UserID).Error; err != nil || !user.IsActive || user.EmailVerificationPending {
+		return nil, nil, ErrAccessTokenInvalid
+	}
+
+	if record.LastUsedAt == nil || time.Since(*record.LastUsedAt) > AccessTokenTouchInterval {
+		now := time.Now()
+		s.db.Model(&models.AccessToken{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ipAddress,
+		})
+		record.LastUsedAt = &now
+		record.LastUsedIP = ipAddress
+	}
+	record.ScopeList = splitScopes(record.Scopes)
+	return &user, &record, nil
+}
+
+// List retourne les tokens d'un utilisateur, actifs ou non (le plus récent en premier)
+func (s *AccessTokenService) List(userID uint) ([]models.AccessToken, error) {
+	var tokens []models.AccessToken
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	for i := range tokens {
+		tokens[i].ScopeList = splitScopes(tokens[i].Scopes)
+	}
+	return tokens, nil
+}
+
+// Revoke révoque un token d'un utilisateur
+func (s *AccessTokenService) Revoke(userID, tokenID uint) error {
+	result := s.db.Model(&models.AccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+// CleanupExpired supprime les tokens expirés ou révoqués depuis plus de RevokedAccessTokenRetention
+func (s *AccessTokenService) CleanupExpired() (int64, error) {
+	cutoff := time.Now().Add(-RevokedAccessTokenRetention)
+	result := s.db.Where("expires_at <= ? OR revoked_at <= ?", cutoff, cutoff).Delete(&models.AccessToken{})
+	return result.RowsAffected, result.Error
+}
+
+// ScopeAllows indique si les scopes d'un token couvrent le scope requis (":write" inclut ":read")
+func ScopeAllows(scopes []string, required string) bool {
+	for _, scope := range scopes {
+		if scope == required {
+			return true
+		}
+		if resource, ok := strings.CutSuffix(required, ":read"); ok && scope == resource+":write" {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeScopes vérifie que les scopes existent (et sont permis au rôle) et supprime les doublons
+func normalizeScopes(scopes []string, isAdmin bool) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, name := range scopes {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			continue
+		}
+		known := false
+		for _, scope := range AccessTokenScopes {
+			if scope.Name == name {
+				if scope.AdminOnly && !isAdmin {
+					return nil, fmt.Errorf("%w: %s", ErrInvalidScope, name)
+				}
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, name)
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	if len(result) == 0 {
+		return nil, ErrInvalidScope
+	}
+	return result, nil
+}
+
+func splitScopes(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
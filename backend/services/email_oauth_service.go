@@ -3,13 +3,9 @@ package services
 import (
 	"airboard/config"
 	"airboard/models"
+	"airboard/utils"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
 	"fmt"
-	"io"
 	"log"
 	"strings"
 	"time"
@@ -227,75 +223,20 @@ func (s *EmailOAuthService) RefreshWithRefreshToken(oauthConfig *models.EmailOAu
 	return nil
 }
 
-// EncryptToken chiffre un token avec AES-256
+// EncryptToken chiffre un token avec AES-256 (clé dérivée du secret JWT)
 func (s *EmailOAuthService) EncryptToken(token string) (string, error) {
-	if token == "" {
-		return "", nil
-	}
-
-	// Utiliser les 32 premiers octets du secret JWT comme clé (même méthode que les passwords)
-	secret := s.config.JWT.Secret
-	if len(secret) < 32 {
-		// Padding si le secret est trop court
-		secret = secret + strings.Repeat("0", 32-len(secret))
-	}
-	key := []byte(secret[:32])
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("erreur création cipher: %w", err)
-	}
-
-	plaintext := []byte(token)
-	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
-	iv := ciphertext[:aes.BlockSize]
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		return "", fmt.Errorf("erreur génération IV: %w", err)
-	}
-
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(ciphertext[aes.BlockSize:], plaintext)
-
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return utils.EncryptSecret(s.config.JWT.Secret, token)
 }
 
 // DecryptToken déchiffre un token chiffré avec AES-256
 func (s *EmailOAuthService) DecryptToken(encrypted string) (string, error) {
-	if encrypted == "" {
-		return "", nil
-	}
-
-	// Utiliser les 32 premiers octets du secret JWT comme clé
-	secret := s.config.JWT.Secret
-	if len(secret) < 32 {
-		// Padding si le secret est trop court
-		secret = secret + strings.Repeat("0", 32-len(secret))
-	}
-	key := []byte(secret[:32])
-
-	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
-	if err != nil {
-		return "", fmt.Errorf("erreur décodage base64: %w", err)
-	}
-
-	block, err := aes.NewCipher(key)
+	token, err := utils.DecryptSecret(s.config.JWT.Secret, encrypted)
 	if err != nil {
-		return "", fmt.Errorf("erreur création cipher: %w", err)
-	}
-
-	if len(ciphertext) < aes.BlockSize {
-		return "", fmt.Errorf("ciphertext trop court")
+		return "", err
 	}
 
-	iv := ciphertext[:aes.BlockSize]
-	ciphertext = ciphertext[aes.BlockSize:]
-
-	stream := cipher.NewCFBDecrypter(block, iv)
-	stream.XORKeyStream(ciphertext, ciphertext)
-
 	// Nettoyer le token des caractères invalides pour les headers HTTP
 	// (newlines, carriage returns, etc.)
-	token := string(ciphertext)
 	token = strings.TrimSpace(token)
 	token = strings.ReplaceAll(token, "\n", "")
 	token = strings.ReplaceAll(token, "\r", "")
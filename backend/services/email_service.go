@@ -73,6 +73,71 @@ type AnnouncementEmailData struct {
 	AppName string
 }
 
+// PasswordResetEmailData contient les données pour le template password_reset
+type PasswordResetEmailData struct {
+	Name      string
+	Username  string
+	Link      string
+	ExpiresIn string
+	AppName   string
+}
+
+// EmailVerificationEmailData contient les données pour le template email_verification
+type EmailVerificationEmailData struct {
+	Name      string
+	Username  string
+	Link      string
+	ExpiresIn string
+	AppName   string
+}
+
+// InvitationEmailData contient les données pour le template invitation
+type InvitationEmailData struct {
+	Email     string
+	InvitedBy string
+	Role      string
+	Groups    string
+	Link      string
+	ExpiresIn string
+	AppName   string
+}
+
+// SendTemplateEmail envoie un email transactionnel (réinitialisation de mot de passe...) à un seul destinataire
+func (s *EmailService) SendTemplateEmail(templateType, to string, data interface{}) error {
+	var smtpConfig models.SMTPConfig
+	if err := s.db.Preload("EmailOAuthConfig").First(&smtpConfig).Error; err != nil {
+		return fmt.Errorf("SMTP non configuré: %w", err)
+	}
+	if !smtpConfig.IsEnabled {
+		return fmt.Errorf("SMTP désactivé")
+	}
+
+	var emailTemplate models.EmailTemplate
+	if err := s.db.Where("type = ? AND is_enabled = ?", templateType, true).First(&emailTemplate).Error; err != nil {
+		return fmt.Errorf("template non trouvé ou désactivé: %w", err)
+	}
+
+	subject, err := s.ExecuteTemplate(emailTemplate.Subject, data)
+	if err != nil {
+		return err
+	}
+	htmlBody, err := s.ExecuteTemplate(emailTemplate.HTMLBody, data)
+	if err != nil {
+		return err
+	}
+	return s.sendEmail(&smtpConfig, to, subject, htmlBody)
+}
+
+// AppName retourne le nom de l'application configuré (Airboard par défaut)
+func (s *EmailService) AppName() string {
+	var appSettings models.AppSettings
+	s.db.First(&appSettings)
+	if appSettings.AppName == "" {
+		return "Airboard"
+	}
+	return appSettings.AppName
+}
+
 // SendNotification envoie des notifications email aux groupes cibles
 func (s *EmailService) SendNotification(templateType string, contentID uint, targetGroupIDs []uint) error {
 	// Récupérer la config SMTP avec la config OAuth si disponible
@@ -570,6 +635,32 @@ func (s *EmailService) GetSampleData(templateType string) interface{} {
 			Type:    "info",
 			AppName: appName,
 		}
+	case "password_reset":
+		return PasswordResetEmailData{
+			Name:      "Jean Dupont",
+			Username:  "jdupont",
+			Link:      fmt.Sprintf("%s/auth/reset-password?token=exemple", s.config.Server.PublicURL),
+			ExpiresIn: "1 heure",
+			AppName:   appName,
+		}
+	case "email_verification":
+		return EmailVerificationEmailData{
+			Name:      "Jean Dupont",
+			Username:  "jdupont",
+			Link:      fmt.Sprintf("%s/auth/verify-email?uid=1&expires=0&signature=exemple", s.config.Server.PublicURL),
+			ExpiresIn: "24 heures",
+			AppName:   appName,
+		}
+	case "invitation":
+		return InvitationEmailData{
+			Email:     "jean.dupont@example.com",
+			InvitedBy: "Marie Martin",
+			Role:      "user",
+			Groups:    "Common, Marketing",
+			Link:      fmt.Sprintf("%s/auth/accept-invitation?token=exemple", s.config.Server.PublicURL),
+			ExpiresIn: "7 jours",
+			AppName:   appName,
+		}
 	}
 	return nil
 }
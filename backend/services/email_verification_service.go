@@ -0,0 +1,169 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Paramètres de la confirmation d'email à l'inscription
+const (
+	EmailVerificationTTL            = 24 * time.Hour
+	EmailVerificationResendInterval = 2 * time.Minute    // Délai minimal entre deux envois pour un même compte
+	UnverifiedAccountRetention      = 7 * 24 * time.Hour // Les inscriptions non confirmées sont supprimées au-delà
+	EmailVerificationTemplate       = "email_verification"
+)
+
+var ErrInvalidVerificationLink = errors.New("invalid or expired email verification link")
+
+// EmailVerificationService gère les liens signés de confirmation d'email des inscriptions.
+// Les liens ne sont pas stockés : la signature HMAC couvre l'utilisateur, son email et
+// l'expiration, un changement d'email invalide donc les liens déjà envoyés.
+type EmailVerificationService struct {
+	db     *gorm.DB
+	config *config.Config
+	email  *EmailService
+}
+
+// NewEmailVerificationService crée une nouvelle instance du service de confirmation d'email
+func NewEmailVerificationService(db *gorm.DB, cfg *config.Config) *EmailVerificationService {
+	return &EmailVerificationService{
+		db:     db,
+		config: cfg,
+		email:  NewEmailService(db, cfg),
+	}
+}
+
+// Send envoie le lien de confirmation à un compte en attente
+func (s *EmailVerificationService) Send(user *models.User) error {
+	expires := time.Now().Add(EmailVerificationTTL).Unix()
+	link := fmt.Sprintf("%s/auth/verify-email?uid=%d&expires=%d&signature=%s",
+		strings.TrimSuffix(s.config.Server.PublicURL, "/"), user.ID, expires,
+		url.QueryEscape(s.sign(user.ID, user.Email, expires)))
+
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.Username
+	}
+	data := EmailVerificationEmailData{
+		Name:      name,
+		Username:  user.Username,
+		Link:      link,
+		ExpiresIn: "24 heures",
+		AppName:   s.email.AppName(),
+	}
+	if err := s.email.SendTemplateEmail(EmailVerificationTemplate, user.Email, data); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	now := time.Now()
+	user.EmailVerificationSentAt = &now
+	return s.db.Model(user).Update("email_verification_sent_at", now).Error
+}
+
+// Resend renvoie le lien d'un compte en attente. Aucune erreur n'est retournée pour un
+// email inconnu ou déjà confirmé afin de ne pas révéler l'existence des comptes.
+func (s *EmailVerificationService) Resend(email string) error {
+	var user models.User
+	if err := s.db.Where("LOWER(email) = ? AND email_verification_pending = ?", strings.ToLower(strings.TrimSpace(email)), true).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerificationSentAt != nil && time.Since(*user.EmailVerificationSentAt) < EmailVerificationResendInterval {
+		log.Printf("[Auth] Verification email resend throttled for user %d", user.ID)
+		return nil
+	}
+	return s.Send(&user)
+}
+
+// Verify contrôle la signature du lien et active le compte. Un lien valide présenté
+// une seconde fois réussit sans effet (double clic, aperçu du client mail...).
+func (s *EmailVerificationService) Verify(userID uint, expires int64, signature string) (*models.User, error) {
+	if time.Now().Unix() > expires {
+		return nil, ErrInvalidVerificationLink
+	}
+
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidVerificationLink
+		}
+		return nil, err
+	}
+
+	expected := s.sign(user.ID, user.Email, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrInvalidVerificationLink
+	}
+
+	if user.EmailVerificationPending {
+		if err := s.db.Model(&user).Update("email_verification_pending", false).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// PurgeUnverified supprime définitivement les inscriptions jamais confirmées après UnverifiedAccountRetention,
+// avec leurs données rattachées (historique des mots de passe, sessions...) comme pour un effacement
+func (s *EmailVerificationService) PurgeUnverified() (int64, error) {
+	var users []models.User
+	if err := s.db.Where("email_verification_pending = ? AND created_at <= ?", true, time.Now().Add(-UnverifiedAccountRetention)).
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for i := range users {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return EraseUserData(tx, &users[i])
+		})
+		if err != nil {
+			log.Printf("[Auth] Erreur lors de la suppression de l'inscription non confirmée %d: %v", users[i].ID, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *EmailVerificationService) sign(userID uint, email string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.config.JWT.Secret))
+	mac.Write([]byte("email-verification\n" + strconv.FormatUint(uint64(userID), 10) + "\n" +
+		strings.ToLower(email) + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EmailDomainAllowed indique si le domaine d'un email figure dans une liste séparée par
+// des virgules (correspondance exacte, insensible à la casse). Une liste vide autorise tout.
+func EmailDomainAllowed(email, allowedDomains string) bool {
+	if strings.TrimSpace(allowedDomains) == "" {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(strings.TrimSpace(email[at+1:]))
+	for _, allowed := range strings.Split(allowedDomains, ",") {
+		if strings.ToLower(strings.TrimSpace(allowed)) == domain {
+			return true
+		}
+	}
+	return false
+}
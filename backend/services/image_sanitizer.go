@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Maximum width/height of stored images (larger uploads are downscaled)
+const (
+	DefaultImageMaxDimension = 4096
+	AvatarMaxDimension       = 512
+)
+
+// ImageSanitizer re-encodes uploaded images so that embedded metadata (EXIF
+// with GPS coordinates, XMP, comments...) never reaches the storage. The EXIF
+// orientation is applied to the pixels and dimensions are capped on the way.
+type ImageSanitizer struct {
+	maxWidth  int
+	maxHeight int
+}
+
+// NewImageSanitizer creates a sanitizer bounding images to maxDimension pixels per side
+func NewImageSanitizer(maxDimension int) *ImageSanitizer {
+	return &ImageSanitizer{
+		maxWidth:  maxDimension,
+		maxHeight: maxDimension,
+	}
+}
+
+// SanitizedImage is the re-encoded image returned by ImageSanitizer.Sanitize
+type SanitizedImage struct {
+	Data     []byte
+	MimeType string
+	Width    int
+	Height   int
+	// Readable EXIF tags of the original file (camera, dates, GPS...)
+	Metadata map[string]string
+}
+
+// SupportsSanitize reports whether an image type is re-encoded on upload.
+// GIFs are left untouched to keep animations.
+func SupportsSanitize(mimeType string) bool {
+	return SupportsVariants(mimeType)
+}
+
+// Sanitize decodes the image, applies its EXIF orientation, downscales it if needed
+// and re-encodes it without metadata. JPEG stays JPEG and PNG stays PNG; WebP
+// (no encoder available) becomes JPEG, or PNG when it has transparency.
+func (s *ImageSanitizer) Sanitize(src io.ReadSeeker, mimeType string) (*SanitizedImage, error) {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind image: %w", err)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxVariantSourcePixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	orientation, metadata := parseEXIF(findEXIF(data, mimeType))
+
+	// Scale first (fewer pixels to rotate); orientations 5-8 swap width and height
+	bounds := img.Bounds()
+	maxW, maxH := s.maxWidth, s.maxHeight
+	if orientation >= 5 {
+		maxW, maxH = maxH, maxW
+	}
+	w, h := fitWithin(bounds.Dx(), bounds.Dy(), maxW, maxH)
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == bounds.Dx() && h == bounds.Dy() {
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	} else {
+		xdraw.CatmullRom.Scale(rgba, rgba.Bounds(), img, bounds, xdraw.Src, nil)
+	}
+	rgba = applyOrientation(rgba, orientation)
+
+	outMIME := mimeType
+	if mimeType == "image/webp" {
+		outMIME = "image/jpeg"
+		if !rgba.Opaque() {
+			outMIME = "image/png"
+		}
+	}
+
+	var buf bytes.Buffer
+	if outMIME == "image/png" {
+		err = png.Encode(&buf, rgba)
+	} else {
+		err = jpeg.Encode(&buf, rgba, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return &SanitizedImage{
+		Data:     buf.Bytes(),
+		MimeType: outMIME,
+		Width:    rgba.Bounds().Dx(),
+		Height:   rgba.Bounds().Dy(),
+		Metadata: metadata,
+	}, nil
+}
+
+// applyOrientation transforms the pixels according to the EXIF orientation (1-8)
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// findEXIF returns the raw TIFF-formatted EXIF block embedded in a JPEG, PNG or WebP file
+func findEXIF(data []byte, mimeType string) []byte {
+	switch mimeType {
+	case "image/jpeg":
+		// Walk the segments up to the image data (SOS)
+		for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+			marker := data[i+1]
+			length := int(binary.BigEndian.Uint16(data[i+2:]))
+			if marker == 0xDA || i+2+length > len(data) {
+				break
+			}
+			segment := data[i+4 : i+2+length]
+			if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				return segment[6:]
+			}
+			i += 2 + length
+		}
+	case "image/png":
+		for i := 8; i+12 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[i:]))
+			if length < 0 || i+12+length > len(data) {
+				break
+			}
+			if string(data[i+4:i+8]) == "eXIf" {
+				return data[i+8 : i+8+length]
+			}
+			i += 12 + length
+		}
+	case "image/webp":
+		if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+			return nil
+		}
+		for i := 12; i+8 <= len(data); {
+			length := int(binary.LittleEndian.Uint32(data[i+4:]))
+			if length < 0 || i+8+length > len(data) {
+				break
+			}
+			if string(data[i:i+4]) == "EXIF" {
+				return bytes.TrimPrefix(data[i+8:i+8+length], []byte("Exif\x00\x00"))
+			}
+			i += 8 + length + length%2
+		}
+	}
+	return nil
+}
+
+// EXIF tags kept in SanitizedImage.Metadata
+var (
+	exifIFD0Tags = map[uint16]string{
+		0x010E: "ImageDescription",
+		0x010F: "Make",
+		0x0110: "Model",
+		0x0131: "Software",
+		0x0132: "DateTime",
+		0x013B: "Artist",
+		0x8298: "Copyright",
+	}
+	exifSubIFDTags = map[uint16]string{
+		0x9003: "DateTimeOriginal",
+		0xA434: "LensModel",
+	}
+)
+
+// exifReader reads IFD entries from a TIFF block
+type exifReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// parseEXIF returns the orientation (1 when unknown) and the readable tags of an EXIF block
+func parseEXIF(tiff []byte) (int, map[string]string) {
+	orientation := 1
+	tags := make(map[string]string)
+	if len(tiff) < 8 {
+		return orientation, tags
+	}
+
+	r := &exifReader{data: tiff}
+	switch string(tiff[0:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return orientation, tags
+	}
+
+	var exifIFD, gpsIFD uint32
+	r.walk(r.order.Uint32(tiff[4:]), func(tag, typ uint16, count, value uint32, raw []byte) {
+		switch {
+		case tag == 0x0112 && typ == 3:
+			orientation = int(r.order.Uint16(raw))
+		case tag == 0x8769:
+			exifIFD = value
+		case tag == 0x8825:
+			gpsIFD = value
+		case exifIFD0Tags[tag] != "" && typ == 2:
+			tags[exifIFD0Tags[tag]] = r.ascii(count, value, raw)
+		}
+	})
+
+	if exifIFD > 0 {
+		r.walk(exifIFD, func(tag, typ uint16, count, value uint32, raw []byte) {
+			if exifSubIFDTags[tag] != "" && typ == 2 {
+				tags[exifSubIFDTags[tag]] = r.ascii(count, value, raw)
+			}
+		})
+	}
+
+	if gpsIFD > 0 {
+		gps := make(map[uint16]string)
+		r.walk(gpsIFD, func(tag, typ uint16, count, value uint32, raw []byte) {
+			switch {
+			case (tag == 1 || tag == 3) && typ == 2: // latitude/longitude reference (N/S, E/W)
+				gps[tag] = r.ascii(count, value, raw)
+			case (tag == 2 || tag == 4) && typ == 5 && count == 3: // degrees, minutes, seconds
+				gps[tag] = r.degrees(value)
+			}
+		})
+		if lat, ok := gps[2]; ok {
+			tags["GPSLatitude"] = signedCoordinate(lat, gps[1] == "S")
+		}
+		if lon, ok := gps[4]; ok {
+			tags["GPSLongitude"] = signedCoordinate(lon, gps[3] == "W")
+		}
+	}
+
+	return orientation, tags
+}
+
+// walk calls fn for every entry of the IFD at offset (raw holds the 4-byte value field)
+func (r *exifReader) walk(offset uint32, fn func(tag, typ uint16, count, value uint32, raw []byte)) {
+	if int(offset)+2 > len(r.data) {
+		return
+	}
+	entries := int(r.order.Uint16(r.data[offset:]))
+	for i := 0; i < entries; i++ {
+		pos := int(offset) + 2 + i*12
+		if pos+12 > len(r.data) {
+			return
+		}
+		entry := r.data[pos : pos+12]
+		fn(r.order.Uint16(entry[0:]), r.order.Uint16(entry[2:]), r.order.Uint32(entry[4:]), r.order.Uint32(entry[8:]), entry[8:12])
+	}
+}
+
+// ascii reads an ASCII value, stored inline when it fits in 4 bytes
+func (r *exifReader) ascii(count, offset uint32, raw []byte) string {
+	var b []byte
+	if count <= 4 {
+		b = raw[:count]
+	} else if int(offset)+int(count) <= len(r.data) {
+		b = r.data[offset : offset+count]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
+
+// degrees converts three RATIONAL values (degrees, minutes, seconds) to decimal degrees
+func (r *exifReader) degrees(offset uint32) string {
+	if int(offset)+24 > len(r.data) {
+		return ""
+	}
+	var parts [3]float64
+	for i := range parts {
+		num := r.order.Uint32(r.data[int(offset)+i*8:])
+		den := r.order.Uint32(r.data[int(offset)+i*8+4:])
+		if den != 0 {
+			parts[i] = float64(num) / float64(den)
+		}
+	}
+	return strconv.FormatFloat(parts[0]+parts[1]/60+parts[2]/3600, 'f', 6, 64)
+}
+
+func signedCoordinate(value string, negative bool) string {
+	if negative && value != "" {
+		return "-" + value
+	}
+	return value
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"airboard/models"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+// ImageVariantSpec describes a resized copy generated for each uploaded image
+type ImageVariantSpec struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int // 0 = no height bound
+}
+
+// DefaultImageVariants are generated on upload: a small thumbnail for the
+// media library plus width-bounded copies for srcset
+var DefaultImageVariants = []ImageVariantSpec{
+	{Name: "thumbnail", MaxWidth: 300, MaxHeight: 300},
+	{Name: "w320", MaxWidth: 320},
+	{Name: "w768", MaxWidth: 768},
+	{Name: "w1280", MaxWidth: 1280},
+}
+
+// maxVariantSourcePixels protects against decompression bombs (40 MP)
+const maxVariantSourcePixels = 40_000_000
+
+// ImageVariantService generates and removes resized copies of uploaded images
+type ImageVariantService struct {
+	db      *gorm.DB
+	storage StorageService
+	specs   []ImageVariantSpec
+}
+
+// NewImageVariantService creates a new image variant service
+func NewImageVariantService(db *gorm.DB, storage StorageService) *ImageVariantService {
+	return &ImageVariantService{
+		db:      db,
+		storage: storage,
+		specs:   DefaultImageVariants,
+	}
+}
+
+// SupportsVariants reports whether variants can be generated for a MIME type.
+// GIFs are skipped to keep animations intact.
+func SupportsVariants(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+// Generate decodes the original image, writes the variants through the storage
+// backend and stores them for the given media. It also fills media.ThumbnailURL.
+func (s *ImageVariantService) Generate(ctx context.Context, media *models.Media, src io.ReadSeeker) error {
+	if !SupportsVariants(media.MimeType) {
+		return nil
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind image: %w", err)
+	}
+	cfg, _, err := image.DecodeConfig(src)
+	if err != nil {
+		return fmt.Errorf("failed to read image header: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxVariantSourcePixels {
+		return fmt.Errorf("image too large for variants: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind image: %w", err)
+	}
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// PNG keeps transparency, everything else is served as JPEG
+	outMIME, outExt := "image/jpeg", ".jpg"
+	if media.MimeType == "image/png" {
+		outMIME, outExt = "image/png", ".png"
+	}
+
+	base := strings.TrimSuffix(media.StoragePath, path.Ext(media.StoragePath))
+	bounds := img.Bounds()
+
+	var variants []models.MediaVariant
+	for _, spec := range s.specs {
+		w, h := fitWithin(bounds.Dx(), bounds.Dy(), spec.MaxWidth, spec.MaxHeight)
+		// Never upscale: a responsive variant wider than the original is useless
+		if spec.Name != "thumbnail" && w >= bounds.Dx() {
+			continue
+		}
+
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+		var buf bytes.Buffer
+		if outMIME == "image/png" {
+			err = png.Encode(&buf, dst)
+		} else {
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 82})
+		}
+		if err != nil {
+			s.deleteStored(ctx, variants)
+			return fmt.Errorf("failed to encode %s variant: %w", spec.Name, err)
+		}
+
+		variantPath := fmt.Sprintf("%s_%s%s", base, spec.Name, outExt)
+		size := int64(buf.Len())
+		if err := s.storage.PutObject(ctx, variantPath, &buf, size, outMIME); err != nil {
+			s.deleteStored(ctx, variants)
+			return fmt.Errorf("failed to store %s variant: %w", spec.Name, err)
+		}
+
+		variants = append(variants, models.MediaVariant{
+			MediaID:     media.ID,
+			Name:        spec.Name,
+			StoragePath: variantPath,
+			URL:         s.storage.GetURL(variantPath),
+			MimeType:    outMIME,
+			FileSize:    size,
+			Width:       w,
+			Height:      h,
+		})
+	}
+
+	if len(variants) == 0 {
+		return nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&variants).Error; err != nil {
+			return err
+		}
+		for _, v := range variants {
+			if v.Name == "thumbnail" {
+				media.ThumbnailURL = v.URL
+				return tx.Model(media).Update("thumbnail_url", v.URL).Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.deleteStored(ctx, variants)
+		return fmt.Errorf("failed to save variants: %w", err)
+	}
+
+	media.Variants = variants
+	return nil
+}
+
+// CopyVariants attaches the variants of an existing media to a deduplicated copy
+// (same content, so the stored files are shared)
+func (s *ImageVariantService) CopyVariants(sourceMediaID uint, media *models.Media) error {
+	var source []models.MediaVariant
+	if err := s.db.Where("media_id = ?", sourceMediaID).Find(&source).Error; err != nil {
+		return err
+	}
+	if len(source) == 0 {
+		return nil
+	}
+
+	variants := make([]models.MediaVariant, 0, len(source))
+	for _, v := range source {
+		v.ID = 0
+		v.MediaID = media.ID
+		v.CreatedAt = time.Time{}
+		variants = append(variants, v)
+		if v.Name == "thumbnail" {
+			media.ThumbnailURL = v.URL
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&variants).Error; err != nil {
+			return err
+		}
+		media.Variants = variants
+		return tx.Model(media).Update("thumbnail_url", media.ThumbnailURL).Error
+	})
+}
+
+// DeleteForMedia removes every variant of a media in database, and in storage
+// unless another (deduplicated) media still uses the same files
+func (s *ImageVariantService) DeleteForMedia(ctx context.Context, mediaID uint) error {
+	var variants []models.MediaVariant
+	if err := s.db.Where("media_id = ?", mediaID).Find(&variants).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("media_id = ?", mediaID).Delete(&models.MediaVariant{}).Error; err != nil {
+		return err
+	}
+
+	var unused []models.MediaVariant
+	for _, v := range variants {
+		var count int64
+		s.db.Model(&models.MediaVariant{}).Where("storage_path = ?", v.StoragePath).Count(&count)
+		if count == 0 {
+			unused = append(unused, v)
+		}
+	}
+	s.deleteStored(ctx, unused)
+	return nil
+}
+
+func (s *ImageVariantService) deleteStored(ctx context.Context, variants []models.MediaVariant) {
+	for _, v := range variants {
+		if err := s.storage.Delete(ctx, v.StoragePath); err != nil {
+			log.Printf("Warning: failed to delete variant %s: %v", v.StoragePath, err)
+		}
+	}
+}
+
+// fitWithin scales (w, h) down to fit in (maxW, maxH) while keeping the aspect ratio
+func fitWithin(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 && w > maxW {
+		scale = float64(maxW) / float64(w)
+	}
+	if maxH > 0 && float64(h)*scale > float64(maxH) {
+		scale = float64(maxH) / float64(h)
+	}
+	nw, nh := int(float64(w)*scale+0.5), int(float64(h)*scale+0.5)
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+	return nw, nh
+}
+
+// LoadVariantsByURL returns the variants of the media matching each URL (covers, hero images...)
+func LoadVariantsByURL(db *gorm.DB, urls []string) map[string][]models.MediaVariant {
+	result := make(map[string][]models.MediaVariant)
+
+	var wanted []string
+	for _, u := range urls {
+		if u != "" {
+			wanted = append(wanted, u)
+		}
+	}
+	if len(wanted) == 0 {
+		return result
+	}
+
+	var medias []models.Media
+	if err := db.Preload("Variants").Where("url IN ?", wanted).Find(&medias).Error; err != nil {
+		log.Printf("Warning: failed to load media variants: %v", err)
+		return result
+	}
+	for _, m := range medias {
+		if len(m.Variants) > 0 {
+			result[m.URL] = m.Variants
+		}
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"airboard/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Paramètres des sessions "voir en tant que"
+const (
+	ImpersonationDefaultTTL = 30 * time.Minute
+	ImpersonationMaxTTL     = time.Hour
+)
+
+var (
+	ErrImpersonationNotAllowed = errors.New("impersonation not allowed for this user")
+	ErrImpersonationEnded      = errors.New("impersonation ended or expired")
+)
+
+// ImpersonationService gère les sessions "voir en tant que" des administrateurs et leur journal
+type ImpersonationService struct {
+	db *gorm.DB
+}
+
+// NewImpersonationService crée une nouvelle instance du service d'impersonation
+func NewImpersonationService(db *gorm.DB) *ImpersonationService {
+	return &ImpersonationService{db: db}
+}
+
+// Start ouvre une session en tant que target et l'inscrit au journal. Les administrateurs
+// ne peuvent pas consulter l'application en tant qu'un autre administrateur ou eux-mêmes.
+func (s *ImpersonationService) Start(admin, target *models.User, reason, ipAddress, userAgent string, ttl time.Duration) (*models.Impersonation, error) {
+	if target.ID == admin.ID || target.Role == "admin" {
+		return nil, ErrImpersonationNotAllowed
+	}
+	if ttl <= 0 {
+		ttl = ImpersonationDefaultTTL
+	}
+	if ttl > ImpersonationMaxTTL {
+		ttl = ImpersonationMaxTTL
+	}
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	impersonation := models.Impersonation{
+		AdminID:        admin.ID,
+		AdminUsername:  admin.Username,
+		TargetUserID:   target.ID,
+		TargetUsername: target.Username,
+		Reason:         reason,
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+		ExpiresAt:      time.Now().Add(ttl),
+	}
+	if err := s.db.Create(&impersonation).Error; err != nil {
+		return nil, err
+	}
+	return &impersonation, nil
+}
+
+// Validate vérifie qu'une session d'impersonation est en cours et que l'administrateur l'est toujours
+func (s *ImpersonationService) Validate(impersonationID, adminID, targetUserID uint) error {
+	var impersonation models.Impersonation
+	if err := s.db.First(&impersonation, impersonationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrImpersonationEnded
+		}
+		return err
+	}
+	if impersonation.AdminID != adminID || impersonation.TargetUserID != targetUserID ||
+		impersonation.EndedAt != nil || time.Now().After(impersonation.ExpiresAt) {
+		return ErrImpersonationEnded
+	}
+
+	var admin models.User
+	if err := s.db.Select("id", "role", "is_active").First(&admin, adminID).Error; err != nil ||
+		!admin.IsActive || admin.Role != "admin" {
+		return ErrImpersonationEnded
+	}
+	return nil
+}
+
+// End termine une session d'impersonation
+func (s *ImpersonationService) End(impersonationID uint) error {
+	result := s.db.Model(&models.Impersonation{}).
+		Where("id = ? AND ended_at IS NULL", impersonationID).
+		Update("ended_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrImpersonationEnded
+	}
+	return nil
+}
+
+// Get retourne une entrée du journal
+func (s *ImpersonationService) Get(impersonationID uint) (*models.Impersonation, error) {
+	var impersonation models.Impersonation
+	if err := s.db.First(&impersonation, impersonationID).Error; err != nil {
+		return nil, err
+	}
+	return &impersonation, nil
+}
+
+// RecordBlockedWrite compte une modification refusée pendant la session
+func (s *ImpersonationService) RecordBlockedWrite(impersonationID uint) {
+	s.db.Model(&models.Impersonation{}).Where("id = ?", impersonationID).
+		UpdateColumn("blocked_writes", gorm.Expr("blocked_writes + 1"))
+}
+
+// List retourne le journal des impersonations (le plus récent en premier), filtré par
+// administrateur et/ou utilisateur consulté lorsque les IDs sont non nuls
+func (s *ImpersonationService) List(adminID, targetUserID uint, limit int) ([]models.Impersonation, error) {
+	query := s.db.Order("created_at DESC").Limit(limit)
+	if adminID != 0 {
+		query = query.Where("admin_id = ?", adminID)
+	}
+	if targetUserID != 0 {
+		query = query.Where("target_user_id = ?", targetUserID)
+	}
+
+	var impersonations []models.Impersonation
+	err := query.Find(&impersonations).Error
+	return impersonations, err
+}
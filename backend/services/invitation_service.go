@@ -0,0 +1,373 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Paramètres des invitations
+const (
+	InvitationTTL      = 7 * 24 * time.Hour
+	InvitationTemplate = "invitation"
+)
+
+var (
+	ErrInvalidInvitation    = errors.New("invalid, expired or revoked invitation")
+	ErrInvitationConflict   = errors.New("an account or a pending invitation already exists for this email")
+	ErrInvitationNotPending = errors.New("invitation has already been accepted or revoked")
+	ErrUsernameTaken        = errors.New("username already in use")
+	ErrInvitationEmail      = errors.New("failed to send invitation email")
+)
+
+// InvitationRoles sont les rôles pouvant être attribués par une invitation
+var InvitationRoles = map[string]bool{"user": true, "editor": true, "admin": true}
+
+// InvitationService gère les invitations à créer un compte local. Le lien envoyé est signé
+// (HMAC sur l'invitation, l'email et l'expiration) : il n'est valable que pour l'expiration
+// enregistrée, un renvoi invalide donc le lien précédent.
+type InvitationService struct {
+	db     *gorm.DB
+	config *config.Config
+	email  *EmailService
+}
+
+// NewInvitationService crée une nouvelle instance du service d'invitations
+func NewInvitationService(db *gorm.DB, cfg *config.Config) *InvitationService {
+	return &InvitationService{
+		db:     db,
+		config: cfg,
+		email:  NewEmailService(db, cfg),
+	}
+}
+
+// Create enregistre une invitation et envoie le lien. L'invitation est supprimée si l'email n'a pas pu être envoyé.
+func (s *InvitationService) Create(email, role string, groups []models.Group, inviter *models.User) (*models.Invitation, error) {
+	email = strings.TrimSpace(email)
+	if err := s.checkAvailable(email, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invitation := models.Invitation{
+		Email:             email,
+		Role:              role,
+		Groups:            groups,
+		InvitedByID:       inviter.ID,
+		InvitedByUsername: inviter.Username,
+		ExpiresAt:         now.Add(InvitationTTL),
+		SentAt:            now,
+		SendCount:         1,
+	}
+	if err := s.db.Create(&invitation).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.send(&invitation); err != nil {
+		if delErr := s.db.Select("Groups").Delete(&invitation).Error; delErr != nil {
+			log.Printf("[Invitations] Erreur lors de la suppression de l'invitation %d non envoyée: %v", invitation.ID, delErr)
+		}
+		return nil, err
+	}
+
+	invitation.Status = invitation.CurrentStatus()
+	log.Printf("[Invitations] Invitation %d sent to %s by user %d (role %s)", invitation.ID, invitation.Email, inviter.ID, role)
+	return &invitation, nil
+}
+
+// Get retourne une invitation avec ses groupes
+func (s *InvitationService) Get(id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := s.db.Preload("Groups").First(&invitation, id).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// List retourne une page d'invitations, les plus récentes d'abord. invitedBy limite la liste
+// aux invitations d'un utilisateur (0 = toutes) et status à un statut (vide = tous).
+func (s *InvitationService) List(invitedBy uint, status string, page, limit int) ([]models.Invitation, int64, error) {
+	query := s.db.Model(&models.Invitation{})
+	if invitedBy != 0 {
+		query = query.Where("invited_by_id = ?", invitedBy)
+	}
+
+	now := time.Now()
+	switch status {
+	case "":
+	case models.InvitationPending:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", now)
+	case models.InvitationAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case models.InvitationRevoked:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NOT NULL")
+	case models.InvitationExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	default:
+		return nil, 0, fmt.Errorf("unknown invitation status: %s", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var invitations []models.Invitation
+	if err := query.Preload("Groups").
+		Order("created_at DESC").
+		Limit(limit).Offset((page - 1) * limit).
+		Find(&invitations).Error; err != nil {
+		return nil, 0, err
+	}
+	return invitations, total, nil
+}
+
+// Resend renvoie une invitation en attente ou expirée avec une nouvelle expiration.
+// L'expiration n'est remplacée qu'après l'envoi : en cas d'échec, le lien précédent reste valable.
+func (s *InvitationService) Resend(invitation *models.Invitation) error {
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return ErrInvitationNotPending
+	}
+	if err := s.checkAvailable(invitation.Email, invitation.ID); err != nil {
+		return err
+	}
+
+	previous := invitation.ExpiresAt
+	now := time.Now()
+	invitation.ExpiresAt = now.Add(InvitationTTL)
+	if err := s.send(invitation); err != nil {
+		invitation.ExpiresAt = previous
+		return err
+	}
+
+	if err := s.db.Model(invitation).Updates(map[string]interface{}{
+		"expires_at": invitation.ExpiresAt,
+		"sent_at":    now,
+		"send_count": gorm.Expr("send_count + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	invitation.SentAt = now
+	invitation.SendCount++
+	invitation.Status = invitation.CurrentStatus()
+	return nil
+}
+
+// Revoke invalide une invitation qui n'a pas encore été acceptée
+func (s *InvitationService) Revoke(invitation *models.Invitation) error {
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return ErrInvitationNotPending
+	}
+	now := time.Now()
+	if err := s.db.Model(invitation).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	invitation.RevokedAt = &now
+	invitation.Status = invitation.CurrentStatus()
+	return nil
+}
+
+// Lookup retourne les informations affichées au destinataire d'un lien valide
+func (s *InvitationService) Lookup(token string) (*models.InvitationDetails, error) {
+	invitation, err := s.parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(invitation.Groups))
+	for _, group := range invitation.Groups {
+		groups = append(groups, group.Name)
+	}
+	return &models.InvitationDetails{
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		Groups:    groups,
+		InvitedBy: s.inviterName(invitation),
+		ExpiresAt: invitation.ExpiresAt,
+	}, nil
+}
+
+// Accept crée le compte local de l'invitation avec le mot de passe choisi par le destinataire.
+// L'email est considéré comme confirmé puisque le lien a été reçu à cette adresse.
+func (s *InvitationService) Accept(req models.InvitationAcceptRequest, bcryptCost int) (*models.User, error) {
+	invitation, err := s.parse(req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := NewPasswordPolicyService(s.db)
+	if err := policy.Validate(nil, req.Password); err != nil {
+		return nil, err
+	}
+
+	// Les comptes supprimés (corbeille) conservent leur identifiant et leur email
+	var count int64
+	if err := s.db.Unscoped().Model(&models.User{}).Where("username = ?", req.Username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUsernameTaken
+	}
+	if err := s.db.Unscoped().Model(&models.User{}).Where("LOWER(email) = ?", strings.ToLower(invitation.Email)).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrInvitationConflict
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username:  req.Username,
+		Email:     invitation.Email,
+		Password:  string(hashedPassword),
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Role:      invitation.Role,
+		IsActive:  true,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Usage unique même en cas de requêtes concurrentes
+		now := time.Now()
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+			Update("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidInvitation
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if len(invitation.Groups) > 0 {
+			if err := tx.Model(&user).Association("Groups").Append(invitation.Groups); err != nil {
+				return err
+			}
+		}
+		if err := policy.Record(tx, user.ID, user.Password); err != nil {
+			return err
+		}
+		return tx.Model(&models.Invitation{}).Where("id = ?", invitation.ID).Update("accepted_user_id", user.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Invitations] Invitation %d accepted: user %d (%s) created with role %s", invitation.ID, user.ID, user.Username, user.Role)
+	return &user, nil
+}
+
+// checkAvailable refuse une adresse déjà utilisée par un compte ou par une autre invitation en attente
+func (s *InvitationService) checkAvailable(email string, excludeID uint) error {
+	lower := strings.ToLower(email)
+
+	var count int64
+	if err := s.db.Unscoped().Model(&models.User{}).Where("LOWER(email) = ?", lower).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrInvitationConflict
+	}
+
+	if err := s.db.Model(&models.Invitation{}).
+		Where("LOWER(email) = ? AND id <> ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", lower, excludeID, time.Now()).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrInvitationConflict
+	}
+	return nil
+}
+
+// parse vérifie un token "<id>.<expiration>.<signature>" et retourne l'invitation en attente correspondante
+func (s *InvitationService) parse(token string) (*models.Invitation, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidInvitation
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, ErrInvalidInvitation
+	}
+
+	invitation, err := s.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+
+	expected := s.sign(invitation.ID, invitation.Email, expires)
+	if invitation.ExpiresAt.Unix() != expires || !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidInvitation
+	}
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return nil, ErrInvalidInvitation
+	}
+	return invitation, nil
+}
+
+func (s *InvitationService) send(invitation *models.Invitation) error {
+	expires := invitation.ExpiresAt.Unix()
+	token := fmt.Sprintf("%d.%d.%s", invitation.ID, expires, s.sign(invitation.ID, invitation.Email, expires))
+
+	groups := make([]string, 0, len(invitation.Groups))
+	for _, group := range invitation.Groups {
+		groups = append(groups, group.Name)
+	}
+	data := InvitationEmailData{
+		Email:     invitation.Email,
+		InvitedBy: s.inviterName(invitation),
+		Role:      invitation.Role,
+		Groups:    strings.Join(groups, ", "),
+		Link:      fmt.Sprintf("%s/auth/accept-invitation?token=%s", strings.TrimSuffix(s.config.Server.PublicURL, "/"), url.QueryEscape(token)),
+		ExpiresIn: "7 jours",
+		AppName:   s.email.AppName(),
+	}
+	if err := s.email.SendTemplateEmail(InvitationTemplate, invitation.Email, data); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvitationEmail, err)
+	}
+	return nil
+}
+
+// inviterName retourne le nom affiché de l'auteur de l'invitation (son identifiant s'il a été supprimé)
+func (s *InvitationService) inviterName(invitation *models.Invitation) string {
+	var inviter models.User
+	if err := s.db.Select("first_name", "last_name").First(&inviter, invitation.InvitedByID).Error; err == nil {
+		if name := strings.TrimSpace(inviter.FirstName + " " + inviter.LastName); name != "" {
+			return name
+		}
+	}
+	return invitation.InvitedByUsername
+}
+
+func (s *InvitationService) sign(invitationID uint, email string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.config.JWT.Secret))
+	mac.Write([]byte("invitation\n" + strconv.FormatUint(uint64(invitationID), 10) + "\n" +
+		strings.ToLower(email) + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
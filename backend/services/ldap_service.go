@@ -0,0 +1,319 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLDAPInvalidCredentials = errors.New("invalid LDAP credentials")
+	ErrLDAPAccountConflict    = errors.New("email already used by a non-LDAP account")
+	ErrLDAPMissingEmail       = errors.New("LDAP entry has no email address")
+	ErrLDAPAccountDisabled    = errors.New("account disabled in Airboard")
+)
+
+// LDAPService authentifie les utilisateurs sur un annuaire LDAP / Active Directory
+// et synchronise leurs attributs et leurs groupes
+type LDAPService struct {
+	db     *gorm.DB
+	config *config.LDAPConfig
+	mapper *SSOMapper
+}
+
+// LDAPSyncResult résume une synchronisation de l'annuaire
+type LDAPSyncResult struct {
+	Synced      int `json:"synced"`
+	Deactivated int `json:"deactivated"`
+	Failed      int `json:"failed"`
+}
+
+// NewLDAPService crée une nouvelle instance du service LDAP
+func NewLDAPService(db *gorm.DB, cfg *config.Config) *LDAPService {
+	return &LDAPService{
+		db:     db,
+		config: &cfg.LDAP,
+		mapper: NewLDAPMapper(db, cfg),
+	}
+}
+
+// Enabled indique si l'authentification LDAP est activée
+func (s *LDAPService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// Authenticate vérifie l'identifiant et le mot de passe sur l'annuaire (recherche avec le compte
+// de service puis bind en tant qu'utilisateur) et crée ou met à jour l'utilisateur Airboard
+func (s *LDAPService) Authenticate(username, password string) (*models.User, error) {
+	// Un bind avec un mot de passe vide est un bind anonyme qui réussit sur beaucoup d'annuaires
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := s.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("LDAP user bind failed: %w", err)
+	}
+
+	// Les groupes sont lus avec le compte de service (l'utilisateur n'a pas toujours le droit de les lister)
+	if err := s.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+	info, err := s.userInfo(conn, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ne pas rattacher à l'annuaire un compte local ou SSO existant qui porte la même adresse
+	// (les comptes provisionnés par SCIM sont rattachés au premier fournisseur utilisé)
+	var existing models.User
+	if err := s.db.Select("id", "sso_provider", "is_active", "scim_managed").Where("email = ?", info.Email).Limit(1).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing.ID != 0 && existing.SSOProvider != s.mapper.provider && !existing.SCIMManaged {
+		return nil, ErrLDAPAccountConflict
+	}
+	// Un compte désactivé par un administrateur n'est pas réactivé par la connexion
+	if existing.ID != 0 && !existing.IsActive {
+		return nil, ErrLDAPAccountDisabled
+	}
+
+	return s.mapper.SyncUser(info)
+}
+
+// Sync met à jour les attributs et les groupes des utilisateurs LDAP actifs et désactive
+// ceux qui ne sont plus trouvés par le filtre (compte supprimé ou désactivé dans l'annuaire)
+func (s *LDAPService) Sync() (*LDAPSyncResult, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var users []models.User
+	if err := s.db.Where("sso_provider = ? AND is_active = ?", s.mapper.provider, true).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	result := &LDAPSyncResult{}
+	entries := make([]*ldap.Entry, len(users))
+	found := 0
+	for i, user := range users {
+		entry, err := s.findUser(conn, user.Username)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = entry
+		if entry != nil {
+			found++
+		}
+	}
+	// Aucun utilisateur trouvé : filtre ou BaseDN probablement erroné, ne désactiver personne
+	if found == 0 && len(users) > 1 {
+		return nil, errors.New("no LDAP user found, check LDAP_BASE_DN and LDAP_USER_FILTER")
+	}
+
+	for i, user := range users {
+		entry := entries[i]
+		if entry == nil {
+			if err := s.deactivate(&user); err != nil {
+				log.Printf("[LDAP] Erreur lors de la désactivation de %s: %v", user.Username, err)
+				result.Failed++
+				continue
+			}
+			log.Printf("[LDAP] Utilisateur %s introuvable dans l'annuaire, compte désactivé", user.Username)
+			result.Deactivated++
+			continue
+		}
+
+		info, err := s.userInfo(conn, entry)
+		if err == nil && !strings.EqualFold(info.Email, user.Email) {
+			err = fmt.Errorf("email changed in directory (%s)", info.Email)
+		}
+		var synced *models.User
+		if err == nil {
+			synced, err = s.mapper.SyncUser(info)
+		}
+		if err != nil {
+			log.Printf("[LDAP] Erreur lors de la synchronisation de %s: %v", user.Username, err)
+			result.Failed++
+			continue
+		}
+
+		// Le rôle est dans le JWT : un changement de groupe admin révoque les sessions en cours
+		if synced.Role != user.Role {
+			if _, err := NewSessionService(s.db).RevokeAll(user.ID, SessionRevokedRoleChanged); err != nil {
+				log.Printf("[LDAP] Erreur lors de la révocation des sessions de %s: %v", user.Username, err)
+			}
+		}
+		result.Synced++
+	}
+	return result, nil
+}
+
+// connect ouvre une connexion authentifiée avec le compte de service
+func (s *LDAPService) connect() (*ldap.Conn, error) {
+	timeout := time.Duration(s.config.Timeout) * time.Second
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.config.InsecureSkipVerify}
+	if u, err := url.Parse(s.config.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(s.config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("LDAP connection failed: %w", err)
+	}
+	conn.SetTimeout(timeout)
+
+	if s.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS failed: %w", err)
+		}
+	}
+
+	if err := s.bindServiceAccount(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (s *LDAPService) bindServiceAccount(conn *ldap.Conn) error {
+	if s.config.BindDN == "" {
+		return conn.UnauthenticatedBind("")
+	}
+	if err := conn.Bind(s.config.BindDN, s.config.BindPassword); err != nil {
+		return fmt.Errorf("LDAP service account bind failed: %w", err)
+	}
+	return nil
+}
+
+// findUser cherche l'entrée d'un utilisateur ; nil si elle est absente ou ambiguë
+func (s *LDAPService) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(s.config.UserFilter, "{username}", ldap.EscapeFilter(username))
+	request := ldap.NewSearchRequest(
+		s.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, s.config.Timeout, false,
+		filter, s.userAttributes(), nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("LDAP user search failed: %w", err)
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return nil, nil
+	}
+	return result.Entries[0], nil
+}
+
+// userInfo convertit une entrée LDAP (et ses groupes) en informations de synchronisation
+func (s *LDAPService) userInfo(conn *ldap.Conn, entry *ldap.Entry) (*SSOUserInfo, error) {
+	email := strings.ToLower(strings.TrimSpace(entry.GetAttributeValue(s.config.EmailAttribute)))
+	if email == "" {
+		return nil, ErrLDAPMissingEmail
+	}
+
+	groups, err := s.userGroups(conn, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	username := entry.GetAttributeValue(s.config.UsernameAttribute)
+	if username == "" {
+		username = email
+	}
+	return &SSOUserInfo{
+		Email:      email,
+		Username:   username,
+		FirstName:  entry.GetAttributeValue(s.config.FirstNameAttribute),
+		LastName:   entry.GetAttributeValue(s.config.LastNameAttribute),
+		Groups:     groups,
+		SSOID:      entry.DN,
+		Phone:      entry.GetAttributeValue(s.config.PhoneAttribute),
+		Department: entry.GetAttributeValue(s.config.DepartmentAttribute),
+		JobTitle:   entry.GetAttributeValue(s.config.JobTitleAttribute),
+	}, nil
+}
+
+// userGroups retourne le nom des groupes LDAP de l'utilisateur
+func (s *LDAPService) userGroups(conn *ldap.Conn, entry *ldap.Entry) ([]string, error) {
+	if s.config.GroupFilter == "" {
+		return nil, nil
+	}
+
+	username := entry.GetAttributeValue(s.config.UsernameAttribute)
+	filter := strings.NewReplacer(
+		"{dn}", ldap.EscapeFilter(entry.DN),
+		"{username}", ldap.EscapeFilter(username),
+	).Replace(s.config.GroupFilter)
+
+	request := ldap.NewSearchRequest(
+		s.config.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, s.config.Timeout, false,
+		filter, []string{s.config.GroupNameAttribute}, nil,
+	)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, fmt.Errorf("LDAP group search failed: %w", err)
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, group := range result.Entries {
+		if name := group.GetAttributeValue(s.config.GroupNameAttribute); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups, nil
+}
+
+func (s *LDAPService) userAttributes() []string {
+	var attributes []string
+	for _, attribute := range []string{
+		s.config.UsernameAttribute, s.config.EmailAttribute, s.config.FirstNameAttribute, s.config.LastNameAttribute,
+		s.config.DepartmentAttribute, s.config.JobTitleAttribute, s.config.PhoneAttribute,
+	} {
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+// deactivate désactive un utilisateur retiré de l'annuaire et révoque ses sessions
+func (s *LDAPService) deactivate(user *models.User) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		_, err := NewSessionService(s.db).RevokeAllTx(tx, user.ID, SessionRevokedDeactivated)
+		return err
+	})
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Media visibility levels, from the most to the least permissive
+const (
+	MediaVisibilityPublic        = "public"        // anyone, no session required
+	MediaVisibilityAuthenticated = "authenticated" // any signed-in user
+	MediaVisibilityGroup         = "group"         // members of Media.AccessGroups only
+)
+
+// MediaViewer identifies who requests a file (zero UserID = anonymous)
+type MediaViewer struct {
+	UserID uint
+	Role   string
+}
+
+// MediaAccess describes the access rules of a stored object, merged over
+// every media (and variant) pointing to it
+type MediaAccess struct {
+	Visibility  string
+	GroupIDs    []uint
+	UploaderIDs []uint
+	MimeType    string
+	ContentHash string // only set for originals, used as ETag
+	Known       bool   // false for files outside the media library (avatars...)
+	Quarantined bool   // every media using the file is quarantined (antivirus)
+}
+
+// MediaAccessService derives media visibility from the content using them and
+// checks download permissions
+type MediaAccessService struct {
+	db *gorm.DB
+}
+
+// NewMediaAccessService creates a new media access service
+func NewMediaAccessService(db *gorm.DB) *MediaAccessService {
+	return &MediaAccessService{db: db}
+}
+
+// recomputeMediaVisibility derives Visibility and AccessGroups from the media references:
+// app settings (login/hero images) make a media public, news and events targeted
+// to groups restrict it to those groups, anything else requires a session.
+func recomputeMediaVisibility(tx *gorm.DB, mediaIDs []uint) error {
+	seen := make(map[uint]bool)
+	for _, mediaID := range mediaIDs {
+		if seen[mediaID] {
+			continue
+		}
+		seen[mediaID] = true
+
+		var refs []models.MediaReference
+		if err := tx.Where("media_id = ?", mediaID).Find(&refs).Error; err != nil {
+			return err
+		}
+
+		visibility, groupIDs, err := deriveVisibility(tx, refs)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Media{}).Where("id = ?", mediaID).Update("visibility", visibility).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM media_access_groups WHERE media_id = ?", mediaID).Error; err != nil {
+			return err
+		}
+		for _, groupID := range groupIDs {
+			if err := tx.Exec("INSERT INTO media_access_groups (media_id, group_id) VALUES (?, ?)", mediaID, groupID).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deriveVisibility(tx *gorm.DB, refs []models.MediaReference) (string, []uint, error) {
+	if len(refs) == 0 {
+		return MediaVisibilityAuthenticated, nil, nil
+	}
+
+	authenticated := false
+	groups := make(map[uint]bool)
+	for _, ref := range refs {
+		var joinTable, column string
+		switch ref.EntityType {
+		case MediaRefAppSettings:
+			return MediaVisibilityPublic, nil, nil
+		case MediaRefNews:
+			joinTable, column = "news_target_groups", "news_id"
+		case MediaRefEvent:
+			joinTable, column = "event_target_groups", "event_id"
+		default:
+			authenticated = true
+			continue
+		}
+
+		// No target group means the content is visible to every user
+		var targets []uint
+		if err := tx.Table(joinTable).Where(column+" = ?", ref.EntityID).Pluck("group_id", &targets).Error; err != nil {
+			return "", nil, err
+		}
+		if len(targets) == 0 {
+			authenticated = true
+		}
+		for _, g := range targets {
+			groups[g] = true
+		}
+	}
+
+	if authenticated {
+		return MediaVisibilityAuthenticated, nil, nil
+	}
+	groupIDs := make([]uint, 0, len(groups))
+	for g := range groups {
+		groupIDs = append(groupIDs, g)
+	}
+	return MediaVisibilityGroup, groupIDs, nil
+}
+
+// Resolve returns the access rules of the object stored at storagePath
+func (s *MediaAccessService) Resolve(storagePath string) (*MediaAccess, error) {
+	access := &MediaAccess{Visibility: MediaVisibilityAuthenticated}
+
+	var medias []models.Media
+	if err := s.db.Where("storage_path = ?", storagePath).Find(&medias).Error; err != nil {
+		return nil, err
+	}
+	if len(medias) > 0 {
+		access.MimeType = medias[0].MimeType
+		access.ContentHash = medias[0].ContentHash
+	}
+
+	var variants []models.MediaVariant
+	if err := s.db.Where("storage_path = ?", storagePath).Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	if len(variants) > 0 {
+		var ids []uint
+		for _, v := range variants {
+			ids = append(ids, v.MediaID)
+		}
+		var owners []models.Media
+		if err := s.db.Where("id IN ?", ids).Find(&owners).Error; err != nil {
+			return nil, err
+		}
+		medias = append(medias, owners...)
+		if access.MimeType == "" {
+			access.MimeType = variants[0].MimeType
+		}
+	}
+
+	if len(medias) == 0 {
+		return access, nil
+	}
+	access.Known = true
+
+	// Quarantined media never grant access to the file
+	available := medias[:0]
+	for _, m := range medias {
+		if !m.Quarantined {
+			available = append(available, m)
+		}
+	}
+	if len(available) == 0 {
+		access.Quarantined = true
+		return access, nil
+	}
+	medias = available
+
+	// Shared (deduplicated) files get the most permissive visibility of their media
+	var mediaIDs []uint
+	restricted := true
+	for _, m := range medias {
+		access.UploaderIDs = append(access.UploaderIDs, m.UploadedBy)
+		switch m.Visibility {
+		case MediaVisibilityPublic:
+			access.Visibility = MediaVisibilityPublic
+			return access, nil
+		case MediaVisibilityGroup:
+			mediaIDs = append(mediaIDs, m.ID)
+		default:
+			restricted = false
+		}
+	}
+
+	if restricted {
+		access.Visibility = MediaVisibilityGroup
+		if err := s.db.Table("media_access_groups").Where("media_id IN ?", mediaIDs).
+			Distinct().Pluck("group_id", &access.GroupIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+	return access, nil
+}
+
+// CanAccess reports whether the viewer may download a file with these rules.
+// Admins and uploaders always can.
+func (s *MediaAccessService) CanAccess(access *MediaAccess, viewer MediaViewer) bool {
+	if access.Visibility == MediaVisibilityPublic {
+		return true
+	}
+	if viewer.UserID == 0 {
+		return false
+	}
+	if access.Visibility != MediaVisibilityGroup || viewer.Role == "admin" {
+		return true
+	}
+	for _, uploader := range access.UploaderIDs {
+		if uploader == viewer.UserID {
+			return true
+		}
+	}
+	if len(access.GroupIDs) == 0 {
+		return false
+	}
+
+	var count int64
+	s.db.Table("user_groups").
+		Where("user_id = ? AND group_id IN ?", viewer.UserID, access.GroupIDs).
+		Count(&count)
+	return count > 0
+}
+
+// MediaURLSigner creates and checks HMAC-signed, expiring /uploads/... URLs
+type MediaURLSigner struct {
+	secret []byte
+	expiry time.Duration
+}
+
+// NewMediaURLSigner creates a signer from the storage configuration
+func NewMediaURLSigner(cfg config.StorageConfig) *MediaURLSigner {
+	return &MediaURLSigner{
+		secret: []byte(cfg.SigningSecret),
+		expiry: time.Duration(cfg.SignedURLExpiry) * time.Minute,
+	}
+}
+
+// Expiry returns the lifetime of signed URLs
+func (s *MediaURLSigner) Expiry() time.Duration {
+	return s.expiry
+}
+
+// SignURL appends an expiration and a signature to an /uploads/... URL
+func (s *MediaURLSigner) SignURL(uploadURL string) string {
+	if i := strings.IndexAny(uploadURL, "?#"); i >= 0 {
+		uploadURL = uploadURL[:i]
+	}
+	key := strings.TrimPrefix(uploadURL, "/uploads/")
+	expires := strconv.FormatInt(time.Now().Add(s.expiry).Unix(), 10)
+	return fmt.Sprintf("%s?expires=%s&signature=%s", uploadURL, expires, s.sign(key, expires))
+}
+
+// Verify checks the signature of a storage key and returns its expiration
+func (s *MediaURLSigner) Verify(key, expires, signature string) (time.Time, bool) {
+	if expires == "" || signature == "" {
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	expiresAt := time.Unix(unix, 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, false
+	}
+	if !hmac.Equal([]byte(s.sign(key, expires)), []byte(signature)) {
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
+
+func (s *MediaURLSigner) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
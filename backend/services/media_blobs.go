@@ -0,0 +1,177 @@
+package services
+
+import (
+	"airboard/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MediaBlobService deduplicates uploads by content hash and reference-counts
+// the stored objects shared by several Media rows
+type MediaBlobService struct {
+	db      *gorm.DB
+	storage StorageService
+}
+
+// NewMediaBlobService creates a new media blob service
+func NewMediaBlobService(db *gorm.DB, storage StorageService) *MediaBlobService {
+	return &MediaBlobService{
+		db:      db,
+		storage: storage,
+	}
+}
+
+// StoredBlob is the result of MediaBlobService.Store
+type StoredBlob struct {
+	ContentHash  string
+	StoragePath  string
+	URL          string
+	Deduplicated bool // true when an existing object was reused
+}
+
+// HashContent streams r through SHA-256 and rewinds it
+func HashContent(r io.ReadSeeker) (string, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Store uploads the file unless an object with the same content already exists,
+// and takes one reference on the resulting blob. Every successful Store must be
+// balanced by a Release when the Media row goes away.
+func (s *MediaBlobService) Store(ctx context.Context, file multipart.File, fileHeader *multipart.FileHeader) (*StoredBlob, error) {
+	hash, err := HashContent(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if blob, err := s.acquire(hash); err != nil {
+		return nil, err
+	} else if blob != nil {
+		return &StoredBlob{
+			ContentHash:  hash,
+			StoragePath:  blob.StoragePath,
+			URL:          s.storage.GetURL(blob.StoragePath),
+			Deduplicated: true,
+		}, nil
+	}
+
+	storagePath, url, err := s.storage.Upload(ctx, file, fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := models.MediaBlob{
+		ContentHash: hash,
+		StorageType: s.storage.GetType(),
+		StoragePath: storagePath,
+		FileSize:    fileHeader.Size,
+		RefCount:    1,
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&blob)
+	if result.Error != nil {
+		s.storage.Delete(ctx, storagePath)
+		return nil, fmt.Errorf("failed to save blob: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		existing, err := s.acquire(hash)
+		if err != nil {
+			s.storage.Delete(ctx, storagePath)
+			return nil, err
+		}
+		if existing == nil {
+			// The blob with this hash is being released: keep our own copy, untracked
+			// like the objects stored before deduplication (Release deletes it directly)
+			return &StoredBlob{
+				ContentHash: hash,
+				StoragePath: storagePath,
+				URL:         url,
+			}, nil
+		}
+		// Same content uploaded concurrently: keep the other copy
+		s.storage.Delete(ctx, storagePath)
+		return &StoredBlob{
+			ContentHash:  hash,
+			StoragePath:  existing.StoragePath,
+			URL:          s.storage.GetURL(existing.StoragePath),
+			Deduplicated: true,
+		}, nil
+	}
+
+	return &StoredBlob{
+		ContentHash: hash,
+		StoragePath: storagePath,
+		URL:         url,
+	}, nil
+}
+
+// acquire increments the reference count of the blob with this hash, if any. A blob
+// whose count already dropped to zero is being deleted by Release and is never reused.
+func (s *MediaBlobService) acquire(hash string) (*models.MediaBlob, error) {
+	var blob models.MediaBlob
+	result := s.db.Model(&blob).
+		Clauses(clause.Returning{}).
+		Where("content_hash = ? AND storage_type = ? AND ref_count > 0", hash, s.storage.GetType()).
+		Update("ref_count", gorm.Expr("ref_count + 1"))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to look up blob: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &blob, nil
+}
+
+// Release drops one reference on the object stored at storagePath and deletes it
+// from storage when nobody uses it anymore. Objects stored before deduplication
+// (no blob row) are deleted directly.
+func (s *MediaBlobService) Release(ctx context.Context, storagePath string) error {
+	var blob models.MediaBlob
+	result := s.db.Model(&blob).
+		Clauses(clause.Returning{}).
+		Where("storage_path = ? AND storage_type = ? AND ref_count > 0", storagePath, s.storage.GetType()).
+		Update("ref_count", gorm.Expr("ref_count - 1"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to release blob: %w", result.Error)
+	}
+
+	if result.RowsAffected > 0 && blob.RefCount > 0 {
+		// Still used by other media
+		return nil
+	}
+
+	// Drop the row before the object: acquire skips blobs at zero, so no new
+	// media can attach to this object while it is being deleted
+	if result.RowsAffected > 0 {
+		if err := s.db.Where("id = ? AND ref_count = 0", blob.ID).Delete(&models.MediaBlob{}).Error; err != nil {
+			log.Printf("Warning: failed to delete blob %d: %v", blob.ID, err)
+		}
+	}
+	return s.storage.Delete(ctx, storagePath)
+}
+
+// IsShared reports whether the object at storagePath is used by more than one media
+func (s *MediaBlobService) IsShared(storagePath string) bool {
+	var count int64
+	s.db.Model(&models.MediaBlob{}).
+		Where("storage_path = ? AND storage_type = ? AND ref_count > 1", storagePath, s.storage.GetType()).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"airboard/models"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Entités qui référencent des fichiers uploadés
+const (
+	MediaRefNews        = "news"
+	MediaRefEvent       = "event"
+	MediaRefUser        = "user"
+	MediaRefAppSettings = "app_settings"
+)
+
+// uploadURLPattern capture les chemins /uploads/... dans du texte brut ou du JSON Tiptap
+// (les URLs absolues https://host/uploads/... sont donc aussi reconnues)
+var uploadURLPattern = regexp.MustCompile(`/uploads/[^\s"'<>()?#\\]+`)
+
+// MediaReferenceService maintient l'index des usages des médias (media_references)
+// et le compteur Media.UsageCount qui en découle
+type MediaReferenceService struct {
+	db *gorm.DB
+}
+
+// NewMediaReferenceService crée une nouvelle instance du service
+func NewMediaReferenceService(db *gorm.DB) *MediaReferenceService {
+	return &MediaReferenceService{db: db}
+}
+
+// ExtractUploadURLs retourne les URLs /uploads/... distinctes trouvées dans les textes
+func ExtractUploadURLs(texts ...string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, text := range texts {
+		for _, match := range uploadURLPattern.FindAllString(text, -1) {
+			if !seen[match] {
+				seen[match] = true
+				urls = append(urls, match)
+			}
+		}
+	}
+	return urls
+}
+
+// SyncNews reconstruit les références d'un article (contenu Tiptap + couverture)
+func (s *MediaReferenceService) SyncNews(news *models.News) error {
+	return s.SyncEntity(MediaRefNews, news.ID, map[string]string{
+		"content":     news.Content,
+		"cover_image": news.CoverImage,
+	})
+}
+
+// SyncEvent reconstruit les références d'un événement (description Tiptap + couverture)
+func (s *MediaReferenceService) SyncEvent(event *models.Event) error {
+	return s.SyncEntity(MediaRefEvent, event.ID, map[string]string{
+		"description": event.Description,
+		"cover_image": event.CoverImage,
+	})
+}
+
+// SyncUser reconstruit la référence de l'avatar d'un utilisateur
+func (s *MediaReferenceService) SyncUser(user *models.User) error {
+	return s.SyncEntity(MediaRefUser, user.ID, map[string]string{
+		"avatar_url": user.AvatarURL,
+	})
+}
+
+// SyncAppSettings reconstruit les références des images hero
+func (s *MediaReferenceService) SyncAppSettings(settings *models.AppSettings) error {
+	return s.SyncEntity(MediaRefAppSettings, settings.ID, map[string]string{
+		"hero_image_url":      settings.HeroImageURL,
+		"hero_image_url_dark": settings.HeroImageURLDark,
+	})
+}
+
+// RemoveEntity supprime toutes les références d'une entité (ex: suppression d'un article)
+func (s *MediaReferenceService) RemoveEntity(entityType string, entityID uint) error {
+	return s.SyncEntity(entityType, entityID, nil)
+}
+
+// SyncEntity remplace les références d'une entité par celles trouvées dans ses champs
+func (s *MediaReferenceService) SyncEntity(entityType string, entityID uint, fields map[string]string) error {
+	var refs []models.MediaReference
+	for field, text := range fields {
+		urls := ExtractUploadURLs(text)
+		if len(urls) == 0 {
+			continue
+		}
+		mediaIDs, err := s.resolveMediaIDs(urls)
+		if err != nil {
+			return err
+		}
+		for _, mediaID := range mediaIDs {
+			refs = append(refs, models.MediaReference{
+				MediaID:    mediaID,
+				EntityType: entityType,
+				EntityID:   entityID,
+				Field:      field,
+			})
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var affected []uint
+		if err := tx.Model(&models.MediaReference{}).
+			Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+			Pluck("media_id", &affected).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+			Delete(&models.MediaReference{}).Error; err != nil {
+			return err
+		}
+
+		if len(refs) > 0 {
+			if err := tx.Create(&refs).Error; err != nil {
+				return err
+			}
+			for _, ref := range refs {
+				affected = append(affected, ref.MediaID)
+			}
+		}
+
+		if err := recountMediaUsage(tx, affected); err != nil {
+			return err
+		}
+		return recomputeMediaVisibility(tx, affected)
+	})
+}
+
+// resolveMediaIDs associe des URLs à des médias (original ou variante redimensionnée)
+func (s *MediaReferenceService) resolveMediaIDs(urls []string) ([]uint, error) {
+	var ids []uint
+	if err := s.db.Model(&models.Media{}).Where("url IN ?", urls).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	var variantMediaIDs []uint
+	if err := s.db.Model(&models.MediaVariant{}).Where("url IN ?", urls).Pluck("media_id", &variantMediaIDs).Error; err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	var result []uint
+	for _, id := range append(ids, variantMediaIDs...) {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// recountMediaUsage recalcule UsageCount (nombre d'entités distinctes) pour les médias donnés
+func recountMediaUsage(tx *gorm.DB, mediaIDs []uint) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+	return tx.Exec(`
+		UPDATE media SET usage_count = (
+			SELECT COUNT(DISTINCT (media_references.entity_type, media_references.entity_id))
+			FROM media_references WHERE media_references.media_id = media.id
+		) WHERE id IN ?`, mediaIDs).Error
+}
+
+// References retourne les usages d'un média
+func (s *MediaReferenceService) References(mediaID uint) ([]models.MediaReference, error) {
+	var refs []models.MediaReference
+	err := s.db.Where("media_id = ?", mediaID).Order("entity_type, entity_id").Find(&refs).Error
+	return refs, err
+}
+
+// RebuildAll reconstruit l'index complet à partir des contenus existants
+func (s *MediaReferenceService) RebuildAll() error {
+	if err := s.db.Where("1 = 1").Delete(&models.MediaReference{}).Error; err != nil {
+		return err
+	}
+
+	var news []models.News
+	if err := s.db.Select("id", "content", "cover_image").Find(&news).Error; err != nil {
+		return err
+	}
+	for i := range news {
+		if err := s.SyncNews(&news[i]); err != nil {
+			log.Printf("[Media] Erreur indexation news %d: %v", news[i].ID, err)
+		}
+	}
+
+	var events []models.Event
+	if err := s.db.Select("id", "description", "cover_image").Find(&events).Error; err != nil {
+		return err
+	}
+	for i := range events {
+		if err := s.SyncEvent(&events[i]); err != nil {
+			log.Printf("[Media] Erreur indexation événement %d: %v", events[i].ID, err)
+		}
+	}
+
+	var users []models.User
+	if err := s.db.Select("id", "avatar_url").Where("avatar_url <> ''").Find(&users).Error; err != nil {
+		return err
+	}
+	for i := range users {
+		if err := s.SyncUser(&users[i]); err != nil {
+			log.Printf("[Media] Erreur indexation utilisateur %d: %v", users[i].ID, err)
+		}
+	}
+
+	var settings []models.AppSettings
+	if err := s.db.Find(&settings).Error; err != nil {
+		return err
+	}
+	for i := range settings {
+		if err := s.SyncAppSettings(&settings[i]); err != nil {
+			log.Printf("[Media] Erreur indexation paramètres: %v", err)
+		}
+	}
+
+	// Remettre à zéro les médias qui ne sont plus référencés du tout
+	if err := s.db.Exec(`DELETE FROM media_access_groups WHERE NOT EXISTS
+		(SELECT 1 FROM media_references WHERE media_references.media_id = media_access_groups.media_id)`).Error; err != nil {
+		return err
+	}
+	return s.db.Exec(`UPDATE media SET usage_count = 0, visibility = ?
+		WHERE NOT EXISTS (SELECT 1 FROM media_references WHERE media_references.media_id = media.id)`,
+		MediaVisibilityAuthenticated).Error
+}
+
+// MediaOrphanReport liste les fichiers sans usage
+type MediaOrphanReport struct {
+	Media []models.Media `json:"media"` // Médias en base non référencés
+	Files []string       `json:"files"` // Fichiers présents dans le stockage sans enregistrement Media
+}
+
+// FindOrphans détecte les médias non référencés (plus anciens que minAge) et
+// les fichiers du stockage qui ne correspondent à aucun enregistrement
+func (s *MediaReferenceService) FindOrphans(ctx context.Context, storage StorageService, minAge time.Duration) (*MediaOrphanReport, error) {
+	report := &MediaOrphanReport{Media: []models.Media{}, Files: []string{}}
+
+	if err := s.db.
+		Where("NOT EXISTS (SELECT 1 FROM media_references WHERE media_references.media_id = media.id)").
+		Where("created_at < ?", time.Now().Add(-minAge)).
+		Order("created_at ASC").
+		Find(&report.Media).Error; err != nil {
+		return nil, fmt.Errorf("failed to list orphaned media: %w", err)
+	}
+
+	files, err := storage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known, err := s.knownStoragePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		// Ignorer les dossiers techniques (fichiers temporaires, etc.)
+		if strings.HasPrefix(file, ".") || strings.Contains(file, "/.") {
+			continue
+		}
+		if !known[file] {
+			report.Files = append(report.Files, file)
+		}
+	}
+
+	return report, nil
+}
+
+// knownStoragePaths retourne les chemins de stockage connus (médias, variantes,
+// et fichiers hors médiathèque référencés directement comme les avatars)
+func (s *MediaReferenceService) knownStoragePaths() (map[string]bool, error) {
+	known := make(map[string]bool)
+
+	var paths []string
+	// Unscoped: un média supprimé logiquement garde son chemin réservé
+	if err := s.db.Unscoped().Model(&models.Media{}).Pluck("storage_path", &paths).Error; err != nil {
+		return nil, err
+	}
+	var variantPaths []string
+	if err := s.db.Model(&models.MediaVariant{}).Pluck("storage_path", &variantPaths).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range append(paths, variantPaths...) {
+		known[strings.ReplaceAll(p, "\\", "/")] = true
+	}
+
+	var directURLs []string
+	if err := s.db.Model(&models.User{}).Where("avatar_url <> ''").Pluck("avatar_url", &directURLs).Error; err != nil {
+		return nil, err
+	}
+	var settings []models.AppSettings
+	if err := s.db.Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	for _, st := range settings {
+		directURLs = append(directURLs, st.HeroImageURL, st.HeroImageURLDark)
+	}
+	for _, u := range ExtractUploadURLs(directURLs...) {
+		known[strings.TrimPrefix(u, "/uploads/")] = true
+	}
+
+	return known, nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// oidcDiscoveryTTL est la durée de cache des documents .well-known/openid-configuration
+	oidcDiscoveryTTL = time.Hour
+	// jwksCacheTTL est la durée de cache d'un jeu de clés (JWKS)
+	jwksCacheTTL = time.Hour
+	// jwksMinRefresh limite le rechargement du JWKS lorsqu'un token présente un kid inconnu
+	jwksMinRefresh = time.Minute
+	// oidcMaxResponseSize borne la taille des documents lus chez le fournisseur
+	oidcMaxResponseSize = 1 << 20
+)
+
+// OIDCDiscovery contient les champs utilisés du document .well-known/openid-configuration
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCService gère la découverte OpenID Connect et la vérification des id_token. Les documents
+// et les clés sont mis en cache : l'instance doit vivre aussi longtemps que le handler OAuth.
+type OIDCService struct {
+	client *http.Client
+
+	mu          sync.Mutex
+	discoveries map[string]*cachedDiscovery
+	keySets     map[string]*cachedKeySet
+}
+
+type cachedDiscovery struct {
+	document  *OIDCDiscovery
+	fetchedAt time.Time
+}
+
+type cachedKeySet struct {
+	keys      map[string]interface{} // kid -> *rsa.PublicKey ou *ecdsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewOIDCService crée une nouvelle instance du service OpenID Connect
+func NewOIDCService() *OIDCService {
+	return &OIDCService{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		discoveries: make(map[string]*cachedDiscovery),
+		keySets:     make(map[string]*cachedKeySet),
+	}
+}
+
+// Discover lit (ou retourne depuis le cache) le document de découverte d'un issuer et vérifie
+// qu'il annonce bien cet issuer
+func (s *OIDCService) Discover(issuer string) (*OIDCDiscovery, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	s.mu.Lock()
+	cached := s.discoveries[issuer]
+	s.mu.Unlock()
+	if cached != nil && time.Since(cached.fetchedAt) < oidcDiscoveryTTL {
+		return cached.document, nil
+	}
+
+	var document OIDCDiscovery
+	if err := s.getJSON(issuer+"/.well-known/openid-configuration", &document); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(document.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer mismatch: %s", document.Issuer)
+	}
+	if document.AuthorizationEndpoint == "" || document.TokenEndpoint == "" || document.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing required endpoints")
+	}
+
+	s.mu.Lock()
+	s.discoveries[issuer] = &cachedDiscovery{document: &document, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return &document, nil
+}
+
+// VerifyIDToken vérifie la signature d'un id_token avec le JWKS du fournisseur puis ses claims
+// iss, aud, exp et nonce, et retourne les claims
+func (s *OIDCService) VerifyIDToken(rawToken, issuer, jwksURL, clientID, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.key(jwksURL, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	// Un token émis pour plusieurs audiences doit désigner ce client comme partie autorisée
+	if audiences, _ := claims.GetAudience(); len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != clientID {
+			return nil, errors.New("invalid id_token: authorized party mismatch")
+		}
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// key retourne la clé publique kid du JWKS. Un kid inconnu (rotation des clés chez le
+// fournisseur) provoque un rechargement, au plus une fois par jwksMinRefresh.
+func (s *OIDCService) key(jwksURL, kid string) (interface{}, error) {
+	if jwksURL == "" {
+		return nil, errors.New("no JWKS URL configured")
+	}
+
+	s.mu.Lock()
+	cached := s.keySets[jwksURL]
+	s.mu.Unlock()
+
+	if cached != nil {
+		key, found := lookupKey(cached.keys, kid)
+		fresh := time.Since(cached.fetchedAt) < jwksCacheTTL
+		if found && fresh {
+			return key, nil
+		}
+		if !found && fresh && time.Since(cached.fetchedAt) < jwksMinRefresh {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	keys, err := s.fetchKeySet(jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.keySets[jwksURL] = &cachedKeySet{keys: keys, fetchedAt: time.Now()}
+	s.mu.Unlock()
+
+	key, found := lookupKey(keys, kid)
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey cherche une clé par kid ; sans kid, le jeu ne doit contenir qu'une clé
+func lookupKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if kid != "" {
+		key, found := keys[kid]
+		return key, found
+	}
+	if len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (s *OIDCService) fetchKeySet(jwksURL string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := s.getJSON(jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Les types de clés non gérés sont ignorés, les autres restent utilisables
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable signing key")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func (s *OIDCService) getJSON(url string, target interface{}) error {
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.Unmarshal(body, target)
+}
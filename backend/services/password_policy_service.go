@@ -0,0 +1,139 @@
+package services
+
+import (
+	"airboard/models"
+	"airboard/utils"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// MaxPasswordHistory est le nombre d'empreintes conservées par utilisateur, quelle que soit la
+// politique, pour que l'historique soit déjà disponible lorsqu'un administrateur l'active
+const MaxPasswordHistory = 24
+
+// PasswordPolicyError est retournée quand un mot de passe ne respecte pas la politique ;
+// son message (en français) peut être affiché tel quel
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Reason
+}
+
+// PasswordPolicyService applique la politique de mots de passe enregistrée dans les paramètres
+type PasswordPolicyService struct {
+	db       *gorm.DB
+	security *utils.AuthSecurityManager
+}
+
+// NewPasswordPolicyService crée une nouvelle instance du service de politique de mots de passe
+func NewPasswordPolicyService(db *gorm.DB) *PasswordPolicyService {
+	return &PasswordPolicyService{db: db, security: utils.NewAuthSecurityManager()}
+}
+
+// Policy retourne la politique en vigueur (politique par défaut si les paramètres n'existent pas)
+func (s *PasswordPolicyService) Policy() *utils.PasswordPolicy {
+	policy := utils.DefaultPasswordPolicy()
+
+	var settings models.AppSettings
+	if err := s.db.First(&settings).Error; err != nil {
+		return policy
+	}
+	if settings.PasswordMinLength > policy.MinLength {
+		policy.MinLength = settings.PasswordMinLength
+	}
+	policy.RequireUpper = settings.PasswordRequireUpper
+	policy.RequireLower = settings.PasswordRequireLower
+	policy.RequireDigit = settings.PasswordRequireDigit
+	policy.RequireSpecial = settings.PasswordRequireSpecial
+	policy.CheckBreached = settings.PasswordCheckBreached
+	policy.HistoryCount = settings.PasswordHistoryCount
+	policy.MaxAge = time.Duration(settings.PasswordMaxAgeDays) * 24 * time.Hour
+	return policy
+}
+
+// Validate vérifie un nouveau mot de passe : règles de la politique puis, pour un utilisateur
+// existant, non-réutilisation du mot de passe actuel et des HistoryCount précédents
+func (s *PasswordPolicyService) Validate(user *models.User, password string) error {
+	policy := s.Policy()
+	if err := s.security.ValidatePassword(password, policy); err != nil {
+		return &PasswordPolicyError{Reason: err.Error()}
+	}
+	if user == nil || user.ID == 0 || policy.HistoryCount <= 0 {
+		return nil
+	}
+
+	reused := &PasswordPolicyError{
+		Reason: fmt.Sprintf("mot de passe déjà utilisé (les %d derniers mots de passe ne peuvent pas être réutilisés)", policy.HistoryCount),
+	}
+	if user.Password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil {
+		return reused
+	}
+
+	var hashes []string
+	if err := s.db.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Limit(policy.HistoryCount).
+		Pluck("password_hash", &hashes).Error; err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return reused
+		}
+	}
+	return nil
+}
+
+// Record enregistre un mot de passe qui vient d'être défini (dans la transaction tx si fournie) :
+// date de changement, fin du changement imposé et ajout à l'historique
+func (s *PasswordPolicyService) Record(tx *gorm.DB, userID uint, passwordHash string) error {
+	if tx == nil {
+		tx = s.db
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password_changed_at":  time.Now(),
+		"must_change_password": false,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&models.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+		return err
+	}
+
+	// Ne garder que les MaxPasswordHistory dernières empreintes
+	return tx.Where("user_id = ? AND id NOT IN (?)", userID,
+		tx.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", userID).
+			Order("created_at DESC, id DESC").Limit(MaxPasswordHistory),
+	).Delete(&models.PasswordHistory{}).Error
+}
+
+// ChangeRequired indique si un compte local doit changer son mot de passe avant d'obtenir une session,
+// et pourquoi : "admin" (imposé par un administrateur) ou "expired" (âge maximal dépassé)
+func (s *PasswordPolicyService) ChangeRequired(user *models.User) (bool, string) {
+	if user.Password == "" {
+		return false, ""
+	}
+	if user.MustChangePassword {
+		return true, "admin"
+	}
+
+	maxAge := s.Policy().MaxAge
+	if maxAge <= 0 {
+		return false, ""
+	}
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	if time.Since(changedAt) > maxAge {
+		return true, "expired"
+	}
+	return false, ""
+}
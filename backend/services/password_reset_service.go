@@ -0,0 +1,170 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Paramètres de la réinitialisation de mot de passe
+const (
+	PasswordResetTTL        = time.Hour
+	PasswordResetMaxPerHour = 3 // Liens envoyés au plus par compte et par heure
+	PasswordResetTemplate   = "password_reset"
+)
+
+var ErrInvalidPasswordResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordResetService gère les liens de réinitialisation de mot de passe des comptes locaux
+type PasswordResetService struct {
+	db     *gorm.DB
+	config *config.Config
+	email  *EmailService
+}
+
+// NewPasswordResetService crée une nouvelle instance du service de réinitialisation
+func NewPasswordResetService(db *gorm.DB, cfg *config.Config) *PasswordResetService {
+	return &PasswordResetService{
+		db:     db,
+		config: cfg,
+		email:  NewEmailService(db, cfg),
+	}
+}
+
+// Request génère un lien de réinitialisation et l'envoie par email. Aucune erreur n'est
+// retournée pour un email inconnu, un compte SSO ou un compte désactivé : l'appelant
+// répond toujours de la même façon pour ne pas révéler l'existence des comptes.
+func (s *PasswordResetService) Request(email, ipAddress string) error {
+	var user models.User
+	if err := s.db.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(email))).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive || user.Password == "" {
+		return nil
+	}
+
+	// Limite par compte, en plus de la limite par IP des routes d'authentification
+	var recent int64
+	if err := s.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-time.Hour)).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if recent >= PasswordResetMaxPerHour {
+		log.Printf("[Auth] Password reset limit reached for user %d", user.ID)
+		return nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate password reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	record := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashChallengeToken(token),
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+		RequestIP: ipAddress,
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.Username
+	}
+	data := PasswordResetEmailData{
+		Name:      name,
+		Username:  user.Username,
+		Link:      fmt.Sprintf("%s/auth/reset-password?token=%s", strings.TrimSuffix(s.config.Server.PublicURL, "/"), url.QueryEscape(token)),
+		ExpiresIn: "1 heure",
+		AppName:   s.email.AppName(),
+	}
+	if err := s.email.SendTemplateEmail(PasswordResetTemplate, user.Email, data); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	log.Printf("[Auth] Password reset link sent to user %d (requested from IP %s)", user.ID, ipAddress)
+	return nil
+}
+
+// Confirm consomme le token, remplace le mot de passe, invalide les autres liens et révoque toutes les sessions
+func (s *PasswordResetService) Confirm(token, newPassword string, bcryptCost int) (*models.User, error) {
+	var record models.PasswordResetToken
+	if err := s.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashChallengeToken(token), time.Now()).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPasswordResetToken
+		}
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.db.First(&user, record.UserID).Error; err != nil || !user.IsActive || user.Password == "" {
+		return nil, ErrInvalidPasswordResetToken
+	}
+
+	// Politique de mots de passe, y compris la non-réutilisation (le lien reste valide en cas de refus)
+	policy := NewPasswordPolicyService(s.db)
+	if err := policy.Validate(&user, newPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Usage unique même en cas de requêtes concurrentes
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", record.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidPasswordResetToken
+		}
+
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		if err := policy.Record(tx, user.ID, string(hashedPassword)); err != nil {
+			return err
+		}
+		_, err := NewSessionService(s.db).RevokeAllTx(tx, user.ID, SessionRevokedPasswordReset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CleanupExpired supprime les liens expirés (les liens utilisés expirent au plus tard PasswordResetTTL après leur envoi)
+func (s *PasswordResetService) CleanupExpired() (int64, error) {
+	result := s.db.Where("expires_at <= ?", time.Now()).Delete(&models.PasswordResetToken{})
+	return result.RowsAffected, result.Error
+}
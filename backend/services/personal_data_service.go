@@ -0,0 +1,429 @@
+package services
+
+import (
+	"airboard/models"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrErasureRequestExists     = errors.New("an erasure request is already pending")
+	ErrErasureRequestNotPending = errors.New("erasure request has already been processed")
+	ErrErasureSelfApproval      = errors.New("administrators cannot approve their own erasure request")
+)
+
+// personalDataRecords liste les tables exportées telles quelles et la condition qui les rattache
+// à l'utilisateur (@id). Les lignes supprimées logiquement sont incluses : elles sont toujours détenues.
+var personalDataRecords = []struct {
+	name  string
+	model interface{}
+	where string
+}{
+	{"chat_messages", &models.ChatMessage{}, "sender_id = @id OR recipient_id = @id"},
+	{"comments", &models.Comment{}, "user_id = @id"},
+	{"feedback", &models.Feedback{}, "user_id = @id"},
+	{"poll_votes", &models.PollVote{}, "user_id = @id"},
+	{"suggestions", &models.Suggestion{}, "user_id = @id"},
+	{"suggestion_votes", &models.SuggestionVote{}, "user_id = @id"},
+	{"news_reactions", &models.NewsReaction{}, "user_id = @id"},
+	{"news_reads", &models.NewsRead{}, "user_id = @id"},
+	{"application_clicks", &models.ApplicationClick{}, "user_id = @id"},
+	{"notifications", &models.Notification{}, "user_id = @id"},
+	{"gamification_profile", &models.GamificationProfile{}, "user_id = @id"},
+	{"achievements", &models.UserAchievement{}, "user_id = @id"},
+	{"xp_transactions", &models.XPTransaction{}, "user_id = @id"},
+}
+
+// PersonalDataExport contient les données personnelles d'un utilisateur (droit d'accès RGPD)
+type PersonalDataExport struct {
+	GeneratedAt  time.Time                           `json:"generated_at"`
+	Profile      models.User                         `json:"profile"`
+	Sessions     []models.Session                    `json:"sessions"`
+	AccessTokens []models.AccessToken                `json:"access_tokens"`
+	Passkeys     []models.WebAuthnCredential         `json:"passkeys"`
+	Records      map[string][]map[string]interface{} `json:"records"`
+}
+
+// PersonalDataService gère l'export des données personnelles et les demandes d'effacement (RGPD)
+type PersonalDataService struct {
+	db      *gorm.DB
+	storage StorageService
+}
+
+// NewPersonalDataService crée une nouvelle instance du service de données personnelles
+func NewPersonalDataService(db *gorm.DB, storage StorageService) *PersonalDataService {
+	return &PersonalDataService{db: db, storage: storage}
+}
+
+// Export rassemble tout ce qui est rattaché à l'identifiant de l'utilisateur
+func (s *PersonalDataService) Export(userID uint) (*PersonalDataExport, error) {
+	export := &PersonalDataExport{
+		GeneratedAt: time.Now(),
+		Records:     make(map[string][]map[string]interface{}, len(personalDataRecords)),
+	}
+	if err := s.db.Preload("Groups").Preload("Favorites").First(&export.Profile, userID).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&export.Sessions).Error; err != nil {
+		return nil, err
+	}
+	tokens, err := NewAccessTokenService(s.db).List(userID)
+	if err != nil {
+		return nil, err
+	}
+	export.AccessTokens = tokens
+	if err := s.db.Where("user_id = ?", userID).Order("created_at").Find(&export.Passkeys).Error; err != nil {
+		return nil, err
+	}
+
+	for _, source := range personalDataRecords {
+		rows := []map[string]interface{}{}
+		if err := s.db.Unscoped().Model(source.model).
+			Where(source.where, map[string]interface{}{"id": userID}).
+			Order("id").
+			Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", source.name, err)
+		}
+		export.Records[source.name] = rows
+	}
+	return export, nil
+}
+
+// WriteZip écrit l'export sous forme d'archive ZIP (un fichier JSON par catégorie de données)
+func (export *PersonalDataExport) WriteZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"sessions.json", export.Sessions},
+		{"access_tokens.json", export.AccessTokens},
+		{"passkeys.json", export.Passkeys},
+	}
+	for _, source := range personalDataRecords {
+		files = append(files, struct {
+			name string
+			data interface{}
+		}{"records/" + source.name + ".json", export.Records[source.name]})
+	}
+
+	for _, file := range files {
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// RequestErasure enregistre une demande d'effacement du compte, à approuver par un administrateur
+func (s *PersonalDataService) RequestErasure(user *models.User, reason string) (*models.ErasureRequest, error) {
+	var count int64
+	if err := s.db.Model(&models.ErasureRequest{}).
+		Where("user_id = ? AND status = ?", user.ID, models.ErasureRequestPending).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrErasureRequestExists
+	}
+
+	request := models.ErasureRequest{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Reason:   strings.TrimSpace(reason),
+		Status:   models.ErasureRequestPending,
+	}
+	if err := s.db.Create(&request).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("[Privacy] Erasure request %d created by user %d", request.ID, user.ID)
+	return &request, nil
+}
+
+// LatestErasure retourne la dernière demande d'effacement de l'utilisateur (nil si aucune)
+func (s *PersonalDataService) LatestErasure(userID uint) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CancelErasure annule la demande en attente de l'utilisateur
+func (s *PersonalDataService) CancelErasure(userID uint) error {
+	result := s.db.Model(&models.ErasureRequest{}).
+		Where("user_id = ? AND status = ?", userID, models.ErasureRequestPending).
+		Update("status", models.ErasureRequestCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrErasureRequestNotPending
+	}
+	return nil
+}
+
+// ListErasures retourne les demandes d'effacement, les plus récentes d'abord (status vide = toutes)
+func (s *PersonalDataService) ListErasures(status string) ([]models.ErasureRequest, error) {
+	query := s.db.Order("created_at DESC").Limit(200)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var requests []models.ErasureRequest
+	return requests, query.Find(&requests).Error
+}
+
+// ApproveErasure efface le compte de la demande : contenus rédigés anonymisés, données personnelles
+// supprimées définitivement. La demande est conservée sans l'identité du demandeur comme trace du traitement.
+func (s *PersonalDataService) ApproveErasure(requestID uint, admin *models.User, note string) (*models.ErasureRequest, error) {
+	request, err := s.pendingErasure(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.UserID == admin.ID {
+		return nil, ErrErasureSelfApproval
+	}
+
+	var user models.User
+	userFound := true
+	if err := s.db.Unscoped().First(&user, request.UserID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		userFound = false // Compte déjà supprimé définitivement
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if userFound {
+			if err := EraseUserData(tx, &user); err != nil {
+				return err
+			}
+		}
+		result := tx.Model(&models.ErasureRequest{}).
+			Where("id = ? AND status = ?", request.ID, models.ErasureRequestPending).
+			Updates(map[string]interface{}{
+				"status":         models.ErasureRequestApproved,
+				"username":       "",
+				"email":          "",
+				"reason":         "",
+				"reviewed_by_id": admin.ID,
+				"review_note":    strings.TrimSpace(note),
+				"reviewed_at":    now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrErasureRequestNotPending
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if userFound {
+		CleanupErasedUserFiles(s.db, s.storage, &user)
+	}
+
+	log.Printf("[Privacy] Erasure request %d approved by admin %d: user %d erased", request.ID, admin.ID, request.UserID)
+	return s.getErasure(request.ID)
+}
+
+// RejectErasure refuse une demande en attente avec un motif communiqué à l'utilisateur
+func (s *PersonalDataService) RejectErasure(requestID uint, admin *models.User, note string) (*models.ErasureRequest, error) {
+	request, err := s.pendingErasure(requestID)
+	if err != nil {
+		return nil, err
+	}
+	result := s.db.Model(&models.ErasureRequest{}).
+		Where("id = ? AND status = ?", request.ID, models.ErasureRequestPending).
+		Updates(map[string]interface{}{
+			"status":         models.ErasureRequestRejected,
+			"reviewed_by_id": admin.ID,
+			"review_note":    strings.TrimSpace(note),
+			"reviewed_at":    time.Now(),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrErasureRequestNotPending
+	}
+	log.Printf("[Privacy] Erasure request %d rejected by admin %d", request.ID, admin.ID)
+	return s.getErasure(request.ID)
+}
+
+func (s *PersonalDataService) pendingErasure(requestID uint) (*models.ErasureRequest, error) {
+	request, err := s.getErasure(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.ErasureRequestPending {
+		return nil, ErrErasureRequestNotPending
+	}
+	return request, nil
+}
+
+func (s *PersonalDataService) getErasure(requestID uint) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	if err := s.db.First(&request, requestID).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// EraseUserData supprime définitivement un utilisateur et ses données personnelles dans la transaction tx.
+// Les contenus qu'il a rédigés (articles, sondages, suggestions, médias) sont conservés sans auteur ;
+// ses événements, commentaires et messages sont supprimés. Utilisé par la suppression définitive
+// d'un compte et par l'effacement RGPD. Les fichiers sont nettoyés après la transaction (CleanupErasedUserFiles).
+func EraseUserData(tx *gorm.DB, user *models.User) error {
+	// 1. Supprimer les associations many-to-many
+	// (nouvelle session : sinon les conditions de chaque Clear s'accumulent sur la suivante)
+	txUnscoped := tx.Unscoped().Session(&gorm.Session{})
+	if err := txUnscoped.Model(user).Association("Groups").Clear(); err != nil {
+		return err
+	}
+	if err := txUnscoped.Model(user).Association("Favorites").Clear(); err != nil {
+		return err
+	}
+	if err := txUnscoped.Model(user).Association("AdminOfGroups").Clear(); err != nil {
+		return err
+	}
+
+	// 2. Supprimer les enregistrements liés à l'utilisateur
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.NewsReaction{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.NewsRead{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.ApplicationClick{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Notification{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("sender_id = ? OR recipient_id = ?", user.ID, user.ID).Delete(&models.ChatMessage{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Comment{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.Feedback{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.PollVote{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.SuggestionVote{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.GamificationProfile{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserAchievement{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.XPTransaction{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.TwoFactorAuth{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.LoginChallenge{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.WebAuthnCredential{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.WebAuthnSession{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.Session{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.AccessToken{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordHistory{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("scope = ? AND target_id = ?", "user", user.ID).Delete(&models.StorageQuota{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("accepted_user_id = ? OR LOWER(email) = ?", user.ID, strings.ToLower(user.Email)).Delete(&models.Invitation{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Invitation{}).Where("invited_by_id = ?", user.ID).Update("invited_by_username", "").Error; err != nil {
+		return err
+	}
+	// Les demandes d'effacement restent comme trace du traitement, sans l'identité du demandeur
+	if err := tx.Model(&models.ErasureRequest{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"username": "", "email": "", "reason": ""}).Error; err != nil {
+		return err
+	}
+
+	// 3. Nullifier les références d'auteur sur le contenu (préserver les articles/sondages)
+	if err := tx.Model(&models.News{}).Where("author_id = ?", user.ID).Update("author_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Poll{}).Where("author_id = ?", user.ID).Update("author_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Suggestion{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"user_id": nil, "is_anonymous": true}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("author_id = ?", user.ID).Delete(&models.Event{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Media{}).Where("uploaded_by = ?", user.ID).Update("uploaded_by", nil).Error; err != nil {
+		return err
+	}
+	// Nullifier moderated_by dans les commentaires
+	if err := tx.Model(&models.Comment{}).Where("moderated_by = ?", user.ID).Update("moderated_by", nil).Error; err != nil {
+		return err
+	}
+
+	// 4. Supprimer définitivement l'utilisateur
+	return txUnscoped.Delete(user).Error
+}
+
+// CleanupErasedUserFiles supprime l'avatar uploadé et les références de médias d'un utilisateur effacé
+func CleanupErasedUserFiles(db *gorm.DB, storage StorageService, user *models.User) {
+	if key, ok := AvatarKey(user.AvatarURL); ok {
+		if err := storage.Delete(context.Background(), key); err != nil {
+			log.Printf("[Privacy] Erreur lors de la suppression de l'avatar de l'utilisateur %d: %v", user.ID, err)
+		}
+	}
+	if err := NewMediaReferenceService(db).RemoveEntity(MediaRefUser, user.ID); err != nil {
+		log.Printf("[Privacy] Erreur lors de la suppression des références de médias de l'utilisateur %d: %v", user.ID, err)
+	}
+}
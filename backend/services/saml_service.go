@@ -0,0 +1,405 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"airboard/utils"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	dsig "github.com/russellhaering/goxmldsig"
+	"gorm.io/gorm"
+)
+
+const (
+	// SAMLRequestTTL est la durée pendant laquelle une réponse de l'IdP est attendue
+	SAMLRequestTTL = 10 * time.Minute
+	// samlMetadataRefresh est l'intervalle de rechargement des métadonnées téléchargées de l'IdP
+	samlMetadataRefresh = 24 * time.Hour
+)
+
+var (
+	ErrSAMLInvalidResponse = errors.New("invalid SAML response")
+	ErrSAMLMissingEmail    = errors.New("SAML assertion has no email address")
+	ErrSAMLAccountConflict = errors.New("email already used by a non-SAML account")
+	ErrSAMLAccountDisabled = errors.New("account disabled in Airboard")
+)
+
+// SAMLService implémente Airboard comme fournisseur de service SAML 2.0 : métadonnées du SP,
+// requêtes d'authentification (bindings HTTP-Redirect et HTTP-POST) et validation des réponses
+// signées de l'IdP. Les métadonnées de l'IdP sont gardées en cache par l'instance.
+type SAMLService struct {
+	db     *gorm.DB
+	config *config.SAMLConfig
+	mapper *SSOMapper
+	store  utils.StateStore // Requêtes en attente ("saml_request:" + RelayState) et assertions consommées
+
+	mu       sync.Mutex
+	sp       *saml.ServiceProvider
+	loadedAt time.Time
+}
+
+// SAMLLoginRequest est une requête d'authentification à transmettre au navigateur : redirection
+// (HTTP-Redirect) ou formulaire soumis automatiquement (HTTP-POST)
+type SAMLLoginRequest struct {
+	RedirectURL string
+	PostForm    []byte
+}
+
+// NewSAMLService crée une nouvelle instance du service SAML
+func NewSAMLService(db *gorm.DB, cfg *config.Config, store utils.StateStore) *SAMLService {
+	return &SAMLService{
+		db:     db,
+		config: &cfg.SAML,
+		mapper: NewSAMLMapper(db, cfg),
+		store:  store,
+	}
+}
+
+// Enabled indique si la connexion SAML est activée
+func (s *SAMLService) Enabled() bool {
+	return s.config.Enabled
+}
+
+// Metadata retourne les métadonnées XML du SP à déclarer auprès de l'IdP
+func (s *SAMLService) Metadata() ([]byte, error) {
+	sp, err := s.serviceProvider()
+	if err != nil {
+		return nil, err
+	}
+	return xml.MarshalIndent(sp.Metadata(), "", "  ")
+}
+
+// StartLogin crée une requête d'authentification et mémorise son ID pour valider InResponseTo.
+// Le binding HTTP-Redirect est préféré lorsque l'IdP le propose.
+func (s *SAMLService) StartLogin() (*SAMLLoginRequest, error) {
+	sp, err := s.serviceProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	relayStateBytes := make([]byte, 32)
+	if _, err := rand.Read(relayStateBytes); err != nil {
+		return nil, err
+	}
+	relayState := base64.RawURLEncoding.EncodeToString(relayStateBytes)
+
+	binding := saml.HTTPRedirectBinding
+	location := sp.GetSSOBindingLocation(binding)
+	if location == "" {
+		binding = saml.HTTPPostBinding
+		location = sp.GetSSOBindingLocation(binding)
+	}
+	if location == "" {
+		return nil, errors.New("IdP metadata has no HTTP-Redirect or HTTP-POST SSO endpoint")
+	}
+
+	request, err := sp.MakeAuthenticationRequest(location, binding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Set(samlRequestKey(relayState), []byte(request.ID), SAMLRequestTTL); err != nil {
+		return nil, fmt.Errorf("failed to store SAML request: %w", err)
+	}
+
+	if binding == saml.HTTPPostBinding {
+		return &SAMLLoginRequest{PostForm: request.Post(relayState)}, nil
+	}
+	redirectURL, err := request.Redirect(relayState, sp)
+	if err != nil {
+		return nil, err
+	}
+	return &SAMLLoginRequest{RedirectURL: redirectURL.String()}, nil
+}
+
+// HandleResponse valide la réponse postée sur l'ACS (signature, destination, audience, validité,
+// InResponseTo) puis crée ou met à jour l'utilisateur (provisioning à la volée)
+func (s *SAMLService) HandleResponse(samlResponse, relayState string) (*models.User, error) {
+	sp, err := s.serviceProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	// La requête en attente est consommée : une même réponse ne peut pas être rejouée
+	var possibleRequestIDs []string
+	if relayState != "" {
+		if err := s.store.Update(samlRequestKey(relayState), SAMLRequestTTL, func(current []byte) ([]byte, error) {
+			if current != nil {
+				possibleRequestIDs = append(possibleRequestIDs, string(current))
+			}
+			return nil, nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if len(possibleRequestIDs) == 0 && !s.config.AllowIdPInitiated {
+		return nil, fmt.Errorf("%w: unknown or expired RelayState", ErrSAMLInvalidResponse)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSAMLInvalidResponse, err)
+	}
+	// L'URL attendue est celle de l'ACS publiée, pas celle reçue derrière le reverse proxy
+	assertion, err := sp.ParseXMLResponse(raw, possibleRequestIDs, sp.AcsURL)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		return nil, fmt.Errorf("%w: %v", ErrSAMLInvalidResponse, err)
+	}
+	if err := s.consumeAssertion(assertion); err != nil {
+		return nil, err
+	}
+
+	info, err := s.userInfo(assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ne pas rattacher à l'IdP un compte local ou d'une autre source qui porte la même adresse
+	// (les comptes provisionnés par SCIM sont rattachés au premier fournisseur utilisé)
+	var existing models.User
+	if err := s.db.Select("id", "sso_provider", "is_active", "scim_managed").Where("email = ?", info.Email).Limit(1).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing.ID != 0 && existing.SSOProvider != s.mapper.provider && !existing.SCIMManaged {
+		return nil, ErrSAMLAccountConflict
+	}
+	// Un compte désactivé par un administrateur n'est pas réactivé par la connexion
+	if existing.ID != 0 && !existing.IsActive {
+		return nil, ErrSAMLAccountDisabled
+	}
+
+	return s.mapper.SyncUser(info)
+}
+
+// consumeAssertion refuse une assertion déjà utilisée (réponses non sollicitées notamment)
+func (s *SAMLService) consumeAssertion(assertion *saml.Assertion) error {
+	ttl := SAMLRequestTTL
+	if assertion.Conditions != nil {
+		if remaining := time.Until(assertion.Conditions.NotOnOrAfter); remaining > ttl {
+			ttl = remaining
+		}
+	}
+	return s.store.Update("saml_assertion:"+assertion.ID, ttl, func(current []byte) ([]byte, error) {
+		if current != nil {
+			return nil, fmt.Errorf("%w: assertion already used", ErrSAMLInvalidResponse)
+		}
+		return []byte("1"), nil
+	})
+}
+
+// userInfo convertit les attributs de l'assertion en informations de synchronisation
+func (s *SAMLService) userInfo(assertion *saml.Assertion) (*SSOUserInfo, error) {
+	attributes := make(map[string][]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			var values []string
+			for _, value := range attribute.Values {
+				if v := strings.TrimSpace(value.Value); v != "" {
+					values = append(values, v)
+				}
+			}
+			attributes[attribute.Name] = append(attributes[attribute.Name], values...)
+			if attribute.FriendlyName != "" {
+				attributes[attribute.FriendlyName] = append(attributes[attribute.FriendlyName], values...)
+			}
+		}
+	}
+	first := func(names []string) string {
+		for _, name := range names {
+			if values := attributes[name]; len(values) > 0 {
+				return values[0]
+			}
+		}
+		return ""
+	}
+
+	var nameID string
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		nameID = strings.TrimSpace(assertion.Subject.NameID.Value)
+	}
+
+	email := first(s.config.EmailAttributes)
+	if email == "" && strings.Contains(nameID, "@") {
+		email = nameID
+	}
+	email = strings.ToLower(email)
+	if email == "" {
+		return nil, ErrSAMLMissingEmail
+	}
+
+	username := first(s.config.UsernameAttributes)
+	if username == "" {
+		username = email
+	}
+	ssoID := nameID
+	if ssoID == "" {
+		ssoID = email
+	}
+
+	var groups []string
+	for _, name := range s.config.GroupsAttributes {
+		if values := attributes[name]; len(values) > 0 {
+			groups = values
+			break
+		}
+	}
+
+	return &SSOUserInfo{
+		Email:     email,
+		Username:  username,
+		FirstName: first(s.config.FirstNameAttributes),
+		LastName:  first(s.config.LastNameAttributes),
+		Groups:    groups,
+		SSOID:     ssoID,
+	}, nil
+}
+
+// serviceProvider construit le SP au premier appel puis recharge périodiquement les métadonnées
+// téléchargées de l'IdP (en gardant les précédentes si l'IdP est injoignable)
+func (s *SAMLService) serviceProvider() (*saml.ServiceProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sp != nil && (s.config.IdPMetadataURL == "" || time.Since(s.loadedAt) < samlMetadataRefresh) {
+		return s.sp, nil
+	}
+
+	sp, err := s.buildServiceProvider()
+	if err != nil {
+		if s.sp != nil {
+			log.Printf("[SAML] Rechargement des métadonnées de l'IdP impossible, conservation des précédentes: %v", err)
+			s.loadedAt = time.Now()
+			return s.sp, nil
+		}
+		return nil, err
+	}
+	s.sp = sp
+	s.loadedAt = time.Now()
+	return sp, nil
+}
+
+func (s *SAMLService) buildServiceProvider() (*saml.ServiceProvider, error) {
+	metadataURL, err := url.Parse(s.config.RootURL + "/auth/saml/metadata")
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML_ROOT_URL: %w", err)
+	}
+	acsURL, err := url.Parse(s.config.RootURL + "/auth/saml/acs")
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML_ROOT_URL: %w", err)
+	}
+
+	idpMetadata, err := s.loadIdPMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:          s.config.EntityID,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idpMetadata,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		AllowIDPInitiated: s.config.AllowIdPInitiated,
+	}
+
+	if s.config.CertFile != "" && s.config.KeyFile != "" {
+		keyPair, err := tls.LoadX509KeyPair(s.config.CertFile, s.config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SAML SP certificate: %w", err)
+		}
+		certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SAML SP certificate: %w", err)
+		}
+		signer, ok := keyPair.PrivateKey.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported SAML SP private key")
+		}
+		sp.Certificate = certificate
+		sp.Key = signer
+		if s.config.SignRequests {
+			sp.SignatureMethod = dsig.RSASHA256SignatureMethod
+			if _, isECDSA := keyPair.PrivateKey.(*ecdsa.PrivateKey); isECDSA {
+				sp.SignatureMethod = dsig.ECDSASHA256SignatureMethod
+			}
+		}
+	} else if s.config.SignRequests {
+		return nil, errors.New("SAML_SIGN_REQUESTS requires SAML_SP_CERT_FILE and SAML_SP_KEY_FILE")
+	}
+
+	return sp, nil
+}
+
+// loadIdPMetadata lit les métadonnées de l'IdP (fichier ou URL)
+func (s *SAMLService) loadIdPMetadata() (*saml.EntityDescriptor, error) {
+	var data []byte
+	switch {
+	case s.config.IdPMetadataFile != "":
+		content, err := os.ReadFile(s.config.IdPMetadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IdP metadata: %w", err)
+		}
+		data = content
+	case s.config.IdPMetadataURL != "":
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(s.config.IdPMetadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch IdP metadata: status %d", resp.StatusCode)
+		}
+		content, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch IdP metadata: %w", err)
+		}
+		data = content
+	default:
+		return nil, errors.New("SAML_IDP_METADATA_URL or SAML_IDP_METADATA_FILE is required")
+	}
+	return parseIdPMetadata(data)
+}
+
+// parseIdPMetadata accepte un EntityDescriptor ou un EntitiesDescriptor (fédération)
+func parseIdPMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	var entity saml.EntityDescriptor
+	if err := xml.Unmarshal(data, &entity); err == nil && len(entity.IDPSSODescriptors) > 0 {
+		return &entity, nil
+	}
+
+	var entities saml.EntitiesDescriptor
+	if err := xml.Unmarshal(data, &entities); err != nil {
+		return nil, fmt.Errorf("invalid IdP metadata: %w", err)
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, errors.New("invalid IdP metadata: no IDPSSODescriptor")
+}
+
+func samlRequestKey(relayState string) string {
+	return "saml_request:" + relayState
+}
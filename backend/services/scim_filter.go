@@ -0,0 +1,396 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filtres SCIM (RFC 7644 §3.4.2.2) : les expressions sont analysées puis traduites en clause
+// SQL paramétrée sur les seuls attributs déclarés pour la ressource.
+
+// scimFilter est un nœud de l'arbre d'un filtre
+type scimFilter struct {
+	op       string // and, or, not, pr, eq, ne, co, sw, ew, gt, ge, lt, le
+	attr     string // chemin de l'attribut, en minuscules et sans URN du schéma principal
+	value    interface{}
+	children []*scimFilter
+}
+
+// scimAttrKind est le type d'un attribut filtrable
+type scimAttrKind int
+
+const (
+	scimString scimAttrKind = iota
+	scimBoolean
+	scimID
+	scimDateTime
+	scimReference // appartenance via user_groups (eq et pr uniquement)
+)
+
+// scimAttribute associe un attribut SCIM à une colonne. Pour scimReference, column est la condition
+// dont le paramètre est l'identifiant recherché et present la condition utilisée par "pr".
+type scimAttribute struct {
+	column  string
+	kind    scimAttrKind
+	present string
+}
+
+// parseSCIMFilter analyse un filtre SCIM
+func parseSCIMFilter(input string) (*scimFilter, error) {
+	tokens, err := tokenizeSCIMFilter(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &scimFilterParser{tokens: tokens}
+	filter, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+	return filter, nil
+}
+
+type scimFilterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *scimFilterParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *scimFilterParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *scimFilterParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *scimFilterParser) expect(token string) error {
+	if p.done() {
+		return fmt.Errorf("expected %q", token)
+	}
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+// parseOr lit une suite de termes séparés par "or" ; prefix est le chemin de l'attribut
+// parent dans un filtre de valeur (emails[value eq "..."])
+func (p *scimFilterParser) parseOr(prefix string) (*scimFilter, error) {
+	left, err := p.parseAnd(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = &scimFilter{op: "or", children: []*scimFilter{left, right}}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseAnd(prefix string) (*scimFilter, error) {
+	left, err := p.parseTerm(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "and") {
+		p.next()
+		right, err := p.parseTerm(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = &scimFilter{op: "and", children: []*scimFilter{left, right}}
+	}
+	return left, nil
+}
+
+func (p *scimFilterParser) parseTerm(prefix string) (*scimFilter, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of filter")
+	case strings.EqualFold(token, "not"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &scimFilter{op: "not", children: []*scimFilter{inner}}, nil
+	case token == "(":
+		inner, err := p.parseOr(prefix)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case strings.ContainsAny(token[:1], "()[]\""):
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+
+	attr := prefix + normalizeSCIMPath(token)
+
+	// Filtre de valeur : attribut multivalué suivi d'un filtre sur ses sous-attributs
+	if p.peek() == "[" {
+		if prefix != "" {
+			return nil, fmt.Errorf("nested value filters are not supported")
+		}
+		p.next()
+		inner, err := p.parseOr(attr + ".")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	op := strings.ToLower(p.next())
+	switch op {
+	case "pr":
+		return &scimFilter{op: op, attr: attr}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", op)
+	}
+
+	raw := p.next()
+	if raw == "" {
+		return nil, fmt.Errorf("missing value for %s", attr)
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		// Les littéraux true, false et null sont insensibles à la casse
+		switch strings.ToLower(raw) {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			return nil, fmt.Errorf("invalid value %s", raw)
+		}
+	}
+	return &scimFilter{op: op, attr: attr, value: value}, nil
+}
+
+// tokenizeSCIMFilter découpe un filtre en parenthèses, crochets, chaînes JSON et mots
+func tokenizeSCIMFilter(input string) ([]string, error) {
+	var tokens []string
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("()[]", r):
+			tokens = append(tokens, string(r))
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()[]\"", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	return tokens, nil
+}
+
+// normalizeSCIMPath met un chemin d'attribut en minuscules et retire l'URN des schémas principaux
+// (les attributs de l'extension entreprise gardent leur URN)
+func normalizeSCIMPath(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+	for _, schema := range []string{"urn:ietf:params:scim:schemas:core:2.0:user:", "urn:ietf:params:scim:schemas:core:2.0:group:"} {
+		path = strings.TrimPrefix(path, schema)
+	}
+	return path
+}
+
+// toSQL traduit le filtre en condition SQL (avec ses paramètres) sur les attributs donnés
+func (f *scimFilter) toSQL(attributes map[string]scimAttribute) (string, []interface{}, error) {
+	switch f.op {
+	case "and", "or":
+		left, leftArgs, err := f.children[0].toSQL(attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := f.children[1].toSQL(attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + left + " " + strings.ToUpper(f.op) + " " + right + ")", append(leftArgs, rightArgs...), nil
+	case "not":
+		inner, args, err := f.children[0].toSQL(attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(NOT " + inner + ")", args, nil
+	}
+
+	attribute, ok := attributes[f.attr]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported attribute %q", f.attr)
+	}
+
+	// "attr eq null" équivaut à "not (attr pr)"
+	if f.op != "pr" && f.value == nil {
+		present, args, err := (&scimFilter{op: "pr", attr: f.attr}).toSQL(attributes)
+		switch {
+		case err != nil:
+			return "", nil, err
+		case f.op == "eq":
+			return "(NOT " + present + ")", args, nil
+		case f.op == "ne":
+			return present, args, nil
+		default:
+			return "", nil, fmt.Errorf("null is only comparable with eq and ne")
+		}
+	}
+
+	column := attribute.column
+	switch attribute.kind {
+	case scimString:
+		if f.op == "pr" {
+			return "(" + column + " IS NOT NULL AND " + column + " <> '')", nil, nil
+		}
+		value, ok := f.value.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("%s expects a string", f.attr)
+		}
+		// Les attributs exposés ne sont pas sensibles à la casse (caseExact=false)
+		value = strings.ToLower(value)
+		lower := "LOWER(" + column + ")"
+		switch f.op {
+		case "eq":
+			return lower + " = ?", []interface{}{value}, nil
+		case "ne":
+			return lower + " <> ?", []interface{}{value}, nil
+		case "co":
+			return lower + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(value) + "%"}, nil
+		case "sw":
+			return lower + ` LIKE ? ESCAPE '\'`, []interface{}{escapeLike(value) + "%"}, nil
+		case "ew":
+			return lower + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(value)}, nil
+		default:
+			return lower + " " + scimComparison[f.op] + " ?", []interface{}{value}, nil
+		}
+
+	case scimBoolean:
+		if f.op == "pr" {
+			return column + " IS NOT NULL", nil, nil
+		}
+		value, ok := f.value.(bool)
+		if !ok || (f.op != "eq" && f.op != "ne") {
+			return "", nil, fmt.Errorf("%s only supports eq and ne with a boolean", f.attr)
+		}
+		return column + " " + scimComparison[f.op] + " ?", []interface{}{value}, nil
+
+	case scimID:
+		if f.op == "pr" {
+			return column + " IS NOT NULL", nil, nil
+		}
+		if f.op != "eq" && f.op != "ne" {
+			return "", nil, fmt.Errorf("%s only supports eq and ne", f.attr)
+		}
+		id, err := parseSCIMID(f.value)
+		if err != nil {
+			// Identifiant qui ne peut pas exister : aucune ressource (ou toutes pour ne)
+			if f.op == "eq" {
+				return "1 = 0", nil, nil
+			}
+			return "1 = 1", nil, nil
+		}
+		return column + " " + scimComparison[f.op] + " ?", []interface{}{id}, nil
+
+	case scimDateTime:
+		if f.op == "pr" {
+			return column + " IS NOT NULL", nil, nil
+		}
+		raw, _ := f.value.(string)
+		value, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil || f.op == "co" || f.op == "sw" || f.op == "ew" {
+			return "", nil, fmt.Errorf("%s expects a comparison with an RFC 3339 date", f.attr)
+		}
+		return column + " " + scimComparison[f.op] + " ?", []interface{}{value}, nil
+
+	case scimReference:
+		if f.op == "pr" {
+			return attribute.present, nil, nil
+		}
+		if f.op != "eq" {
+			return "", nil, fmt.Errorf("%s only supports eq and pr", f.attr)
+		}
+		id, err := parseSCIMID(f.value)
+		if err != nil {
+			return "1 = 0", nil, nil
+		}
+		return column, []interface{}{id}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported attribute %q", f.attr)
+}
+
+var scimComparison = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
+
+// parseSCIMID convertit un identifiant SCIM (chaîne) en identifiant numérique
+func parseSCIMID(value interface{}) (uint, error) {
+	var raw string
+	switch v := value.(type) {
+	case string:
+		raw = v
+	case float64:
+		raw = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid id %v", value)
+	}
+	return uint(id), nil
+}
+
+// escapeLike échappe les caractères spéciaux d'un motif LIKE (caractère d'échappement \)
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,1018 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Pagination des recherches SCIM
+const (
+	SCIMDefaultCount = 100
+	SCIMMaxResults   = 200
+)
+
+// SCIMError est une erreur renvoyée au client SCIM avec son statut et son scimType
+type SCIMError struct {
+	Status int
+	Type   string
+	Detail string
+}
+
+func (e *SCIMError) Error() string {
+	return e.Detail
+}
+
+func scimError(status int, scimType, format string, args ...interface{}) *SCIMError {
+	return &SCIMError{Status: status, Type: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+var errSCIMUserNotFound = scimError(http.StatusNotFound, "", "user not found")
+var errSCIMGroupNotFound = scimError(http.StatusNotFound, "", "group not found")
+var errSCIMUserNotManaged = scimError(http.StatusForbidden, "", "user is not managed by the identity provider")
+
+// Attributs filtrables des ressources User et Group
+var scimUserAttributes = map[string]scimAttribute{
+	"id":                 {column: "id", kind: scimID},
+	"username":           {column: "username", kind: scimString},
+	"externalid":         {column: "scim_external_id", kind: scimString},
+	"name.givenname":     {column: "first_name", kind: scimString},
+	"name.familyname":    {column: "last_name", kind: scimString},
+	"emails":             {column: "email", kind: scimString},
+	"emails.value":       {column: "email", kind: scimString},
+	"phonenumbers":       {column: "phone", kind: scimString},
+	"phonenumbers.value": {column: "phone", kind: scimString},
+	"title":              {column: "job_title", kind: scimString},
+	"active":             {column: "is_active", kind: scimBoolean},
+	"meta.created":       {column: "created_at", kind: scimDateTime},
+	"meta.lastmodified":  {column: "updated_at", kind: scimDateTime},
+	"groups": {
+		column: "id IN (SELECT user_id FROM user_groups WHERE group_id = ?)", kind: scimReference,
+		present: "id IN (SELECT user_id FROM user_groups)",
+	},
+	"groups.value": {
+		column: "id IN (SELECT user_id FROM user_groups WHERE group_id = ?)", kind: scimReference,
+		present: "id IN (SELECT user_id FROM user_groups)",
+	},
+	scimEnterprisePrefix + ":department": {column: "department", kind: scimString},
+}
+
+var scimGroupAttributes = map[string]scimAttribute{
+	"id":                {column: "id", kind: scimID},
+	"displayname":       {column: "name", kind: scimString},
+	"externalid":        {column: "scim_external_id", kind: scimString},
+	"meta.created":      {column: "created_at", kind: scimDateTime},
+	"meta.lastmodified": {column: "updated_at", kind: scimDateTime},
+	"members": {
+		column: "id IN (SELECT group_id FROM user_groups WHERE user_id = ?)", kind: scimReference,
+		present: "id IN (SELECT group_id FROM user_groups)",
+	},
+	"members.value": {
+		column: "id IN (SELECT group_id FROM user_groups WHERE user_id = ?)", kind: scimReference,
+		present: "id IN (SELECT group_id FROM user_groups)",
+	},
+}
+
+// scimEnterprisePrefix est l'URN (normalisée) de l'extension entreprise
+const scimEnterprisePrefix = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:user"
+
+// scimValueFilter retire les filtres de valeur d'un chemin PATCH (emails[type eq "work"].value)
+var scimValueFilter = regexp.MustCompile(`\[[^\]]*\]`)
+
+// SCIMService expose les utilisateurs et les groupes Airboard au protocole SCIM 2.0. Les comptes
+// de service ne sont pas visibles : ils appartiennent à Airboard, pas à l'IdP.
+type SCIMService struct {
+	db      *gorm.DB
+	config  *config.Config
+	baseURL string
+}
+
+// NewSCIMService crée une nouvelle instance du service SCIM
+func NewSCIMService(db *gorm.DB, cfg *config.Config) *SCIMService {
+	return &SCIMService{
+		db:      db,
+		config:  cfg,
+		baseURL: strings.TrimSuffix(cfg.Server.PublicURL, "/") + "/api/v1/scim/v2",
+	}
+}
+
+func (s *SCIMService) users() *gorm.DB {
+	return s.db.Model(&models.User{}).Where("is_service_account = ?", false)
+}
+
+// managedUsers restreint users() aux comptes que l'IdP peut modifier (voir findManagedUser)
+func (s *SCIMService) managedUsers() *gorm.DB {
+	return s.users().Where("scim_managed = ? OR sso_provider = ? OR (sso_provider <> ? AND role <> ?)", true, "scim", "", "admin")
+}
+
+// ===== Users =====
+
+// ListUsers retourne une page d'utilisateurs correspondant au filtre (startIndex commence à 1)
+func (s *SCIMService) ListUsers(filter string, startIndex, count int) ([]models.User, int64, error) {
+	query, err := applySCIMFilter(s.users(), filter, scimUserAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	if count > 0 {
+		if err := query.Preload("Groups").Order("id").Offset(startIndex - 1).Limit(count).Find(&users).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	return users, total, nil
+}
+
+// GetUser retourne un utilisateur et ses groupes
+func (s *SCIMService) GetUser(id string) (*models.User, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(user).Association("Groups").Find(&user.Groups); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser provisionne un utilisateur. Il n'a pas de mot de passe local et se connecte par SSO ;
+// ses groupes sont ensuite gérés par l'IdP sur /Groups.
+func (s *SCIMService) CreateUser(resource *models.SCIMUser) (*models.User, error) {
+	user := models.User{
+		Role:        s.config.SSO.DefaultRole,
+		IsActive:    true,
+		SSOProvider: "scim",
+		SCIMManaged: true,
+	}
+	if err := applySCIMUser(&user, resource); err != nil {
+		return nil, err
+	}
+	if err := s.checkUserUnique(&user); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("[SCIM] Utilisateur provisionné: %s (%s)", user.Username, user.Email)
+	return &user, nil
+}
+
+// ReplaceUser remplace les attributs d'un utilisateur (PUT)
+func (s *SCIMService) ReplaceUser(id string, resource *models.SCIMUser) (*models.User, error) {
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateUser(user, resource); err != nil {
+		return nil, err
+	}
+	return s.GetUser(id)
+}
+
+// PatchUser applique des opérations PATCH à un utilisateur
+func (s *SCIMService) PatchUser(id string, operations []models.SCIMPatchOperation) (*models.User, error) {
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return nil, err
+	}
+	resource := s.UserResource(user)
+	for _, operation := range operations {
+		if err := patchSCIMUser(resource, operation); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.updateUser(user, resource); err != nil {
+		return nil, err
+	}
+	return s.GetUser(id)
+}
+
+// DeleteUser supprime un utilisateur (suppression logique, comme depuis l'administration)
+func (s *SCIMService) DeleteUser(id string) error {
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Association("Groups").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	}); err != nil {
+		return err
+	}
+	s.revokeSessions(user.ID, SessionRevokedDeleted)
+	log.Printf("[SCIM] Utilisateur supprimé: %s (%s)", user.Username, user.Email)
+	return nil
+}
+
+// UserResource convertit un utilisateur en ressource SCIM
+func (s *SCIMService) UserResource(user *models.User) *models.SCIMUser {
+	id := strconv.FormatUint(uint64(user.ID), 10)
+	active := user.IsActive
+	formatted := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	displayName := formatted
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	resource := &models.SCIMUser{
+		Schemas:     []string{models.SCIMSchemaUser},
+		ID:          id,
+		ExternalID:  user.SCIMExternalID,
+		UserName:    user.Username,
+		DisplayName: displayName,
+		Title:       user.JobTitle,
+		Active:      &active,
+		Emails:      []models.SCIMMultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Meta: &models.SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     s.baseURL + "/Users/" + id,
+		},
+	}
+	if formatted != "" {
+		resource.Name = &models.SCIMName{Formatted: formatted, GivenName: user.FirstName, FamilyName: user.LastName}
+	}
+	if user.Phone != "" {
+		resource.PhoneNumbers = []models.SCIMMultiValue{{Value: user.Phone, Type: "work", Primary: true}}
+	}
+	if user.Department != "" {
+		resource.Schemas = append(resource.Schemas, models.SCIMSchemaEnterpriseUser)
+		resource.Enterprise = &models.SCIMEnterpriseUser{Department: user.Department}
+	}
+	for _, group := range user.Groups {
+		groupID := strconv.FormatUint(uint64(group.ID), 10)
+		resource.Groups = append(resource.Groups, models.SCIMMember{
+			Value:   groupID,
+			Ref:     s.baseURL + "/Groups/" + groupID,
+			Display: group.Name,
+		})
+	}
+	return resource
+}
+
+func (s *SCIMService) findUser(id string) (*models.User, error) {
+	userID, err := parseSCIMID(id)
+	if err != nil {
+		return nil, errSCIMUserNotFound
+	}
+	var user models.User
+	if err := s.users().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errSCIMUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findManagedUser retourne un utilisateur que l'IdP peut modifier : un compte provisionné par SCIM,
+// ou un compte SSO non administrateur (adopté à sa première modification). Les comptes locaux et
+// les administrateurs locaux restent hors de portée : changer leur email permettrait de les lier
+// à une connexion OAuth contrôlée par l'IdP.
+func (s *SCIMService) findManagedUser(id string) (*models.User, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if user.SCIMManaged || user.SSOProvider == "scim" {
+		return user, nil
+	}
+	if user.SSOProvider == "" || user.Role == "admin" {
+		return nil, errSCIMUserNotManaged
+	}
+	return user, nil
+}
+
+// updateUser enregistre la ressource sur l'utilisateur ; une désactivation révoque ses sessions
+func (s *SCIMService) updateUser(user *models.User, resource *models.SCIMUser) error {
+	wasActive := user.IsActive
+	if err := applySCIMUser(user, resource); err != nil {
+		return err
+	}
+	if err := s.checkUserUnique(user); err != nil {
+		return err
+	}
+	user.SCIMManaged = true
+	if err := s.db.Save(user).Error; err != nil {
+		return err
+	}
+	if wasActive && !user.IsActive {
+		s.revokeSessions(user.ID, SessionRevokedDeactivated)
+		log.Printf("[SCIM] Utilisateur désactivé: %s (%s)", user.Username, user.Email)
+	}
+	return nil
+}
+
+// checkUserUnique refuse un userName ou un email déjà utilisé, y compris par un compte supprimé
+// (les contraintes d'unicité de la base les incluent)
+func (s *SCIMService) checkUserUnique(user *models.User) error {
+	var count int64
+	if err := s.db.Unscoped().Model(&models.User{}).
+		Where("id <> ? AND (LOWER(username) = ? OR LOWER(email) = ?)", user.ID, strings.ToLower(user.Username), strings.ToLower(user.Email)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scimError(http.StatusConflict, "uniqueness", "userName or email already in use")
+	}
+	return nil
+}
+
+func (s *SCIMService) revokeSessions(userID uint, reason string) {
+	if _, err := NewSessionService(s.db).RevokeAll(userID, reason); err != nil {
+		log.Printf("[SCIM] Erreur lors de la révocation des sessions de l'utilisateur %d: %v", userID, err)
+	}
+}
+
+// applySCIMUser copie les attributs de la ressource sur l'utilisateur. L'email est l'email principal,
+// à défaut le premier, à défaut le userName s'il a la forme d'une adresse.
+func applySCIMUser(user *models.User, resource *models.SCIMUser) error {
+	username := strings.TrimSpace(resource.UserName)
+	if username == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	email := ""
+	for _, candidate := range resource.Emails {
+		if email == "" || candidate.Primary {
+			email = strings.TrimSpace(candidate.Value)
+		}
+	}
+	if email == "" && strings.Contains(username, "@") {
+		email = username
+	}
+	if !strings.Contains(email, "@") {
+		return scimError(http.StatusBadRequest, "invalidValue", "a valid email is required")
+	}
+
+	user.Username = username
+	user.Email = email
+	user.SCIMExternalID = resource.ExternalID
+	user.JobTitle = resource.Title
+	user.FirstName, user.LastName = "", ""
+	if resource.Name != nil {
+		user.FirstName = resource.Name.GivenName
+		user.LastName = resource.Name.FamilyName
+	}
+	user.Phone = ""
+	for _, phone := range resource.PhoneNumbers {
+		if user.Phone == "" || phone.Primary {
+			user.Phone = phone.Value
+		}
+	}
+	user.Department = ""
+	if resource.Enterprise != nil {
+		user.Department = resource.Enterprise.Department
+	}
+	if resource.Active != nil {
+		user.IsActive = *resource.Active
+	}
+	return nil
+}
+
+// patchSCIMUser applique une opération PATCH à la ressource. Les attributs non gérés par Airboard
+// sont ignorés pour ne pas bloquer la synchronisation des IdP qui les envoient.
+func patchSCIMUser(resource *models.SCIMUser, operation models.SCIMPatchOperation) error {
+	op, value, err := decodeSCIMOperation(operation)
+	if err != nil {
+		return err
+	}
+	if operation.Path == "" {
+		if op == "remove" {
+			return scimError(http.StatusBadRequest, "noTarget", "remove requires a path")
+		}
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			return scimError(http.StatusBadRequest, "invalidValue", "value must be an object when no path is given")
+		}
+		for key, attributeValue := range attributes {
+			if err := setSCIMUserAttribute(resource, normalizeSCIMPath(key), attributeValue, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	path := scimValueFilter.ReplaceAllString(normalizeSCIMPath(operation.Path), "")
+	return setSCIMUserAttribute(resource, path, value, op == "remove")
+}
+
+func setSCIMUserAttribute(resource *models.SCIMUser, path string, value interface{}, remove bool) error {
+	if remove {
+		value = nil
+	}
+	if resource.Name == nil {
+		resource.Name = &models.SCIMName{}
+	}
+	if resource.Enterprise == nil {
+		resource.Enterprise = &models.SCIMEnterpriseUser{}
+	}
+
+	switch path {
+	case "username":
+		resource.UserName = scimStringValue(value)
+	case "externalid":
+		resource.ExternalID = scimStringValue(value)
+	case "title":
+		resource.Title = scimStringValue(value)
+	case "name.givenname":
+		resource.Name.GivenName = scimStringValue(value)
+	case "name.familyname":
+		resource.Name.FamilyName = scimStringValue(value)
+	case "emails.value":
+		resource.Emails = []models.SCIMMultiValue{{Value: scimStringValue(value), Primary: true}}
+	case "phonenumbers.value":
+		resource.PhoneNumbers = []models.SCIMMultiValue{{Value: scimStringValue(value), Primary: true}}
+	case scimEnterprisePrefix + ":department":
+		resource.Enterprise.Department = scimStringValue(value)
+
+	case "emails", "phonenumbers":
+		values, err := scimMultiValues(value)
+		if err != nil {
+			return err
+		}
+		if path == "emails" {
+			resource.Emails = values
+		} else {
+			resource.PhoneNumbers = values
+		}
+
+	case "name", scimEnterprisePrefix:
+		if value == nil {
+			if path == "name" {
+				resource.Name = &models.SCIMName{}
+			} else {
+				resource.Enterprise = &models.SCIMEnterpriseUser{}
+			}
+			return nil
+		}
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			return scimError(http.StatusBadRequest, "invalidValue", "%s must be an object", path)
+		}
+		separator := "."
+		if path == scimEnterprisePrefix {
+			separator = ":"
+		}
+		for key, attributeValue := range attributes {
+			if err := setSCIMUserAttribute(resource, path+separator+strings.ToLower(key), attributeValue, false); err != nil {
+				return err
+			}
+		}
+
+	case "active":
+		if value == nil {
+			return nil
+		}
+		active, ok := scimBoolValue(value)
+		if !ok {
+			return scimError(http.StatusBadRequest, "invalidValue", "active must be a boolean")
+		}
+		resource.Active = &active
+
+	case "groups":
+		return scimError(http.StatusBadRequest, "mutability", "group memberships are managed on /Groups")
+	}
+	return nil
+}
+
+// ===== Groups =====
+
+// ListGroups retourne une page de groupes correspondant au filtre, avec ou sans leurs membres
+func (s *SCIMService) ListGroups(filter string, startIndex, count int, withMembers bool) ([]models.Group, int64, error) {
+	query, err := applySCIMFilter(s.db.Model(&models.Group{}), filter, scimGroupAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var groups []models.Group
+	if count > 0 {
+		if withMembers {
+			query = query.Preload("Users", "is_service_account = ?", false)
+		}
+		if err := query.Order("id").Offset(startIndex - 1).Limit(count).Find(&groups).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	return groups, total, nil
+}
+
+// GetGroup retourne un groupe, avec ou sans ses membres
+func (s *SCIMService) GetGroup(id string, withMembers bool) (*models.Group, error) {
+	group, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if withMembers {
+		if err := s.db.Model(group).Where("is_service_account = ?", false).Association("Users").Find(&group.Users); err != nil {
+			return nil, err
+		}
+	}
+	return group, nil
+}
+
+// CreateGroup crée un groupe et ses appartenances
+func (s *SCIMService) CreateGroup(resource *models.SCIMGroup) (*models.Group, error) {
+	name := strings.TrimSpace(resource.DisplayName)
+	if name == "" {
+		return nil, scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	group := models.Group{
+		Name:           name,
+		Description:    "Provisionné par SCIM",
+		IsActive:       true,
+		SCIMExternalID: resource.ExternalID,
+	}
+	if err := s.checkGroupUnique(&group); err != nil {
+		return nil, err
+	}
+	members, err := s.memberUsers(scimMemberIDs(resource.Members))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return nil
+		}
+		return tx.Model(&group).Association("Users").Append(members)
+	}); err != nil {
+		return nil, err
+	}
+	log.Printf("[SCIM] Groupe provisionné: %s (%d membre(s))", group.Name, len(members))
+	return s.GetGroup(strconv.FormatUint(uint64(group.ID), 10), true)
+}
+
+// ReplaceGroup remplace le nom, l'externalId et, s'ils sont fournis, les membres d'un groupe (PUT)
+func (s *SCIMService) ReplaceGroup(id string, resource *models.SCIMGroup) (*models.Group, error) {
+	group, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	patch := scimGroupPatch{name: resource.DisplayName, externalID: resource.ExternalID}
+	if resource.Members != nil {
+		patch.replace = true
+		patch.add = scimMemberIDs(resource.Members)
+	}
+	if err := s.updateGroup(group, &patch); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(id, true)
+}
+
+// PatchGroup applique des opérations PATCH à un groupe (renommage, ajout et retrait de membres)
+func (s *SCIMService) PatchGroup(id string, operations []models.SCIMPatchOperation) error {
+	group, err := s.findGroup(id)
+	if err != nil {
+		return err
+	}
+	patch := scimGroupPatch{name: group.Name, externalID: group.SCIMExternalID}
+	for _, operation := range operations {
+		if err := patch.apply(operation); err != nil {
+			return err
+		}
+	}
+	return s.updateGroup(group, &patch)
+}
+
+// DeleteGroup supprime un groupe et ses appartenances (comme depuis l'administration)
+func (s *SCIMService) DeleteGroup(id string) error {
+	group, err := s.findGroup(id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(group).Association("Users").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Model(group).Association("AppGroups").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	}); err != nil {
+		return err
+	}
+	log.Printf("[SCIM] Groupe supprimé: %s", group.Name)
+	return nil
+}
+
+// GroupResource convertit un groupe en ressource SCIM (les membres sont ceux chargés dans group.Users)
+func (s *SCIMService) GroupResource(group *models.Group) *models.SCIMGroup {
+	id := strconv.FormatUint(uint64(group.ID), 10)
+	resource := &models.SCIMGroup{
+		Schemas:     []string{models.SCIMSchemaGroup},
+		ID:          id,
+		ExternalID:  group.SCIMExternalID,
+		DisplayName: group.Name,
+		Meta: &models.SCIMMeta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     s.baseURL + "/Groups/" + id,
+		},
+	}
+	for _, user := range group.Users {
+		userID := strconv.FormatUint(uint64(user.ID), 10)
+		resource.Members = append(resource.Members, models.SCIMMember{
+			Value:   userID,
+			Ref:     s.baseURL + "/Users/" + userID,
+			Display: user.Username,
+		})
+	}
+	return resource
+}
+
+func (s *SCIMService) findGroup(id string) (*models.Group, error) {
+	groupID, err := parseSCIMID(id)
+	if err != nil {
+		return nil, errSCIMGroupNotFound
+	}
+	var group models.Group
+	if err := s.db.First(&group, groupID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errSCIMGroupNotFound
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// scimGroupPatch accumule les modifications d'un groupe avant de les enregistrer
+type scimGroupPatch struct {
+	name       string
+	externalID string
+	replace    bool   // Remplacer tous les membres par add
+	removeAll  bool   // Retirer tous les membres avant d'appliquer add
+	add        []uint // Membres ajoutés
+	remove     []uint // Membres retirés
+}
+
+func (p *scimGroupPatch) apply(operation models.SCIMPatchOperation) error {
+	op, value, err := decodeSCIMOperation(operation)
+	if err != nil {
+		return err
+	}
+
+	if operation.Path == "" {
+		if op == "remove" {
+			return scimError(http.StatusBadRequest, "noTarget", "remove requires a path")
+		}
+		attributes, ok := value.(map[string]interface{})
+		if !ok {
+			return scimError(http.StatusBadRequest, "invalidValue", "value must be an object when no path is given")
+		}
+		for key, attributeValue := range attributes {
+			if err := p.set(op, normalizeSCIMPath(key), attributeValue); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path := normalizeSCIMPath(operation.Path)
+	if strings.HasPrefix(path, "members[") {
+		// Retrait ciblé : members[value eq "42"] (éventuellement plusieurs valeurs reliées par or)
+		if op != "remove" || !strings.HasSuffix(path, "]") {
+			return scimError(http.StatusBadRequest, "invalidPath", "unsupported path %q", operation.Path)
+		}
+		raw := strings.TrimSpace(operation.Path)
+		filter, err := parseSCIMFilter(raw[strings.Index(raw, "[")+1 : len(raw)-1])
+		if err != nil {
+			return scimError(http.StatusBadRequest, "invalidPath", "invalid member filter: %v", err)
+		}
+		ids, err := scimMemberFilterIDs(filter)
+		if err != nil {
+			return err
+		}
+		p.removeMembers(ids)
+		return nil
+	}
+	return p.set(op, path, value)
+}
+
+func (p *scimGroupPatch) set(op, path string, value interface{}) error {
+	switch path {
+	case "displayname":
+		if op == "remove" || strings.TrimSpace(scimStringValue(value)) == "" {
+			return scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+		}
+		p.name = scimStringValue(value)
+	case "externalid":
+		p.externalID = ""
+		if op != "remove" {
+			p.externalID = scimStringValue(value)
+		}
+	case "members":
+		if op == "remove" && value == nil {
+			p.replace, p.removeAll, p.add, p.remove = false, true, nil, nil
+			return nil
+		}
+		ids, err := scimMemberValueIDs(value)
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "add":
+			p.addMembers(ids)
+		case "remove":
+			p.removeMembers(ids)
+		case "replace":
+			p.replace, p.removeAll, p.add, p.remove = true, false, ids, nil
+		}
+	}
+	return nil
+}
+
+func (p *scimGroupPatch) addMembers(ids []uint) {
+	p.remove = withoutIDs(p.remove, ids)
+	p.add = append(withoutIDs(p.add, ids), ids...)
+}
+
+func (p *scimGroupPatch) removeMembers(ids []uint) {
+	p.add = withoutIDs(p.add, ids)
+	if !p.replace && !p.removeAll {
+		p.remove = append(p.remove, ids...)
+	}
+}
+
+// updateGroup enregistre le nom, l'externalId et les appartenances du groupe dans user_groups
+func (s *SCIMService) updateGroup(group *models.Group, patch *scimGroupPatch) error {
+	name := strings.TrimSpace(patch.name)
+	if name == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	group.Name = name
+	group.SCIMExternalID = patch.externalID
+	if err := s.checkGroupUnique(group); err != nil {
+		return err
+	}
+	added, err := s.memberUsers(patch.add)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(group).Error; err != nil {
+			return err
+		}
+		switch {
+		case patch.replace, patch.removeAll:
+			// Seuls les membres gérés par l'IdP sont retirés : les comptes de service et les comptes
+			// locaux, qu'il ne peut pas renvoyer dans la liste, gardent leur appartenance
+			if err := tx.Exec("DELETE FROM user_groups WHERE group_id = ? AND user_id IN (?)",
+				group.ID, s.managedUsers().Select("id")).Error; err != nil {
+				return err
+			}
+		case len(patch.remove) > 0:
+			removed := make([]models.User, 0, len(patch.remove))
+			for _, id := range patch.remove {
+				removed = append(removed, models.User{ID: id})
+			}
+			if err := tx.Model(group).Association("Users").Delete(removed); err != nil {
+				return err
+			}
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		rows := make([]map[string]interface{}, 0, len(added))
+		for _, user := range added {
+			rows = append(rows, map[string]interface{}{"user_id": user.ID, "group_id": group.ID})
+		}
+		return tx.Table("user_groups").Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+	})
+}
+
+func (s *SCIMService) checkGroupUnique(group *models.Group) error {
+	var count int64
+	if err := s.db.Unscoped().Model(&models.Group{}).
+		Where("id <> ? AND LOWER(name) = ?", group.ID, strings.ToLower(group.Name)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scimError(http.StatusConflict, "uniqueness", "displayName already in use")
+	}
+	return nil
+}
+
+// memberUsers charge les utilisateurs désignés comme membres ; un identifiant inconnu est refusé
+func (s *SCIMService) memberUsers(ids []uint) ([]models.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var users []models.User
+	if err := s.users().Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) != len(uniqueIDs(ids)) {
+		return nil, scimError(http.StatusBadRequest, "invalidValue", "unknown member")
+	}
+	return users, nil
+}
+
+// ===== Helpers =====
+
+// applySCIMFilter ajoute la condition du filtre à la requête (erreur invalidFilter si non géré)
+func applySCIMFilter(query *gorm.DB, filter string, attributes map[string]scimAttribute) (*gorm.DB, error) {
+	if strings.TrimSpace(filter) == "" {
+		return query.Session(&gorm.Session{}), nil
+	}
+	parsed, err := parseSCIMFilter(filter)
+	if err != nil {
+		return nil, scimError(http.StatusBadRequest, "invalidFilter", "invalid filter: %v", err)
+	}
+	condition, args, err := parsed.toSQL(attributes)
+	if err != nil {
+		return nil, scimError(http.StatusBadRequest, "invalidFilter", "invalid filter: %v", err)
+	}
+	return query.Where(condition, args...).Session(&gorm.Session{}), nil
+}
+
+// decodeSCIMOperation retourne l'opération (en minuscules) et sa valeur décodée
+func decodeSCIMOperation(operation models.SCIMPatchOperation) (string, interface{}, error) {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "remove" && op != "replace" {
+		return "", nil, scimError(http.StatusBadRequest, "invalidSyntax", "unknown operation %q", operation.Op)
+	}
+	var value interface{}
+	if len(operation.Value) > 0 {
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return "", nil, scimError(http.StatusBadRequest, "invalidSyntax", "invalid value: %v", err)
+		}
+	}
+	if op != "remove" && value == nil {
+		return "", nil, scimError(http.StatusBadRequest, "invalidValue", "%s requires a value", op)
+	}
+	return op, value, nil
+}
+
+// scimStringValue lit une valeur simple (ou l'attribut value d'un objet ou d'une liste d'un élément)
+func scimStringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64, bool:
+		return fmt.Sprint(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			if strings.EqualFold(key, "value") {
+				return scimStringValue(item)
+			}
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			return scimStringValue(v[0])
+		}
+	}
+	return ""
+}
+
+// scimBoolValue accepte les booléens JSON et leur forme texte ("True", "false") envoyée par certains IdP
+func scimBoolValue(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		parsed, err := strconv.ParseBool(strings.ToLower(strings.TrimSpace(v)))
+		return parsed, err == nil
+	case map[string]interface{}:
+		for key, item := range v {
+			if strings.EqualFold(key, "value") {
+				return scimBoolValue(item)
+			}
+		}
+	}
+	return false, false
+}
+
+// scimMultiValues lit un attribut multivalué (liste d'objets, objet seul ou valeur simple)
+func scimMultiValues(value interface{}) ([]models.SCIMMultiValue, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		items = v
+	default:
+		items = []interface{}{v}
+	}
+
+	var values []models.SCIMMultiValue
+	for _, item := range items {
+		entry := models.SCIMMultiValue{Value: scimStringValue(item)}
+		if object, ok := item.(map[string]interface{}); ok {
+			for key, field := range object {
+				switch strings.ToLower(key) {
+				case "type":
+					entry.Type = scimStringValue(field)
+				case "primary":
+					entry.Primary, _ = scimBoolValue(field)
+				}
+			}
+		}
+		if entry.Value == "" {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "multi-valued attribute without value")
+		}
+		values = append(values, entry)
+	}
+	return values, nil
+}
+
+// scimMemberIDs retourne les identifiants des membres d'une ressource Group (les valeurs
+// qui ne peuvent pas être des identifiants Airboard sont conservées à 0 pour être refusées)
+func scimMemberIDs(members []models.SCIMMember) []uint {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		id, _ := parseSCIMID(member.Value)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// scimMemberValueIDs lit la valeur d'une opération sur members : [{"value": "42"}, ...]
+func scimMemberValueIDs(value interface{}) ([]uint, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		id, err := parseSCIMID(scimStringValue(item))
+		if err != nil {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "unknown member")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// scimMemberFilterIDs extrait les identifiants d'un filtre de membres (value eq "..." [or ...])
+func scimMemberFilterIDs(filter *scimFilter) ([]uint, error) {
+	switch {
+	case filter.op == "or":
+		var ids []uint
+		for _, child := range filter.children {
+			childIDs, err := scimMemberFilterIDs(child)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, childIDs...)
+		}
+		return ids, nil
+	case filter.op == "eq" && filter.attr == "value":
+		id, err := parseSCIMID(filter.value)
+		if err != nil {
+			// Un membre qui ne peut pas exister n'a rien à retirer
+			return nil, nil
+		}
+		return []uint{id}, nil
+	}
+	return nil, scimError(http.StatusBadRequest, "invalidFilter", "member filters only support value eq")
+}
+
+func withoutIDs(ids, excluded []uint) []uint {
+	result := ids[:0:0]
+	for _, id := range ids {
+		keep := true
+		for _, other := range excluded {
+			if id == other {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
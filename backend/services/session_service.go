@@ -0,0 +1,230 @@
+package services
+
+import (
+	"airboard/models"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Paramètres des sessions serveur
+const (
+	SessionTouchInterval    = 5 * time.Minute     // Fréquence maximale de mise à jour de last_seen_at
+	RevokedSessionRetention = 30 * 24 * time.Hour // Durée de conservation des sessions révoquées (historique)
+
+	SessionRevokedLogout        = "logout"
+	SessionRevokedLogoutAll     = "logout_all"
+	SessionRevokedByUser        = "revoked_by_user"
+	SessionRevokedReuse         = "refresh_token_reuse"
+	SessionRevokedDeactivated   = "user_deactivated"
+	SessionRevokedRoleChanged   = "role_changed"
+	SessionRevokedDeleted       = "user_deleted"
+	SessionRevokedPasswordReset = "password_reset"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionRevoked     = errors.New("session revoked or expired")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// SessionService gère les sessions de connexion persistées et la rotation des refresh tokens
+type SessionService struct {
+	db *gorm.DB
+}
+
+// NewSessionService crée une nouvelle instance du service de sessions
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// Create ouvre une session pour un utilisateur (RefreshTokenID est le jti de son premier refresh token)
+func (s *SessionService) Create(userID uint, ipAddress, userAgent string, ttl time.Duration) (*models.Session, error) {
+	tokenID, err := newSessionTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	session := models.Session{
+		UserID:         userID,
+		RefreshTokenID: tokenID,
+		Device:         DescribeUserAgent(userAgent),
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(ttl),
+	}
+	if err := s.db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Validate vérifie qu'une session est active pour un utilisateur et met à jour sa dernière activité
+func (s *SessionService) Validate(sessionID, userID uint, ipAddress string) error {
+	var session models.Session
+	if err := s.db.Select("id", "user_id", "last_seen_at", "expires_at", "revoked_at").
+		First(&session, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return ErrSessionRevoked
+	}
+
+	if time.Since(session.LastSeenAt) > SessionTouchInterval {
+		s.db.Model(&models.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip_address":   ipAddress,
+		})
+	}
+	return nil
+}
+
+// Rotate remplace le refresh token d'une session et retourne le nouveau jti.
+// Présenter un refresh token déjà remplacé signifie qu'il a été volé (ou rejoué) :
+// la session entière est alors révoquée.
+func (s *SessionService) Rotate(sessionID, userID uint, tokenID, ipAddress string, ttl time.Duration) (string, error) {
+	var session models.Session
+	if err := s.db.First(&session, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrSessionNotFound
+		}
+		return "", err
+	}
+	if session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return "", ErrSessionRevoked
+	}
+
+	newTokenID, err := newSessionTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	// Mise à jour conditionnelle : deux requêtes avec le même token ne peuvent pas toutes deux réussir
+	now := time.Now()
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_id = ? AND revoked_at IS NULL", session.ID, tokenID).
+		Updates(map[string]interface{}{
+			"refresh_token_id": newTokenID,
+			"last_seen_at":     now,
+			"ip_address":       ipAddress,
+			"expires_at":       now.Add(ttl),
+		})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		s.db.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", session.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": SessionRevokedReuse})
+		return "", ErrRefreshTokenReused
+	}
+	return newTokenID, nil
+}
+
+// ListActive retourne les sessions actives d'un utilisateur (la plus récente en premier)
+func (s *SessionService) ListActive(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke révoque une session d'un utilisateur
+func (s *SessionService) Revoke(userID, sessionID uint, reason string) error {
+	result := s.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAll révoque toutes les sessions actives d'un utilisateur et retourne leur nombre
+func (s *SessionService) RevokeAll(userID uint, reason string) (int64, error) {
+	return s.RevokeAllTx(s.db, userID, reason)
+}
+
+// RevokeAllTx révoque les sessions actives d'un utilisateur dans la transaction tx
+func (s *SessionService) RevokeAllTx(tx *gorm.DB, userID uint, reason string) (int64, error) {
+	result := tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// CleanupExpired supprime les sessions expirées et les sessions révoquées depuis plus de RevokedSessionRetention
+func (s *SessionService) CleanupExpired() (int64, error) {
+	now := time.Now()
+	result := s.db.Where("expires_at <= ? OR revoked_at <= ?", now, now.Add(-RevokedSessionRetention)).
+		Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// DescribeUserAgent résume un User-Agent en "Navigateur sur Système" pour la liste des sessions
+func DescribeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Appareil inconnu"
+	}
+
+	browser := "Navigateur inconnu"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	system := ""
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			system = o.name
+			break
+		}
+	}
+
+	if system == "" {
+		return browser
+	}
+	return browser + " sur " + system
+}
+
+func newSessionTokenID() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate session token id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
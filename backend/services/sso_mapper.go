@@ -13,13 +13,56 @@ import (
 type SSOMapper struct {
 	db     *gorm.DB
 	config *config.Config
+
+	provider    string   // Valeur de User.SSOProvider (preset du proxy SSO, ldap, saml, nom du fournisseur OAuth)
+	sourceLabel string   // Nom de la source dans la description des groupes créés
+	adminGroups []string // Groupes externes qui donnent le rôle admin
 }
 
-// NewSSOMapper crée une nouvelle instance de SSOMapper
+// NewSSOMapper crée un SSOMapper pour les utilisateurs authentifiés par les headers du proxy
+// d'authentification (Authentik par défaut, voir SSO_HEADER_PRESET)
 func NewSSOMapper(db *gorm.DB, cfg *config.Config) *SSOMapper {
 	return &SSOMapper{
-		db:     db,
-		config: cfg,
+		db:          db,
+		config:      cfg,
+		provider:    cfg.SSO.Headers.Provider,
+		sourceLabel: cfg.SSO.Headers.Label,
+		adminGroups: cfg.SSO.AdminGroups,
+	}
+}
+
+// NewLDAPMapper crée un SSOMapper pour les utilisateurs authentifiés par LDAP / Active Directory
+// (mêmes règles de mapping des groupes, groupes admin définis par LDAP_ADMIN_GROUPS)
+func NewLDAPMapper(db *gorm.DB, cfg *config.Config) *SSOMapper {
+	return &SSOMapper{
+		db:          db,
+		config:      cfg,
+		provider:    "ldap",
+		sourceLabel: "LDAP",
+		adminGroups: cfg.LDAP.AdminGroups,
+	}
+}
+
+// NewSAMLMapper crée un SSOMapper pour les utilisateurs authentifiés par un IdP SAML 2.0
+// (groupes admin définis par SAML_ADMIN_GROUPS)
+func NewSAMLMapper(db *gorm.DB, cfg *config.Config) *SSOMapper {
+	return &SSOMapper{
+		db:          db,
+		config:      cfg,
+		provider:    "saml",
+		sourceLabel: "SAML",
+		adminGroups: cfg.SAML.AdminGroups,
+	}
+}
+
+// NewOAuthMapper crée un SSOMapper pour les groupes transmis par un fournisseur OAuth / OpenID Connect
+func NewOAuthMapper(db *gorm.DB, cfg *config.Config, provider *models.OAuthProvider) *SSOMapper {
+	return &SSOMapper{
+		db:          db,
+		config:      cfg,
+		provider:    provider.ProviderName,
+		sourceLabel: provider.DisplayName,
+		adminGroups: splitGroups(provider.AdminGroups),
 	}
 }
 
@@ -31,6 +74,10 @@ type SSOUserInfo struct {
 	LastName  string
 	Groups    []string
 	SSOID     string
+	// Renseignés par l'annuaire LDAP (ignorés lorsqu'ils sont vides)
+	Phone      string
+	Department string
+	JobTitle   string
 }
 
 // SyncUser crée ou met à jour un utilisateur à partir des informations SSO
@@ -54,8 +101,11 @@ func (m *SSOMapper) SyncUser(info *SSOUserInfo) (*models.User, error) {
 			Password:    "", // Pas de mot de passe pour SSO
 			Role:        m.determineRole(info.Groups),
 			IsActive:    true,
-			SSOProvider: "authentik",
+			SSOProvider: m.provider,
 			SSOID:       info.SSOID,
+			Phone:       info.Phone,
+			Department:  info.Department,
+			JobTitle:    info.JobTitle,
 		}
 
 		if err := m.db.Create(&user).Error; err != nil {
@@ -64,6 +114,19 @@ func (m *SSOMapper) SyncUser(info *SSOUserInfo) (*models.User, error) {
 		}
 	} else if result.Error != nil {
 		return nil, result.Error
+	} else if user.SCIMManaged {
+		// Profil, état et groupes provisionnés par SCIM : la connexion ne fait que rattacher le compte
+		log.Printf("[SSO] Connexion d'un utilisateur provisionné par SCIM: %s (%s)", user.Username, user.Email)
+
+		user.SSOProvider = m.provider
+		user.SSOID = info.SSOID
+		if err := m.db.Model(&user).Updates(map[string]interface{}{
+			"sso_provider": user.SSOProvider,
+			"sso_id":       user.SSOID,
+		}).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
 	} else {
 		// Mettre à jour l'utilisateur existant
 		log.Printf("[SSO] Mise à jour de l'utilisateur: %s (%s)", info.Username, info.Email)
@@ -71,9 +134,18 @@ func (m *SSOMapper) SyncUser(info *SSOUserInfo) (*models.User, error) {
 		user.FirstName = info.FirstName
 		user.LastName = info.LastName
 		user.Role = m.determineRole(info.Groups)
-		user.SSOProvider = "authentik"
+		user.SSOProvider = m.provider
 		user.SSOID = info.SSOID
 		user.IsActive = true
+		if info.Phone != "" {
+			user.Phone = info.Phone
+		}
+		if info.Department != "" {
+			user.Department = info.Department
+		}
+		if info.JobTitle != "" {
+			user.JobTitle = info.JobTitle
+		}
 
 		if err := m.db.Save(&user).Error; err != nil {
 			log.Printf("[SSO] Erreur lors de la mise à jour de l'utilisateur: %v", err)
@@ -95,11 +167,27 @@ func (m *SSOMapper) SyncUser(info *SSOUserInfo) (*models.User, error) {
 	return &user, nil
 }
 
+// SyncGroups remplace les groupes d'un utilisateur existant par ceux transmis par le fournisseur et,
+// si des groupes admin sont configurés, met à jour son rôle (sauf pour les utilisateurs gérés par SCIM)
+func (m *SSOMapper) SyncGroups(user *models.User, groups []string) error {
+	if user.SCIMManaged {
+		return nil
+	}
+	if len(m.adminGroups) > 0 {
+		if role := m.determineRole(groups); role != user.Role {
+			if err := m.db.Model(user).Update("role", role).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return m.syncGroups(user, groups)
+}
+
 // determineRole détermine le rôle de l'utilisateur basé sur ses groupes Authentik
 func (m *SSOMapper) determineRole(authentikGroups []string) string {
 	// Vérifier si l'utilisateur appartient à un groupe admin
 	for _, authentikGroup := range authentikGroups {
-		for _, adminGroup := range m.config.SSO.AdminGroups {
+		for _, adminGroup := range m.adminGroups {
 			if strings.EqualFold(authentikGroup, adminGroup) {
 				log.Printf("[SSO] Utilisateur attribué au rôle admin (groupe: %s)", authentikGroup)
 				return "admin"
@@ -128,7 +216,7 @@ func (m *SSOMapper) syncGroups(user *models.User, authentikGroups []string) erro
 				log.Printf("[SSO] Création du groupe: %s (depuis %s)", groupName, authentikGroup)
 				group = models.Group{
 					Name:        groupName,
-					Description: "Synchronisé depuis Authentik",
+					Description: "Synchronisé depuis " + m.sourceLabel,
 					IsActive:    true,
 				}
 				if err := m.db.Create(&group).Error; err != nil {
@@ -199,3 +287,13 @@ func (m *SSOMapper) mapGroupName(authentikGroup string) string {
 
 	return groupName
 }
+
+func splitGroups(value string) []string {
+	var groups []string
+	for _, group := range strings.Split(value, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
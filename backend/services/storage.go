@@ -1,12 +1,14 @@
 package services
 
 import (
+	"airboard/config"
 	"context"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,7 +17,11 @@ import (
 // StorageService defines the interface for file storage operations
 type StorageService interface {
 	Upload(ctx context.Context, file multipart.File, fileHeader *multipart.FileHeader) (string, string, error)
+	PutObject(ctx context.Context, path string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, path string) (io.ReadCloser, error)
 	Delete(ctx context.Context, path string) error
+	List(ctx context.Context) ([]string, error)
+	TempDir() string
 	GetURL(path string) string
 	GetType() string
 }
@@ -76,6 +82,40 @@ func (ls *LocalStorage) Upload(ctx context.Context, file multipart.File, fileHea
 	return storagePath, url, nil
 }
 
+// PutObject writes the content of r at the given path (used for derived files like variants)
+func (ls *LocalStorage) PutObject(ctx context.Context, path string, r io.Reader, size int64, contentType string) error {
+	fullPath := filepath.Join(ls.uploadDir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
+
+// Open opens a stored file for streaming
+func (ls *LocalStorage) Open(ctx context.Context, path string) (*os.File, error) {
+	fullPath := filepath.Join(ls.uploadDir, filepath.FromSlash(path))
+	rel, err := filepath.Rel(ls.uploadDir, fullPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("invalid path: %s", path)
+	}
+	return os.Open(fullPath)
+}
+
+// Get opens a stored file for reading
+func (ls *LocalStorage) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	return ls.Open(ctx, path)
+}
+
 // Delete deletes a file from local storage
 func (ls *LocalStorage) Delete(ctx context.Context, path string) error {
 	fullPath := filepath.Join(ls.uploadDir, path)
@@ -85,6 +125,29 @@ func (ls *LocalStorage) Delete(ctx context.Context, path string) error {
 	return nil
 }
 
+// List returns the path of every stored file (forward slashes)
+func (ls *LocalStorage) List(ctx context.Context) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(ls.uploadDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(ls.uploadDir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return paths, nil
+}
+
 // GetURL returns the public URL for a file
 func (ls *LocalStorage) GetURL(path string) string {
 	// Always use forward slashes for URLs, even on Windows
@@ -92,20 +155,46 @@ func (ls *LocalStorage) GetURL(path string) string {
 	return fmt.Sprintf("/uploads/%s", urlPath)
 }
 
+// TempDir returns the local directory used for partial (resumable) uploads
+func (ls *LocalStorage) TempDir() string {
+	return filepath.Join(ls.uploadDir, ".tmp")
+}
+
 // GetType returns the storage type
 func (ls *LocalStorage) GetType() string {
 	return "local"
 }
 
-// TODO: Implement S3Storage for production use
-// type S3Storage struct {
-// 	bucket    string
-// 	region    string
-// 	client    *s3.Client
-// 	baseURL   string
-// }
-//
-// func NewS3Storage(bucket, region, endpoint, accessKey, secretKey string) (*S3Storage, error) {
-// 	// Implementation for S3/MinIO storage
-// 	return &S3Storage{}, nil
-// }
+// PresignedURLProvider is implemented by backends that can hand out
+// temporary direct download links (S3/MinIO)
+type PresignedURLProvider interface {
+	PresignedURL(ctx context.Context, path string) (string, error)
+}
+
+// FileOpener is implemented by backends whose files are streamed by the
+// server itself (local disk)
+type FileOpener interface {
+	Open(ctx context.Context, path string) (*os.File, error)
+}
+
+// AvatarKey returns the storage key of an uploaded avatar ("/uploads/avatars/..."),
+// or false for an external avatar URL (SSO provider, Gravatar...)
+func AvatarKey(avatarURL string) (string, bool) {
+	key, ok := strings.CutPrefix(avatarURL, "/uploads/")
+	if !ok || !strings.HasPrefix(key, "avatars/") {
+		return "", false
+	}
+	return key, true
+}
+
+// NewStorageService returns the storage backend selected by STORAGE_TYPE
+func NewStorageService(cfg *config.Config) (StorageService, error) {
+	switch cfg.Storage.Type {
+	case "", "local":
+		return NewLocalStorage(cfg.Storage.UploadDir, cfg.Storage.BaseURL)
+	case "s3", "minio":
+		return NewS3Storage(cfg.Storage)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Storage.Type)
+	}
+}
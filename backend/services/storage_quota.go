@@ -0,0 +1,254 @@
+package services
+
+import (
+	"airboard/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Storage quota scopes
+const (
+	QuotaScopeUser  = "user"  // a single user (overrides the role quota)
+	QuotaScopeRole  = "role"  // every user with the role
+	QuotaScopeGroup = "group" // combined usage of the admins of a group
+)
+
+// StorageUsage is the media storage used by a user or by the admins of a group
+type StorageUsage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// QuotaStatus is a quota that applies to a user, with the usage it is measured against
+type QuotaStatus struct {
+	Quota     models.StorageQuota `json:"quota"`
+	Usage     StorageUsage        `json:"usage"`
+	GroupName string              `json:"group_name,omitempty"`
+}
+
+// Exceeds reports whether adding one file of size bytes would go over the quota
+func (q QuotaStatus) Exceeds(size int64) bool {
+	if q.Quota.MaxBytes > 0 && q.Usage.Bytes+size > q.Quota.MaxBytes {
+		return true
+	}
+	return q.Quota.MaxFiles > 0 && q.Usage.Files+1 > int64(q.Quota.MaxFiles)
+}
+
+// QuotaExceededError is returned by StorageQuotaService.Check when an upload is over quota
+type QuotaExceededError struct {
+	Status QuotaStatus
+	Size   int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	target := "your account"
+	switch e.Status.Quota.Scope {
+	case QuotaScopeRole:
+		target = fmt.Sprintf("the %s role", e.Status.Quota.Role)
+	case QuotaScopeGroup:
+		target = fmt.Sprintf("group %q", e.Status.GroupName)
+	}
+
+	q, u := e.Status.Quota, e.Status.Usage
+	if q.MaxFiles > 0 && u.Files+1 > int64(q.MaxFiles) {
+		return fmt.Sprintf("Storage quota exceeded for %s: %d of %d files used", target, u.Files, q.MaxFiles)
+	}
+	return fmt.Sprintf("Storage quota exceeded for %s: %s used of %s, file is %s",
+		target, FormatBytes(u.Bytes), FormatBytes(q.MaxBytes), FormatBytes(e.Size))
+}
+
+// StorageConsumer is a row of the top users report
+type StorageConsumer struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Bytes    int64  `json:"bytes"`
+	Files    int64  `json:"files"`
+	MaxBytes int64  `json:"max_bytes"` // Effective quota (0 = unlimited)
+	MaxFiles int    `json:"max_files"`
+}
+
+// GroupStorageConsumer is a row of the top groups report
+type GroupStorageConsumer struct {
+	GroupID  uint   `json:"group_id"`
+	Name     string `json:"name"`
+	Bytes    int64  `json:"bytes"`
+	Files    int64  `json:"files"`
+	MaxBytes int64  `json:"max_bytes"`
+	MaxFiles int    `json:"max_files"`
+}
+
+// StorageQuotaService computes media storage usage from Media.FileSize and enforces quotas.
+// Deduplicated uploads count for every media, as each uploader "owns" a copy.
+type StorageQuotaService struct {
+	db *gorm.DB
+}
+
+// NewStorageQuotaService creates a new storage quota service
+func NewStorageQuotaService(db *gorm.DB) *StorageQuotaService {
+	return &StorageQuotaService{db: db}
+}
+
+// UserUsage returns the storage used by the media a user uploaded
+func (s *StorageQuotaService) UserUsage(userID uint) (StorageUsage, error) {
+	var usage StorageUsage
+	err := s.db.Model(&models.Media{}).
+		Select("COALESCE(SUM(file_size), 0) AS bytes, COUNT(*) AS files").
+		Where("uploaded_by = ?", userID).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// GroupUsage returns the storage used by the media uploaded by the admins of a group
+func (s *StorageQuotaService) GroupUsage(groupID uint) (StorageUsage, error) {
+	var usage StorageUsage
+	err := s.db.Model(&models.Media{}).
+		Select("COALESCE(SUM(file_size), 0) AS bytes, COUNT(*) AS files").
+		Where("uploaded_by IN (?)", s.db.Table("group_admins").Select("user_id").Where("group_id = ?", groupID)).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// Applicable returns the quotas that apply to a user with their current usage: the
+// user quota (or else the quota of their role) and the quotas of the groups they manage
+func (s *StorageQuotaService) Applicable(userID uint) ([]QuotaStatus, error) {
+	var user models.User
+	if err := s.db.Select("id", "role").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var statuses []QuotaStatus
+
+	var quota models.StorageQuota
+	err := s.db.Where("scope = ? AND target_id = ?", QuotaScopeUser, userID).First(&quota).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = s.db.Where("scope = ? AND role = ?", QuotaScopeRole, user.Role).First(&quota).Error
+	}
+	switch {
+	case err == nil:
+		usage, err := s.UserUsage(userID)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, QuotaStatus{Quota: quota, Usage: usage})
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	var groupQuotas []models.StorageQuota
+	if err := s.db.Where("scope = ? AND target_id IN (?)", QuotaScopeGroup,
+		s.db.Table("group_admins").Select("group_id").Where("user_id = ?", userID)).
+		Find(&groupQuotas).Error; err != nil {
+		return nil, err
+	}
+	for _, q := range groupQuotas {
+		usage, err := s.GroupUsage(q.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		var group models.Group
+		s.db.Select("id", "name").First(&group, q.TargetID)
+		statuses = append(statuses, QuotaStatus{Quota: q, Usage: usage, GroupName: group.Name})
+	}
+
+	return statuses, nil
+}
+
+// Check returns a *QuotaExceededError if storing one more file of size bytes would
+// exceed one of the quotas of the user
+func (s *StorageQuotaService) Check(userID uint, size int64) error {
+	statuses, err := s.Applicable(userID)
+	if err != nil {
+		return fmt.Errorf("failed to compute storage quota: %w", err)
+	}
+	for _, status := range statuses {
+		if status.Exceeds(size) {
+			return &QuotaExceededError{Status: status, Size: size}
+		}
+	}
+	return nil
+}
+
+// TopUsers returns the users using the most storage, with their effective quota
+func (s *StorageQuotaService) TopUsers(limit int) ([]StorageConsumer, error) {
+	var consumers []StorageConsumer
+	if err := s.db.Table("media").
+		Select("users.id AS user_id, users.username, users.email, users.role, SUM(media.file_size) AS bytes, COUNT(*) AS files").
+		Joins("JOIN users ON users.id = media.uploaded_by").
+		Where("media.deleted_at IS NULL").
+		Group("users.id, users.username, users.email, users.role").
+		Order("bytes DESC").
+		Limit(limit).
+		Scan(&consumers).Error; err != nil {
+		return nil, err
+	}
+
+	var quotas []models.StorageQuota
+	if err := s.db.Where("scope IN ?", []string{QuotaScopeUser, QuotaScopeRole}).Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	userQuotas := make(map[uint]models.StorageQuota)
+	roleQuotas := make(map[string]models.StorageQuota)
+	for _, q := range quotas {
+		if q.Scope == QuotaScopeUser {
+			userQuotas[q.TargetID] = q
+		} else {
+			roleQuotas[q.Role] = q
+		}
+	}
+	for i := range consumers {
+		q, ok := userQuotas[consumers[i].UserID]
+		if !ok {
+			q = roleQuotas[consumers[i].Role]
+		}
+		consumers[i].MaxBytes = q.MaxBytes
+		consumers[i].MaxFiles = q.MaxFiles
+	}
+	return consumers, nil
+}
+
+// TopGroups returns the managed groups whose admins use the most storage
+func (s *StorageQuotaService) TopGroups(limit int) ([]GroupStorageConsumer, error) {
+	var consumers []GroupStorageConsumer
+	if err := s.db.Table("group_admins").
+		Select("groups.id AS group_id, groups.name, SUM(media.file_size) AS bytes, COUNT(media.id) AS files").
+		Joins("JOIN groups ON groups.id = group_admins.group_id AND groups.deleted_at IS NULL").
+		Joins("JOIN media ON media.uploaded_by = group_admins.user_id AND media.deleted_at IS NULL").
+		Group("groups.id, groups.name").
+		Order("bytes DESC").
+		Limit(limit).
+		Scan(&consumers).Error; err != nil {
+		return nil, err
+	}
+
+	var quotas []models.StorageQuota
+	if err := s.db.Where("scope = ?", QuotaScopeGroup).Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	groupQuotas := make(map[uint]models.StorageQuota)
+	for _, q := range quotas {
+		groupQuotas[q.TargetID] = q
+	}
+	for i := range consumers {
+		consumers[i].MaxBytes = groupQuotas[consumers[i].GroupID].MaxBytes
+		consumers[i].MaxFiles = groupQuotas[consumers[i].GroupID].MaxFiles
+	}
+	return consumers, nil
+}
+
+// FormatBytes formats a size for error messages (1.5 MB)
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"airboard/config"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage implements file storage on an S3-compatible object store (AWS S3, MinIO...)
+type S3Storage struct {
+	client      *minio.Client
+	bucket      string
+	storageType string
+	urlExpiry   time.Duration
+	tempDir     string
+}
+
+// NewS3Storage creates a new S3/MinIO storage service
+func NewS3Storage(cfg config.StorageConfig) (*S3Storage, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required for %s storage", cfg.Type)
+	}
+
+	endpoint, secure, err := parseS3Endpoint(cfg.S3Endpoint, cfg.S3UseSSL)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.S3ForcePathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure:       secure,
+		Region:       cfg.S3Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// Create the bucket if it doesn't exist yet (typical for a fresh MinIO)
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", cfg.S3Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket %s: %w", cfg.S3Bucket, err)
+		}
+	}
+
+	storageType := cfg.Type
+	if storageType == "" {
+		storageType = "s3"
+	}
+
+	return &S3Storage{
+		client:      client,
+		bucket:      cfg.S3Bucket,
+		storageType: storageType,
+		urlExpiry:   time.Duration(cfg.S3URLExpiry) * time.Minute,
+		tempDir:     filepath.Join(cfg.UploadDir, ".tmp"),
+	}, nil
+}
+
+// parseS3Endpoint accepts either "host:port" or a full URL and returns the host and TLS flag
+func parseS3Endpoint(endpoint string, useSSL bool) (string, bool, error) {
+	if endpoint == "" {
+		return "s3.amazonaws.com", true, nil
+	}
+	if !strings.Contains(endpoint, "://") {
+		return strings.TrimSuffix(endpoint, "/"), useSSL, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid S3_ENDPOINT: %w", err)
+	}
+	if u.Host == "" {
+		return "", false, fmt.Errorf("invalid S3_ENDPOINT: missing host")
+	}
+	return u.Host, u.Scheme == "https", nil
+}
+
+// Upload uploads a file to the bucket
+func (s *S3Storage) Upload(ctx context.Context, file multipart.File, fileHeader *multipart.FileHeader) (string, string, error) {
+	// Same key layout as local storage: YYYY/MM/<timestamp>-<uuid><ext>
+	ext := path.Ext(fileHeader.Filename)
+	filename := fmt.Sprintf("%s-%s%s", time.Now().Format("20060102-150405"), uuid.New().String()[:8], ext)
+	key := path.Join(time.Now().Format("2006/01"), filename)
+
+	if err := s.PutObject(ctx, key, file, fileHeader.Size, fileHeader.Header.Get("Content-Type")); err != nil {
+		return "", "", err
+	}
+
+	return key, s.GetURL(key), nil
+}
+
+// PutObject stores the content of r under the given key
+func (s *S3Storage) PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	}); err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	return nil
+}
+
+// Get opens an object for reading
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	// GetObject is lazy: Stat surfaces missing keys right away
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	return obj, nil
+}
+
+// Delete deletes an object from the bucket
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// List returns the key of every object in the bucket
+func (s *S3Storage) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// GetURL returns the stable application URL for an object.
+// Requests on /uploads/* are redirected to a presigned URL, so stored
+// links (news content, covers, avatars) never expire.
+func (s *S3Storage) GetURL(key string) string {
+	return fmt.Sprintf("/uploads/%s", key)
+}
+
+// PresignedURL returns a temporary direct download URL for an object
+func (s *S3Storage) PresignedURL(ctx context.Context, key string) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.urlExpiry, url.Values{})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// TempDir returns the local directory used for partial (resumable) uploads
+// before they are pushed to the bucket
+func (s *S3Storage) TempDir() string {
+	return s.tempDir
+}
+
+// GetType returns the storage type
+func (s *S3Storage) GetType() string {
+	return s.storageType
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"airboard/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Paramètres de la connexion en deux étapes
+const (
+	RecoveryCodeCount      = 10
+	LoginChallengeTTL      = 5 * time.Minute
+	LoginChallengeAttempts = 5 // Codes erronés tolérés avant d'invalider le challenge
+
+	ChallengePurposeVerify = "verify"
+	ChallengePurposeSetup  = "setup"
+	// Mot de passe expiré ou à changer : le challenge est consommé par le changement de mot de passe
+	ChallengePurposePassword = "password"
+	// Retour de l'IdP SAML : le challenge transmis au navigateur est échangé contre les tokens
+	ChallengePurposeSAML = "saml"
+
+	TwoFactorMethodTOTP     = "totp"
+	TwoFactorMethodRecovery = "recovery_code"
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication setup not started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidLoginChallenge   = errors.New("invalid or expired login challenge")
+)
+
+// TwoFactorService gère la double authentification TOTP (RFC 6238) des comptes locaux
+type TwoFactorService struct {
+	db     *gorm.DB
+	config *config.Config
+}
+
+// NewTwoFactorService crée une nouvelle instance du service de double authentification
+func NewTwoFactorService(db *gorm.DB, cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// RequiredForRole indique si les paramètres de l'application imposent la double authentification à un rôle
+func (s *TwoFactorService) RequiredForRole(role string) bool {
+	var settings models.AppSettings
+	if err := s.db.Select("two_factor_required_roles").First(&settings).Error; err != nil {
+		return false
+	}
+	for _, r := range strings.Split(settings.TwoFactorRequiredRoles, ",") {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredFor indique si la double authentification est imposée à l'utilisateur. Les comptes
+// sans mot de passe local (LDAP, SSO) en sont exemptés : le second facteur relève de leur
+// fournisseur d'identité et ils ne peuvent pas enrôler de TOTP.
+func (s *TwoFactorService) RequiredFor(user *models.User) bool {
+	return user.Password != "" && s.RequiredForRole(user.Role)
+}
+
+// BeginSetup génère un nouveau secret (remplace un enrôlement non confirmé) et retourne
+// le secret en clair avec son URI otpauth://
+func (s *TwoFactorService) BeginSetup(user *models.User) (*models.TwoFactorSetupResponse, error) {
+	var existing models.TwoFactorAuth
+	err := s.db.Where("user_id = ?", user.ID).First(&existing).Error
+	if err == nil && existing.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptSecret(s.config.JWT.Secret, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.UserID = user.ID
+	existing.Secret = encrypted
+	existing.Enabled = false
+	existing.LastUsedStep = 0
+	if err := s.db.Save(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	issuer := "Airboard"
+	var settings models.AppSettings
+	if s.db.Select("app_name").First(&settings).Error == nil && settings.AppName != "" {
+		issuer = settings.AppName
+	}
+
+	return &models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPProvisioningURI(issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable confirme l'enrôlement avec un premier code et retourne les codes de récupération
+func (s *TwoFactorService) Enable(userID uint, code string) ([]string, error) {
+	var tfa models.TwoFactorAuth
+	if err := s.db.Where("user_id = ?", userID).First(&tfa).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTwoFactorNotSetUp
+		}
+		return nil, err
+	}
+	if tfa.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if err := s.checkTOTP(&tfa, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&tfa).Updates(map[string]interface{}{
+			"enabled":        true,
+			"enabled_at":     now,
+			"last_used_step": tfa.LastUsedStep,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify vérifie un code TOTP ou un code de récupération (consommé) et retourne la méthode utilisée
+func (s *TwoFactorService) Verify(userID uint, code string) (string, error) {
+	var tfa models.TwoFactorAuth
+	if err := s.db.Where("user_id = ? AND enabled = ?", userID, true).First(&tfa).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrTwoFactorNotEnabled
+		}
+		return "", err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(strings.ReplaceAll(code, " ", "")) == utils.TOTPDigits {
+		if err := s.checkTOTP(&tfa, code); err != nil {
+			return "", err
+		}
+		// Conditionnel pour qu'un même code ne passe pas deux fois en requêtes concurrentes
+		result := s.db.Model(&models.TwoFactorAuth{}).
+			Where("id = ? AND last_used_step < ?", tfa.ID, tfa.LastUsedStep).
+			Update("last_used_step", tfa.LastUsedStep)
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected == 0 {
+			return "", ErrInvalidTwoFactorCode
+		}
+		return TwoFactorMethodTOTP, nil
+	}
+
+	// Code de récupération : marqué utilisé de façon atomique (usage unique même en cas de requêtes concurrentes)
+	result := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", ErrInvalidTwoFactorCode
+	}
+	return TwoFactorMethodRecovery, nil
+}
+
+// RegenerateRecoveryCodes invalide les anciens codes de récupération et en génère de nouveaux
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID uint) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// Disable supprime le secret et les codes de récupération d'un utilisateur
+func (s *TwoFactorService) Disable(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorAuth{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.LoginChallenge{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", false).Error
+	})
+}
+
+// Status retourne l'état de la double authentification d'un utilisateur
+func (s *TwoFactorService) Status(user *models.User) (*models.TwoFactorStatusResponse, error) {
+	status := &models.TwoFactorStatusResponse{
+		Required: s.RequiredFor(user),
+	}
+
+	var tfa models.TwoFactorAuth
+	err := s.db.Where("user_id = ? AND enabled = ?", user.ID, true).First(&tfa).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled = true
+	status.EnabledAt = tfa.EnabledAt
+	if err := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&status.RecoveryCodesRemaining).Error; err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// CreateChallenge crée le challenge d'une connexion en attente du second facteur et retourne son token
+func (s *TwoFactorService) CreateChallenge(userID uint, purpose string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate challenge token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	challenge := models.LoginChallenge{
+		TokenHash: hashChallengeToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(LoginChallengeTTL),
+	}
+	if err := s.db.Create(&challenge).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetChallenge retourne un challenge valide (non expiré, tentatives restantes)
+func (s *TwoFactorService) GetChallenge(token string) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	if err := s.db.Where("token_hash = ? AND expires_at > ? AND attempts < ?",
+		hashChallengeToken(token), time.Now(), LoginChallengeAttempts).
+		First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidLoginChallenge
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RecordChallengeFailure compte un code erroné ; le challenge devient inutilisable après LoginChallengeAttempts échecs
+func (s *TwoFactorService) RecordChallengeFailure(challenge *models.LoginChallenge) {
+	s.db.Model(challenge).UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+}
+
+// DeleteChallenge supprime un challenge utilisé
+func (s *TwoFactorService) DeleteChallenge(challenge *models.LoginChallenge) {
+	s.db.Delete(challenge)
+}
+
+// CleanupExpiredChallenges supprime les challenges expirés ou épuisés
+func (s *TwoFactorService) CleanupExpiredChallenges() (int64, error) {
+	result := s.db.Where("expires_at <= ? OR attempts >= ?", time.Now(), LoginChallengeAttempts).
+		Delete(&models.LoginChallenge{})
+	return result.RowsAffected, result.Error
+}
+
+// checkTOTP vérifie un code contre le secret et met à jour LastUsedStep (sans sauvegarder)
+func (s *TwoFactorService) checkTOTP(tfa *models.TwoFactorAuth, code string) error {
+	secret, err := utils.DecryptSecret(s.config.JWT.Secret, tfa.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), tfa.LastUsedStep)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	tfa.LastUsedStep = step
+	return nil
+}
+
+// replaceRecoveryCodes remplace les codes de récupération d'un utilisateur et retourne les codes en clair
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	records := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: utils.HashRecoveryCode(code)}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func hashChallengeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"airboard/config"
+	"airboard/models"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+)
+
+// Paramètres des cérémonies WebAuthn
+const (
+	WebAuthnSessionTTL = 5 * time.Minute
+
+	WebAuthnPurposeRegistration = "registration"
+	WebAuthnPurposeLogin        = "login"
+)
+
+var (
+	ErrInvalidWebAuthnSession  = errors.New("invalid or expired webauthn session")
+	ErrWebAuthnCredentialClone = errors.New("webauthn credential sign counter went backwards")
+	ErrWebAuthnUserNotFound    = errors.New("no active user for this passkey")
+)
+
+// WebAuthnService gère l'enregistrement des passkeys et la connexion par passkey
+type WebAuthnService struct {
+	db       *gorm.DB
+	webauthn *webauthn.WebAuthn
+}
+
+// NewWebAuthnService crée le service WebAuthn à partir du RP ID et des origines configurés
+func NewWebAuthnService(db *gorm.DB, cfg *config.Config) (*WebAuthnService, error) {
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid webauthn configuration: %w", err)
+	}
+	return &WebAuthnService{
+		db:       db,
+		webauthn: wa,
+	}, nil
+}
+
+// webAuthnUser adapte models.User à l'interface webauthn.User
+type webAuthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+// WebAuthnID retourne le user handle (identifiant opaque stocké dans la passkey)
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return webAuthnUserHandle(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	name := strings.TrimSpace(u.user.FirstName + " " + u.user.LastName)
+	if name == "" {
+		return u.user.Username
+	}
+	return name
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// BeginRegistration démarre l'enregistrement d'une passkey (les passkeys existantes sont exclues)
+func (s *WebAuthnService) BeginRegistration(user *models.User, name string) (*protocol.CredentialCreation, string, error) {
+	waUser, err := s.loadUser(user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creation, session, err := s.webauthn.BeginRegistration(waUser,
+		webauthn.WithExclusions(webauthn.Credentials(waUser.credentials).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyNotRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementPreferred,
+			UserVerification:   protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.saveSession(user.ID, WebAuthnPurposeRegistration, name, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, sessionID, nil
+}
+
+// FinishRegistration vérifie la réponse de l'authentificateur et enregistre la passkey
+func (s *WebAuthnService) FinishRegistration(user *models.User, sessionID string, r *http.Request) (*models.WebAuthnCredential, error) {
+	record, session, err := s.consumeSession(sessionID, WebAuthnPurposeRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID != user.ID {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	waUser, err := s.loadUser(user)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := s.webauthn.FinishRegistration(waUser, *session, r)
+	if err != nil {
+		return nil, err
+	}
+
+	name := record.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	transports := make([]string, len(credential.Transport))
+	for i, t := range credential.Transport {
+		transports[i] = string(t)
+	}
+
+	stored := models.WebAuthnCredential{
+		UserID:          user.ID,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Flags:           uint8(credential.Flags.ProtocolValue()),
+		Name:            name,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := s.db.Create(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// BeginLogin démarre une connexion par passkey. Sans identifiant, la connexion est découvrable :
+// le navigateur propose les passkeys enregistrées pour ce domaine.
+func (s *WebAuthnService) BeginLogin(username string) (*protocol.CredentialAssertion, string, error) {
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		err       error
+		userID    uint
+	)
+
+	if username == "" {
+		assertion, session, err = s.webauthn.BeginDiscoverableLogin(
+			webauthn.WithUserVerification(protocol.VerificationRequired),
+		)
+	} else {
+		// Identifiant inconnu ou sans passkey : repli sur une connexion découvrable
+		var user models.User
+		var waUser *webAuthnUser
+		if s.db.Where("username = ? OR email = ?", username, username).First(&user).Error == nil {
+			waUser, err = s.loadUser(&user)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		if waUser != nil && len(waUser.credentials) > 0 {
+			userID = user.ID
+			assertion, session, err = s.webauthn.BeginLogin(waUser,
+				webauthn.WithUserVerification(protocol.VerificationRequired),
+			)
+		} else {
+			assertion, session, err = s.webauthn.BeginDiscoverableLogin(
+				webauthn.WithUserVerification(protocol.VerificationRequired),
+			)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := s.saveSession(userID, WebAuthnPurposeLogin, "", session)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, sessionID, nil
+}
+
+// FinishLogin vérifie l'assertion, met à jour le compteur de la passkey et retourne l'utilisateur
+func (s *WebAuthnService) FinishLogin(sessionID string, r *http.Request) (*models.User, error) {
+	record, session, err := s.consumeSession(sessionID, WebAuthnPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		user       *models.User
+		credential *webauthn.Credential
+	)
+	if record.UserID != 0 {
+		var u models.User
+		if err := s.db.Where("id = ? AND is_active = ?", record.UserID, true).First(&u).Error; err != nil {
+			return nil, ErrWebAuthnUserNotFound
+		}
+		waUser, err := s.loadUser(&u)
+		if err != nil {
+			return nil, err
+		}
+		credential, err = s.webauthn.FinishLogin(waUser, *session, r)
+		if err != nil {
+			return nil, err
+		}
+		user = &u
+	} else {
+		var waUser webauthn.User
+		waUser, credential, err = s.webauthn.FinishPasskeyLogin(s.discoverUser, *session, r)
+		if err != nil {
+			return nil, err
+		}
+		user = waUser.(*webAuthnUser).user
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return nil, ErrWebAuthnCredentialClone
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&models.WebAuthnCredential{}).
+		Where("user_id = ? AND credential_id = ?", user.ID, base64.RawURLEncoding.EncodeToString(credential.ID)).
+		Updates(map[string]interface{}{
+			"sign_count":   credential.Authenticator.SignCount,
+			"flags":        uint8(credential.Flags.ProtocolValue()),
+			"backup_state": credential.Flags.BackupState,
+			"last_used_at": now,
+		}).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListCredentials retourne les passkeys d'un utilisateur
+func (s *WebAuthnService) ListCredentials(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := s.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&credentials).Error
+	return credentials, err
+}
+
+// DeleteCredential révoque une passkey d'un utilisateur
+func (s *WebAuthnService) DeleteCredential(userID, credentialID uint) error {
+	result := s.db.Where("id = ? AND user_id = ?", credentialID, userID).Delete(&models.WebAuthnCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CleanupExpiredSessions supprime les cérémonies WebAuthn expirées
+func (s *WebAuthnService) CleanupExpiredSessions() (int64, error) {
+	result := s.db.Where("expires_at <= ?", time.Now()).Delete(&models.WebAuthnSession{})
+	return result.RowsAffected, result.Error
+}
+
+// discoverUser retrouve l'utilisateur d'une passkey découvrable à partir de son user handle
+func (s *WebAuthnService) discoverUser(rawID, userHandle []byte) (webauthn.User, error) {
+	id, err := strconv.ParseUint(string(userHandle), 10, 64)
+	if err != nil {
+		return nil, ErrWebAuthnUserNotFound
+	}
+	var user models.User
+	if err := s.db.Where("id = ? AND is_active = ?", id, true).First(&user).Error; err != nil {
+		return nil, ErrWebAuthnUserNotFound
+	}
+	return s.loadUser(&user)
+}
+
+// loadUser charge les passkeys d'un utilisateur au format de la bibliothèque WebAuthn
+func (s *WebAuthnService) loadUser(user *models.User) (*webAuthnUser, error) {
+	stored, err := s.ListCredentials(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, c := range stored {
+		id, err := base64.RawURLEncoding.DecodeString(c.CredentialID)
+		if err != nil {
+			continue
+		}
+		var transports []protocol.AuthenticatorTransport
+		for _, t := range strings.Split(c.Transports, ",") {
+			if t != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(t))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(c.Flags)),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// saveSession conserve l'état d'une cérémonie et retourne l'identifiant à présenter à l'étape finish
+func (s *WebAuthnService) saveSession(userID uint, purpose, name string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate webauthn session id: %w", err)
+	}
+	sessionID := base64.RawURLEncoding.EncodeToString(raw)
+
+	record := models.WebAuthnSession{
+		TokenHash: hashChallengeToken(sessionID),
+		UserID:    userID,
+		Purpose:   purpose,
+		Name:      name,
+		Data:      string(data),
+		ExpiresAt: time.Now().Add(WebAuthnSessionTTL),
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// consumeSession récupère et supprime une cérémonie (usage unique, même en cas d'échec de vérification)
+func (s *WebAuthnService) consumeSession(sessionID, purpose string) (*models.WebAuthnSession, *webauthn.SessionData, error) {
+	if sessionID == "" {
+		return nil, nil, ErrInvalidWebAuthnSession
+	}
+
+	var record models.WebAuthnSession
+	if err := s.db.Where("token_hash = ? AND purpose = ? AND expires_at > ?",
+		hashChallengeToken(sessionID), purpose, time.Now()).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidWebAuthnSession
+		}
+		return nil, nil, err
+	}
+
+	// Suppression conditionnelle : une même session ne peut être utilisée que par une requête
+	result := s.db.Delete(&models.WebAuthnSession{}, record.ID)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrInvalidWebAuthnSession
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(record.Data), &session); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode webauthn session: %w", err)
+	}
+	return &record, &session, nil
+}
+
+// webAuthnUserHandle dérive le user handle WebAuthn de l'identifiant de l'utilisateur
+func webAuthnUserHandle(userID uint) []byte {
+	return []byte(strconv.FormatUint(uint64(userID), 10))
+}
@@ -4,17 +4,17 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 )
 
 // AuthSecurityManager gère la sécurité d'authentification
 type AuthSecurityManager struct {
-	failedAttempts  map[string]FailedAttempt // IP/username -> FailedAttempt
-	mu              sync.RWMutex
+	store           StateStore // Tentatives échouées (clé "lockout:" + IP/username)
 	lockoutDuration time.Duration
 	maxAttempts     int
 }
@@ -27,7 +27,8 @@ type FailedAttempt struct {
 	LockedUntil time.Time
 }
 
-// PasswordPolicy définit la politique de mots de passe
+// PasswordPolicy définit la politique de mots de passe (paramétrée par les administrateurs
+// dans AppSettings, voir services.PasswordPolicyService)
 type PasswordPolicy struct {
 	MinLength      int
 	MaxLength      int
@@ -37,20 +38,14 @@ type PasswordPolicy struct {
 	RequireSpecial bool
 	DisallowCommon bool
 	BannedWords    []string
+	CheckBreached  bool          // Refuser les mots de passe de la liste des mots de passe compromis
+	HistoryCount   int           // Nombre de mots de passe précédents non réutilisables (0 = désactivé)
+	MaxAge         time.Duration // Durée de validité d'un mot de passe (0 = illimitée)
 }
 
-// NewAuthSecurityManager crée un nouveau gestionnaire de sécurité d'authentification
-func NewAuthSecurityManager() *AuthSecurityManager {
-	return &AuthSecurityManager{
-		failedAttempts:  make(map[string]FailedAttempt),
-		lockoutDuration: 30 * time.Minute, // 30 minutes de lockout
-		maxAttempts:     5,                // 5 tentatives max
-	}
-}
-
-// ValidatePassword valide un mot de passe selon la politique
-func (asm *AuthSecurityManager) ValidatePassword(password string) error {
-	policy := &PasswordPolicy{
+// DefaultPasswordPolicy retourne la politique appliquée tant qu'aucun paramètre n'est enregistré
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
 		MinLength:      8,
 		MaxLength:      128,
 		RequireUpper:   true,
@@ -62,8 +57,29 @@ func (asm *AuthSecurityManager) ValidatePassword(password string) error {
 			"password", "admin", "airboard", "user", "123456",
 			"qwerty", "letmein", "welcome", "monkey", "dragon",
 		},
+		CheckBreached: true,
 	}
+}
+
+// NewAuthSecurityManager crée un gestionnaire dont les verrouillages restent en mémoire
+func NewAuthSecurityManager() *AuthSecurityManager {
+	return NewAuthSecurityManagerWithStore(NewMemoryStateStore())
+}
 
+// NewAuthSecurityManagerWithStore crée un gestionnaire dont les verrouillages sont conservés dans store
+func NewAuthSecurityManagerWithStore(store StateStore) *AuthSecurityManager {
+	return &AuthSecurityManager{
+		store:           store,
+		lockoutDuration: 30 * time.Minute, // 30 minutes de lockout
+		maxAttempts:     5,                // 5 tentatives max
+	}
+}
+
+// ValidatePassword valide un mot de passe selon la politique (politique par défaut si nil)
+func (asm *AuthSecurityManager) ValidatePassword(password string, policy *PasswordPolicy) error {
+	if policy == nil {
+		policy = DefaultPasswordPolicy()
+	}
 	return asm.validatePasswordWithPolicy(password, policy)
 }
 
@@ -119,6 +135,11 @@ func (asm *AuthSecurityManager) validatePasswordWithPolicy(password string, poli
 		}
 	}
 
+	// Vérifier la liste des mots de passe compromis
+	if policy.CheckBreached && IsBreachedPassword(password) {
+		return fmt.Errorf("mot de passe présent dans une liste de mots de passe compromis")
+	}
+
 	// Vérifier les patterns dangereux
 	dangerousPatterns := []string{
 		"(.)\\1{2,}",                            // Caractères répétés 3+ fois
@@ -136,78 +157,73 @@ func (asm *AuthSecurityManager) validatePasswordWithPolicy(password string, poli
 	return nil
 }
 
-// CheckFailedLogin vérifie si une tentative de connexion a échoué
+// CheckFailedLogin indique si un identifiant est verrouillé et pour combien de temps
 func (asm *AuthSecurityManager) CheckFailedLogin(identifier string) (bool, time.Duration) {
-	asm.mu.RLock()
-	defer asm.mu.RUnlock()
-
-	attempt, exists := asm.failedAttempts[identifier]
-	if !exists {
+	value, err := asm.store.Get(failedLoginKey(identifier))
+	if err != nil {
+		log.Printf("[Security] Erreur lors de la lecture des tentatives échouées: %v", err)
 		return false, 0
 	}
-
-	// Vérifier si encore locké
-	if time.Now().Before(attempt.LockedUntil) {
-		remaining := attempt.LockedUntil.Sub(time.Now())
-		return true, remaining
+	if value == nil {
+		return false, 0
 	}
 
-	// Si le lockout a expiré, nettoyer
-	if time.Now().After(attempt.LockedUntil) {
-		delete(asm.failedAttempts, identifier)
+	var attempt FailedAttempt
+	if err := json.Unmarshal(value, &attempt); err != nil {
+		return false, 0
+	}
+	if remaining := time.Until(attempt.LockedUntil); remaining > 0 {
+		return true, remaining
 	}
-
 	return false, 0
 }
 
-// RecordFailedLogin enregistre une tentative de connexion échouée
+// RecordFailedLogin enregistre une tentative de connexion échouée. Les tentatives sont
+// comptées tant qu'elles se suivent à moins de lockoutDuration d'intervalle.
 func (asm *AuthSecurityManager) RecordFailedLogin(identifier string) (bool, time.Duration) {
-	asm.mu.Lock()
-	defer asm.mu.Unlock()
-
-	now := time.Now()
-	attempt, exists := asm.failedAttempts[identifier]
-
-	if !exists {
-		attempt = FailedAttempt{
-			Count:   1,
-			FirstAt: now,
-			LastAt:  now,
-		}
-	} else {
-		// Réinitialiser si le lockout a expiré
-		if now.After(attempt.LockedUntil) {
-			attempt = FailedAttempt{
-				Count:   1,
-				FirstAt: now,
-				LastAt:  now,
+	var attempt FailedAttempt
+	err := asm.store.Update(failedLoginKey(identifier), asm.lockoutDuration, func(current []byte) ([]byte, error) {
+		now := time.Now()
+		attempt = FailedAttempt{}
+		if current != nil {
+			if err := json.Unmarshal(current, &attempt); err != nil {
+				attempt = FailedAttempt{}
 			}
-		} else {
-			attempt.Count++
-			attempt.LastAt = now
 		}
-	}
 
-	// Appliquer le lockout si nécessaire
-	if attempt.Count >= asm.maxAttempts {
-		attempt.LockedUntil = now.Add(asm.lockoutDuration)
-	}
-
-	asm.failedAttempts[identifier] = attempt
+		// Repartir de zéro après la fin d'un verrouillage
+		if attempt.Count == 0 || (!attempt.LockedUntil.IsZero() && now.After(attempt.LockedUntil)) {
+			attempt = FailedAttempt{FirstAt: now}
+		}
+		attempt.Count++
+		attempt.LastAt = now
 
-	if attempt.Count >= asm.maxAttempts {
-		return true, asm.lockoutDuration
+		// Appliquer le lockout si nécessaire
+		if attempt.Count >= asm.maxAttempts && attempt.LockedUntil.IsZero() {
+			attempt.LockedUntil = now.Add(asm.lockoutDuration)
+		}
+		return json.Marshal(attempt)
+	})
+	if err != nil {
+		log.Printf("[Security] Erreur lors de l'enregistrement d'une tentative échouée: %v", err)
+		return false, 0
 	}
 
+	if remaining := time.Until(attempt.LockedUntil); remaining > 0 {
+		return true, remaining
+	}
 	return false, 0
 }
 
 // RecordSuccessfulLogin enregistre une connexion réussie et nettoie les tentatives échouées
 func (asm *AuthSecurityManager) RecordSuccessfulLogin(identifier string) {
-	asm.mu.Lock()
-	defer asm.mu.Unlock()
+	if err := asm.store.Delete(failedLoginKey(identifier)); err != nil {
+		log.Printf("[Security] Erreur lors du nettoyage des tentatives échouées: %v", err)
+	}
+}
 
-	delete(asm.failedAttempts, identifier)
+func failedLoginKey(identifier string) string {
+	return "lockout:" + identifier
 }
 
 // GenerateSecureSecret génère un secret JWT sécurisé
@@ -342,33 +358,3 @@ func (asm *AuthSecurityManager) GenerateSecureToken(length int) (string, error)
 
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
-
-// CleanupFailedAttempts nettoie les tentatives échouées expirées
-func (asm *AuthSecurityManager) CleanupFailedAttempts() {
-	asm.mu.Lock()
-	defer asm.mu.Unlock()
-
-	now := time.Now()
-	for identifier, attempt := range asm.failedAttempts {
-		// Supprimer les tentatives de plus de 24h
-		if now.Sub(attempt.FirstAt) > 24*time.Hour {
-			delete(asm.failedAttempts, identifier)
-		}
-	}
-}
-
-// GetAuthStats retourne des statistiques d'authentification
-func (asm *AuthSecurityManager) GetAuthStats() (total, locked, attempts int) {
-	asm.mu.RLock()
-	defer asm.mu.RUnlock()
-
-	now := time.Now()
-	for _, attempt := range asm.failedAttempts {
-		total++
-		if now.Before(attempt.LockedUntil) {
-			locked++
-		}
-		attempts += attempt.Count
-	}
-	return
-}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+// Liste embarquée des mots de passe les plus fréquents dans les fuites de données publiques
+//
+//go:embed breached_passwords.txt
+var breachedPasswordList string
+
+var (
+	breachedPasswords     map[string]struct{}
+	breachedPasswordsOnce sync.Once
+)
+
+// IsBreachedPassword indique si un mot de passe figure dans la liste embarquée (insensible à la casse)
+func IsBreachedPassword(password string) bool {
+	breachedPasswordsOnce.Do(func() {
+		breachedPasswords = make(map[string]struct{})
+		for _, line := range strings.Split(breachedPasswordList, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			breachedPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	})
+
+	_, found := breachedPasswords[strings.ToLower(password)]
+	return found
+}
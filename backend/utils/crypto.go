@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// encryptionKey dérive la clé AES-256 du secret JWT (32 premiers octets, complétés si trop court)
+func encryptionKey(secret string) []byte {
+	if len(secret) < 32 {
+		secret = secret + strings.Repeat("0", 32-len(secret))
+	}
+	return []byte(secret[:32])
+}
+
+// EncryptSecret chiffre une valeur sensible stockée en base (tokens OAuth, secrets TOTP...)
+// avec AES-256 à partir du secret JWT. Le résultat est encodé en base64 (IV inclus).
+func EncryptSecret(secret, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	block, err := aes.NewCipher(encryptionKey(secret))
+	if err != nil {
+		return "", fmt.Errorf("erreur création cipher: %w", err)
+	}
+
+	ciphertext := make([]byte, aes.BlockSize+len(plaintext))
+	iv := ciphertext[:aes.BlockSize]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return "", fmt.Errorf("erreur génération IV: %w", err)
+	}
+
+	stream := cipher.NewCFBEncrypter(block, iv)
+	stream.XORKeyStream(ciphertext[aes.BlockSize:], []byte(plaintext))
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptSecret déchiffre une valeur chiffrée avec EncryptSecret
+func DecryptSecret(secret, encrypted string) (string, error) {
+	if encrypted == "" {
+		return "", nil
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("erreur décodage base64: %w", err)
+	}
+
+	block, err := aes.NewCipher(encryptionKey(secret))
+	if err != nil {
+		return "", fmt.Errorf("erreur création cipher: %w", err)
+	}
+
+	if len(ciphertext) < aes.BlockSize {
+		return "", fmt.Errorf("ciphertext trop court")
+	}
+
+	iv := ciphertext[:aes.BlockSize]
+	ciphertext = ciphertext[aes.BlockSize:]
+
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(ciphertext, ciphertext)
+
+	return string(ciphertext), nil
+}
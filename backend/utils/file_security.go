@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -21,6 +23,10 @@ type FileSecurityResult struct {
 	SafeExt    string
 	Reason     string
 	ScanNeeded bool
+
+	// Analyse antivirus (si un scanner est configuré)
+	Scan       *ScanResult
+	Quarantine bool // Fichier à conserver hors ligne pour examen (menace ou analyse impossible)
 }
 
 // SecureFileValidator validateur sécurisé de fichiers
@@ -29,6 +35,8 @@ type SecureFileValidator struct {
 	allowedMIMETypes  map[string]bool
 	maxFileSize       int64
 	forbiddenPatterns []*regexp.Regexp
+	scanner           Scanner // nil = pas d'analyse antivirus
+	scanFailOpen      bool    // Accepter les fichiers quand l'analyse échoue
 }
 
 // NewSecureFileValidator crée un nouveau validateur sécurisé
@@ -134,12 +142,51 @@ func (v *SecureFileValidator) ValidateSecureFile(file multipart.File, fileHeader
 		}
 	}
 
-	return &FileSecurityResult{
+	// 9. Analyse antivirus
+	file.Seek(0, 0)
+	scan := v.ScanContent(file)
+	file.Seek(0, 0)
+
+	result := &FileSecurityResult{
 		IsValid:  true,
 		SafeMIME: mimeType,
 		SafeExt:  ext,
 		Reason:   "Fichier valide",
+		Scan:     scan,
 	}
+	switch {
+	case scan.Status == ScanStatusInfected:
+		result.IsValid = false
+		result.Quarantine = true
+		result.Reason = fmt.Sprintf("Menace détectée: %s", scan.Signature)
+	case scan.Status == ScanStatusError && !v.scanFailOpen:
+		result.IsValid = false
+		result.Quarantine = true
+		result.Reason = "Analyse antivirus impossible, fichier mis en quarantaine"
+	}
+	return result
+}
+
+// SetScanner active l'analyse antivirus. Avec failOpen, un fichier dont l'analyse
+// échoue est accepté (statut "error") au lieu d'être mis en quarantaine.
+func (v *SecureFileValidator) SetScanner(scanner Scanner, failOpen bool) {
+	v.scanner = scanner
+	v.scanFailOpen = failOpen
+}
+
+// ScanContent analyse un contenu avec le scanner configuré (jamais d'erreur :
+// un échec d'analyse est retourné avec le statut "error")
+func (v *SecureFileValidator) ScanContent(r io.Reader) *ScanResult {
+	if v.scanner == nil {
+		return &ScanResult{Status: ScanStatusSkipped}
+	}
+
+	result, err := v.scanner.Scan(context.Background(), r)
+	if err != nil {
+		log.Printf("[Antivirus] Échec de l'analyse: %v", err)
+		return &ScanResult{Status: ScanStatusError, ScannedAt: time.Now()}
+	}
+	return result
 }
 
 // isValidFilename vérifie si le nom de fichier est sûr
@@ -264,6 +311,11 @@ func (v *SecureFileValidator) generateShortHash(data string) string {
 	return hex.EncodeToString(hash[:])[:8]
 }
 
+// MaxFileSize retourne la taille maximale autorisée (en octets)
+func (v *SecureFileValidator) MaxFileSize() int64 {
+	return v.maxFileSize
+}
+
 // GetAllowedFileTypes retourne la liste des types de fichiers autorisés
 func (v *SecureFileValidator) GetAllowedFileTypes() []string {
 	types := make([]string, 0, len(v.allowedExtensions))
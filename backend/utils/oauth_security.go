@@ -2,120 +2,134 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"strings"
-	"sync"
 	"time"
 )
 
+// OAuthStateTTL est la durée de validité d'un état OAuth
+const OAuthStateTTL = 10 * time.Minute
+
 // OAuthStateManager gère les états OAuth pour la protection CSRF
 type OAuthStateManager struct {
-	states map[string]OAuthState // state -> OAuthState
-	mu     sync.RWMutex
+	store StateStore // États en attente de callback (clé "oauth_state:" + state)
 }
 
 // OAuthState représente un état OAuth avec son expiration
 type OAuthState struct {
-	Provider  string    `json:"provider"`
-	ClientID  string    `json:"client_id"`
-	Nonce     string    `json:"nonce"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
-	Used      bool      `json:"used"`
+	Provider string `json:"provider"`
+	ClientID string `json:"client_id"`
+	Nonce    string `json:"nonce"`
+	// CodeVerifier est le secret PKCE (RFC 7636) présenté à l'échange du code
+	CodeVerifier string    `json:"code_verifier"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Used         bool      `json:"used"`
 }
 
-// NewOAuthStateManager crée un nouveau gestionnaire d'états OAuth
-func NewOAuthStateManager() *OAuthStateManager {
-	return &OAuthStateManager{
-		states: make(map[string]OAuthState),
-	}
+// NewOAuthStateManager crée un nouveau gestionnaire d'états OAuth. Le store doit être
+// partagé entre instances pour que le callback puisse arriver sur n'importe laquelle.
+func NewOAuthStateManager(store StateStore) *OAuthStateManager {
+	return &OAuthStateManager{store: store}
 }
 
-// GenerateState génère un nouvel état OAuth sécurisé
-func (osm *OAuthStateManager) GenerateState(provider, clientID string) (string, string, error) {
+// GenerateState génère un nouvel état OAuth sécurisé (state, nonce et code verifier PKCE)
+func (osm *OAuthStateManager) GenerateState(provider, clientID string) (string, *OAuthState, error) {
 	// Générer un state aléatoire
 	stateBytes := make([]byte, 32)
 	if _, err := rand.Read(stateBytes); err != nil {
-		return "", "", fmt.Errorf("failed to generate random state: %w", err)
+		return "", nil, fmt.Errorf("failed to generate random state: %w", err)
 	}
 	state := base64.URLEncoding.EncodeToString(stateBytes)
 
 	// Générer un nonce pour une protection supplémentaire
 	nonceBytes := make([]byte, 16)
 	if _, err := rand.Read(nonceBytes); err != nil {
-		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 	nonce := base64.URLEncoding.EncodeToString(nonceBytes)
 
+	// Code verifier PKCE : 43 caractères non réservés
+	verifierBytes := make([]byte, 32)
+	if _, err := rand.Read(verifierBytes); err != nil {
+		return "", nil, fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
 	// Créer l'état avec expiration (10 minutes)
 	now := time.Now()
 	oauthState := OAuthState{
-		Provider:  provider,
-		ClientID:  clientID,
-		Nonce:     nonce,
-		CreatedAt: now,
-		ExpiresAt: now.Add(10 * time.Minute),
-		Used:      false,
+		Provider:     provider,
+		ClientID:     clientID,
+		Nonce:        nonce,
+		CodeVerifier: base64.RawURLEncoding.EncodeToString(verifierBytes),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(OAuthStateTTL),
+		Used:         false,
 	}
 
 	// Stocker l'état
-	osm.mu.Lock()
-	osm.states[state] = oauthState
-	osm.mu.Unlock()
+	value, err := json.Marshal(oauthState)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := osm.store.Set(oauthStateKey(state), value, OAuthStateTTL); err != nil {
+		return "", nil, fmt.Errorf("failed to store OAuth state: %w", err)
+	}
 
-	return state, nonce, nil
+	return state, &oauthState, nil
 }
 
-// ValidateState valide un état OAuth reçu
-func (osm *OAuthStateManager) ValidateState(state, expectedProvider, expectedClientID string) (string, error) {
+// ValidateState valide un état OAuth reçu et le marque comme utilisé (de façon atomique,
+// un même state ne peut être validé qu'une fois même sur plusieurs instances)
+func (osm *OAuthStateManager) ValidateState(state, expectedProvider, expectedClientID string) (*OAuthState, error) {
 	// Validation de base
 	if state == "" {
-		return "", fmt.Errorf("missing OAuth state")
+		return nil, fmt.Errorf("missing OAuth state")
 	}
 
-	osm.mu.RLock()
-	oauthState, exists := osm.states[state]
-	osm.mu.RUnlock()
-
-	if !exists {
-		return "", fmt.Errorf("invalid or expired state")
-	}
+	var oauthState OAuthState
+	err := osm.store.Update(oauthStateKey(state), OAuthStateTTL, func(current []byte) ([]byte, error) {
+		if current == nil {
+			return nil, fmt.Errorf("invalid or expired state")
+		}
+		if err := json.Unmarshal(current, &oauthState); err != nil {
+			return nil, fmt.Errorf("invalid or expired state")
+		}
 
-	// Vérifier l'expiration
-	if time.Now().After(oauthState.ExpiresAt) {
-		osm.mu.Lock()
-		delete(osm.states, state)
-		osm.mu.Unlock()
-		return "", fmt.Errorf("state expired")
-	}
+		// Vérifier l'expiration
+		if time.Now().After(oauthState.ExpiresAt) {
+			return nil, fmt.Errorf("state expired")
+		}
 
-	// Vérifier si déjà utilisé (protection contre replay)
-	if oauthState.Used {
-		return "", fmt.Errorf("state already used")
-	}
+		// Vérifier si déjà utilisé (protection contre replay)
+		if oauthState.Used {
+			return nil, fmt.Errorf("state already used")
+		}
 
-	// Valider le provider
-	if oauthState.Provider != expectedProvider {
-		return "", fmt.Errorf("state provider mismatch")
-	}
+		// Valider le provider
+		if oauthState.Provider != expectedProvider {
+			return nil, fmt.Errorf("state provider mismatch")
+		}
 
-	// Valider le client ID
-	if oauthState.ClientID != expectedClientID {
-		return "", fmt.Errorf("state client ID mismatch")
-	}
+		// Valider le client ID
+		if oauthState.ClientID != expectedClientID {
+			return nil, fmt.Errorf("state client ID mismatch")
+		}
 
-	// Marquer comme utilisé
-	osm.mu.Lock()
-	if existingState, ok := osm.states[state]; ok {
-		existingState.Used = true
-		osm.states[state] = existingState
+		// Marquer comme utilisé (conservé jusqu'à expiration pour ValidateNonce)
+		oauthState.Used = true
+		return json.Marshal(oauthState)
+	})
+	if err != nil {
+		return nil, err
 	}
-	osm.mu.Unlock()
 
-	// Retourner le nonce pour validation supplémentaire
-	return oauthState.Nonce, nil
+	// Retourner l'état (nonce et code verifier) pour la suite du callback
+	return &oauthState, nil
 }
 
 // ValidateNonce valide un nonce OAuth
@@ -124,11 +138,12 @@ func (osm *OAuthStateManager) ValidateNonce(state, receivedNonce string) error {
 		return fmt.Errorf("missing nonce")
 	}
 
-	osm.mu.RLock()
-	oauthState, exists := osm.states[state]
-	osm.mu.RUnlock()
-
-	if !exists {
+	value, err := osm.store.Get(oauthStateKey(state))
+	if err != nil {
+		return fmt.Errorf("failed to read OAuth state: %w", err)
+	}
+	var oauthState OAuthState
+	if value == nil || json.Unmarshal(value, &oauthState) != nil {
 		return fmt.Errorf("invalid state for nonce validation")
 	}
 
@@ -139,37 +154,8 @@ func (osm *OAuthStateManager) ValidateNonce(state, receivedNonce string) error {
 	return nil
 }
 
-// CleanupExpiredStates nettoie les états expirés
-func (osm *OAuthStateManager) CleanupExpiredStates() {
-	now := time.Now()
-
-	osm.mu.Lock()
-	defer osm.mu.Unlock()
-
-	for state, oauthState := range osm.states {
-		if now.After(oauthState.ExpiresAt) || oauthState.Used {
-			delete(osm.states, state)
-		}
-	}
-}
-
-// GetStats retourne des statistiques sur les états gérés
-func (osm *OAuthStateManager) GetStats() (total, active, expired, used int) {
-	osm.mu.RLock()
-	defer osm.mu.RUnlock()
-
-	now := time.Now()
-	for _, oauthState := range osm.states {
-		total++
-		if oauthState.Used {
-			used++
-		} else if now.After(oauthState.ExpiresAt) {
-			expired++
-		} else {
-			active++
-		}
-	}
-	return
+func oauthStateKey(state string) string {
+	return "oauth_state:" + state
 }
 
 // SecureOAuthURL construit une URL OAuth sécurisée avec state et nonce
@@ -193,6 +179,12 @@ func SecureOAuthURL(baseURL string, params map[string]string, state, nonce strin
 	return u.String(), nil
 }
 
+// PKCEChallenge calcule le code_challenge S256 d'un code verifier (RFC 7636)
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 // ValidateOAuthCallbackURL valide une URL de callback OAuth
 func ValidateOAuthCallbackURL(callbackURL, expectedDomain string) error {
 	u, err := url.Parse(callbackURL)
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"airboard/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StateStore conserve l'état éphémère de sécurité (verrouillages de connexion, tokens CSRF,
+// états OAuth). L'implémentation Postgres le partage entre instances et survit aux redémarrages.
+type StateStore interface {
+	// Get retourne la valeur d'une clé non expirée (nil si absente)
+	Get(key string) ([]byte, error)
+	// Set enregistre une valeur pour la durée ttl
+	Set(key string, value []byte, ttl time.Duration) error
+	// Update lit et remplace une valeur de façon atomique. fn reçoit nil si la clé est absente
+	// ou expirée ; retourner nil supprime la clé, retourner une erreur annule la mise à jour.
+	Update(key string, ttl time.Duration, fn func(current []byte) ([]byte, error)) error
+	// Delete supprime une clé
+	Delete(key string) error
+	// CleanupExpired supprime les entrées expirées et retourne leur nombre
+	CleanupExpired() (int64, error)
+}
+
+// NewStateStore retourne le store configuré par STATE_STORE (postgres par défaut, memory pour une instance unique)
+func NewStateStore(backend string, db *gorm.DB) StateStore {
+	if backend == "memory" {
+		return NewMemoryStateStore()
+	}
+	return NewPostgresStateStore(db)
+}
+
+// RunStateStoreCleanup purge périodiquement les entrées expirées (à lancer en goroutine)
+func RunStateStoreCleanup(store StateStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		count, err := store.CleanupExpired()
+		if err != nil {
+			log.Printf("[Security] Erreur lors de la purge de l'état de sécurité: %v", err)
+		} else if count > 0 {
+			log.Printf("[Security] %d entrée(s) d'état expirée(s) supprimée(s)", count)
+		}
+	}
+}
+
+// MemoryStateStore garde l'état en mémoire du processus (perdu au redémarrage, non partagé)
+type MemoryStateStore struct {
+	entries map[string]memoryStateEntry
+	mu      sync.Mutex
+}
+
+type memoryStateEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryStateStore crée un store en mémoire
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		entries: make(map[string]memoryStateEntry),
+	}
+}
+
+func (s *MemoryStateStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current(key), nil
+}
+
+func (s *MemoryStateStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryStateEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStateStore) Update(key string, ttl time.Duration, fn func(current []byte) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, err := fn(s.current(key))
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		delete(s.entries, key)
+		return nil
+	}
+	s.entries[key] = memoryStateEntry{value: next, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStateStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStateStore) CleanupExpired() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	now := time.Now()
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// current retourne la valeur non expirée d'une clé (verrou déjà pris)
+func (s *MemoryStateStore) current(key string) []byte {
+	entry, exists := s.entries[key]
+	if !exists || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	return entry.value
+}
+
+// PostgresStateStore partage l'état entre instances via la table state_entries
+type PostgresStateStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStateStore crée un store adossé à la base de données
+func NewPostgresStateStore(db *gorm.DB) *PostgresStateStore {
+	return &PostgresStateStore{db: db}
+}
+
+func (s *PostgresStateStore) Get(key string) ([]byte, error) {
+	var entry models.StateEntry
+	err := s.db.Where("key = ? AND expires_at > ?", key, time.Now()).Limit(1).Find(&entry).Error
+	if err != nil || entry.Key == "" {
+		return nil, err
+	}
+	return entry.Value, nil
+}
+
+func (s *PostgresStateStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at"}),
+	}).Create(&models.StateEntry{Key: key, Value: value, ExpiresAt: time.Now().Add(ttl)}).Error
+}
+
+func (s *PostgresStateStore) Update(key string, ttl time.Duration, fn func(current []byte) ([]byte, error)) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Ligne déjà expirée insérée si besoin, pour pouvoir la verrouiller même lors de la première écriture
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.StateEntry{Key: key, Value: []byte{}, ExpiresAt: time.Now()}).Error; err != nil {
+			return err
+		}
+
+		var entry models.StateEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&entry).Error; err != nil {
+			return err
+		}
+
+		var current []byte
+		if entry.ExpiresAt.After(time.Now()) {
+			current = entry.Value
+		}
+		next, err := fn(current)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return tx.Where("key = ?", key).Delete(&models.StateEntry{}).Error
+		}
+		return tx.Model(&models.StateEntry{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"value":      next,
+			"expires_at": time.Now().Add(ttl),
+		}).Error
+	})
+}
+
+func (s *PostgresStateStore) Delete(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.StateEntry{}).Error
+}
+
+func (s *PostgresStateStore) CleanupExpired() (int64, error) {
+	result := s.db.Where("expires_at <= ?", time.Now()).Delete(&models.StateEntry{})
+	return result.RowsAffected, result.Error
+}
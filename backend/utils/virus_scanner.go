@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Statuts d'analyse antivirus
+const (
+	ScanStatusClean    = "clean"    // Aucune menace détectée
+	ScanStatusInfected = "infected" // Menace détectée (fichier mis en quarantaine)
+	ScanStatusError    = "error"    // Analyse impossible (scanner indisponible, limite dépassée...)
+	ScanStatusSkipped  = "skipped"  // Aucun scanner configuré
+)
+
+// ScanResult résultat de l'analyse d'un fichier
+type ScanResult struct {
+	Status    string
+	Signature string // Nom de la menace détectée (ex: Win.Test.EICAR_HDB-1)
+	ScannedAt time.Time
+}
+
+// Scanner analyse le contenu d'un fichier à la recherche de logiciels malveillants.
+// Une erreur signifie que l'analyse n'a pas pu aboutir (et non que le fichier est infecté).
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*ScanResult, error)
+}
+
+// ClamdScanner envoie les fichiers à clamd via la commande INSTREAM
+type ClamdScanner struct {
+	network   string // tcp ou unix
+	address   string
+	timeout   time.Duration
+	chunkSize int
+}
+
+// NewClamdScanner crée un scanner clamd. L'adresse peut être "tcp://host:3310",
+// "unix:///var/run/clamav/clamd.ctl" ou simplement "host:3310".
+func NewClamdScanner(address string, timeout time.Duration) (*ClamdScanner, error) {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	}
+	if address == "" {
+		return nil, errors.New("adresse clamd vide")
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &ClamdScanner{
+		network:   network,
+		address:   address,
+		timeout:   timeout,
+		chunkSize: 64 * 1024,
+	}, nil
+}
+
+// Ping vérifie que clamd répond (PONG)
+func (s *ClamdScanner) Ping(ctx context.Context) error {
+	reply, err := s.command(ctx, "zPING\x00", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("réponse clamd inattendue: %q", reply)
+	}
+	return nil
+}
+
+// Scan envoie le contenu en flux (INSTREAM) et interprète la réponse de clamd
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	reply, err := s.command(ctx, "zINSTREAM\x00", r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Réponses possibles : "stream: OK", "stream: <signature> FOUND", "<message> ERROR"
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &ScanResult{Status: ScanStatusClean, ScannedAt: time.Now()}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &ScanResult{
+			Status:    ScanStatusInfected,
+			Signature: strings.TrimSuffix(reply, " FOUND"),
+			ScannedAt: time.Now(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("erreur clamd: %s", reply)
+	}
+}
+
+// command ouvre une connexion, envoie la commande (et le flux éventuel) puis lit la réponse
+func (s *ClamdScanner) command(ctx context.Context, cmd string, body io.Reader) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return "", fmt.Errorf("connexion à clamd impossible: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, cmd); err != nil {
+		return "", fmt.Errorf("envoi de la commande clamd: %w", err)
+	}
+
+	if body != nil {
+		if err := s.stream(conn, body); err != nil {
+			return "", err
+		}
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return "", fmt.Errorf("lecture de la réponse clamd: %w", err)
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// stream découpe le contenu en blocs préfixés par leur taille (uint32 big-endian),
+// terminés par un bloc de taille zéro
+func (s *ClamdScanner) stream(conn net.Conn, body io.Reader) error {
+	buf := make([]byte, s.chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				// clamd coupe la connexion quand StreamMaxLength est dépassé :
+				// la raison est dans la réponse, qui sera lue par l'appelant
+				return nil
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("lecture du fichier à analyser: %w", readErr)
+		}
+	}
+
+	// Même remarque : une erreur d'écriture ici est expliquée par la réponse de clamd
+	conn.Write(bytes.Repeat([]byte{0}, 4))
+	return nil
+}
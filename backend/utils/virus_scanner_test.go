@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd écoute sur un port local et répond à zINSTREAM avec reply(contenu reçu)
+func fakeClamd(t *testing.T, reply func(content string) string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn, reply)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveClamd(conn net.Conn, reply func(content string) string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	cmd, err := reader.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch strings.TrimRight(cmd, "\x00") {
+	case "zPING":
+		io.WriteString(conn, "PONG\x00")
+	case "zINSTREAM":
+		var content strings.Builder
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(reader, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, reader, int64(n)); err != nil {
+				return
+			}
+		}
+		io.WriteString(conn, reply(content.String())+"\x00")
+	}
+}
+
+func TestClamdScannerScan(t *testing.T) {
+	addr := fakeClamd(t, func(content string) string {
+		switch {
+		case strings.Contains(content, "EICAR"):
+			return "stream: Eicar-Signature FOUND"
+		case strings.Contains(content, "huge"):
+			return "INSTREAM size limit exceeded. ERROR"
+		}
+		return "stream: OK"
+	})
+
+	scanner, err := NewClamdScanner("tcp://"+addr, time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	scanner.chunkSize = 4 // plusieurs blocs INSTREAM
+
+	tests := []struct {
+		name      string
+		content   string
+		status    string
+		signature string
+		wantErr   bool
+	}{
+		{name: "clean", content: "hello world", status: ScanStatusClean},
+		{name: "infected", content: "X5O!P%@AP-EICAR-TEST", status: ScanStatusInfected, signature: "Eicar-Signature"},
+		{name: "error", content: "huge file", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), strings.NewReader(tt.content))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Scan() = %+v, want error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan() error: %v", err)
+			}
+			if result.Status != tt.status || result.Signature != tt.signature {
+				t.Errorf("Scan() = %q/%q, want %q/%q", result.Status, result.Signature, tt.status, tt.signature)
+			}
+		})
+	}
+}
+
+func TestClamdScannerPing(t *testing.T) {
+	addr := fakeClamd(t, func(string) string { return "stream: OK" })
+	scanner, err := NewClamdScanner(addr, time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	if err := scanner.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error: %v", err)
+	}
+}
+
+func TestClamdScannerTimeout(t *testing.T) {
+	// clamd accepte la connexion mais ne répond jamais
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	scanner, err := NewClamdScanner(listener.Addr().String(), 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	start := time.Now()
+	if result, err := scanner.Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Fatalf("Scan() = %+v, want timeout error", result)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Scan() took %v, want about 100ms", elapsed)
+	}
+}
+
+func TestClamdScannerUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	scanner, err := NewClamdScanner(addr, time.Second)
+	if err != nil {
+		t.Fatalf("NewClamdScanner: %v", err)
+	}
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Fatal("Scan() succeeded, want connection error")
+	}
+}
+
+func TestNewClamdScannerAddress(t *testing.T) {
+	tests := []struct {
+		address, network, want string
+	}{
+		{"tcp://clamav:3310", "tcp", "clamav:3310"},
+		{"clamav:3310", "tcp", "clamav:3310"},
+		{"unix:///var/run/clamav/clamd.ctl", "unix", "/var/run/clamav/clamd.ctl"},
+		{"/var/run/clamav/clamd.ctl", "unix", "/var/run/clamav/clamd.ctl"},
+	}
+	for _, tt := range tests {
+		scanner, err := NewClamdScanner(tt.address, 0)
+		if err != nil {
+			t.Fatalf("NewClamdScanner(%q): %v", tt.address, err)
+		}
+		if scanner.network != tt.network || scanner.address != tt.want {
+			t.Errorf("NewClamdScanner(%q) = %s %s, want %s %s", tt.address, scanner.network, scanner.address, tt.network, tt.want)
+		}
+	}
+	if _, err := NewClamdScanner("tcp://", 0); err == nil {
+		t.Error("NewClamdScanner(\"tcp://\") succeeded, want error")
+	}
+}
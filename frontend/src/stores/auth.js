@@ -116,7 +116,12 @@ export const useAuthStore = defineStore('auth', () => {
     try {
       isLoading.value = true
       const response = await authService.register(userData)
-      
+
+      // Compte en attente de confirmation de l'email : aucun token délivré
+      if (response.email_verification_required) {
+        return response
+      }
+
       // Stocker les données
       user.value = response.user
       token.value = response.token